@@ -3,12 +3,17 @@ package main
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/storage"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 	"golang.org/x/text/unicode/norm"
@@ -35,7 +40,7 @@ func normalizeInput(s string) string {
 	return string(filtered)
 }
 
-func buildAgentCommands(logger *zap.Logger) *cobra.Command {
+func buildAgentCommands(cfg *config.Config, logger *zap.Logger) *cobra.Command {
 	agentCmd := &cobra.Command{
 		Use:   "agent",
 		Short: "Agent 관리 명령어",
@@ -43,24 +48,53 @@ func buildAgentCommands(logger *zap.Logger) *cobra.Command {
 	}
 
 	// agent create
+	var createLabels []string
 	agentCreateCmd := &cobra.Command{
 		Use:   "create",
 		Short: "새로운 Agent 생성",
-		Long:  "대화형 입력을 통해 새로운 Agent를 생성합니다.",
+		Long:  "대화형 입력을 통해 새로운 Agent를 생성합니다. --label key=value로 레이블을 붙일 수 있습니다.",
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgentCreate(logger)
+			labels, err := storage.ParseLabels(createLabels)
+			if err != nil {
+				return fmt.Errorf("유효하지 않은 레이블: %w", err)
+			}
+			return runAgentCreate(cfg, logger, labels)
 		},
 	}
+	agentCreateCmd.Flags().StringArrayVar(&createLabels, "label", nil, "Agent 레이블 key=value (여러 번 지정 가능)")
 
 	// agent list
+	var (
+		agentListFlags listFlags
+		listModel      string
+		listPrefix     string
+	)
 	agentListCmd := &cobra.Command{
 		Use:   "list",
 		Short: "Agent 목록 조회",
-		Long:  "생성된 모든 Agent의 목록을 조회합니다.",
+		Long: `생성된 Agent 목록을 조회합니다.
+기본으로 생성 순 50개를 보여주며, 더 있으면 출력되는 --cursor 값으로 다음 페이지를 조회합니다.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgentList(logger)
+			after, before, err := agentListFlags.validate(time.Now())
+			if err != nil {
+				return err
+			}
+			return runAgentList(cfg, logger, storage.AgentFilter{
+				Statuses:      agentListFlags.statuses,
+				Model:         listModel,
+				NamePrefix:    listPrefix,
+				CreatedAfter:  after,
+				CreatedBefore: before,
+				Labels:        agentListFlags.labels,
+				Sort:          agentListFlags.sort,
+				Limit:         agentListFlags.limit,
+				Cursor:        agentListFlags.cursor,
+			})
 		},
 	}
+	agentListFlags.register(agentListCmd)
+	agentListCmd.Flags().StringVar(&listModel, "model", "", "모델로 필터링")
+	agentListCmd.Flags().StringVar(&listPrefix, "prefix", "", "이름 접두사로 필터링 (대소문자 무시)")
 
 	// agent view
 	agentViewCmd := &cobra.Command{
@@ -69,18 +103,45 @@ func buildAgentCommands(logger *zap.Logger) *cobra.Command {
 		Long:  "특정 Agent의 상세 정보를 조회합니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgentView(logger, args[0])
+			return runAgentView(cfg, logger, args[0])
 		},
 	}
 
 	// agent delete
+	var drain bool
 	agentDeleteCmd := &cobra.Command{
 		Use:   "delete <agent-name>",
 		Short: "Agent 삭제",
-		Long:  "특정 Agent를 삭제합니다.",
+		Long: `특정 Agent를 삭제합니다. 삭제된 Agent는 목록에서 숨겨지고 새 Task를 만들거나 실행할 수 없습니다.
+대기 중이거나 실행 중인 Task는 취소합니다. --drain을 지정하면 취소하지 않고 실행 중인 Task가 끝나도록 둡니다.
+'cnap agent restore'로 되돌리거나 'cnap agent purge'로 영구 삭제할 수 있습니다.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgentDelete(cfg, logger, args[0], drain)
+		},
+	}
+	agentDeleteCmd.Flags().BoolVar(&drain, "drain", false, "대기 중·실행 중인 Task를 취소하지 않음")
+
+	// agent restore
+	agentRestoreCmd := &cobra.Command{
+		Use:   "restore <agent-name>",
+		Short: "삭제된 Agent 복구",
+		Long:  "삭제된 Agent를 다시 활성화합니다. 삭제할 때 취소된 Task는 복구되지 않습니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgentDelete(logger, args[0])
+			return runAgentRestore(cfg, logger, args[0])
+		},
+	}
+
+	// agent purge
+	agentPurgeCmd := &cobra.Command{
+		Use:   "purge <agent-name>",
+		Short: "삭제된 Agent 영구 삭제",
+		Long: `삭제된 Agent를 Task, 메시지, 실행 단계, 체크포인트, 저장된 메시지 파일과 함께 영구 삭제합니다.
+되돌릴 수 없으며, 먼저 'cnap agent delete'로 삭제한 Agent만 영구 삭제할 수 있습니다. 감사 로그는 유지됩니다.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgentPurge(cfg, logger, args[0])
 		},
 	}
 
@@ -91,7 +152,52 @@ func buildAgentCommands(logger *zap.Logger) *cobra.Command {
 		Long:  "대화형 입력을 통해 특정 Agent의 정보를 수정합니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runAgentEdit(logger, args[0])
+			return runAgentEdit(cfg, logger, args[0])
+		},
+	}
+
+	// agent history
+	agentHistoryCmd := &cobra.Command{
+		Use:   "history <agent-name>",
+		Short: "Agent 리비전 이력 조회",
+		Long:  "Agent 설정이 변경될 때마다 기록된 리비전 목록을 조회합니다.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgentHistory(cfg, logger, args[0])
+		},
+	}
+
+	// agent diff
+	agentDiffCmd := &cobra.Command{
+		Use:   "diff <agent-name> <from-revision> <to-revision>",
+		Short: "Agent 리비전 비교",
+		Long:  "두 리비전의 설명, 모델, 프롬프트 차이를 출력합니다. 리비전은 r3 또는 3 형식으로 지정합니다.",
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			from, err := parseRevision(args[1])
+			if err != nil {
+				return err
+			}
+			to, err := parseRevision(args[2])
+			if err != nil {
+				return err
+			}
+			return runAgentDiff(cfg, logger, args[0], from, to)
+		},
+	}
+
+	// agent rollback
+	agentRollbackCmd := &cobra.Command{
+		Use:   "rollback <agent-name> <revision>",
+		Short: "Agent를 이전 리비전으로 되돌리기",
+		Long:  "지정한 리비전의 설정을 새 리비전으로 적용합니다. 기존 이력은 유지됩니다.",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			revision, err := parseRevision(args[1])
+			if err != nil {
+				return err
+			}
+			return runAgentRollback(cfg, logger, args[0], revision)
 		},
 	}
 
@@ -99,16 +205,24 @@ func buildAgentCommands(logger *zap.Logger) *cobra.Command {
 	agentCmd.AddCommand(agentListCmd)
 	agentCmd.AddCommand(agentViewCmd)
 	agentCmd.AddCommand(agentDeleteCmd)
+	agentCmd.AddCommand(agentRestoreCmd)
+	agentCmd.AddCommand(agentPurgeCmd)
 	agentCmd.AddCommand(agentEditCmd)
+	agentCmd.AddCommand(agentHistoryCmd)
+	agentCmd.AddCommand(agentDiffCmd)
+	agentCmd.AddCommand(agentRollbackCmd)
+	agentCmd.AddCommand(buildLabelCommand(cfg, logger, agentLabelTarget))
+	agentCmd.AddCommand(buildAgentExportCommand(cfg, logger))
+	agentCmd.AddCommand(buildAgentApplyCommand(cfg, logger))
 
 	return agentCmd
 }
 
-func runAgentCreate(logger *zap.Logger) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runAgentCreate(cfg *config.Config, logger *zap.Logger, labels map[string]string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -139,7 +253,7 @@ func runAgentCreate(logger *zap.Logger) error {
 	}
 
 	// Agent 생성
-	if err := ctrl.CreateAgent(ctx, name, description, model, prompt); err != nil {
+	if err := ctrl.CreateAgent(ctx, name, description, model, prompt, controller.WithLabels(labels)); err != nil {
 		return fmt.Errorf("agent 생성 실패: %w", err)
 	}
 
@@ -147,20 +261,21 @@ func runAgentCreate(logger *zap.Logger) error {
 	return nil
 }
 
-func runAgentList(logger *zap.Logger) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runAgentList(cfg *config.Config, logger *zap.Logger, filter storage.AgentFilter) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
 	defer cleanup()
 
-	agents, err := ctrl.ListAgentsWithInfo(ctx)
+	page, err := ctrl.ListAgentsPage(ctx, filter)
 	if err != nil {
 		return fmt.Errorf("agent 목록 조회 실패: %w", err)
 	}
+	agents := page.Agents
 
 	if len(agents) == 0 {
 		fmt.Println("등록된 Agent가 없습니다.")
@@ -169,32 +284,34 @@ func runAgentList(logger *zap.Logger) error {
 
 	// 테이블 형식 출력
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "NAME\tSTATUS\tMODEL\tDESCRIPTION\tCREATED")
-	_, _ = fmt.Fprintln(w, "----\t------\t-----\t-----------\t-------")
+	_, _ = fmt.Fprintln(w, "NAME\tSTATUS\tMODEL\tDESCRIPTION\tCREATED\tLABELS")
+	_, _ = fmt.Fprintln(w, "----\t------\t-----\t-----------\t-------\t------")
 
 	for _, agent := range agents {
 		desc := agent.Description
 		if len(desc) > 40 {
 			desc = desc[:37] + "..."
 		}
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
 			agent.Name,
 			agent.Status,
 			agent.Model,
 			desc,
 			agent.CreatedAt.Format("2006-01-02 15:04"),
+			storage.FormatLabels(agent.Labels),
 		)
 	}
 	_ = w.Flush()
 
+	printNextCursor(page.NextCursor)
 	return nil
 }
 
-func runAgentView(logger *zap.Logger, agentName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runAgentView(cfg *config.Config, logger *zap.Logger, agentName string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -210,7 +327,27 @@ func runAgentView(logger *zap.Logger, agentName string) error {
 	fmt.Printf("이름:        %s\n", agent.Name)
 	fmt.Printf("상태:        %s\n", agent.Status)
 	fmt.Printf("모델:        %s\n", agent.Model)
+	fmt.Printf("리비전:      r%d\n", agent.Revision)
+	fmt.Printf("버전:        %d\n", agent.Version)
 	fmt.Printf("설명:        %s\n", agent.Description)
+	if len(agent.Parameters) > 0 {
+		params, _ := json.Marshal(agent.Parameters)
+		fmt.Printf("파라미터:    %s\n", params)
+	}
+	if len(agent.Tools) > 0 {
+		fmt.Printf("도구:        %s\n", strings.Join(agent.Tools, ", "))
+	}
+	if len(agent.Labels) > 0 {
+		fmt.Printf("레이블:      %s\n", storage.FormatLabels(agent.Labels))
+	}
+	if tmpl, err := controller.ParsePromptTemplate(agent.Prompt); err == nil {
+		if required := tmpl.Required(); len(required) > 0 {
+			fmt.Printf("필수 변수:   %s\n", strings.Join(required, ", "))
+		}
+		if optional := tmpl.Optional(); len(optional) > 0 {
+			fmt.Printf("선택 변수:   %s\n", strings.Join(optional, ", "))
+		}
+	}
 	fmt.Printf("프롬프트:\n%s\n\n", agent.Prompt)
 	fmt.Printf("생성일:      %s\n", agent.CreatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Printf("수정일:      %s\n", agent.UpdatedAt.Format("2006-01-02 15:04:05"))
@@ -218,11 +355,11 @@ func runAgentView(logger *zap.Logger, agentName string) error {
 	return nil
 }
 
-func runAgentDelete(logger *zap.Logger, agentName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runAgentDelete(cfg *config.Config, logger *zap.Logger, agentName string, drain bool) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -239,19 +376,74 @@ func runAgentDelete(logger *zap.Logger, agentName string) error {
 		return nil
 	}
 
-	if err := ctrl.DeleteAgent(ctx, agentName); err != nil {
-		return fmt.Errorf("agent 삭제 실패: %w", err)
+	var opts []controller.DeleteOption
+	if drain {
+		opts = append(opts, controller.WithDrainRunningTasks())
+	}
+	canceled, err := ctrl.DeleteAgent(ctx, agentName, opts...)
+	if err != nil {
+		return fmt.Errorf("agent 삭제 실패: %w", conflictHint(err))
 	}
 
 	fmt.Printf("✓ Agent '%s' 삭제 완료\n", agentName)
+	if len(canceled) > 0 {
+		fmt.Printf("  취소된 Task %d개: %s\n", len(canceled), strings.Join(canceled, ", "))
+	}
+	return nil
+}
+
+func runAgentRestore(cfg *config.Config, logger *zap.Logger, agentName string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	if err := ctrl.RestoreAgent(ctx, agentName); err != nil {
+		return fmt.Errorf("agent 복구 실패: %w", conflictHint(err))
+	}
+
+	fmt.Printf("✓ Agent '%s' 복구 완료\n", agentName)
+	return nil
+}
+
+func runAgentPurge(cfg *config.Config, logger *zap.Logger, agentName string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 10*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	// 확인 메시지
+	fmt.Printf("Agent '%s'와(과) 모든 Task, 메시지를 영구 삭제합니다. 되돌릴 수 없습니다.\n", agentName)
+	fmt.Printf("계속하려면 Agent 이름을 입력하세요: ")
+	reader := bufio.NewReader(os.Stdin)
+	confirm, _ := reader.ReadString('\n')
+	if strings.TrimSpace(confirm) != agentName {
+		fmt.Println("취소되었습니다.")
+		return nil
+	}
+
+	removed, err := ctrl.PurgeAgent(ctx, agentName)
+	if err != nil {
+		return fmt.Errorf("agent 영구 삭제 실패: %w", err)
+	}
+
+	fmt.Printf("✓ Agent '%s' 영구 삭제 완료 (메시지 파일 %d개 삭제)\n", agentName, removed)
 	return nil
 }
 
-func runAgentEdit(logger *zap.Logger, agentName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runAgentEdit(cfg *config.Config, logger *zap.Logger, agentName string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -287,11 +479,119 @@ func runAgentEdit(logger *zap.Logger, agentName string) error {
 		prompt = agent.Prompt
 	}
 
-	// Agent 수정
-	if err := ctrl.UpdateAgent(ctx, agentName, description, model, prompt); err != nil {
-		return fmt.Errorf("agent 수정 실패: %w", err)
+	// Agent 수정 (입력하는 동안 다른 수정이 반영되었으면 덮어쓰지 않음)
+	if err := ctrl.UpdateAgent(ctx, agentName, description, model, prompt, controller.WithExpectedVersion(agent.Version)); err != nil {
+		return fmt.Errorf("agent 수정 실패: %w", conflictHint(err))
 	}
 
 	fmt.Printf("✓ Agent '%s' 수정 완료\n", agentName)
 	return nil
 }
+
+// parseRevision은 "r3" 또는 "3" 형식의 리비전 번호를 해석합니다.
+func parseRevision(s string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(s), "r"))
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("유효하지 않은 리비전: %q (예: r3)", s)
+	}
+	return n, nil
+}
+
+func runAgentHistory(cfg *config.Config, logger *zap.Logger, agentName string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	revisions, err := ctrl.ListAgentRevisions(ctx, agentName)
+	if err != nil {
+		return fmt.Errorf("리비전 이력 조회 실패: %w", err)
+	}
+
+	if len(revisions) == 0 {
+		fmt.Printf("Agent '%s'의 리비전 이력이 없습니다.\n", agentName)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "REVISION\tAUTHOR\tMODEL\tPROMPT\tCREATED")
+	_, _ = fmt.Fprintln(w, "--------\t------\t-----\t------\t-------")
+
+	// 최신 리비전부터 출력
+	for i := len(revisions) - 1; i >= 0; i-- {
+		rev := revisions[i]
+		author := rev.Author
+		if author == "" {
+			author = "-"
+		}
+		_, _ = fmt.Fprintf(w, "r%d\t%s\t%s\t%s\t%s\n",
+			rev.Revision,
+			author,
+			rev.Model,
+			truncateString(strings.Join(strings.Fields(rev.Prompt), " "), 40),
+			rev.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+		)
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+func runAgentDiff(cfg *config.Config, logger *zap.Logger, agentName string, from, to int) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	diff, err := ctrl.DiffAgentRevisions(ctx, agentName, from, to)
+	if err != nil {
+		return fmt.Errorf("리비전 비교 실패: %w", err)
+	}
+
+	fmt.Printf("--- %s r%d (%s, %s)\n", agentName, diff.From.Revision, diff.From.Author, diff.From.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	fmt.Printf("+++ %s r%d (%s, %s)\n", agentName, diff.To.Revision, diff.To.Author, diff.To.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+
+	if len(diff.Fields) == 0 {
+		fmt.Println("차이가 없습니다.")
+		return nil
+	}
+	printFieldDiffs(diff.Fields)
+	return nil
+}
+
+// printFieldDiffs는 필드별 줄 단위 diff를 unified diff와 비슷한 형식으로 출력합니다.
+func printFieldDiffs(fields []controller.FieldDiff) {
+	for _, field := range fields {
+		fmt.Printf("@@ %s @@\n", field.Field)
+		for _, line := range field.Lines {
+			fmt.Printf("%s%s\n", line.Op, line.Text)
+		}
+	}
+}
+
+func runAgentRollback(cfg *config.Config, logger *zap.Logger, agentName string, revision int) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	newRevision, err := ctrl.RollbackAgent(ctx, agentName, revision)
+	if err != nil {
+		return fmt.Errorf("agent 되돌리기 실패: %w", conflictHint(err))
+	}
+
+	fmt.Printf("✓ Agent '%s'을(를) r%d의 설정으로 되돌렸습니다 (현재 리비전: r%d)\n", agentName, revision, newRevision)
+	return nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/manifest"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func buildAgentExportCommand(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	var (
+		all    bool
+		output string
+		dir    string
+	)
+	cmd := &cobra.Command{
+		Use:   "export [agent-name...]",
+		Short: "Agent 설정을 매니페스트로 내보내기",
+		Long: `Agent 설정(이름, 설명, 모델, 프롬프트, 파라미터, 도구)을 YAML 또는 JSON 매니페스트로 출력합니다.
+--dir을 지정하면 Agent마다 <이름>.yaml(.json) 파일을 만들며, 'cnap agent apply -f'로 다시 적용할 수 있습니다.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if all == (len(args) > 0) {
+				return fmt.Errorf("agent 이름 또는 --all 중 하나를 지정하세요")
+			}
+			if output != manifest.FormatYAML && output != manifest.FormatJSON {
+				return fmt.Errorf("지원하지 않는 출력 형식: %s (yaml 또는 json)", output)
+			}
+			return runAgentExport(cfg, logger, args, output, dir)
+		},
+	}
+	cmd.Flags().BoolVar(&all, "all", false, "삭제되지 않은 모든 Agent 내보내기")
+	cmd.Flags().StringVarP(&output, "output", "o", manifest.FormatYAML, "출력 형식 (yaml, json)")
+	cmd.Flags().StringVar(&dir, "dir", "", "Agent별 매니페스트 파일을 쓸 디렉터리 (기본: 표준 출력)")
+	return cmd
+}
+
+func buildAgentApplyCommand(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	var (
+		path   string
+		dryRun bool
+		prune  bool
+	)
+	cmd := &cobra.Command{
+		Use:   "apply -f <file|dir>",
+		Short: "매니페스트대로 Agent 생성/수정/삭제",
+		Long: `매니페스트 파일 또는 디렉터리(.yaml, .yml, .json)를 읽어 데이터베이스의 Agent를 같은 상태로 맞춥니다.
+없는 Agent는 생성하고 다른 Agent는 수정합니다. --prune을 지정하면 매니페스트에 없는 Agent를 삭제합니다.
+이미 같은 상태이면 아무것도 바꾸지 않으므로 여러 번 실행해도 안전합니다.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAgentApply(cfg, logger, path, dryRun, prune)
+		},
+	}
+	cmd.Flags().StringVarP(&path, "filename", "f", "", "매니페스트 파일 또는 디렉터리")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "변경하지 않고 적용 계획과 diff만 출력")
+	cmd.Flags().BoolVar(&prune, "prune", false, "매니페스트에 없는 Agent 삭제")
+	_ = cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+func runAgentExport(cfg *config.Config, logger *zap.Logger, names []string, format, dir string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	agents, err := ctrl.ExportAgentManifests(ctx, names...)
+	if err != nil {
+		return fmt.Errorf("agent 내보내기 실패: %w", err)
+	}
+
+	if dir == "" {
+		return manifest.Encode(os.Stdout, agents, format)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("디렉터리 생성 실패: %w", err)
+	}
+	for _, agent := range agents {
+		if strings.ContainsAny(agent.Name, `/\`) || strings.HasPrefix(agent.Name, ".") {
+			return fmt.Errorf("파일 이름으로 쓸 수 없는 agent 이름: %s", agent.Name)
+		}
+		path := filepath.Join(dir, agent.Name+"."+format)
+		if err := writeManifestFile(path, agent, format); err != nil {
+			return fmt.Errorf("매니페스트 저장 실패: %w", err)
+		}
+		fmt.Printf("✓ %s\n", path)
+	}
+	return nil
+}
+
+func writeManifestFile(path string, agent manifest.Agent, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := manifest.Encode(f, []manifest.Agent{agent}, format); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func runAgentApply(cfg *config.Config, logger *zap.Logger, path string, dryRun, prune bool) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 5*time.Minute)
+	defer cancel()
+
+	agents, err := manifest.Load(path)
+	if err != nil {
+		return fmt.Errorf("매니페스트 읽기 실패: %w", err)
+	}
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	changes, err := ctrl.ApplyAgentManifests(ctx, agents, controller.ApplyOptions{Prune: prune, DryRun: dryRun})
+	if err != nil && changes == nil {
+		return fmt.Errorf("매니페스트 적용 실패: %w", err)
+	}
+
+	counts := map[controller.ApplyAction]int{}
+	for _, change := range changes {
+		counts[change.Action]++
+		fmt.Printf("%s %s %s\n", applySymbol(change.Action), change.Action, change.Name)
+		if dryRun {
+			printFieldDiffs(change.Fields)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("매니페스트 적용 실패: %w", conflictHint(err))
+	}
+
+	summary := fmt.Sprintf("생성 %d, 수정 %d, 삭제 %d, 변경 없음 %d",
+		counts[controller.ApplyCreate], counts[controller.ApplyUpdate],
+		counts[controller.ApplyDelete], counts[controller.ApplyUnchanged])
+	if dryRun {
+		fmt.Printf("\n(dry run) %s — 변경 사항은 적용되지 않았습니다.\n", summary)
+		return nil
+	}
+	fmt.Printf("\n✓ 적용 완료: %s\n", summary)
+	return nil
+}
+
+func applySymbol(action controller.ApplyAction) string {
+	switch action {
+	case controller.ApplyCreate:
+		return "+"
+	case controller.ApplyUpdate:
+		return "~"
+	case controller.ApplyDelete:
+		return "-"
+	default:
+		return "="
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func buildAuditCommands(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	auditCmd := &cobra.Command{
+		Use:   "audit",
+		Short: "감사 로그 명령어",
+		Long:  "Agent, Task에 대한 모든 변경 이력(감사 로그)을 조회합니다.",
+	}
+
+	// audit list
+	var (
+		agentID string
+		actor   string
+		action  string
+		since   string
+		limit   int
+		jsonOut bool
+	)
+	auditListCmd := &cobra.Command{
+		Use:   "list",
+		Short: "감사 로그 조회",
+		Long:  "조건에 맞는 감사 로그를 조회합니다. --json 옵션으로 컴플라이언스용 JSON을 출력할 수 있습니다.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := storage.AuditFilter{
+				AgentID: agentID,
+				Actor:   actor,
+				Action:  action,
+				Limit:   limit,
+			}
+			if since != "" {
+				t, err := parseSince(since, time.Now())
+				if err != nil {
+					return err
+				}
+				filter.Since = t
+			}
+			return runAuditList(cfg, logger, filter, jsonOut)
+		},
+	}
+	auditListCmd.Flags().StringVar(&agentID, "agent", "", "Agent 이름으로 필터링")
+	auditListCmd.Flags().StringVar(&actor, "actor", "", "actor로 필터링 (예: discord:1234, cli:alice)")
+	auditListCmd.Flags().StringVar(&action, "action", "", "action으로 필터링 (예: agent.update, task.cancel)")
+	auditListCmd.Flags().StringVar(&since, "since", "", "조회 시작 시점 (예: 7d, 12h, 2024-01-01)")
+	auditListCmd.Flags().IntVar(&limit, "limit", 0, "최근 N개만 조회 (0이면 전체)")
+	auditListCmd.Flags().BoolVar(&jsonOut, "json", false, "JSON 형식으로 출력")
+
+	auditCmd.AddCommand(auditListCmd)
+
+	return auditCmd
+}
+
+// auditEventJSON은 감사 로그의 JSON 내보내기 형식입니다.
+type auditEventJSON struct {
+	ID         int64                `json:"id"`
+	Timestamp  time.Time            `json:"timestamp"`
+	Actor      string               `json:"actor"`
+	Action     string               `json:"action"`
+	TargetType string               `json:"target_type"`
+	TargetID   string               `json:"target_id"`
+	AgentID    string               `json:"agent_id,omitempty"`
+	Before     json.RawMessage      `json:"before,omitempty"`
+	After      json.RawMessage      `json:"after,omitempty"`
+	Changes    map[string][2]string `json:"changes,omitempty"`
+}
+
+func runAuditList(cfg *config.Config, logger *zap.Logger, filter storage.AuditFilter, jsonOut bool) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	events, err := ctrl.ListAuditEvents(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("감사 로그 조회 실패: %w", err)
+	}
+
+	if jsonOut {
+		out := make([]auditEventJSON, 0, len(events))
+		for _, ev := range events {
+			changes, err := controller.AuditDiff(ev)
+			if err != nil {
+				return fmt.Errorf("감사 로그 %d 해석 실패: %w", ev.ID, err)
+			}
+			item := auditEventJSON{
+				ID:         ev.ID,
+				Timestamp:  ev.CreatedAt.UTC(),
+				Actor:      ev.Actor,
+				Action:     ev.Action,
+				TargetType: ev.TargetType,
+				TargetID:   ev.TargetID,
+				AgentID:    ev.AgentID,
+				Changes:    changes,
+			}
+			if ev.BeforeState != "" {
+				item.Before = json.RawMessage(ev.BeforeState)
+			}
+			if ev.AfterState != "" {
+				item.After = json.RawMessage(ev.AfterState)
+			}
+			out = append(out, item)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	if len(events) == 0 {
+		fmt.Println("조건에 맞는 감사 로그가 없습니다.")
+		return nil
+	}
+
+	// 테이블 형식 출력
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TIME\tACTOR\tACTION\tTARGET\tCHANGES")
+	_, _ = fmt.Fprintln(w, "----\t-----\t------\t------\t-------")
+
+	for _, ev := range events {
+		changes, err := controller.AuditDiff(ev)
+		if err != nil {
+			return fmt.Errorf("감사 로그 %d 해석 실패: %w", ev.ID, err)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s/%s\t%s\n",
+			ev.CreatedAt.Local().Format("2006-01-02 15:04:05"),
+			ev.Actor,
+			ev.Action,
+			ev.TargetType,
+			ev.TargetID,
+			formatAuditChanges(changes),
+		)
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+// formatAuditChanges는 변경된 필드를 "field: before → after" 형식으로 요약합니다.
+func formatAuditChanges(changes map[string][2]string) string {
+	if len(changes) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(changes))
+	for key := range changes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %s → %s",
+			key,
+			truncateString(changes[key][0], 20),
+			truncateString(changes[key][1], 20),
+		))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseSince는 "7d", "12h", "30m" 같은 상대 기간이나 날짜/RFC3339 시각을 절대 시각으로 변환합니다.
+func parseSince(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	for _, suffix := range []struct {
+		unit string
+		dur  time.Duration
+	}{
+		{"d", 24 * time.Hour},
+		{"w", 7 * 24 * time.Hour},
+	} {
+		if strings.HasSuffix(value, suffix.unit) {
+			n, err := strconv.Atoi(strings.TrimSuffix(value, suffix.unit))
+			if err != nil || n < 0 {
+				return time.Time{}, fmt.Errorf("유효하지 않은 기간: %s", value)
+			}
+			return now.Add(-time.Duration(n) * suffix.dur), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("유효하지 않은 기간: %s (예: 7d, 12h, 2024-01-01)", value)
+}
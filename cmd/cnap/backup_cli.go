@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cnap-oss/app/internal/backup"
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+func buildBackupCommands(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	backupCmd := &cobra.Command{
+		Use:   "backup",
+		Short: "데이터베이스와 메시지 저장소 백업/복원",
+		Long: `데이터베이스의 CNAP 테이블과 메시지 본문을 하나의 tar.gz 아카이브로 백업하고 복원합니다.
+아카이브에는 파일별 SHA-256 체크섬과 스키마 버전을 담은 manifest.json이 포함됩니다.`,
+	}
+
+	// backup create
+	backupCreateCmd := &cobra.Command{
+		Use:   "create <file>",
+		Short: "백업 아카이브 생성",
+		Long: `실행 중인 서버를 멈추지 않고 일관된 백업을 만듭니다.
+SQLite는 온라인 백업(VACUUM INTO) 스냅샷에서, PostgreSQL은 REPEATABLE READ 트랜잭션에서 테이블을 읽습니다.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBackupCreate(cfg, logger, args[0])
+		},
+	}
+
+	// backup restore
+	var force bool
+	backupRestoreCmd := &cobra.Command{
+		Use:   "restore <file>",
+		Short: "백업 아카이브 복원",
+		Long: `체크섬과 스키마 버전을 확인한 뒤 아카이브를 현재 설정의 데이터베이스와 메시지 저장소에 복원합니다.
+대상 데이터베이스는 'cnap db migrate up'으로 같은 스키마 버전까지 마이그레이션되어 있어야 하고,
+--force 없이는 비어 있어야 합니다. 복원하는 동안 서버를 멈춰 두세요.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runBackupRestore(cfg, logger, args[0], force)
+		},
+	}
+	backupRestoreCmd.Flags().BoolVar(&force, "force", false, "기존 데이터를 모두 지우고 복원")
+
+	// backup verify
+	backupVerifyCmd := &cobra.Command{
+		Use:   "verify <file>",
+		Short: "백업 아카이브의 체크섬 확인",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			manifest, err := backup.Verify(args[0])
+			if err != nil {
+				return fmt.Errorf("백업 검증 실패: %w", err)
+			}
+			printBackupManifest(manifest)
+			fmt.Println("✓ 모든 파일의 체크섬이 일치합니다.")
+			return nil
+		},
+	}
+
+	backupCmd.AddCommand(backupCreateCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupVerifyCmd)
+	return backupCmd
+}
+
+func runBackupCreate(cfg *config.Config, logger *zap.Logger, file string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	db, cleanup, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("데이터베이스 연결 실패: %w", err)
+	}
+	defer cleanup()
+
+	messages, err := cfg.OpenMessageStore()
+	if err != nil {
+		return fmt.Errorf("메시지 저장소 초기화 실패: %w", err)
+	}
+
+	// 중간에 실패해도 불완전한 아카이브가 남지 않도록 임시 파일에 쓴 뒤 이름을 바꿉니다.
+	tmp, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("백업 파일 생성 실패: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	manifest, err := backup.Create(ctx, db, messages, tmp, backup.CreateOptions{AppVersion: Version})
+	if err != nil {
+		tmp.Close()
+		if errors.Is(err, storage.ErrSchemaVersionMismatch) {
+			return fmt.Errorf("백업 생성 실패: %w ('cnap db migrate up'으로 스키마를 갱신하세요)", err)
+		}
+		return fmt.Errorf("백업 생성 실패: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("백업 파일 쓰기 실패: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("백업 파일 쓰기 실패: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return fmt.Errorf("백업 파일 쓰기 실패: %w", err)
+	}
+
+	printBackupManifest(manifest)
+	if n := len(manifest.MissingMessages); n > 0 {
+		fmt.Printf("⚠ 메시지 저장소에 본문이 없는 메시지 %d개는 제외했습니다.\n", n)
+	}
+	fmt.Printf("✓ 백업을 '%s'에 저장했습니다.\n", file)
+	return nil
+}
+
+func runBackupRestore(cfg *config.Config, logger *zap.Logger, file string, force bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Hour)
+	defer cancel()
+
+	db, cleanup, err := openDatabase(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("데이터베이스 연결 실패: %w", err)
+	}
+	defer cleanup()
+
+	messages, err := cfg.OpenMessageStore()
+	if err != nil {
+		return fmt.Errorf("메시지 저장소 초기화 실패: %w", err)
+	}
+
+	manifest, err := backup.Restore(ctx, db, messages, file, backup.RestoreOptions{Force: force})
+	if err != nil {
+		switch {
+		case errors.Is(err, backup.ErrNotEmpty):
+			return fmt.Errorf("백업 복원 실패: %w (기존 데이터를 지우고 복원하려면 --force를 사용하세요)", err)
+		case errors.Is(err, storage.ErrSchemaVersionMismatch):
+			return fmt.Errorf("백업 복원 실패: %w (백업과 같은 버전의 cnap으로 복원하거나 'cnap db migrate up'으로 스키마를 맞추세요)", err)
+		}
+		return fmt.Errorf("백업 복원 실패: %w", err)
+	}
+
+	printBackupManifest(manifest)
+	fmt.Printf("✓ '%s'을(를) 복원했습니다.\n", file)
+	return nil
+}
+
+// printBackupManifest는 아카이브 요약을 출력합니다.
+func printBackupManifest(m *backup.Manifest) {
+	fmt.Printf("생성 시각: %s\n", m.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if m.AppVersion != "" {
+		fmt.Printf("cnap 버전: %s\n", m.AppVersion)
+	}
+	fmt.Printf("데이터베이스: %s (스키마 버전 %d)\n", m.Dialect, m.SchemaVersion)
+	for _, t := range m.Tables {
+		fmt.Printf("  %s: %d행\n", t.Table, t.Rows)
+	}
+	var size int64
+	for _, f := range m.Messages {
+		size += f.Size
+	}
+	fmt.Printf("메시지 파일: %d개 (%s)\n", len(m.Messages), formatBytes(size))
+}
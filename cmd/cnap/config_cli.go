@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// buildConfigCommands는 config 명령어 그룹을 생성합니다.
+func buildConfigCommands(cfg *config.Config) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the effective configuration",
+		Long: `Inspect the configuration after merging, in order of precedence:
+defaults < config file (--config or $CNAP_CONFIG) < environment variables < flags.`,
+	}
+
+	printCmd := &cobra.Command{
+		Use:   "print",
+		Short: "Print the effective configuration with secrets masked",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			out, err := cfg.Masked().YAML()
+			if err != nil {
+				return fmt.Errorf("설정 출력 실패: %w", err)
+			}
+			fmt.Print(string(out))
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(printCmd)
+	return configCmd
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func buildDBCommands(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	dbCmd := &cobra.Command{
+		Use:   "db",
+		Short: "데이터베이스 관리 명령어",
+		Long:  "데이터베이스 스키마 마이그레이션 등 저장소 관리 작업을 수행합니다.",
+	}
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "스키마 마이그레이션",
+		Long: `바이너리에 내장된 번호별 마이그레이션을 적용하거나 되돌립니다.
+적용 이력은 schema_migrations 테이블에 기록되며, 동시에 한 인스턴스만 마이그레이션할 수 있습니다.`,
+	}
+
+	// db migrate up
+	migrateUpCmd := &cobra.Command{
+		Use:   "up",
+		Short: "적용되지 않은 마이그레이션을 모두 적용",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrateUp(cfg, logger)
+		},
+	}
+
+	// db migrate down
+	var steps int
+	migrateDownCmd := &cobra.Command{
+		Use:   "down",
+		Short: "최근 마이그레이션 되돌리기",
+		Long:  "최근에 적용된 마이그레이션부터 --steps 개를 되돌립니다. 되돌린 테이블의 데이터는 삭제됩니다.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrateDown(cfg, logger, steps)
+		},
+	}
+	migrateDownCmd.Flags().IntVar(&steps, "steps", 1, "되돌릴 마이그레이션 수")
+
+	// db migrate status
+	migrateStatusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "마이그레이션 적용 상태 조회",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMigrateStatus(cfg, logger)
+		},
+	}
+
+	// db copy
+	var (
+		copyFrom  string
+		copyTo    string
+		batchSize int
+	)
+	copyCmd := &cobra.Command{
+		Use:   "copy",
+		Short: "다른 데이터베이스로 모든 데이터 복사",
+		Long: `모든 CNAP 테이블을 --from 데이터베이스에서 --to 데이터베이스로 ID와 시각을 유지한 채 배치 단위로 복사합니다.
+SQLite에서 PostgreSQL로 옮길 때 사용합니다. 대상 스키마는 자동으로 최신 버전까지 마이그레이션됩니다.
+배치마다 커밋하므로 중단되면 같은 명령을 다시 실행해 이어서 복사할 수 있고, 끝나면 테이블별 행 수와 체크섬을 비교합니다.
+복사하는 동안에는 원본을 사용하는 서버를 멈춰 두세요.`,
+		Example: `  cnap db copy --from file:./data/cnap.db --to "postgres://cnap:secret@db:5432/cnap?sslmode=disable"`,
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDBCopy(cfg, logger, copyFrom, copyTo, batchSize)
+		},
+	}
+	copyCmd.Flags().StringVar(&copyFrom, "from", "", "원본 데이터베이스 DSN")
+	copyCmd.Flags().StringVar(&copyTo, "to", "", "대상 데이터베이스 DSN")
+	copyCmd.Flags().IntVar(&batchSize, "batch-size", storage.DefaultCopyBatchSize, "한 트랜잭션에서 복사할 행 수")
+	_ = copyCmd.MarkFlagRequired("from")
+	_ = copyCmd.MarkFlagRequired("to")
+
+	migrateCmd.AddCommand(migrateUpCmd)
+	migrateCmd.AddCommand(migrateDownCmd)
+	migrateCmd.AddCommand(migrateStatusCmd)
+	dbCmd.AddCommand(migrateCmd)
+	dbCmd.AddCommand(copyCmd)
+
+	return dbCmd
+}
+
+// newMigrator는 데이터베이스에 연결해 Migrator를 생성합니다.
+func newMigrator(cfg *config.Config, logger *zap.Logger) (*storage.Migrator, func(), error) {
+	db, cleanup, err := openDatabase(cfg, logger)
+	if err != nil {
+		return nil, func() {}, fmt.Errorf("데이터베이스 연결 실패: %w", err)
+	}
+	migrator, err := storage.NewMigrator(db)
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+	return migrator, cleanup, nil
+}
+
+func runMigrateUp(cfg *config.Config, logger *zap.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	migrator, cleanup, err := newMigrator(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	applied, err := migrator.Up(ctx)
+	if err != nil {
+		return fmt.Errorf("마이그레이션 적용 실패: %w", err)
+	}
+	if len(applied) == 0 {
+		fmt.Printf("스키마가 이미 최신입니다 (버전 %d).\n", migrator.Latest())
+		return nil
+	}
+	for _, m := range applied {
+		fmt.Printf("✓ %04d_%s 적용\n", m.Version, m.Name)
+	}
+	fmt.Printf("스키마 버전: %d\n", migrator.Latest())
+	return nil
+}
+
+func runMigrateDown(cfg *config.Config, logger *zap.Logger, steps int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	migrator, cleanup, err := newMigrator(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	reverted, err := migrator.Down(ctx, steps)
+	if err != nil {
+		return fmt.Errorf("마이그레이션 되돌리기 실패: %w", err)
+	}
+	if len(reverted) == 0 {
+		fmt.Println("되돌릴 마이그레이션이 없습니다.")
+		return nil
+	}
+	for _, m := range reverted {
+		fmt.Printf("✓ %04d_%s 되돌림\n", m.Version, m.Name)
+	}
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("스키마 버전: %d\n", version)
+	return nil
+}
+
+func runMigrateStatus(cfg *config.Config, logger *zap.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+	defer cancel()
+
+	migrator, cleanup, err := newMigrator(cfg, logger)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("마이그레이션 상태 조회 실패: %w", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	_, _ = fmt.Fprintln(w, "-------\t----\t------\t----------")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state = "applied"
+			appliedAt = s.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	_ = w.Flush()
+
+	version, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n현재 버전: %d / 최신 버전: %d\n", version, migrator.Latest())
+	if version != migrator.Latest() {
+		fmt.Println("'cnap db migrate up'으로 스키마를 갱신하세요.")
+	}
+	return nil
+}
+
+func runDBCopy(cfg *config.Config, logger *zap.Logger, from, to string, batchSize int) error {
+	if from == to {
+		return fmt.Errorf("--from과 --to가 같은 데이터베이스입니다")
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	src, closeSrc, err := openDSN(cfg, logger, from)
+	if err != nil {
+		return fmt.Errorf("원본 데이터베이스 연결 실패: %w", err)
+	}
+	defer closeSrc()
+	dst, closeDst, err := openDSN(cfg, logger, to)
+	if err != nil {
+		return fmt.Errorf("대상 데이터베이스 연결 실패: %w", err)
+	}
+	defer closeDst()
+
+	if err := storage.CheckSchema(ctx, src); err != nil {
+		return fmt.Errorf("원본 스키마 확인 실패: %w (원본에 'cnap db migrate up'을 먼저 실행하세요)", err)
+	}
+	if err := storage.MigrateUp(ctx, dst); err != nil {
+		return fmt.Errorf("대상 스키마 마이그레이션 실패: %w", err)
+	}
+
+	fmt.Printf("%s → %s\n", storage.Dialect(src), storage.Dialect(dst))
+	err = storage.CopyTables(ctx, src, dst, storage.CopyOptions{
+		BatchSize: batchSize,
+		Progress: func(p storage.CopyProgress) {
+			resumed := ""
+			if p.Resumed > 0 {
+				resumed = fmt.Sprintf(" (이어서 복사: %d행 건너뜀)", p.Resumed)
+			}
+			fmt.Printf("\r  %-18s %d/%d행%s", p.Table, p.Copied, p.Total, resumed)
+			if p.Copied == p.Total {
+				fmt.Println()
+			}
+		},
+	})
+	if err != nil {
+		fmt.Println()
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("복사가 중단되었습니다. 같은 명령을 다시 실행하면 이어서 복사합니다")
+		}
+		if errors.Is(err, storage.ErrCopyMismatch) {
+			return fmt.Errorf("데이터 복사 실패: %w (대상에 원본에 없는 데이터가 있습니다. 빈 데이터베이스로 다시 시도하세요)", err)
+		}
+		return fmt.Errorf("데이터 복사 실패: %w (같은 명령을 다시 실행하면 이어서 복사합니다)", err)
+	}
+
+	fmt.Println("\n검증 중...")
+	comparisons, err := storage.CompareTables(ctx, src, dst)
+	if err != nil {
+		return fmt.Errorf("복사 결과 검증 실패: %w", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TABLE\tSOURCE\tTARGET\tCHECKSUM\tRESULT")
+	_, _ = fmt.Fprintln(w, "-----\t------\t------\t--------\t------")
+	mismatched := 0
+	for _, c := range comparisons {
+		result := "ok"
+		if !c.Match() {
+			result = "MISMATCH"
+			mismatched++
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\n", c.Table, c.SourceRows, c.TargetRows, c.SourceChecksum[:12], result)
+	}
+	_ = w.Flush()
+	if mismatched > 0 {
+		return fmt.Errorf("%w: %d개 테이블이 원본과 다릅니다 (복사 중 원본이 변경되었는지 확인하세요)", storage.ErrCopyMismatch, mismatched)
+	}
+	fmt.Println("✓ 모든 테이블의 행 수와 체크섬이 일치합니다.")
+	return nil
+}
+
+// openDSN은 설정의 연결 옵션으로 dsn 데이터베이스에 연결합니다.
+func openDSN(cfg *config.Config, logger *zap.Logger, dsn string) (*gorm.DB, func(), error) {
+	storageCfg := cfg.StorageConfig()
+	storageCfg.DSN = dsn
+	db, err := storage.Open(storageCfg)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	return db, func() {
+		if err := storage.Close(db); err != nil {
+			logger.Warn("Failed to close storage", zap.Error(err))
+		}
+	}, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// buildGCCommand는 보존 정책을 한 번 적용하는 gc 명령어를 생성합니다.
+func buildGCCommand(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Apply data retention rules",
+		Long: `Delete tasks, messages, run steps and checkpoints that fall outside the retention
+rules in the config file. With --dry-run, only report what would be reclaimed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runGC(cfg, logger, dryRun)
+		},
+	}
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Report what would be reclaimed without deleting anything")
+	return cmd
+}
+
+func runGC(cfg *config.Config, logger *zap.Logger, dryRun bool) error {
+	rules := cfg.RetentionRules()
+	if len(rules) == 0 {
+		fmt.Println("보존 정책이 설정되어 있지 않습니다. 설정 파일의 retention.rules를 확인하세요.")
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 30*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	report, err := ctrl.ApplyRetention(ctx, rules, dryRun)
+	if report != nil {
+		printRetentionReport(rules, report)
+	}
+	if err != nil {
+		return fmt.Errorf("데이터 정리 실패: %w", err)
+	}
+	return nil
+}
+
+// printRetentionReport는 보존 정책 적용 결과를 출력합니다.
+func printRetentionReport(rules []storage.RetentionRule, report *controller.RetentionReport) {
+	fmt.Println("적용한 보존 정책:")
+	for _, rule := range rules {
+		fmt.Printf("  - %s\n", describeRetentionRule(rule))
+	}
+	fmt.Println()
+
+	if report.DryRun {
+		fmt.Println("정리 예정 (--dry-run, 아무것도 삭제하지 않았습니다):")
+	} else {
+		fmt.Println("정리 결과:")
+	}
+	fmt.Printf("  작업: %d\n", report.Tasks)
+	fmt.Printf("  메시지: %d\n", report.Messages)
+	fmt.Printf("  실행 단계: %d\n", report.RunSteps)
+	fmt.Printf("  체크포인트: %d\n", report.Checkpoints)
+	fmt.Printf("  검색 문서: %d\n", report.SearchDocuments)
+	fmt.Printf("  합계(행): %d\n", report.Rows())
+	fmt.Printf("  메시지 파일: %d개 (%s)\n", len(report.Files), formatBytes(report.Bytes))
+	if !report.DryRun && report.RemovedFiles < len(report.Files) {
+		fmt.Printf("\n메시지 파일 %d개를 삭제하지 못했습니다. 로그를 확인하세요.\n", len(report.Files)-report.RemovedFiles)
+	}
+}
+
+// describeRetentionRule은 보존 규칙을 한 줄로 설명합니다.
+func describeRetentionRule(rule storage.RetentionRule) string {
+	scope := "전체 에이전트"
+	if rule.AgentID != "" {
+		scope = "에이전트 " + rule.AgentID
+	}
+	statuses := rule.Statuses
+	if len(statuses) == 0 {
+		statuses = storage.TerminalTaskStatuses
+	}
+	desc := fmt.Sprintf("%s, 상태 %v", scope, statuses)
+	if len(rule.Labels) > 0 {
+		desc += fmt.Sprintf(", 레이블 %s", rule.Labels)
+	}
+	if rule.OlderThan > 0 {
+		desc += fmt.Sprintf(", %s 지난 작업", formatAge(rule.OlderThan))
+	}
+	if rule.KeepLast > 0 {
+		desc += fmt.Sprintf(", 최근 %d개 보존", rule.KeepLast)
+	}
+	return desc
+}
+
+// formatAge는 일 단위로 나누어떨어지는 기간을 "90d"처럼 표시합니다.
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
+
+// formatBytes는 바이트 수를 사람이 읽기 쉬운 단위로 표시합니다.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/health"
+	taskrunner "github.com/cnap-oss/app/internal/runner"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// defaultMaxQueueBacklog는 readiness 검사가 허용하는 pending 작업 수의 기본 상한입니다.
+const defaultMaxQueueBacklog = 100
+
+// newHealthChecker는 DB, 스키마, 제공자, 작업 큐 검사를 등록한 Checker를 생성합니다.
+// gateway가 nil이 아니면 Discord 게이트웨이 검사도 등록합니다.
+func newHealthChecker(repo *storage.Repository, gateway health.CheckFunc) *health.Checker {
+	checker := health.NewChecker(health.DefaultTimeout)
+	checker.Register("database", health.Liveness, repo.Ping)
+	checker.Register("schema", health.Readiness, repo.CheckSchema)
+	checker.Register("provider", health.Readiness, taskrunner.CheckProvider)
+	checker.Register("queue", health.Readiness,
+		health.QueueBacklog(repo.CountTasksByStatus, storage.TaskStatusPending, defaultMaxQueueBacklog))
+	if gateway != nil {
+		checker.Register("discord", health.Readiness, gateway)
+	}
+	return checker
+}
+
+// buildHealthCommand는 health 명령어를 생성합니다.
+func buildHealthCommand(cfg *config.Config, logger *zap.Logger) *cobra.Command {
+	var (
+		url    string
+		live   bool
+		local  bool
+		asJSON bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Check application health status",
+		Long: `Query the /readyz (or /healthz with --live) endpoint of a running instance
+and exit non-zero if any check fails. With --local, run the checks in this process instead.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := context.WithTimeout(context.Background(), 2*health.DefaultTimeout)
+			defer cancel()
+
+			var (
+				report *health.Report
+				err    error
+			)
+			if local {
+				report, err = runLocalHealth(ctx, cfg, logger, live)
+			} else {
+				if url == "" {
+					if cfg.Metrics.Port == 0 {
+						return fmt.Errorf("헬스 체크 실패: 메트릭 서버가 비활성화되어 있습니다(metrics.port 0). --url 또는 --local을 사용하세요")
+					}
+					url = fmt.Sprintf("http://localhost:%d", cfg.Metrics.Port)
+				}
+				report, err = health.Fetch(ctx, healthURL(url, live))
+			}
+			if err != nil {
+				return fmt.Errorf("헬스 체크 실패: %w", err)
+			}
+
+			if asJSON {
+				enc := json.NewEncoder(os.Stdout)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printHealthReport(report)
+			}
+
+			if !report.OK() {
+				return fmt.Errorf("헬스 체크 실패: %s", failedChecks(report))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&url, "url", "", "Base URL of the running instance's metrics/health server (default http://localhost:<metrics.port>)")
+	cmd.Flags().BoolVar(&live, "live", false, "Run liveness checks only (/healthz)")
+	cmd.Flags().BoolVar(&local, "local", false, "Run the checks in this process instead of querying a running instance")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print the report as JSON")
+	return cmd
+}
+
+// healthURL은 기본 URL에 검사 종류에 맞는 경로를 붙입니다.
+func healthURL(base string, live bool) string {
+	path := "/readyz"
+	if live {
+		path = "/healthz"
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+// runLocalHealth는 저장소에 직접 연결해 검사를 실행합니다.
+// 실행 중인 Discord 세션이 없으므로 게이트웨이 검사는 제외됩니다.
+func runLocalHealth(ctx context.Context, cfg *config.Config, logger *zap.Logger, live bool) (*health.Report, error) {
+	repo, cleanup, err := initStorage(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	checker := newHealthChecker(repo, nil)
+	if live {
+		return checker.Live(ctx), nil
+	}
+	return checker.Ready(ctx), nil
+}
+
+func printHealthReport(report *health.Report) {
+	for _, res := range report.Checks {
+		mark := "✓"
+		if res.Status != health.StatusOK {
+			mark = "✗"
+		}
+		line := fmt.Sprintf("%s %-10s %s (%s)", mark, res.Name, res.Status, time.Duration(res.DurationMS)*time.Millisecond)
+		if res.Error != "" {
+			line += ": " + res.Error
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("상태: %s\n", report.Status)
+}
+
+func failedChecks(report *health.Report) string {
+	var names []string
+	for _, res := range report.Checks {
+		if res.Status != health.StatusOK {
+			names = append(names, res.Name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+)
+
+// labelTarget은 'label' 명령어가 레이블을 읽고 바꾸는 대상입니다.
+type labelTarget struct {
+	// kind는 출력에 쓰는 대상 이름이고, arg는 사용법에 쓰는 인자 이름입니다.
+	kind string
+	arg  string
+	get  func(ctx context.Context, ctrl *controller.Controller, id string) (map[string]string, error)
+	set  func(ctrl *controller.Controller, ctx context.Context, id string, labels map[string]string) error
+}
+
+var taskLabelTarget = labelTarget{
+	kind: "Task",
+	arg:  "task-id",
+	get: func(ctx context.Context, ctrl *controller.Controller, id string) (map[string]string, error) {
+		task, err := ctrl.GetTaskInfo(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return task.Labels, nil
+	},
+	set: (*controller.Controller).SetTaskLabels,
+}
+
+var agentLabelTarget = labelTarget{
+	kind: "Agent",
+	arg:  "agent-name",
+	get: func(ctx context.Context, ctrl *controller.Controller, id string) (map[string]string, error) {
+		agent, err := ctrl.GetAgentInfo(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		return agent.Labels, nil
+	},
+	set: (*controller.Controller).SetAgentLabels,
+}
+
+func buildLabelCommand(cfg *config.Config, logger *zap.Logger, target labelTarget) *cobra.Command {
+	return &cobra.Command{
+		Use:   fmt.Sprintf("label <%s> [key=value | key-]...", target.arg),
+		Short: target.kind + " 레이블 조회·변경",
+		Long: fmt.Sprintf(`%[1]s의 레이블을 추가, 변경, 삭제합니다. key=value는 레이블을 추가하거나 값을 바꾸고, key-는 레이블을 지웁니다.
+변경할 레이블을 지정하지 않으면 현재 레이블을 출력합니다. 레이블을 바꿔도 %[1]s 버전과 리비전은 바뀌지 않습니다.`, target.kind),
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			set, remove, err := parseLabelChanges(args[1:])
+			if err != nil {
+				return err
+			}
+			return runLabel(cfg, logger, target, args[0], set, remove)
+		},
+	}
+}
+
+// parseLabelChanges는 "key=value"와 "key-" 인자를 추가할 레이블과 지울 키로 나눕니다.
+func parseLabelChanges(args []string) (map[string]string, []string, error) {
+	var (
+		pairs  []string
+		remove []string
+	)
+	for _, arg := range args {
+		if key, ok := strings.CutSuffix(arg, "-"); ok && !strings.Contains(arg, "=") {
+			remove = append(remove, key)
+			continue
+		}
+		pairs = append(pairs, arg)
+	}
+	set, err := storage.ParseLabels(pairs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("유효하지 않은 레이블: %w", err)
+	}
+	for _, key := range remove {
+		if _, ok := set[key]; ok {
+			return nil, nil, fmt.Errorf("레이블 %q를 추가하면서 지울 수 없습니다", key)
+		}
+	}
+	return set, remove, nil
+}
+
+func runLabel(cfg *config.Config, logger *zap.Logger, target labelTarget, id string, set map[string]string, remove []string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	current, err := target.get(ctx, ctrl, id)
+	if err != nil {
+		return fmt.Errorf("%s 조회 실패: %w", strings.ToLower(target.kind), err)
+	}
+	if len(set) == 0 && len(remove) == 0 {
+		if len(current) == 0 {
+			fmt.Printf("%s '%s'에 레이블이 없습니다.\n", target.kind, id)
+			return nil
+		}
+		fmt.Println(storage.FormatLabels(current))
+		return nil
+	}
+
+	labels := controller.UpdateLabels(current, set, remove)
+	if err := target.set(ctrl, ctx, id, labels); err != nil {
+		return fmt.Errorf("레이블 변경 실패: %w", err)
+	}
+	if len(labels) == 0 {
+		fmt.Printf("✓ %s '%s' 레이블을 모두 지웠습니다\n", target.kind, id)
+		return nil
+	}
+	fmt.Printf("✓ %s '%s' 레이블: %s\n", target.kind, id, storage.FormatLabels(labels))
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+// defaultListLimit은 목록 명령어가 한 번에 보여주는 기본 행 수입니다.
+const defaultListLimit = 50
+
+// listFlags는 목록 명령어의 페이지, 필터, 정렬 플래그입니다.
+type listFlags struct {
+	limit    int
+	statuses []string
+	sort     string
+	since    string
+	until    string
+	cursor   string
+	selector string
+	// labels는 validate가 selector를 해석한 결과입니다.
+	labels storage.LabelSelector
+}
+
+func (f *listFlags) register(cmd *cobra.Command) {
+	cmd.Flags().IntVar(&f.limit, "limit", defaultListLimit, "한 페이지에 조회할 개수 (0이면 전체)")
+	cmd.Flags().StringSliceVar(&f.statuses, "status", nil, "상태로 필터링 (쉼표로 여러 개 지정)")
+	cmd.Flags().StringVar(&f.sort, "sort", storage.SortCreated, "정렬 기준 (created, updated, name / 앞에 -를 붙이면 내림차순)")
+	cmd.Flags().StringVar(&f.since, "since", "", "이 시점 이후에 생성된 항목만 조회 (예: 7d, 12h, 2024-01-01)")
+	cmd.Flags().StringVar(&f.until, "until", "", "이 시점 이전에 생성된 항목만 조회 (예: 1d, 2024-02-01)")
+	cmd.Flags().StringVar(&f.cursor, "cursor", "", "이전 조회가 출력한 다음 페이지 커서")
+	cmd.Flags().StringVarP(&f.selector, "selector", "l", "", "레이블 셀렉터로 필터링 (예: team=infra,env!=prod,ticket,!archived)")
+}
+
+// validate는 플래그 값을 검사하고 생성 시각 범위를 계산합니다.
+func (f *listFlags) validate(now time.Time) (after, before time.Time, err error) {
+	if f.limit < 0 {
+		return time.Time{}, time.Time{}, fmt.Errorf("--limit은 0 이상이어야 합니다")
+	}
+	if _, _, err := storage.ParseSort(f.sort); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("유효하지 않은 정렬 기준: %s (created, updated, name)", f.sort)
+	}
+	if after, err = parseSince(f.since, now); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if before, err = parseSince(f.until, now); err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if f.labels, err = storage.ParseLabelSelector(f.selector); err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("유효하지 않은 레이블 셀렉터: %w", err)
+	}
+	return after, before, nil
+}
+
+// printNextCursor는 다음 페이지가 있으면 이어서 조회하는 방법을 출력합니다.
+func printNextCursor(next string) {
+	if next == "" {
+		return
+	}
+	fmt.Printf("\n다음 페이지가 있습니다. --cursor %s 로 이어서 조회하세요.\n", next)
+}
@@ -2,18 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"os/user"
 	"sync"
 	"syscall"
 	"time"
 
+	"github.com/cnap-oss/app/internal/config"
 	"github.com/cnap-oss/app/internal/connector"
 	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/metrics"
+	taskrunner "github.com/cnap-oss/app/internal/runner"
 	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"github.com/joho/godotenv"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"gorm.io/gorm"
 )
 
 var (
@@ -23,46 +32,77 @@ var (
 
 func main() {
 	// Logger 초기화
-	logger, err := initLogger()
+	// 로그 레벨은 설정을 읽은 뒤 PersistentPreRunE에서 다시 적용합니다.
+	logLevel := zap.NewAtomicLevel()
+	logger, err := initLogger(logLevel)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Failed to initialize logger: %v\n", err)
 		os.Exit(1)
 	}
 	defer logger.Sync()
 
+	// cfg는 명령 실행 직전에 설정 파일, 환경 변수, 플래그로 채워집니다.
+	cfg := config.Default()
+	var (
+		configPath  string
+		logLevelArg string
+		databaseURL string
+	)
+
 	rootCmd := &cobra.Command{
 		Use:     "cnap",
 		Short:   "CNAP - AI Agent Supervisor CLI",
 		Long:    `CNAP is a command-line interface for managing AI agent supervisor and connector servers.`,
 		Version: fmt.Sprintf("%s (built at %s)", Version, BuildTime),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			loaded, err := loadConfig(cmd, configPath, logLevelArg, databaseURL)
+			if err != nil {
+				return err
+			}
+			*cfg = *loaded
+			if cfg.Log.Level != "" {
+				level, _ := zapcore.ParseLevel(cfg.Log.Level)
+				logLevel.SetLevel(level)
+			}
+			return nil
+		},
 	}
+	rootCmd.PersistentFlags().StringVar(&configPath, "config", "", "Path to a YAML config file (default $"+config.EnvConfigPath+")")
+	rootCmd.PersistentFlags().StringVar(&logLevelArg, "log-level", "", "Log level override (debug, info, warn, error)")
+	rootCmd.PersistentFlags().StringVar(&databaseURL, "database-url", "", "Database URL override (PostgreSQL or SQLite DSN)")
 
 	// start 명령어
+	var (
+		metricsPort int
+		migrate     bool
+	)
 	startCmd := &cobra.Command{
 		Use:   "start",
 		Short: "Start controller and connector server processes",
 		Long:  `Start the server processes for internal/controller and internal/connector.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runStart(logger)
-		},
-	}
-
-	// health 명령어
-	healthCmd := &cobra.Command{
-		Use:   "health",
-		Short: "Check application health status",
-		Long:  `Check if the application is running and healthy.`,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			fmt.Println("OK")
-			return nil
+			if cmd.Flags().Changed("metrics-port") {
+				cfg.Metrics.Port = metricsPort
+				if err := cfg.Validate(); err != nil {
+					return fmt.Errorf("설정 오류: %w", err)
+				}
+			}
+			return runStart(cfg, logger, migrate)
 		},
 	}
+	startCmd.Flags().IntVar(&metricsPort, "metrics-port", 9090, "Port for the /metrics, /healthz and /readyz endpoints (0 disables them)")
+	startCmd.Flags().BoolVar(&migrate, "migrate", false, "Apply pending schema migrations before starting")
 
 	// 명령어 구성
 	rootCmd.AddCommand(startCmd)
-	rootCmd.AddCommand(healthCmd)
-	rootCmd.AddCommand(buildAgentCommands(logger))
-	rootCmd.AddCommand(buildTaskCommands(logger))
+	rootCmd.AddCommand(buildHealthCommand(cfg, logger))
+	rootCmd.AddCommand(buildConfigCommands(cfg))
+	rootCmd.AddCommand(buildDBCommands(cfg, logger))
+	rootCmd.AddCommand(buildAgentCommands(cfg, logger))
+	rootCmd.AddCommand(buildTaskCommands(cfg, logger))
+	rootCmd.AddCommand(buildAuditCommands(cfg, logger))
+	rootCmd.AddCommand(buildGCCommand(cfg, logger))
+	rootCmd.AddCommand(buildBackupCommands(cfg, logger))
 
 	if err := rootCmd.Execute(); err != nil {
 		logger.Error("Command execution failed", zap.Error(err))
@@ -71,42 +111,104 @@ func main() {
 }
 
 // initLogger는 zap logger를 초기화합니다.
-func initLogger() (*zap.Logger, error) {
-	env := os.Getenv("ENV")
-	logLevel := os.Getenv("LOG_LEVEL")
-
-	var config zap.Config
-	if env == "production" {
-		config = zap.NewProductionConfig()
+// ENV=production이면 JSON 형식을 사용하며, 초기 레벨은 LOG_LEVEL 환경 변수를 따릅니다.
+func initLogger(level zap.AtomicLevel) (*zap.Logger, error) {
+	var zapConfig zap.Config
+	if os.Getenv("ENV") == "production" {
+		zapConfig = zap.NewProductionConfig()
 	} else {
-		config = zap.NewDevelopmentConfig()
+		zapConfig = zap.NewDevelopmentConfig()
 	}
 
-	// LOG_LEVEL 환경변수가 설정되어 있으면 적용
-	if logLevel != "" {
-		level, err := zap.ParseAtomicLevel(logLevel)
-		if err == nil {
-			config.Level = level
+	level.SetLevel(zapConfig.Level.Level())
+	if value := os.Getenv("LOG_LEVEL"); value != "" {
+		if parsed, err := zapcore.ParseLevel(value); err == nil {
+			level.SetLevel(parsed)
 		}
 	}
+	zapConfig.Level = level
+
+	return zapConfig.Build()
+}
+
+// loadConfig는 .env 파일, 설정 파일, 환경 변수, 플래그 순으로 설정을 합쳐 검증합니다.
+// 설정 파일 경로는 --config 플래그, 없으면 CNAP_CONFIG 환경 변수를 사용합니다.
+func loadConfig(cmd *cobra.Command, path, logLevel, databaseURL string) (*config.Config, error) {
+	// .env는 이미 설정된 환경 변수를 덮어쓰지 않습니다.
+	_ = godotenv.Load()
+
+	if path == "" {
+		path = os.Getenv(config.EnvConfigPath)
+	}
+	cfg, err := config.Load(path, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("설정 오류: %w", err)
+	}
 
-	return config.Build()
+	flags := cmd.Flags()
+	if flags.Changed("log-level") {
+		cfg.Log.Level = logLevel
+	}
+	if flags.Changed("database-url") {
+		cfg.Database.URL = databaseURL
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("설정 오류: %w", err)
+	}
+	return cfg, nil
+}
+
+// server는 runStart가 함께 실행하고 종료하는 서버 프로세스입니다.
+type server interface {
+	Start(ctx context.Context) error
+	Stop(ctx context.Context) error
 }
 
 // runStart는 controller와 connector 서버를 시작합니다.
-func runStart(logger *zap.Logger) error {
+// metrics.port가 0보다 크면 메트릭과 헬스 체크 엔드포인트를 제공하는 서버도 함께 시작합니다.
+func runStart(cfg *config.Config, logger *zap.Logger, migrate bool) error {
 	logger.Info("Starting CNAP servers",
 		zap.String("version", Version),
 		zap.String("build_time", BuildTime),
 	)
 
-	repo, cleanup, err := initStorage(logger)
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg.TracingConfig())
+	if err != nil {
+		logger.Error("Failed to initialize tracing", zap.Error(err))
+		return err
+	}
+	defer func() {
+		flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer flushCancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Warn("Failed to flush traces", zap.Error(err))
+		}
+	}()
+
+	repo, cleanup, err := initStorage(cfg, logger)
 	if err != nil {
 		logger.Error("Failed to initialize storage", zap.Error(err))
 		return err
 	}
 	defer cleanup()
 
+	if migrate {
+		if err := storage.MigrateUp(context.Background(), repo.DB()); err != nil {
+			logger.Error("Failed to apply schema migrations", zap.Error(err))
+			return err
+		}
+	}
+	if err := requireSchema(context.Background(), repo); err != nil {
+		logger.Error("Refusing to start with mismatched schema version", zap.Error(err))
+		return err
+	}
+
+	messages, err := cfg.OpenMessageStore()
+	if err != nil {
+		logger.Error("Failed to initialize message store", zap.Error(err))
+		return err
+	}
+
 	// Context 생성
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -116,30 +218,47 @@ func runStart(logger *zap.Logger) error {
 	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
 
 	// 서버 인스턴스 생성
-	controllerServer := controller.NewController(logger.Named("controller"), repo)
-	connectorServer := connector.NewServer(logger.Named("connector"), controllerServer)
+	controllerServer := controller.NewController(logger.Named("controller"), repo,
+		controller.WithMessageStore(messages),
+		controller.WithRetention(cfg.Retention.Interval, cfg.RetentionRules()),
+		controller.WithTaskRunner(taskrunner.NewRunner(logger.Named("runner"), cfg.Provider.APIKey)),
+	)
+	connectorServer := connector.NewServer(logger.Named("connector"), controllerServer, connector.Config{
+		Token:           cfg.Discord.Token,
+		PermissionsFile: cfg.Discord.PermissionsFile,
+	})
+	controllerServer.SetTaskNotifier(connectorServer)
+
+	servers := map[string]server{
+		"controller": controllerServer,
+		"connector":  connectorServer,
+	}
+	if cfg.Metrics.Port > 0 {
+		if err := registerMetrics(repo); err != nil {
+			logger.Error("Failed to register metrics", zap.Error(err))
+			return err
+		}
+		metricsServer := metrics.NewServer(logger.Named("metrics"), fmt.Sprintf(":%d", cfg.Metrics.Port))
+		checker := newHealthChecker(repo, connectorServer.CheckGateway)
+		metricsServer.Handle("/healthz", checker.LiveHandler())
+		metricsServer.Handle("/readyz", checker.ReadyHandler())
+		servers["metrics"] = metricsServer
+	}
 
 	// 에러 채널
-	errChan := make(chan error, 2)
+	errChan := make(chan error, len(servers))
 	var wg sync.WaitGroup
 
-	// Controller 서버 시작
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := controllerServer.Start(ctx); err != nil && err != context.Canceled {
-			errChan <- fmt.Errorf("controller error: %w", err)
-		}
-	}()
-
-	// Connector 서버 시작
-	wg.Add(1)
-	go func() {
-		defer wg.Done()
-		if err := connectorServer.Start(ctx); err != nil && err != context.Canceled {
-			errChan <- fmt.Errorf("connector error: %w", err)
-		}
-	}()
+	// 서버 시작
+	for name, srv := range servers {
+		wg.Add(1)
+		go func(name string, srv server) {
+			defer wg.Done()
+			if err := srv.Start(ctx); err != nil && err != context.Canceled {
+				errChan <- fmt.Errorf("%s error: %w", name, err)
+			}
+		}(name, srv)
+	}
 
 	// 종료 대기
 	select {
@@ -156,15 +275,13 @@ func runStart(logger *zap.Logger) error {
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer shutdownCancel()
 
-	shutdownErrChan := make(chan error, 2)
-
-	go func() {
-		shutdownErrChan <- controllerServer.Stop(shutdownCtx)
-	}()
+	shutdownErrChan := make(chan error, len(servers))
 
-	go func() {
-		shutdownErrChan <- connectorServer.Stop(shutdownCtx)
-	}()
+	for _, srv := range servers {
+		go func(srv server) {
+			shutdownErrChan <- srv.Stop(shutdownCtx)
+		}(srv)
+	}
 
 	// 모든 고루틴이 종료될 때까지 대기
 	go func() {
@@ -173,7 +290,7 @@ func runStart(logger *zap.Logger) error {
 	}()
 
 	// Shutdown 에러 확인
-	for i := 0; i < 2; i++ {
+	for i := 0; i < len(servers); i++ {
 		if err := <-shutdownErrChan; err != nil {
 			logger.Error("Shutdown error", zap.Error(err))
 		}
@@ -183,25 +300,25 @@ func runStart(logger *zap.Logger) error {
 	return nil
 }
 
-func initStorage(logger *zap.Logger) (*storage.Repository, func(), error) {
-	cfg, err := storage.ConfigFromEnv()
+// registerMetrics는 저장소 기반 메트릭 수집기를 등록합니다.
+func registerMetrics(repo *storage.Repository) error {
+	sqlDB, err := repo.DB().DB()
 	if err != nil {
-		return nil, func() {}, err
+		return fmt.Errorf("get sql.DB for metrics: %w", err)
 	}
-
-	db, err := storage.Open(cfg)
-	if err != nil {
-		return nil, func() {}, err
+	if err := metrics.RegisterDBStats(sqlDB, "cnap"); err != nil {
+		return err
 	}
-
-	if err := storage.AutoMigrate(db); err != nil {
-		_ = storage.Close(db)
-		return nil, func() {}, err
+	if err := metrics.RegisterTaskCollector(repo.CountTasksByStatus, storage.TaskStatusPending); err != nil {
+		return err
 	}
+	return metrics.RegisterRuntime()
+}
 
-	repo, err := storage.NewRepository(db)
+// openDatabase는 데이터베이스에 연결합니다. 스키마 버전은 검사하지 않습니다.
+func openDatabase(cfg *config.Config, logger *zap.Logger) (*gorm.DB, func(), error) {
+	db, err := storage.Open(cfg.StorageConfig())
 	if err != nil {
-		_ = storage.Close(db)
 		return nil, func() {}, err
 	}
 
@@ -210,16 +327,69 @@ func initStorage(logger *zap.Logger) (*storage.Repository, func(), error) {
 			logger.Warn("Failed to close storage", zap.Error(err))
 		}
 	}
+	return db, cleanup, nil
+}
+
+func initStorage(cfg *config.Config, logger *zap.Logger) (*storage.Repository, func(), error) {
+	db, cleanup, err := openDatabase(cfg, logger)
+	if err != nil {
+		return nil, func() {}, err
+	}
+
+	repo, err := storage.NewRepository(db)
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
 
 	return repo, cleanup, nil
 }
 
-func newController(logger *zap.Logger) (*controller.Controller, func(), error) {
-	repo, cleanup, err := initStorage(logger)
+// requireSchema는 스키마 버전이 바이너리와 다르면 마이그레이션 안내와 함께 에러를 반환합니다.
+func requireSchema(ctx context.Context, repo *storage.Repository) error {
+	if err := repo.CheckSchema(ctx); err != nil {
+		return fmt.Errorf("%w ('cnap db migrate up'으로 스키마를 갱신하세요)", err)
+	}
+	return nil
+}
+
+func newController(cfg *config.Config, logger *zap.Logger) (*controller.Controller, func(), error) {
+	repo, cleanup, err := initStorage(cfg, logger)
 	if err != nil {
 		return nil, func() {}, err
 	}
 
-	ctrl := controller.NewController(logger.Named("controller"), repo)
+	if err := requireSchema(context.Background(), repo); err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+
+	messages, err := cfg.OpenMessageStore()
+	if err != nil {
+		cleanup()
+		return nil, func() {}, err
+	}
+
+	ctrl := controller.NewController(logger.Named("controller"), repo, controller.WithMessageStore(messages))
 	return ctrl, cleanup, nil
 }
+
+// withCLIActor는 현재 OS 사용자를 actor로 기록한 컨텍스트를 반환합니다.
+func withCLIActor(ctx context.Context) context.Context {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		name = u.Username
+	}
+	if name == "" {
+		name = "unknown"
+	}
+	return controller.WithActor(ctx, controller.CLIActor(name))
+}
+
+// conflictHint는 동시 수정으로 실패한 에러에 다시 조회한 뒤 재시도하라는 안내를 덧붙입니다.
+func conflictHint(err error) error {
+	if errors.Is(err, storage.ErrConflict) {
+		return fmt.Errorf("%w\n다른 사용자가 먼저 수정했습니다. 최신 상태를 다시 조회한 뒤 재시도하세요", err)
+	}
+	return err
+}
@@ -2,7 +2,10 @@ package main
 
 import (
 	"context"
+	"maps"
 	"os"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -140,3 +143,73 @@ func BenchmarkApplicationStartup(b *testing.B) {
 		// 실제 시작 로직은 구현하지 않음
 	}
 }
+
+// TestParseSince는 감사 로그 --since 옵션의 기간 파싱을 검증합니다.
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		input   string
+		want    time.Time
+		wantErr bool
+	}{
+		{input: "7d", want: now.Add(-7 * 24 * time.Hour)},
+		{input: "2w", want: now.Add(-14 * 24 * time.Hour)},
+		{input: "12h", want: now.Add(-12 * time.Hour)},
+		{input: "2024-05-01T00:00:00Z", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+		{input: "yesterday", wantErr: true},
+		{input: "-3d", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseSince(tt.input, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseSince(%q) 에러가 예상되었지만 발생하지 않음", tt.input)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseSince(%q) 예상치 못한 에러: %v", tt.input, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Fatalf("parseSince(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLabelChanges(t *testing.T) {
+	tests := []struct {
+		args       []string
+		wantSet    map[string]string
+		wantRemove []string
+		wantErr    bool
+	}{
+		{args: []string{"ticket=ABC-12", "env-"}, wantSet: map[string]string{"ticket": "ABC-12"}, wantRemove: []string{"env"}},
+		{args: []string{"range=a-b", "flag="}, wantSet: map[string]string{"range": "a-b", "flag": ""}},
+		{args: []string{"suffix=a-"}, wantErr: true},
+		{args: []string{"team=infra", "team-"}, wantErr: true},
+		{args: []string{"team"}, wantErr: true},
+		{args: nil, wantSet: map[string]string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			set, remove, err := parseLabelChanges(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseLabelChanges(%q) 에러가 예상되었지만 발생하지 않음", tt.args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseLabelChanges(%q) 예상치 못한 에러: %v", tt.args, err)
+			}
+			if !maps.Equal(set, tt.wantSet) || !slices.Equal(remove, tt.wantRemove) {
+				t.Fatalf("parseLabelChanges(%q) = %v, %v, want %v, %v", tt.args, set, remove, tt.wantSet, tt.wantRemove)
+			}
+		})
+	}
+}
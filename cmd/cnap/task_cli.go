@@ -4,15 +4,19 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 	"time"
 
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/controller"
 	"github.com/cnap-oss/app/internal/storage"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 )
 
-func buildTaskCommands(logger *zap.Logger) *cobra.Command {
+func buildTaskCommands(cfg *config.Config, logger *zap.Logger) *cobra.Command {
 	taskCmd := &cobra.Command{
 		Use:   "task",
 		Short: "Task 관리 명령어",
@@ -20,26 +24,100 @@ func buildTaskCommands(logger *zap.Logger) *cobra.Command {
 	}
 
 	// task create
-	var createPrompt string
+	var (
+		createPrompt string
+		createLabels []string
+		createVars   []string
+	)
 	taskCreateCmd := &cobra.Command{
 		Use:   "create <agent-name> <task-id>",
 		Short: "새로운 Task 생성",
-		Long:  "특정 Agent에 새로운 Task를 생성합니다. --prompt 옵션으로 초기 프롬프트를 설정할 수 있습니다.",
-		Args:  cobra.ExactArgs(2),
+		Long: `특정 Agent에 새로운 Task를 생성합니다. --prompt 옵션으로 초기 프롬프트를 설정할 수 있습니다.
+--label key=value로 프로젝트, 티켓, 고객 등을 나타내는 레이블을 붙일 수 있습니다 (여러 번 지정 가능).
+Agent 프롬프트가 {{.Language}} 같은 변수를 쓰면 --var Language=Go로 값을 지정합니다 (여러 번 지정 가능).`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskCreate(logger, args[0], args[1], createPrompt)
+			labels, err := storage.ParseLabels(createLabels)
+			if err != nil {
+				return fmt.Errorf("유효하지 않은 레이블: %w", err)
+			}
+			vars, err := controller.ParsePromptVariables(createVars)
+			if err != nil {
+				return fmt.Errorf("유효하지 않은 변수: %w", err)
+			}
+			return runTaskCreate(cfg, logger, args[0], args[1], createPrompt, labels, vars)
 		},
 	}
 	taskCreateCmd.Flags().StringVarP(&createPrompt, "prompt", "p", "", "Task 초기 프롬프트")
+	taskCreateCmd.Flags().StringArrayVar(&createLabels, "label", nil, "Task 레이블 key=value (여러 번 지정 가능)")
+	taskCreateCmd.Flags().StringArrayVar(&createVars, "var", nil, "Agent 프롬프트 템플릿 변수 Key=Value (여러 번 지정 가능)")
 
 	// task list
+	var (
+		taskListFlags listFlags
+		listAgent     string
+		listPrompt    string
+	)
 	taskListCmd := &cobra.Command{
-		Use:   "list <agent-name>",
+		Use:   "list [agent-name]",
 		Short: "Task 목록 조회",
-		Long:  "특정 Agent의 모든 Task 목록을 조회합니다.",
-		Args:  cobra.ExactArgs(1),
+		Long: `Task 목록을 조회합니다. Agent를 지정하지 않으면 모든 Agent의 Task를 보여줍니다.
+기본으로 생성 순 50개를 보여주며, 더 있으면 출력되는 --cursor 값으로 다음 페이지를 조회합니다.`,
+		Args: cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskList(logger, args[0])
+			if len(args) == 1 {
+				if listAgent != "" && listAgent != args[0] {
+					return fmt.Errorf("agent 이름과 --agent가 다릅니다: %s, %s", args[0], listAgent)
+				}
+				listAgent = args[0]
+			}
+			after, before, err := taskListFlags.validate(time.Now())
+			if err != nil {
+				return err
+			}
+			return runTaskList(cfg, logger, storage.TaskFilter{
+				AgentID:        listAgent,
+				Statuses:       taskListFlags.statuses,
+				PromptContains: listPrompt,
+				CreatedAfter:   after,
+				CreatedBefore:  before,
+				Labels:         taskListFlags.labels,
+				Sort:           taskListFlags.sort,
+				Limit:          taskListFlags.limit,
+				Cursor:         taskListFlags.cursor,
+			})
+		},
+	}
+	taskListFlags.register(taskListCmd)
+	taskListCmd.Flags().StringVar(&listAgent, "agent", "", "Agent 이름으로 필터링")
+	taskListCmd.Flags().StringVar(&listPrompt, "prompt", "", "프롬프트에 포함된 문자열로 필터링 (대소문자 무시)")
+
+	// task search
+	var (
+		searchAgent string
+		searchLimit int
+	)
+	taskSearchCmd := &cobra.Command{
+		Use:   "search <query>",
+		Short: "Task 프롬프트와 메시지 검색",
+		Long: `모든 Agent의 Task 프롬프트와 메시지 본문을 전문 검색합니다.
+공백으로 구분한 모든 단어가 포함된 결과를 찾으며, 단어는 접두사로 일치합니다 (예: "결제" → "결제가").`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTaskSearch(cfg, logger, storage.SearchFilter{Query: args[0], AgentID: searchAgent, Limit: searchLimit})
+		},
+	}
+	taskSearchCmd.Flags().StringVar(&searchAgent, "agent", "", "Agent 이름으로 필터링")
+	taskSearchCmd.Flags().IntVar(&searchLimit, "limit", 20, "최대 결과 수")
+
+	// task reindex
+	taskReindexCmd := &cobra.Command{
+		Use:   "reindex",
+		Short: "Task 검색 인덱스 다시 만들기",
+		Long:  "모든 Task의 프롬프트와 메시지 본문을 검색 인덱스에 다시 등록합니다. 검색 기능 도입 전에 저장된 메시지를 검색하려면 한 번 실행하세요.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTaskReindex(cfg, logger)
 		},
 	}
 
@@ -50,29 +128,33 @@ func buildTaskCommands(logger *zap.Logger) *cobra.Command {
 		Long:  "특정 Task의 상세 정보를 조회합니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskView(logger, args[0])
+			return runTaskView(cfg, logger, args[0])
 		},
 	}
 
 	// task update-status
+	var ifVersion int
 	taskUpdateStatusCmd := &cobra.Command{
 		Use:   "update-status <task-id> <status>",
 		Short: "Task 상태 변경",
-		Long:  "Task의 상태를 변경합니다. (pending, running, completed, failed, canceled)",
-		Args:  cobra.ExactArgs(2),
+		Long: `Task의 상태를 변경합니다. (pending, running, completed, failed, canceled)
+--if-version에는 'task view'로 확인한 Task 버전을 지정하며, 그 사이 다른 변경이 있었으면 변경하지 않습니다.`,
+		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskUpdateStatus(logger, args[0], args[1])
+			return runTaskUpdateStatus(cfg, logger, args[0], args[1], ifVersion)
 		},
 	}
+	taskUpdateStatusCmd.Flags().IntVar(&ifVersion, "if-version", 0, "이 버전일 때만 변경 (다른 수정이 먼저 반영되었으면 실패)")
+	_ = taskUpdateStatusCmd.MarkFlagRequired("if-version")
 
 	// task cancel
 	taskCancelCmd := &cobra.Command{
 		Use:   "cancel <task-id>",
 		Short: "Task 취소",
-		Long:  "Task를 취소 상태로 변경합니다.",
+		Long:  "Task를 취소 상태로 변경합니다. 조회한 뒤 취소하기 전에 다른 변경이 있었으면 취소하지 않습니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskUpdateStatus(logger, args[0], storage.TaskStatusCanceled)
+			return runTaskCancel(cfg, logger, args[0])
 		},
 	}
 
@@ -83,7 +165,7 @@ func buildTaskCommands(logger *zap.Logger) *cobra.Command {
 		Long:  "Task의 메시지를 전송하고 실행을 트리거합니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskSend(logger, args[0])
+			return runTaskSend(cfg, logger, args[0])
 		},
 	}
 
@@ -94,44 +176,148 @@ func buildTaskCommands(logger *zap.Logger) *cobra.Command {
 		Long:  "Task에 새로운 메시지를 추가합니다. 실행은 트리거하지 않습니다.",
 		Args:  cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskAddMessage(logger, args[0], args[1])
+			return runTaskAddMessage(cfg, logger, args[0], args[1])
 		},
 	}
 
 	// task messages
+	var (
+		fullContent  bool
+		withVersions bool
+	)
 	taskMessagesCmd := &cobra.Command{
 		Use:   "messages <task-id>",
 		Short: "Task 메시지 목록 조회",
-		Long:  "Task에 추가된 메시지 목록을 조회합니다.",
+		Long:  "Task에 추가된 메시지 목록을 본문과 함께 조회합니다.",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return runTaskMessages(logger, args[0])
+			return runTaskMessages(cfg, logger, args[0], fullContent, withVersions)
+		},
+	}
+	taskMessagesCmd.Flags().BoolVar(&fullContent, "full", false, "메시지 본문을 자르지 않고 출력")
+	taskMessagesCmd.Flags().BoolVar(&withVersions, "versions", false, "다시 생성하거나 수정해 대체된 이전 버전도 함께 출력")
+
+	// task regenerate
+	taskRegenerateCmd := &cobra.Command{
+		Use:   "regenerate <task-id>",
+		Short: "마지막 응답 다시 생성",
+		Long: `Task의 마지막 assistant 메시지를 다시 생성합니다.
+이전 응답은 대체된 버전으로 남고('task messages --versions'), Task가 다시 실행됩니다.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTaskRegenerate(cfg, logger, args[0])
+		},
+	}
+
+	// task edit-message
+	taskEditMessageCmd := &cobra.Command{
+		Use:   "edit-message <task-id> <conversation-index> <message>",
+		Short: "이전 질문 수정 후 다시 실행",
+		Long: `Task의 user 메시지를 수정하고 그 지점부터 다시 실행합니다.
+수정한 메시지 뒤의 대화는 모두 대체된 버전으로 남습니다. 인덱스는 'task messages'의 INDEX입니다.`,
+		Args: cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := parseMessageIndex(args[1])
+			if err != nil {
+				return err
+			}
+			return runTaskEditMessage(cfg, logger, args[0], index, args[2])
+		},
+	}
+
+	// task delete-message
+	taskDeleteMessageCmd := &cobra.Command{
+		Use:   "delete-message <task-id> <conversation-index>",
+		Short: "메시지 삭제",
+		Long: `Task의 메시지를 대체된 버전과 본문까지 영구 삭제합니다.
+다른 메시지의 인덱스는 바뀌지 않으며, Task를 다시 실행하지 않습니다.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			index, err := parseMessageIndex(args[1])
+			if err != nil {
+				return err
+			}
+			return runTaskDeleteMessage(cfg, logger, args[0], index)
+		},
+	}
+
+	// task timeline
+	taskTimelineCmd := &cobra.Command{
+		Use:   "timeline <task-id>",
+		Short: "Task 실행 단계 타임라인 조회",
+		Long: `Task 실행 단계를 상위/하위 단계 트리로 출력합니다.
+각 단계의 시작 시점(실행 시작 기준), 소요 시간, 전체 실행 시간 대비 비율, 모델, 토큰 사용량과 오류를 함께 보여줍니다.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTaskTimeline(cfg, logger, args[0])
 		},
 	}
 
+	// task fork
+	var (
+		forkAt    int
+		forkAgent string
+		forkModel string
+		forkID    string
+	)
+	taskForkCmd := &cobra.Command{
+		Use:   "fork <task-id> --at <conversation-index>",
+		Short: "Task 대화 분기",
+		Long: `Task의 대화 0..N번 메시지를 복사한 새 Task를 만듭니다. (N은 --at)
+새 Task는 pending 상태이며, 'task add-message'로 다른 메시지를 추가한 뒤 'task send'로 실행합니다.
+--agent, --model로 다른 Agent나 모델로 다시 시도할 수 있습니다.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var opts []controller.ForkOption
+			if forkAgent != "" {
+				opts = append(opts, controller.WithForkAgent(forkAgent))
+			}
+			if forkModel != "" {
+				opts = append(opts, controller.WithForkModel(forkModel))
+			}
+			if forkID != "" {
+				opts = append(opts, controller.WithForkTaskID(forkID))
+			}
+			return runTaskFork(cfg, logger, args[0], forkAt, opts)
+		},
+	}
+	taskForkCmd.Flags().IntVar(&forkAt, "at", 0, "복사할 마지막 메시지의 대화 인덱스 ('task messages'의 INDEX)")
+	taskForkCmd.Flags().StringVar(&forkAgent, "agent", "", "새 Task를 실행할 Agent (기본값: 원래 Task의 Agent)")
+	taskForkCmd.Flags().StringVar(&forkModel, "model", "", "Agent 모델 대신 사용할 모델")
+	taskForkCmd.Flags().StringVar(&forkID, "id", "", "새 Task ID (기본값: <task-id>-fork-<시각>)")
+	_ = taskForkCmd.MarkFlagRequired("at")
+
 	taskCmd.AddCommand(taskCreateCmd)
 	taskCmd.AddCommand(taskListCmd)
+	taskCmd.AddCommand(taskSearchCmd)
+	taskCmd.AddCommand(taskReindexCmd)
 	taskCmd.AddCommand(taskViewCmd)
 	taskCmd.AddCommand(taskUpdateStatusCmd)
 	taskCmd.AddCommand(taskCancelCmd)
 	taskCmd.AddCommand(taskSendCmd)
 	taskCmd.AddCommand(taskAddMessageCmd)
 	taskCmd.AddCommand(taskMessagesCmd)
+	taskCmd.AddCommand(taskRegenerateCmd)
+	taskCmd.AddCommand(taskEditMessageCmd)
+	taskCmd.AddCommand(taskDeleteMessageCmd)
+	taskCmd.AddCommand(taskTimelineCmd)
+	taskCmd.AddCommand(taskForkCmd)
+	taskCmd.AddCommand(buildLabelCommand(cfg, logger, taskLabelTarget))
 
 	return taskCmd
 }
 
-func runTaskCreate(logger *zap.Logger, agentName, taskID, prompt string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskCreate(cfg *config.Config, logger *zap.Logger, agentName, taskID, prompt string, labels, vars map[string]string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
 	defer cleanup()
 
-	if err := ctrl.CreateTask(ctx, agentName, taskID, prompt); err != nil {
+	if err := ctrl.CreateTask(ctx, agentName, taskID, prompt, controller.WithTaskLabels(labels), controller.WithTaskVariables(vars)); err != nil {
 		return fmt.Errorf("task 생성 실패: %w", err)
 	}
 
@@ -140,6 +326,12 @@ func runTaskCreate(logger *zap.Logger, agentName, taskID, prompt string) error {
 	} else {
 		fmt.Printf("✓ Task '%s' 생성 완료 (Agent: %s)\n", taskID, agentName)
 	}
+	if len(labels) > 0 {
+		fmt.Printf("  레이블: %s\n", storage.FormatLabels(labels))
+	}
+	if len(vars) > 0 {
+		fmt.Printf("  변수: %s\n", storage.FormatLabels(vars))
+	}
 	return nil
 }
 
@@ -150,49 +342,113 @@ func truncateString(s string, maxLen int) string {
 	return s[:maxLen-3] + "..."
 }
 
-func runTaskList(logger *zap.Logger, agentName string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskList(cfg *config.Config, logger *zap.Logger, filter storage.TaskFilter) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
 	defer cleanup()
 
-	tasks, err := ctrl.ListTasksByAgent(ctx, agentName)
+	page, err := ctrl.ListTasksPage(ctx, filter)
 	if err != nil {
 		return fmt.Errorf("task 목록 조회 실패: %w", err)
 	}
+	tasks := page.Tasks
 
 	if len(tasks) == 0 {
-		fmt.Printf("Agent '%s'에 등록된 Task가 없습니다.\n", agentName)
+		if filter.AgentID != "" {
+			fmt.Printf("Agent '%s'에 조건에 맞는 Task가 없습니다.\n", filter.AgentID)
+		} else {
+			fmt.Println("조건에 맞는 Task가 없습니다.")
+		}
 		return nil
 	}
 
 	// 테이블 형식 출력
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "TASK ID\tSTATUS\tCREATED\tUPDATED")
-	_, _ = fmt.Fprintln(w, "-------\t------\t-------\t-------")
+	_, _ = fmt.Fprintln(w, "TASK ID\tAGENT\tSTATUS\tCREATED\tUPDATED\tLABELS")
+	_, _ = fmt.Fprintln(w, "-------\t-----\t------\t-------\t-------\t------")
 
 	for _, task := range tasks {
-		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
 			task.TaskID,
+			task.AgentID,
 			task.Status,
 			task.CreatedAt.Format("2006-01-02 15:04"),
 			task.UpdatedAt.Format("2006-01-02 15:04"),
+			storage.FormatLabels(task.Labels),
 		)
 	}
 	_ = w.Flush()
 
+	printNextCursor(page.NextCursor)
 	return nil
 }
 
-func runTaskView(logger *zap.Logger, taskID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskSearch(cfg *config.Config, logger *zap.Logger, filter storage.SearchFilter) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	results, err := ctrl.SearchTasks(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("task 검색 실패: %w", err)
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("'%s'와(과) 일치하는 Task가 없습니다.\n", filter.Query)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "TASK ID\tAGENT\tMATCH\tCREATED\tSNIPPET")
+	_, _ = fmt.Fprintln(w, "-------\t-----\t-----\t-------\t-------")
+	for _, r := range results {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+			r.TaskID,
+			r.AgentID,
+			r.Source,
+			r.CreatedAt.Format("2006-01-02 15:04"),
+			r.Snippet,
+		)
+	}
+	_ = w.Flush()
+
+	return nil
+}
+
+func runTaskReindex(cfg *config.Config, logger *zap.Logger) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 30*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	indexed, err := ctrl.RebuildSearchIndex(ctx)
+	if err != nil {
+		return fmt.Errorf("검색 인덱스 재생성 실패 (%d개 색인 후 중단): %w", indexed, err)
+	}
+
+	fmt.Printf("✓ 검색 인덱스에 문서 %d개를 등록했습니다\n", indexed)
+	return nil
+}
+
+func runTaskView(cfg *config.Config, logger *zap.Logger, taskID string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -207,21 +463,66 @@ func runTaskView(logger *zap.Logger, taskID string) error {
 	fmt.Printf("=== Task 정보: %s ===\n\n", task.TaskID)
 	fmt.Printf("Task ID:     %s\n", task.TaskID)
 	fmt.Printf("Agent ID:    %s\n", task.AgentID)
+	if task.AgentRevision > 0 {
+		fmt.Printf("Agent 리비전: r%d\n", task.AgentRevision)
+	}
 	fmt.Printf("상태:        %s\n", task.Status)
+	fmt.Printf("버전:        %d\n", task.Version)
+	if task.Model != "" {
+		fmt.Printf("모델:        %s (Agent 모델 대신 사용)\n", task.Model)
+	}
+	if task.ParentTaskID != "" {
+		fmt.Printf("분기 원본:   %s (#%d까지 복사)\n", task.ParentTaskID, task.ForkIndex)
+	}
+	if len(task.Labels) > 0 {
+		fmt.Printf("레이블:      %s\n", storage.FormatLabels(task.Labels))
+	}
+	if len(task.Variables) > 0 {
+		fmt.Printf("변수:        %s\n", storage.FormatLabels(task.Variables))
+	}
 	if task.Prompt != "" {
 		fmt.Printf("프롬프트:    %s\n", task.Prompt)
 	}
 	fmt.Printf("생성일:      %s\n", task.CreatedAt.Format("2006-01-02 15:04:05"))
 	fmt.Printf("수정일:      %s\n", task.UpdatedAt.Format("2006-01-02 15:04:05"))
+	if task.SystemPrompt != "" {
+		fmt.Printf("\n=== 시스템 프롬프트 ===\n\n%s\n", task.SystemPrompt)
+	}
+
+	if task.Attempts == 0 {
+		return nil
+	}
+
+	// 마지막 실행 결과 출력
+	fmt.Printf("\n=== 실행 결과 ===\n\n")
+	fmt.Printf("시도 횟수:   %d\n", task.Attempts)
+	if task.StartedAt != nil {
+		fmt.Printf("시작:        %s\n", task.StartedAt.Format("2006-01-02 15:04:05"))
+	}
+	if task.FinishedAt != nil {
+		fmt.Printf("종료:        %s (%s)\n", task.FinishedAt.Format("2006-01-02 15:04:05"), formatStepDuration(task.Duration()))
+	}
+	if task.ResultModel != "" {
+		fmt.Printf("응답 모델:   %s\n", task.ResultModel)
+	}
+	if task.ErrorClass != "" || task.ErrorMessage != "" {
+		fmt.Printf("오류:        [%s] %s\n", task.ErrorClass, task.ErrorMessage)
+	}
+	if task.ResultRef != "" {
+		fmt.Printf("결과 참조:   %s\n", task.ResultRef)
+	}
+	if task.Output != "" {
+		fmt.Printf("\n%s\n", task.Output)
+	}
 
 	return nil
 }
 
-func runTaskUpdateStatus(logger *zap.Logger, taskID, status string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskUpdateStatus(cfg *config.Config, logger *zap.Logger, taskID, status string, ifVersion int) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -248,19 +549,41 @@ func runTaskUpdateStatus(logger *zap.Logger, taskID, status string) error {
 		return fmt.Errorf("유효하지 않은 상태: %s (사용 가능: %v)", status, validStatuses)
 	}
 
-	if err := ctrl.UpdateTaskStatus(ctx, taskID, status); err != nil {
-		return fmt.Errorf("task 상태 변경 실패: %w", err)
+	if err := ctrl.UpdateTaskStatusIfVersion(ctx, taskID, status, ifVersion); err != nil {
+		return fmt.Errorf("task 상태 변경 실패: %w", conflictHint(err))
 	}
 
 	fmt.Printf("✓ Task '%s' 상태 변경: %s\n", taskID, status)
 	return nil
 }
 
-func runTaskSend(logger *zap.Logger, taskID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskCancel(cfg *config.Config, logger *zap.Logger, taskID string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	task, err := ctrl.GetTaskInfo(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("task 조회 실패: %w", err)
+	}
+	if err := ctrl.UpdateTaskStatusIfVersion(ctx, taskID, storage.TaskStatusCanceled, task.Version); err != nil {
+		return fmt.Errorf("task 취소 실패: %w", conflictHint(err))
+	}
+
+	fmt.Printf("✓ Task '%s' 취소됨 (이전 상태: %s)\n", taskID, task.Status)
+	return nil
+}
+
+func runTaskSend(cfg *config.Config, logger *zap.Logger, taskID string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -274,11 +597,11 @@ func runTaskSend(logger *zap.Logger, taskID string) error {
 	return nil
 }
 
-func runTaskAddMessage(logger *zap.Logger, taskID, message string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskAddMessage(cfg *config.Config, logger *zap.Logger, taskID, message string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
@@ -292,17 +615,22 @@ func runTaskAddMessage(logger *zap.Logger, taskID, message string) error {
 	return nil
 }
 
-func runTaskMessages(logger *zap.Logger, taskID string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 1*time.Minute)
+func runTaskMessages(cfg *config.Config, logger *zap.Logger, taskID string, full, versions bool) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
 	defer cancel()
 
-	ctrl, cleanup, err := newController(logger)
+	ctrl, cleanup, err := newController(cfg, logger)
 	if err != nil {
 		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
 	}
 	defer cleanup()
 
-	messages, err := ctrl.ListMessages(ctx, taskID)
+	var messages []*controller.MessageInfo
+	if versions {
+		messages, err = ctrl.ListMessageVersions(ctx, taskID)
+	} else {
+		messages, err = ctrl.ListMessagesWithContent(ctx, taskID)
+	}
 	if err != nil {
 		return fmt.Errorf("메시지 목록 조회 실패: %w", err)
 	}
@@ -312,20 +640,265 @@ func runTaskMessages(logger *zap.Logger, taskID string) error {
 		return nil
 	}
 
+	if full {
+		for _, msg := range messages {
+			fmt.Printf("[%d] %s (%s)", msg.ConversationIndex, msg.Role, msg.CreatedAt.Format("2006-01-02 15:04"))
+			if versions {
+				fmt.Printf(" r%d", msg.Revision)
+				if msg.SupersededAt != nil {
+					fmt.Print(" (대체됨)")
+				}
+			}
+			fmt.Println()
+			fmt.Println(msg.Content)
+			fmt.Println()
+		}
+		return nil
+	}
+
 	// 테이블 형식 출력
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	_, _ = fmt.Fprintln(w, "INDEX\tROLE\tFILE PATH\tCREATED")
-	_, _ = fmt.Fprintln(w, "-----\t----\t---------\t-------")
+	if versions {
+		_, _ = fmt.Fprintln(w, "INDEX\tREV\tROLE\tCONTENT\tCREATED")
+		_, _ = fmt.Fprintln(w, "-----\t---\t----\t-------\t-------")
+	} else {
+		_, _ = fmt.Fprintln(w, "INDEX\tROLE\tCONTENT\tCREATED")
+		_, _ = fmt.Fprintln(w, "-----\t----\t-------\t-------")
+	}
 
 	for _, msg := range messages {
-		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n",
-			msg.ConversationIndex,
+		index := strconv.Itoa(msg.ConversationIndex)
+		if versions {
+			index += "\t" + messageRevision(msg)
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			index,
 			msg.Role,
-			truncateString(msg.FilePath, 40),
+			truncateString(strings.Join(strings.Fields(msg.Content), " "), 50),
 			msg.CreatedAt.Format("2006-01-02 15:04"),
 		)
 	}
 	_ = w.Flush()
+	if versions {
+		fmt.Println("\n* 다시 생성하거나 수정해 대체된 버전")
+	}
+
+	return nil
+}
+
+// messageRevision은 메시지 버전을 "r2"처럼 표시하고, 대체된 버전이면 *를 덧붙입니다.
+func messageRevision(msg *controller.MessageInfo) string {
+	rev := fmt.Sprintf("r%d", msg.Revision)
+	if msg.SupersededAt != nil {
+		rev += "*"
+	}
+	return rev
+}
+
+// parseMessageIndex는 명령 인자로 받은 대화 인덱스를 해석합니다.
+func parseMessageIndex(arg string) (int, error) {
+	index, err := strconv.Atoi(arg)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("잘못된 메시지 인덱스: %s", arg)
+	}
+	return index, nil
+}
+
+func runTaskRegenerate(cfg *config.Config, logger *zap.Logger, taskID string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	index, err := ctrl.RegenerateMessage(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("응답 다시 생성 실패: %w", err)
+	}
+
+	fmt.Printf("✓ Task '%s'의 #%d 응답을 다시 생성합니다. 이전 응답은 'task messages --versions'로 볼 수 있습니다.\n", taskID, index)
+	return nil
+}
+
+func runTaskEditMessage(cfg *config.Config, logger *zap.Logger, taskID string, index int, message string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	if err := ctrl.EditMessage(ctx, taskID, index, message); err != nil {
+		return fmt.Errorf("메시지 수정 실패: %w", err)
+	}
 
+	fmt.Printf("✓ Task '%s'의 #%d 메시지를 수정하고 다시 실행합니다.\n", taskID, index)
+	return nil
+}
+
+func runTaskDeleteMessage(cfg *config.Config, logger *zap.Logger, taskID string, index int) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	if err := ctrl.DeleteMessage(ctx, taskID, index); err != nil {
+		return fmt.Errorf("메시지 삭제 실패: %w", err)
+	}
+
+	fmt.Printf("✓ Task '%s'의 #%d 메시지가 삭제되었습니다.\n", taskID, index)
+	return nil
+}
+
+func runTaskTimeline(cfg *config.Config, logger *zap.Logger, taskID string) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	timeline, err := ctrl.GetTaskTimeline(ctx, taskID)
+	if err != nil {
+		return fmt.Errorf("타임라인 조회 실패: %w", err)
+	}
+
+	if len(timeline.Steps) == 0 {
+		fmt.Printf("Task '%s'에 기록된 실행 단계가 없습니다.\n", taskID)
+		return nil
+	}
+
+	now := time.Now()
+	start, end, hasBounds := timeline.Bounds()
+	var total time.Duration
+	if hasBounds {
+		if end.Before(start) {
+			end = now
+		}
+		total = end.Sub(start)
+	} else {
+		for _, node := range timeline.Steps {
+			total += stepElapsed(&node.RunStep, now)
+		}
+	}
+
+	fmt.Printf("=== Task 타임라인: %s (%s) ===\n", taskID, timeline.Task.Status)
+	fmt.Printf("전체 실행 시간: %s\n\n", formatStepDuration(total))
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "STEP\tSTATUS\tSTART\tDURATION\tSHARE\tDETAIL")
+	_, _ = fmt.Fprintln(w, "----\t------\t-----\t--------\t-----\t------")
+
+	var render func(nodes []*controller.StepNode, prefix string, root bool)
+	render = func(nodes []*controller.StepNode, prefix string, root bool) {
+		for i, node := range nodes {
+			last := i == len(nodes)-1
+			branch, childPrefix := "", ""
+			if !root {
+				branch, childPrefix = "├── ", prefix+"│   "
+				if last {
+					branch, childPrefix = "└── ", prefix+"    "
+				}
+			}
+
+			offset := "-"
+			if hasBounds && node.StartedAt != nil {
+				offset = "+" + formatStepDuration(node.StartedAt.Sub(start))
+			}
+			elapsed := stepElapsed(&node.RunStep, now)
+			duration := formatStepDuration(elapsed)
+			if node.FinishedAt == nil && node.DurationMS == 0 && node.StartedAt != nil {
+				duration += " (진행 중)"
+			}
+			share := "-"
+			if total > 0 {
+				share = fmt.Sprintf("%.1f%%", float64(elapsed)/float64(total)*100)
+			}
+
+			_, _ = fmt.Fprintf(w, "%s%s#%d %s\t%s\t%s\t%s\t%s\t%s\n",
+				prefix, branch, node.StepNo, node.Type,
+				node.Status,
+				offset,
+				duration,
+				share,
+				stepDetail(&node.RunStep),
+			)
+			render(node.Children, childPrefix, false)
+		}
+	}
+	render(timeline.Steps, "", true)
+	_ = w.Flush()
+
+	return nil
+}
+
+// stepElapsed는 단계의 소요 시간을 반환합니다. 아직 끝나지 않은 단계는 지금까지의 경과 시간입니다.
+func stepElapsed(step *storage.RunStep, now time.Time) time.Duration {
+	if d := step.Duration(); d > 0 || step.FinishedAt != nil || step.StartedAt == nil {
+		return d
+	}
+	return now.Sub(*step.StartedAt)
+}
+
+// stepDetail은 단계의 모델, 토큰 사용량, 오류를 한 줄로 요약합니다.
+func stepDetail(step *storage.RunStep) string {
+	var parts []string
+	if step.Model != "" {
+		parts = append(parts, step.Model)
+	}
+	if step.InputTokens > 0 || step.OutputTokens > 0 {
+		parts = append(parts, fmt.Sprintf("tokens %d→%d", step.InputTokens, step.OutputTokens))
+	}
+	if step.ErrorClass != "" || step.ErrorMessage != "" {
+		msg := strings.Join(strings.Fields(step.ErrorMessage), " ")
+		parts = append(parts, truncateString(strings.TrimSpace("error "+step.ErrorClass+": "+msg), 60))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatStepDuration은 소요 시간을 크기에 맞는 단위로 출력합니다.
+func formatStepDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return fmt.Sprintf("%dms", d.Milliseconds())
+	case d < time.Minute:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+func runTaskFork(cfg *config.Config, logger *zap.Logger, taskID string, at int, opts []controller.ForkOption) error {
+	ctx, cancel := context.WithTimeout(withCLIActor(context.Background()), 1*time.Minute)
+	defer cancel()
+
+	ctrl, cleanup, err := newController(cfg, logger)
+	if err != nil {
+		return fmt.Errorf("컨트롤러 초기화 실패: %w", err)
+	}
+	defer cleanup()
+
+	fork, err := ctrl.ForkTask(ctx, taskID, at, opts...)
+	if err != nil {
+		return fmt.Errorf("task 분기 실패: %w", err)
+	}
+
+	fmt.Printf("✓ Task '%s'의 #0..#%d 메시지를 복사해 '%s'를 생성했습니다.\n", taskID, at, fork.TaskID)
+	fmt.Printf("  Agent: %s", fork.AgentID)
+	if fork.Model != "" {
+		fmt.Printf(", 모델: %s", fork.Model)
+	}
+	fmt.Println()
 	return nil
 }
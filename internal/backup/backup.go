@@ -0,0 +1,446 @@
+// Package backup은 CNAP 데이터베이스와 메시지 저장소를 하나의 tar.gz 아카이브로 백업하고 복원합니다.
+//
+// 아카이브에는 테이블별 JSON Lines 파일(database/<table>.jsonl)과 메시지 본문(messages/<key>),
+// 마지막 항목으로 각 파일의 크기와 SHA-256 체크섬, 스키마 버전을 기록한 manifest.json이 들어 있습니다.
+// 테이블은 드라이버와 무관한 형식으로 저장하므로 SQLite 백업을 PostgreSQL로 복원할 수도 있습니다.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"gorm.io/gorm"
+)
+
+// FormatVersion은 아카이브 형식의 버전입니다.
+const FormatVersion = 1
+
+const (
+	manifestName    = "manifest.json"
+	databaseDir     = "database/"
+	messagesDir     = "messages/"
+	readBatchSize   = 1000
+	insertBatchSize = 500
+)
+
+var (
+	// ErrChecksumMismatch는 아카이브 파일이 매니페스트의 크기나 체크섬과 다를 때 반환됩니다.
+	ErrChecksumMismatch = errors.New("backup: checksum mismatch")
+	// ErrNotEmpty는 데이터가 있는 데이터베이스에 강제 옵션 없이 복원하려 할 때 반환됩니다.
+	ErrNotEmpty = errors.New("backup: target database is not empty")
+)
+
+// Manifest는 아카이브의 내용을 설명합니다.
+type Manifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	AppVersion    string    `json:"app_version,omitempty"`
+	// Dialect는 백업한 데이터베이스의 드라이버(sqlite, postgres)입니다.
+	Dialect       string       `json:"dialect"`
+	SchemaVersion int          `json:"schema_version"`
+	Tables        []TableEntry `json:"tables"`
+	Messages      []FileEntry  `json:"messages"`
+	// MissingMessages는 msg_index에는 있지만 메시지 저장소에 본문이 없던 키입니다.
+	MissingMessages []string `json:"missing_messages,omitempty"`
+}
+
+// TableEntry는 테이블 하나의 백업 파일입니다.
+type TableEntry struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	FileEntry
+}
+
+// FileEntry는 아카이브 안의 파일 하나입니다.
+type FileEntry struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Rows는 모든 테이블의 행 수 합계입니다.
+func (m *Manifest) Rows() int64 {
+	var total int64
+	for _, t := range m.Tables {
+		total += t.Rows
+	}
+	return total
+}
+
+// CreateOptions는 Create의 선택 항목입니다.
+type CreateOptions struct {
+	// AppVersion은 매니페스트에 기록할 바이너리 버전입니다.
+	AppVersion string
+}
+
+// Create는 db와 store의 일관된 백업을 w에 tar.gz 아카이브로 씁니다.
+// 데이터베이스는 storage.Snapshot으로 읽으므로 서버가 실행 중이어도 됩니다.
+// 메시지 본문은 스냅샷의 msg_index가 가리키는 것만 담습니다.
+func Create(ctx context.Context, db *gorm.DB, store msgstore.MessageStore, w io.Writer, opts CreateOptions) (*Manifest, error) {
+	migrator, err := storage.NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := migrator.CheckVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	manifest := &Manifest{
+		FormatVersion: FormatVersion,
+		CreatedAt:     time.Now().UTC(),
+		AppVersion:    opts.AppVersion,
+		Dialect:       storage.Dialect(db),
+		SchemaVersion: migrator.Latest(),
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	var keys []string
+	err = storage.Snapshot(ctx, db, func(snap *gorm.DB) error {
+		for _, model := range storage.Models() {
+			entry, err := writeTable(ctx, tw, snap, model)
+			if err != nil {
+				return err
+			}
+			manifest.Tables = append(manifest.Tables, *entry)
+		}
+		return snap.Model(&storage.MessageIndex{}).Order("id ASC").Pluck("file_path", &keys).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		msg, err := store.Get(ctx, key)
+		if err != nil {
+			if errors.Is(err, msgstore.ErrNotFound) {
+				manifest.MissingMessages = append(manifest.MissingMessages, key)
+				continue
+			}
+			return nil, err
+		}
+		data, err := json.Marshal(msg)
+		if err != nil {
+			return nil, fmt.Errorf("backup: encode message %s: %w", key, err)
+		}
+		entry, err := writeEntry(tw, messagesDir+key, data)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Messages = append(manifest.Messages, *entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("backup: encode manifest: %w", err)
+	}
+	if _, err := writeEntry(tw, manifestName, data); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("backup: close archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("backup: close archive: %w", err)
+	}
+	return manifest, nil
+}
+
+// writeTable은 테이블의 모든 행을 JSON Lines로 임시 파일에 쓴 뒤 아카이브에 추가합니다.
+// tar 헤더에 크기를 먼저 적어야 하므로 큰 테이블도 메모리에 올리지 않도록 임시 파일을 거칩니다.
+func writeTable(ctx context.Context, tw *tar.Writer, db *gorm.DB, model interface{}) (*TableEntry, error) {
+	table, err := storage.TableName(db, model)
+	if err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp("", "cnap-backup-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("backup: create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	entry := &TableEntry{Table: table, FileEntry: FileEntry{Path: databaseDir + table + ".jsonl"}}
+	hash := sha256.New()
+	buf := bufio.NewWriter(io.MultiWriter(tmp, hash))
+	enc := json.NewEncoder(buf)
+	var afterID int64
+	for {
+		rows, last, n, err := storage.ReadRows(ctx, db, model, afterID, readBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("backup: read %s: %w", table, err)
+		}
+		if n == 0 {
+			break
+		}
+		slice := reflect.ValueOf(rows).Elem()
+		for i := 0; i < n; i++ {
+			if err := enc.Encode(slice.Index(i).Interface()); err != nil {
+				return nil, fmt.Errorf("backup: encode %s row: %w", table, err)
+			}
+		}
+		entry.Rows += int64(n)
+		afterID = last
+	}
+	if err := buf.Flush(); err != nil {
+		return nil, fmt.Errorf("backup: write temp file: %w", err)
+	}
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, fmt.Errorf("backup: write temp file: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("backup: write temp file: %w", err)
+	}
+
+	entry.Size = size
+	entry.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if err := tw.WriteHeader(&tar.Header{Name: entry.Path, Mode: 0o600, Size: size, ModTime: time.Now()}); err != nil {
+		return nil, fmt.Errorf("backup: write %s: %w", entry.Path, err)
+	}
+	if _, err := io.Copy(tw, tmp); err != nil {
+		return nil, fmt.Errorf("backup: write %s: %w", entry.Path, err)
+	}
+	return entry, nil
+}
+
+// writeEntry는 data를 아카이브의 name 파일로 추가합니다.
+func writeEntry(tw *tar.Writer, name string, data []byte) (*FileEntry, error) {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return nil, fmt.Errorf("backup: write %s: %w", name, err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return nil, fmt.Errorf("backup: write %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	return &FileEntry{Path: name, Size: int64(len(data)), SHA256: hex.EncodeToString(sum[:])}, nil
+}
+
+// Verify는 아카이브의 모든 파일이 매니페스트의 크기, 체크섬과 일치하는지 확인하고 매니페스트를 반환합니다.
+func Verify(archive string) (*Manifest, error) {
+	type seen struct {
+		size int64
+		sum  string
+	}
+	files := map[string]seen{}
+	var manifest *Manifest
+
+	err := walkArchive(archive, func(hdr *tar.Header, r io.Reader) error {
+		if hdr.Name == manifestName {
+			data, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return fmt.Errorf("backup: decode manifest: %w", err)
+			}
+			return nil
+		}
+		hash := sha256.New()
+		size, err := io.Copy(hash, r)
+		if err != nil {
+			return err
+		}
+		files[hdr.Name] = seen{size: size, sum: hex.EncodeToString(hash.Sum(nil))}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if manifest == nil {
+		return nil, fmt.Errorf("backup: %s has no %s", archive, manifestName)
+	}
+	if manifest.FormatVersion != FormatVersion {
+		return nil, fmt.Errorf("backup: unsupported archive format version %d", manifest.FormatVersion)
+	}
+
+	expected := make([]FileEntry, 0, len(manifest.Tables)+len(manifest.Messages))
+	for _, t := range manifest.Tables {
+		expected = append(expected, t.FileEntry)
+	}
+	expected = append(expected, manifest.Messages...)
+	for _, entry := range expected {
+		got, ok := files[entry.Path]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s is missing", ErrChecksumMismatch, entry.Path)
+		}
+		if got.size != entry.Size || got.sum != entry.SHA256 {
+			return nil, fmt.Errorf("%w: %s", ErrChecksumMismatch, entry.Path)
+		}
+		delete(files, entry.Path)
+	}
+	for name := range files {
+		return nil, fmt.Errorf("%w: unexpected file %s", ErrChecksumMismatch, name)
+	}
+	return manifest, nil
+}
+
+// RestoreOptions는 Restore의 선택 항목입니다.
+type RestoreOptions struct {
+	// Force가 true이면 대상 데이터베이스의 기존 행을 모두 지우고 복원합니다.
+	// 메시지 저장소의 기존 파일은 지우지 않고, 같은 키는 덮어씁니다.
+	Force bool
+}
+
+// Restore는 아카이브를 검증한 뒤 db와 store에 복원합니다.
+// 대상 데이터베이스는 아카이브와 같은 스키마 버전까지 마이그레이션되어 있어야 하며,
+// Force가 아니면 비어 있어야 합니다. 데이터베이스 복원은 한 트랜잭션으로 이루어집니다.
+func Restore(ctx context.Context, db *gorm.DB, store msgstore.MessageStore, archive string, opts RestoreOptions) (*Manifest, error) {
+	manifest, err := Verify(archive)
+	if err != nil {
+		return nil, err
+	}
+
+	migrator, err := storage.NewMigrator(db)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.SchemaVersion != migrator.Latest() {
+		return nil, fmt.Errorf("%w: archive is at version %d, binary expects %d",
+			storage.ErrSchemaVersionMismatch, manifest.SchemaVersion, migrator.Latest())
+	}
+	if err := migrator.CheckVersion(ctx); err != nil {
+		return nil, err
+	}
+
+	models := map[string]interface{}{}
+	for _, model := range storage.Models() {
+		table, err := storage.TableName(db, model)
+		if err != nil {
+			return nil, err
+		}
+		models[databaseDir+table+".jsonl"] = model
+	}
+
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if opts.Force {
+			if err := storage.TruncateTables(ctx, tx); err != nil {
+				return err
+			}
+		} else if err := requireEmpty(ctx, tx); err != nil {
+			return err
+		}
+
+		err := walkArchive(archive, func(hdr *tar.Header, r io.Reader) error {
+			switch {
+			case strings.HasPrefix(hdr.Name, databaseDir):
+				model, ok := models[hdr.Name]
+				if !ok {
+					return fmt.Errorf("backup: unknown table file %s", hdr.Name)
+				}
+				return restoreTable(ctx, tx, model, hdr.Name, r)
+			case strings.HasPrefix(hdr.Name, messagesDir):
+				// 메시지 저장소는 트랜잭션 밖이지만 같은 키로 다시 복원하면 덮어쓰므로 재시도해도 안전합니다.
+				var msg msgstore.Message
+				if err := json.NewDecoder(r).Decode(&msg); err != nil {
+					return fmt.Errorf("backup: decode %s: %w", hdr.Name, err)
+				}
+				return store.Put(ctx, strings.TrimPrefix(hdr.Name, messagesDir), &msg)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return storage.ResetSequences(ctx, tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// requireEmpty는 CNAP 테이블에 행이 하나라도 있으면 ErrNotEmpty를 반환합니다.
+func requireEmpty(ctx context.Context, db *gorm.DB) error {
+	for _, model := range storage.Models() {
+		var count int64
+		if err := db.WithContext(ctx).Model(model).Limit(1).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			table, _ := storage.TableName(db, model)
+			return fmt.Errorf("%w: %s has rows", ErrNotEmpty, table)
+		}
+	}
+	return nil
+}
+
+// restoreTable은 JSON Lines 파일의 행을 배치로 나누어 추가합니다.
+func restoreTable(ctx context.Context, tx *gorm.DB, model interface{}, name string, r io.Reader) error {
+	dec := json.NewDecoder(r)
+	rows := storage.NewRows(model)
+	slice := reflect.ValueOf(rows).Elem()
+	elem := slice.Type().Elem()
+	for {
+		row := reflect.New(elem)
+		err := dec.Decode(row.Interface())
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("backup: decode %s: %w", name, err)
+		}
+		slice.Set(reflect.Append(slice, row.Elem()))
+		if slice.Len() >= insertBatchSize {
+			if err := storage.InsertRows(ctx, tx, rows); err != nil {
+				return fmt.Errorf("backup: restore %s: %w", name, err)
+			}
+			slice.SetLen(0)
+		}
+	}
+	if err := storage.InsertRows(ctx, tx, rows); err != nil {
+		return fmt.Errorf("backup: restore %s: %w", name, err)
+	}
+	return nil
+}
+
+// walkArchive는 tar.gz 아카이브의 일반 파일마다 fn을 호출합니다.
+func walkArchive(archive string, fn func(hdr *tar.Header, r io.Reader) error) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return fmt.Errorf("backup: open archive: %w", err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("backup: read archive: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("backup: read archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		if name := path.Clean(hdr.Name); name != hdr.Name || strings.HasPrefix(name, "../") || path.IsAbs(name) {
+			return fmt.Errorf("backup: invalid archive path %q", hdr.Name)
+		}
+		if err := fn(hdr, tr); err != nil {
+			return err
+		}
+	}
+}
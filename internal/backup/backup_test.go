@@ -0,0 +1,164 @@
+package backup_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cnap-oss/app/internal/backup"
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func openTestDB(t *testing.T) (*gorm.DB, *storage.Repository) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "cnap.db")), &gorm.Config{})
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, storage.Close(db)) })
+	require.NoError(t, storage.MigrateUp(context.Background(), db))
+	repo, err := storage.NewRepository(db)
+	require.NoError(t, err)
+	return db, repo
+}
+
+func TestBackupCreateAndRestore(t *testing.T) {
+	ctx := context.Background()
+	srcDB, src := openTestDB(t)
+	srcStore, err := msgstore.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, src.CreateAgent(ctx, &storage.Agent{AgentID: "bot", Model: "gpt-4", Status: storage.AgentStatusActive}))
+	require.NoError(t, src.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "bot", Prompt: "환불 문의", Status: storage.TaskStatusPending}))
+	key := msgstore.NewKey("task-1", time.Unix(0, 1))
+	require.NoError(t, srcStore.Put(ctx, key, &msgstore.Message{TaskID: "task-1", Role: storage.MessageRoleUser, Content: "hello"}))
+	_, err = src.AppendMessageIndex(ctx, "task-1", storage.MessageRoleUser, key)
+	require.NoError(t, err)
+	_, err = src.AppendMessageIndex(ctx, "task-1", storage.MessageRoleAssistant, "messages/task-1/missing.json")
+	require.NoError(t, err)
+	require.NoError(t, src.CreateAuditEvent(ctx, &storage.AuditEvent{Actor: "cli:alice", Action: storage.AuditActionAgentCreate, TargetType: storage.AuditTargetAgent, TargetID: "bot"}))
+
+	archive := filepath.Join(t.TempDir(), "cnap.tgz")
+	f, err := os.Create(archive)
+	require.NoError(t, err)
+	manifest, err := backup.Create(ctx, srcDB, srcStore, f, backup.CreateOptions{AppVersion: "test"})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	require.Equal(t, "sqlite", manifest.Dialect)
+	require.Len(t, manifest.Messages, 1)
+	require.Equal(t, []string{"messages/task-1/missing.json"}, manifest.MissingMessages)
+
+	verified, err := backup.Verify(archive)
+	require.NoError(t, err)
+	require.Equal(t, manifest.Rows(), verified.Rows())
+
+	// 비어 있는 다른 데이터베이스와 저장소에 복원합니다.
+	dstDB, dst := openTestDB(t)
+	dstStore, err := msgstore.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+	_, err = backup.Restore(ctx, dstDB, dstStore, archive, backup.RestoreOptions{})
+	require.NoError(t, err)
+
+	srcTask, err := src.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	dstTask, err := dst.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, srcTask.ID, dstTask.ID)
+	require.True(t, srcTask.CreatedAt.Equal(dstTask.CreatedAt))
+	require.Equal(t, srcTask.NextMessageIndex, dstTask.NextMessageIndex)
+
+	msg, err := dstStore.Get(ctx, key)
+	require.NoError(t, err)
+	require.Equal(t, "hello", msg.Content)
+	docs, err := dst.SearchTasks(ctx, storage.SearchFilter{Query: "환불"})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	events, err := dst.ListAuditEvents(ctx, storage.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+
+	// 복원 후에도 새 행은 이어지는 ID를 받습니다.
+	require.NoError(t, dst.CreateTask(ctx, &storage.Task{TaskID: "task-2", AgentID: "bot", Status: storage.TaskStatusPending}))
+
+	// 데이터가 있으면 --force 없이는 복원하지 않습니다.
+	_, err = backup.Restore(ctx, dstDB, dstStore, archive, backup.RestoreOptions{})
+	require.ErrorIs(t, err, backup.ErrNotEmpty)
+	_, err = backup.Restore(ctx, dstDB, dstStore, archive, backup.RestoreOptions{Force: true})
+	require.NoError(t, err)
+	_, err = dst.GetTask(ctx, "task-2")
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestBackupVerifyDetectsTampering(t *testing.T) {
+	ctx := context.Background()
+	db, repo := openTestDB(t)
+	store, err := msgstore.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, repo.CreateAgent(ctx, &storage.Agent{AgentID: "bot", Model: "gpt-4", Status: storage.AgentStatusActive}))
+
+	var buf bytes.Buffer
+	_, err = backup.Create(ctx, db, store, &buf, backup.CreateOptions{})
+	require.NoError(t, err)
+
+	// agents 테이블 파일의 내용을 바꾼 아카이브를 만듭니다.
+	tampered := filepath.Join(t.TempDir(), "tampered.tgz")
+	rewriteArchive(t, buf.Bytes(), tampered, func(name string, data []byte) []byte {
+		if name == "database/agents.jsonl" {
+			return bytes.Replace(data, []byte("gpt-4"), []byte("gpt-5"), 1)
+		}
+		return data
+	})
+	_, err = backup.Verify(tampered)
+	require.ErrorIs(t, err, backup.ErrChecksumMismatch)
+	_, err = backup.Restore(ctx, db, store, tampered, backup.RestoreOptions{Force: true})
+	require.ErrorIs(t, err, backup.ErrChecksumMismatch)
+
+	// 스키마 버전이 다른 아카이브는 복원하지 않습니다.
+	older := filepath.Join(t.TempDir(), "older.tgz")
+	rewriteArchive(t, buf.Bytes(), older, func(name string, data []byte) []byte {
+		if name == "manifest.json" {
+			return bytes.Replace(data, []byte(`"schema_version": `), []byte(`"schema_version": 1`), 1)
+		}
+		return data
+	})
+	_, err = backup.Restore(ctx, db, store, older, backup.RestoreOptions{Force: true})
+	require.ErrorIs(t, err, storage.ErrSchemaVersionMismatch)
+}
+
+// rewriteArchive는 src 아카이브의 파일 내용을 edit으로 바꿔 dst에 씁니다.
+func rewriteArchive(t *testing.T, src []byte, dst string, edit func(name string, data []byte) []byte) {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(src))
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+
+	out, err := os.Create(dst)
+	require.NoError(t, err)
+	defer out.Close()
+	gw := gzip.NewWriter(out)
+	tw := tar.NewWriter(gw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		data = edit(hdr.Name, data)
+		hdr.Size = int64(len(data))
+		require.NoError(t, tw.WriteHeader(hdr))
+		_, err = tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gw.Close())
+}
@@ -0,0 +1,525 @@
+// Package config는 CNAP 실행 설정을 하나의 타입으로 로드하고 검증합니다.
+//
+// 우선순위는 낮은 것부터 기본값 → YAML 설정 파일 → 환경 변수 → 명령행 플래그입니다.
+// 잘못된 값은 기본값으로 대체하지 않고 어떤 키가 잘못되었는지 알려주는 에러를 반환합니다.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvConfigPath는 --config 플래그가 없을 때 설정 파일 경로를 지정하는 환경 변수입니다.
+const EnvConfigPath = "CNAP_CONFIG"
+
+// maskedValue는 config print에서 비밀 값을 대신해 출력하는 문자열입니다.
+const maskedValue = "xxxxx"
+
+// Config는 CNAP 실행 설정입니다.
+type Config struct {
+	Log       LogConfig       `yaml:"log"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Discord   DiscordConfig   `yaml:"discord"`
+	Provider  ProviderConfig  `yaml:"provider"`
+	Messages  MessagesConfig  `yaml:"messages"`
+	Metrics   MetricsConfig   `yaml:"metrics"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Retention RetentionConfig `yaml:"retention"`
+}
+
+// LogConfig는 애플리케이션 로그 설정입니다.
+type LogConfig struct {
+	// Level은 debug, info, warn, error 중 하나입니다. (LOG_LEVEL)
+	// 비어 있으면 ENV에 따라 development는 debug, production은 info를 사용합니다.
+	Level string `yaml:"level"`
+}
+
+// DatabaseConfig는 데이터베이스 연결 설정입니다.
+type DatabaseConfig struct {
+	// URL은 PostgreSQL 또는 SQLite DSN입니다. 비어 있으면 SQLitePath를 사용합니다. (DATABASE_URL)
+	URL string `yaml:"url"`
+	// SQLitePath는 URL이 없을 때 사용하는 SQLite 파일 경로입니다. (SQLITE_DATABASE)
+	SQLitePath string `yaml:"sqlite_path"`
+	// LogLevel은 GORM 로그 레벨(silent, error, warn, info)입니다. (DB_LOG_LEVEL)
+	LogLevel        string        `yaml:"log_level"`
+	MaxIdleConns    int           `yaml:"max_idle_conns"`    // DB_MAX_IDLE
+	MaxOpenConns    int           `yaml:"max_open_conns"`    // DB_MAX_OPEN
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // DB_CONN_LIFETIME
+	SkipDefaultTxn  bool          `yaml:"skip_default_txn"`  // DB_SKIP_DEFAULT_TXN
+	PrepareStmt     bool          `yaml:"prepare_stmt"`      // DB_PREPARE_STMT
+	DisableAutoPing bool          `yaml:"disable_auto_ping"` // DB_DISABLE_AUTO_PING
+}
+
+// DiscordConfig는 Discord 봇 설정입니다.
+type DiscordConfig struct {
+	Token           string `yaml:"token"`            // DISCORD_TOKEN (비밀 값)
+	PermissionsFile string `yaml:"permissions_file"` // DISCORD_PERMISSIONS_FILE
+}
+
+// ProviderConfig는 LLM 제공자 설정입니다.
+type ProviderConfig struct {
+	APIKey string `yaml:"api_key"` // OPEN_CODE_API_KEY (비밀 값)
+}
+
+// 메시지 본문 저장소 종류입니다.
+const (
+	MessageStoreFS = "fs"
+	MessageStoreS3 = "s3"
+)
+
+// MessagesConfig는 메시지 본문 저장소 설정입니다.
+type MessagesConfig struct {
+	// Store는 fs 또는 s3입니다. (MESSAGE_STORE)
+	Store string `yaml:"store"`
+	// Dir은 fs 저장소의 루트 디렉터리입니다. (MESSAGE_DIR)
+	Dir string   `yaml:"dir"`
+	S3  S3Config `yaml:"s3"`
+}
+
+// S3Config는 S3 호환 메시지 저장소 설정입니다.
+type S3Config struct {
+	Endpoint        string `yaml:"endpoint"`          // S3_ENDPOINT
+	Region          string `yaml:"region"`            // S3_REGION
+	Bucket          string `yaml:"bucket"`            // S3_BUCKET
+	Prefix          string `yaml:"prefix"`            // S3_PREFIX
+	AccessKeyID     string `yaml:"access_key_id"`     // S3_ACCESS_KEY_ID
+	SecretAccessKey string `yaml:"secret_access_key"` // S3_SECRET_ACCESS_KEY (비밀 값)
+}
+
+// MetricsConfig는 메트릭/헬스 체크 HTTP 서버 설정입니다.
+type MetricsConfig struct {
+	// Port가 0이면 서버를 시작하지 않습니다. (METRICS_PORT)
+	Port int `yaml:"port"`
+}
+
+// TracingConfig는 OpenTelemetry 트레이싱 설정입니다.
+// OTLP 엔드포인트는 OTEL_EXPORTER_OTLP_ENDPOINT 등 표준 환경 변수를 따릅니다.
+type TracingConfig struct {
+	Exporter    string `yaml:"exporter"`     // OTEL_TRACES_EXPORTER
+	File        string `yaml:"file"`         // TRACE_FILE
+	ServiceName string `yaml:"service_name"` // OTEL_SERVICE_NAME
+}
+
+// RetentionConfig는 작업과 메시지 데이터 보존 정책입니다.
+type RetentionConfig struct {
+	// Interval은 controller가 보존 정책을 적용하는 주기입니다. 0이면 백그라운드 정리를 하지 않습니다. (RETENTION_INTERVAL)
+	Interval time.Duration `yaml:"interval"`
+	// Rules가 비어 있으면 아무것도 정리하지 않습니다.
+	Rules []RetentionRuleConfig `yaml:"rules"`
+}
+
+// RetentionRuleConfig는 보존 규칙 하나입니다. older_than과 keep_last 중 하나 이상을 지정해야 하며,
+// 둘 다 지정하면 두 조건을 모두 만족하는 작업만 정리합니다.
+type RetentionRuleConfig struct {
+	// Agent가 비어 있으면 에이전트별 규칙이 없는 모든 에이전트에 적용되는 전역 규칙입니다.
+	Agent string `yaml:"agent,omitempty"`
+	// Statuses는 정리할 작업 상태입니다. 비어 있으면 completed, failed, canceled입니다.
+	Statuses []string `yaml:"statuses,omitempty"`
+	// OlderThan은 작업이 마지막으로 갱신된 뒤 지나야 하는 기간입니다. (예: 90d, 2w, 12h)
+	OlderThan string `yaml:"older_than,omitempty"`
+	// KeepLast는 에이전트마다 남겨 둘 최근 작업 수입니다.
+	KeepLast int `yaml:"keep_last,omitempty"`
+	// Labels는 규칙을 적용할 작업의 레이블 셀렉터입니다. (예: "team=infra,env!=prod")
+	Labels string `yaml:"labels,omitempty"`
+}
+
+// Default는 설정 파일과 환경 변수가 없을 때의 기본 설정을 반환합니다.
+func Default() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			SQLitePath:      storage.DefaultSQLitePath,
+			LogLevel:        "warn",
+			MaxIdleConns:    5,
+			MaxOpenConns:    20,
+			ConnMaxLifetime: 30 * time.Minute,
+			SkipDefaultTxn:  true,
+		},
+		Messages: MessagesConfig{
+			Store: MessageStoreFS,
+			Dir:   "./data",
+		},
+		Metrics: MetricsConfig{Port: 9090},
+		Tracing: TracingConfig{
+			Exporter:    tracing.ExporterNone,
+			File:        "./data/traces.json",
+			ServiceName: "cnap",
+		},
+		Retention: RetentionConfig{Interval: time.Hour},
+	}
+}
+
+// LookupFunc는 환경 변수 조회 함수입니다. 보통 os.LookupEnv를 사용합니다.
+type LookupFunc func(key string) (string, bool)
+
+// Load는 기본값 위에 path의 YAML 파일과 환경 변수를 차례로 적용하고 검증합니다.
+// path가 비어 있으면 설정 파일 없이 기본값과 환경 변수만 사용합니다.
+// 플래그는 호출자가 결과에 덮어쓴 뒤 Validate를 다시 호출해야 합니다.
+func Load(path string, lookup LookupFunc) (*Config, error) {
+	cfg := Default()
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := cfg.applyEnv(lookup); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile은 YAML 파일을 읽어 cfg에 덮어씁니다. 알 수 없는 키는 에러입니다.
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	// 빈 파일(io.EOF)은 기본값을 그대로 사용합니다.
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv는 설정된 환경 변수를 cfg에 덮어씁니다. 해석할 수 없는 값은 에러입니다.
+func (c *Config) applyEnv(lookup LookupFunc) error {
+	if lookup == nil {
+		return nil
+	}
+	e := envReader{lookup: lookup}
+
+	e.str("LOG_LEVEL", &c.Log.Level)
+
+	e.str("DATABASE_URL", &c.Database.URL)
+	e.str("SQLITE_DATABASE", &c.Database.SQLitePath)
+	e.str("DB_LOG_LEVEL", &c.Database.LogLevel)
+	e.int("DB_MAX_IDLE", &c.Database.MaxIdleConns)
+	e.int("DB_MAX_OPEN", &c.Database.MaxOpenConns)
+	e.duration("DB_CONN_LIFETIME", &c.Database.ConnMaxLifetime)
+	e.bool("DB_SKIP_DEFAULT_TXN", &c.Database.SkipDefaultTxn)
+	e.bool("DB_PREPARE_STMT", &c.Database.PrepareStmt)
+	e.bool("DB_DISABLE_AUTO_PING", &c.Database.DisableAutoPing)
+
+	e.str("DISCORD_TOKEN", &c.Discord.Token)
+	e.str("DISCORD_PERMISSIONS_FILE", &c.Discord.PermissionsFile)
+
+	e.str("OPEN_CODE_API_KEY", &c.Provider.APIKey)
+
+	e.str("MESSAGE_STORE", &c.Messages.Store)
+	e.str("MESSAGE_DIR", &c.Messages.Dir)
+	e.str("S3_ENDPOINT", &c.Messages.S3.Endpoint)
+	e.str("S3_REGION", &c.Messages.S3.Region)
+	e.str("S3_BUCKET", &c.Messages.S3.Bucket)
+	e.str("S3_PREFIX", &c.Messages.S3.Prefix)
+	e.str("S3_ACCESS_KEY_ID", &c.Messages.S3.AccessKeyID)
+	e.str("S3_SECRET_ACCESS_KEY", &c.Messages.S3.SecretAccessKey)
+
+	e.int("METRICS_PORT", &c.Metrics.Port)
+
+	e.str("OTEL_TRACES_EXPORTER", &c.Tracing.Exporter)
+	e.str("TRACE_FILE", &c.Tracing.File)
+	e.str("OTEL_SERVICE_NAME", &c.Tracing.ServiceName)
+
+	e.duration("RETENTION_INTERVAL", &c.Retention.Interval)
+
+	return errors.Join(e.errs...)
+}
+
+// Validate는 설정 값의 범위와 허용 값을 검사합니다.
+// 여러 문제가 있으면 모두 모아서 반환합니다.
+func (c *Config) Validate() error {
+	var errs []error
+	if _, err := zapcore.ParseLevel(c.Log.Level); c.Log.Level != "" && err != nil {
+		errs = append(errs, fmt.Errorf("log.level: unknown level %q (expected debug, info, warn or error)", c.Log.Level))
+	}
+
+	if c.Database.URL == "" && c.Database.SQLitePath == "" {
+		errs = append(errs, fmt.Errorf("database: either url or sqlite_path must be set"))
+	}
+	if _, err := storage.ParseLogLevel(c.Database.LogLevel); err != nil {
+		errs = append(errs, fmt.Errorf("database.log_level: %w", err))
+	}
+	if c.Database.MaxIdleConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_idle_conns: must not be negative, got %d", c.Database.MaxIdleConns))
+	}
+	if c.Database.MaxOpenConns < 0 {
+		errs = append(errs, fmt.Errorf("database.max_open_conns: must not be negative, got %d", c.Database.MaxOpenConns))
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		errs = append(errs, fmt.Errorf("database.conn_max_lifetime: must not be negative, got %s", c.Database.ConnMaxLifetime))
+	}
+
+	switch c.Messages.Store {
+	case MessageStoreFS:
+		if c.Messages.Dir == "" {
+			errs = append(errs, fmt.Errorf("messages.dir: required when messages.store is %q", MessageStoreFS))
+		}
+	case MessageStoreS3:
+		s3 := c.Messages.S3
+		for key, value := range map[string]string{
+			"endpoint":          s3.Endpoint,
+			"bucket":            s3.Bucket,
+			"access_key_id":     s3.AccessKeyID,
+			"secret_access_key": s3.SecretAccessKey,
+		} {
+			if value == "" {
+				errs = append(errs, fmt.Errorf("messages.s3.%s: required when messages.store is %q", key, MessageStoreS3))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("messages.store: unknown store %q (expected fs or s3)", c.Messages.Store))
+	}
+
+	if c.Metrics.Port < 0 || c.Metrics.Port > 65535 {
+		errs = append(errs, fmt.Errorf("metrics.port: must be between 0 and 65535, got %d", c.Metrics.Port))
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterOTLP:
+	case tracing.ExporterFile:
+		if c.Tracing.File == "" {
+			errs = append(errs, fmt.Errorf("tracing.file: required when tracing.exporter is %q", tracing.ExporterFile))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: unknown exporter %q (expected none, otlp or file)", c.Tracing.Exporter))
+	}
+
+	if c.Retention.Interval < 0 {
+		errs = append(errs, fmt.Errorf("retention.interval: must not be negative, got %s", c.Retention.Interval))
+	}
+	for i, rule := range c.Retention.Rules {
+		key := fmt.Sprintf("retention.rules[%d]", i)
+		if rule.OlderThan == "" && rule.KeepLast == 0 {
+			errs = append(errs, fmt.Errorf("%s: either older_than or keep_last must be set", key))
+		}
+		if rule.OlderThan != "" {
+			if age, err := parseAge(rule.OlderThan); err != nil || age <= 0 {
+				errs = append(errs, fmt.Errorf("%s.older_than: invalid duration %q (e.g. 90d, 2w, 12h)", key, rule.OlderThan))
+			}
+		}
+		if rule.KeepLast < 0 {
+			errs = append(errs, fmt.Errorf("%s.keep_last: must not be negative, got %d", key, rule.KeepLast))
+		}
+		for _, status := range rule.Statuses {
+			if !slices.Contains(storage.TerminalTaskStatuses, status) {
+				errs = append(errs, fmt.Errorf("%s.statuses: %q is not a finished status (expected completed, failed or canceled)", key, status))
+			}
+		}
+		if _, err := storage.ParseLabelSelector(rule.Labels); err != nil {
+			errs = append(errs, fmt.Errorf("%s.labels: %w", key, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// StorageConfig는 데이터베이스 설정을 storage.Config로 변환합니다.
+func (c *Config) StorageConfig() storage.Config {
+	dsn := c.Database.URL
+	if dsn == "" {
+		dsn = c.Database.SQLitePath
+	}
+	level, _ := storage.ParseLogLevel(c.Database.LogLevel)
+	return storage.Config{
+		DSN:                  dsn,
+		LogLevel:             level,
+		MaxIdleConns:         c.Database.MaxIdleConns,
+		MaxOpenConns:         c.Database.MaxOpenConns,
+		ConnMaxLifetime:      c.Database.ConnMaxLifetime,
+		SkipDefaultTxn:       c.Database.SkipDefaultTxn,
+		PrepareStmt:          c.Database.PrepareStmt,
+		DisableAutomaticPing: c.Database.DisableAutoPing,
+	}
+}
+
+// TracingConfig는 트레이싱 설정을 tracing.Config로 변환합니다.
+func (c *Config) TracingConfig() tracing.Config {
+	return tracing.Config{
+		Exporter:    c.Tracing.Exporter,
+		FilePath:    c.Tracing.File,
+		ServiceName: c.Tracing.ServiceName,
+	}
+}
+
+// RetentionRules는 보존 규칙을 storage.RetentionRule로 변환합니다. Validate를 통과한 설정이어야 합니다.
+func (c *Config) RetentionRules() []storage.RetentionRule {
+	rules := make([]storage.RetentionRule, 0, len(c.Retention.Rules))
+	for _, rule := range c.Retention.Rules {
+		var age time.Duration
+		if rule.OlderThan != "" {
+			age, _ = parseAge(rule.OlderThan)
+		}
+		selector, _ := storage.ParseLabelSelector(rule.Labels)
+		rules = append(rules, storage.RetentionRule{
+			AgentID:   rule.Agent,
+			Statuses:  rule.Statuses,
+			OlderThan: age,
+			KeepLast:  rule.KeepLast,
+			Labels:    selector,
+		})
+	}
+	return rules
+}
+
+// OpenMessageStore는 설정에 맞는 메시지 본문 저장소를 생성합니다.
+func (c *Config) OpenMessageStore() (msgstore.MessageStore, error) {
+	if c.Messages.Store == MessageStoreS3 {
+		s3 := c.Messages.S3
+		return msgstore.NewS3Store(msgstore.S3Config{
+			Endpoint:        s3.Endpoint,
+			Region:          s3.Region,
+			Bucket:          s3.Bucket,
+			Prefix:          s3.Prefix,
+			AccessKeyID:     s3.AccessKeyID,
+			SecretAccessKey: s3.SecretAccessKey,
+		})
+	}
+	return msgstore.NewFSStore(c.Messages.Dir)
+}
+
+// Masked는 비밀 값을 가린 복사본을 반환합니다.
+// DATABASE_URL에 포함된 비밀번호도 가립니다.
+func (c *Config) Masked() *Config {
+	masked := *c
+	if masked.Discord.Token != "" {
+		masked.Discord.Token = maskedValue
+	}
+	if masked.Provider.APIKey != "" {
+		masked.Provider.APIKey = maskedValue
+	}
+	if masked.Messages.S3.SecretAccessKey != "" {
+		masked.Messages.S3.SecretAccessKey = maskedValue
+	}
+	masked.Database.URL = maskDSN(masked.Database.URL)
+	return &masked
+}
+
+// YAML은 설정을 YAML 문서로 직렬화합니다.
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// maskDSN은 URL 형식 DSN의 비밀번호와 password 파라미터를 가립니다.
+func maskDSN(dsn string) string {
+	if dsn == "" {
+		return dsn
+	}
+	u, err := url.Parse(dsn)
+	if err != nil || u.Scheme == "" {
+		// key=value 형식 DSN은 password 항목만 가립니다.
+		fields := strings.Fields(dsn)
+		for i, field := range fields {
+			if strings.HasPrefix(strings.ToLower(field), "password=") {
+				fields[i] = "password=" + maskedValue
+			}
+		}
+		return strings.Join(fields, " ")
+	}
+	q := u.Query()
+	if q.Has("password") {
+		q.Set("password", maskedValue)
+		u.RawQuery = q.Encode()
+	}
+	// Redacted는 사용자 정보의 비밀번호를 maskedValue와 같은 "xxxxx"로 바꿉니다.
+	return u.Redacted()
+}
+
+// parseAge는 time.ParseDuration 형식에 더해 일(d)과 주(w) 단위를 해석합니다.
+func parseAge(value string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, ok := strings.CutSuffix(value, suffix); ok {
+			count, err := strconv.Atoi(n)
+			if err != nil {
+				return 0, err
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+	return time.ParseDuration(value)
+}
+
+// envReader는 환경 변수를 타입에 맞게 해석하고 에러를 모읍니다.
+type envReader struct {
+	lookup LookupFunc
+	errs   []error
+}
+
+func (e *envReader) get(key string) (string, bool) {
+	value, ok := e.lookup(key)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimSpace(value), true
+}
+
+func (e *envReader) str(key string, dst *string) {
+	if value, ok := e.get(key); ok && value != "" {
+		*dst = value
+	}
+}
+
+func (e *envReader) int(key string, dst *int) {
+	value, ok := e.get(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: invalid integer %q", key, value))
+		return
+	}
+	*dst = parsed
+}
+
+func (e *envReader) bool(key string, dst *bool) {
+	value, ok := e.get(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: invalid boolean %q", key, value))
+		return
+	}
+	*dst = parsed
+}
+
+func (e *envReader) duration(key string, dst *time.Duration) {
+	value, ok := e.get(key)
+	if !ok || value == "" {
+		return
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		e.errs = append(e.errs, fmt.Errorf("%s: invalid duration %q (e.g. 30m, 1h)", key, value))
+		return
+	}
+	*dst = parsed
+}
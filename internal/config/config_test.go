@@ -0,0 +1,144 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cnap-oss/app/internal/config"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func envLookup(values map[string]string) config.LookupFunc {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "cnap.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfig(t, `
+database:
+  url: postgres://cnap:file-secret@db:5432/cnap
+  max_open_conns: 50
+  conn_max_lifetime: 1h
+metrics:
+  port: 9100
+discord:
+  permissions_file: /etc/cnap/perms.yaml
+`)
+
+	cfg, err := config.Load(path, envLookup(map[string]string{
+		"METRICS_PORT":  "9200",
+		"DISCORD_TOKEN": "token-from-env",
+	}))
+	require.NoError(t, err)
+
+	// 파일이 기본값을 덮어씁니다.
+	require.Equal(t, 50, cfg.Database.MaxOpenConns)
+	require.Equal(t, time.Hour, cfg.Database.ConnMaxLifetime)
+	require.Equal(t, "/etc/cnap/perms.yaml", cfg.Discord.PermissionsFile)
+	// 파일에 없는 값은 기본값을 유지합니다.
+	require.Equal(t, 5, cfg.Database.MaxIdleConns)
+	// 환경 변수가 파일을 덮어씁니다.
+	require.Equal(t, 9200, cfg.Metrics.Port)
+	require.Equal(t, "token-from-env", cfg.Discord.Token)
+
+	storageCfg := cfg.StorageConfig()
+	require.Equal(t, "postgres://cnap:file-secret@db:5432/cnap", storageCfg.DSN)
+}
+
+func TestLoadRejectsInvalidValues(t *testing.T) {
+	_, err := config.Load(writeConfig(t, "metric:\n  port: 1\n"), nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "field metric not found")
+
+	_, err = config.Load("", envLookup(map[string]string{"DB_CONN_LIFETIME": "forever"}))
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "DB_CONN_LIFETIME")
+
+	_, err = config.Load("", envLookup(map[string]string{
+		"METRICS_PORT":         "70000",
+		"OTEL_TRACES_EXPORTER": "jaeger",
+		"DB_LOG_LEVEL":         "loud",
+		"MESSAGE_STORE":        "s3",
+		"S3_ENDPOINT":          "http://minio:9000",
+	}))
+	require.Error(t, err)
+	for _, key := range []string{"metrics.port", "tracing.exporter", "database.log_level", "messages.s3.bucket"} {
+		require.Contains(t, err.Error(), key)
+	}
+
+	_, err = config.Load(filepath.Join(t.TempDir(), "missing.yaml"), nil)
+	require.Error(t, err)
+}
+
+func TestRetentionRules(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, `
+retention:
+  rules:
+    - older_than: 90d
+    - agent: support
+      statuses: [failed]
+      older_than: 12h
+      keep_last: 20
+    - labels: "ticket,env!=prod"
+      keep_last: 5
+`), envLookup(map[string]string{"RETENTION_INTERVAL": "15m"}))
+	require.NoError(t, err)
+	require.Equal(t, 15*time.Minute, cfg.Retention.Interval)
+	require.Equal(t, []storage.RetentionRule{
+		{OlderThan: 90 * 24 * time.Hour},
+		{AgentID: "support", Statuses: []string{"failed"}, OlderThan: 12 * time.Hour, KeepLast: 20},
+		{KeepLast: 5, Labels: storage.LabelSelector{
+			{Key: "ticket", Operator: storage.LabelOpExists},
+			{Key: "env", Operator: storage.LabelOpNotEquals, Value: "prod"},
+		}},
+	}, cfg.RetentionRules())
+
+	_, err = config.Load(writeConfig(t, `
+retention:
+  interval: -1m
+  rules:
+    - agent: support
+    - older_than: 3 months
+    - statuses: [running]
+      keep_last: -1
+    - keep_last: 1
+      labels: "team=a b"
+`), nil)
+	require.Error(t, err)
+	for _, key := range []string{"retention.interval", "retention.rules[0]", "retention.rules[1].older_than", "retention.rules[2].statuses", "retention.rules[2].keep_last", "retention.rules[3].labels"} {
+		require.Contains(t, err.Error(), key)
+	}
+}
+
+func TestMaskedHidesSecrets(t *testing.T) {
+	cfg, err := config.Load(writeConfig(t, ""), envLookup(map[string]string{
+		"DATABASE_URL":         "postgres://cnap:db-secret@db:5432/cnap",
+		"DISCORD_TOKEN":        "discord-secret",
+		"OPEN_CODE_API_KEY":    "api-secret",
+		"S3_SECRET_ACCESS_KEY": "s3-secret",
+	}))
+	require.NoError(t, err)
+
+	out, err := cfg.Masked().YAML()
+	require.NoError(t, err)
+	for _, secret := range []string{"db-secret", "discord-secret", "api-secret", "s3-secret"} {
+		require.False(t, strings.Contains(string(out), secret), "secret %q leaked", secret)
+	}
+	require.Contains(t, string(out), "postgres://cnap:xxxxx@db:5432/cnap")
+
+	// 원본은 변경되지 않습니다.
+	require.Equal(t, "discord-secret", cfg.Discord.Token)
+}
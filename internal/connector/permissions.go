@@ -0,0 +1,278 @@
+package connector
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cnap-oss/app/internal/controller"
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// Role은 Discord 사용자가 CNAP 명령어에 대해 가지는 권한 수준입니다.
+type Role int
+
+// 권한 수준은 높은 값이 낮은 값의 권한을 모두 포함합니다.
+const (
+	RoleNone Role = iota
+	RoleViewer
+	RoleUser
+	RoleAdmin
+)
+
+// String은 권한 수준의 이름을 반환합니다.
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleUser:
+		return "user"
+	case RoleAdmin:
+		return "admin"
+	default:
+		return "none"
+	}
+}
+
+// ParseRole은 설정 파일의 권한 이름을 Role로 변환합니다.
+func ParseRole(value string) (Role, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "none":
+		return RoleNone, nil
+	case "viewer":
+		return RoleViewer, nil
+	case "user":
+		return RoleUser, nil
+	case "admin":
+		return RoleAdmin, nil
+	default:
+		return RoleNone, fmt.Errorf("unknown role: %q (expected none, viewer, user or admin)", value)
+	}
+}
+
+// GuildPermissions는 한 길드에서 각 권한 수준에 매핑되는 Discord 역할 ID 또는 사용자 ID 목록입니다.
+type GuildPermissions struct {
+	DefaultRole string   `yaml:"default_role"`
+	Admins      []string `yaml:"admins"`
+	Users       []string `yaml:"users"`
+	Viewers     []string `yaml:"viewers"`
+}
+
+// DefaultRole은 권한 파일이 없거나 default_role을 지정하지 않았을 때 매핑되지 않은 사용자가 받는 권한입니다.
+const DefaultRole = RoleUser
+
+// PermissionConfig는 길드별 권한 매핑을 담습니다.
+// 매핑되지 않은 사용자는 길드의 default_role, 없으면 전역 default_role, 그것도 없으면 DefaultRole을 받습니다.
+type PermissionConfig struct {
+	DefaultRole string                      `yaml:"default_role"`
+	Guilds      map[string]GuildPermissions `yaml:"guilds"`
+}
+
+// DefaultPermissionConfig는 권한 파일이 없을 때 사용하는 설정을 반환합니다.
+// 모든 멤버는 DefaultRole(user) 권한을 받아 자신이 만든 에이전트만 수정할 수 있습니다.
+func DefaultPermissionConfig() *PermissionConfig {
+	return &PermissionConfig{DefaultRole: DefaultRole.String()}
+}
+
+// LoadPermissionConfig는 YAML 권한 파일을 읽고 검증합니다.
+func LoadPermissionConfig(path string) (*PermissionConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read permission file: %w", err)
+	}
+
+	cfg := &PermissionConfig{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse permission file %s: %w", path, err)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid permission file %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Validate는 권한 이름이 올바른지 검사합니다.
+func (p *PermissionConfig) Validate() error {
+	if p.DefaultRole != "" {
+		if _, err := ParseRole(p.DefaultRole); err != nil {
+			return fmt.Errorf("default_role: %w", err)
+		}
+	}
+	for guildID, guild := range p.Guilds {
+		if guild.DefaultRole != "" {
+			if _, err := ParseRole(guild.DefaultRole); err != nil {
+				return fmt.Errorf("guilds.%s.default_role: %w", guildID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// RoleOf는 길드 멤버의 사용자 ID와 Discord 역할 ID 목록으로 권한 수준을 계산합니다.
+// 여러 매핑에 해당하면 가장 높은 권한을 반환합니다.
+func (p *PermissionConfig) RoleOf(guildID, userID string, roleIDs []string) Role {
+	ids := make(map[string]struct{}, len(roleIDs)+1)
+	ids[userID] = struct{}{}
+	for _, id := range roleIDs {
+		ids[id] = struct{}{}
+	}
+	matches := func(list []string) bool {
+		for _, id := range list {
+			if _, ok := ids[id]; ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	defaultRole := p.DefaultRole
+	if guild, ok := p.Guilds[guildID]; ok {
+		switch {
+		case matches(guild.Admins):
+			return RoleAdmin
+		case matches(guild.Users):
+			return RoleUser
+		case matches(guild.Viewers):
+			return RoleViewer
+		}
+		if guild.DefaultRole != "" {
+			defaultRole = guild.DefaultRole
+		}
+	}
+
+	if defaultRole == "" {
+		return DefaultRole
+	}
+	role, err := ParseRole(defaultRole)
+	if err != nil {
+		return RoleNone
+	}
+	return role
+}
+
+// roleForMember는 길드 멤버의 권한 수준을 계산합니다. 상호작용과 스레드 메시지가 같은 규칙을 쓰도록
+// 권한 매핑보다 Discord 서버 관리자(Administrator) 권한을 먼저 확인해 항상 admin으로 취급합니다.
+func (s *Server) roleForMember(guildID, userID string, roleIDs []string, perms int64) Role {
+	if perms&discordgo.PermissionAdministrator != 0 {
+		return RoleAdmin
+	}
+	return s.permissions.RoleOf(guildID, userID, roleIDs)
+}
+
+// roleForInteraction은 상호작용을 보낸 사용자의 권한 수준을 계산합니다.
+func (s *Server) roleForInteraction(i *discordgo.InteractionCreate) Role {
+	if i.Member == nil {
+		// DM에서는 길드 매핑을 적용할 수 없으므로 전역 기본 권한을 사용합니다.
+		if i.User == nil {
+			return RoleNone
+		}
+		return s.roleForMember("", i.User.ID, nil, 0)
+	}
+	return s.roleForMember(i.GuildID, i.Member.User.ID, i.Member.Roles, i.Member.Permissions)
+}
+
+// roleForMessage는 스레드 메시지 작성자의 권한 수준을 계산합니다.
+// MESSAGE_CREATE 이벤트에는 멤버의 권한 값이 없으므로 세션 상태의 길드 역할로 계산합니다.
+func (s *Server) roleForMessage(m *discordgo.Message) Role {
+	var roleIDs []string
+	var perms int64
+	if m.Member != nil {
+		roleIDs = m.Member.Roles
+		p, err := s.session.State.MessagePermissions(m)
+		if err != nil {
+			s.logger.Debug("Failed to compute message author permissions from state",
+				zap.Error(err),
+				zap.String("user_id", m.Author.ID),
+				zap.String("channel_id", m.ChannelID),
+			)
+		}
+		perms = p
+	}
+	return s.roleForMember(m.GuildID, m.Author.ID, roleIDs, perms)
+}
+
+// interactionUserID는 상호작용을 보낸 사용자의 ID를 반환합니다.
+func interactionUserID(i *discordgo.InteractionCreate) string {
+	if i.Member != nil && i.Member.User != nil {
+		return i.Member.User.ID
+	}
+	if i.User != nil {
+		return i.User.ID
+	}
+	return ""
+}
+
+// requireRole은 사용자가 최소 권한을 갖는지 확인하고, 부족하면 임시 메시지로 알립니다.
+func (s *Server) requireRole(i *discordgo.InteractionCreate, required Role, action string) bool {
+	role := s.roleForInteraction(i)
+	if role >= required {
+		return true
+	}
+	s.logger.Info("Permission denied",
+		zap.String("user_id", interactionUserID(i)),
+		zap.String("guild_id", i.GuildID),
+		zap.String("action", action),
+		zap.String("role", role.String()),
+		zap.String("required", required.String()),
+	)
+	s.respondEphemeral(i, fmt.Sprintf("권한이 없어요: '%s' 작업에는 **%s** 이상의 권한이 필요해요. (현재 권한: %s)", action, required, role))
+	return false
+}
+
+// requireAgentModify는 에이전트를 수정/삭제할 수 있는지 확인합니다.
+// user 권한을 가진 에이전트 소유자 또는 admin만 허용됩니다.
+func (s *Server) requireAgentModify(i *discordgo.InteractionCreate, agent *controller.AgentInfo, action string) bool {
+	if !s.requireRole(i, RoleUser, action) {
+		return false
+	}
+	if s.roleForInteraction(i) >= RoleAdmin {
+		return true
+	}
+	if agent.Owner != "" && agent.Owner == controller.DiscordActor(interactionUserID(i)) {
+		return true
+	}
+	s.logger.Info("Permission denied: not agent owner",
+		zap.String("user_id", interactionUserID(i)),
+		zap.String("agent", agent.Name),
+		zap.String("owner", agent.Owner),
+		zap.String("action", action),
+	)
+	s.respondEphemeral(i, fmt.Sprintf("권한이 없어요: 에이전트 '**%s**'은(는) 소유자 또는 admin만 %s할 수 있어요.", agent.Name, action))
+	return false
+}
+
+// requireTaskModify는 작업을 분기하거나 대화를 바꿀 수 있는지 확인합니다.
+// user 권한을 가진 작업 생성자, 에이전트 소유자 또는 admin만 허용됩니다.
+func (s *Server) requireTaskModify(i *discordgo.InteractionCreate, taskID, action string) bool {
+	if !s.requireRole(i, RoleUser, action) {
+		return false
+	}
+	if s.roleForInteraction(i) >= RoleAdmin {
+		return true
+	}
+	ctx := s.interactionContext(i)
+	task, err := s.controller.GetTaskInfo(ctx, taskID)
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to get task info from controller", zap.Error(err), zap.String("task_id", taskID))
+		s.respondEphemeral(i, fmt.Sprintf("오류: Task '**%s**'의 정보를 가져오지 못했어요. 에러: %v", taskID, err))
+		return false
+	}
+	actor := controller.DiscordActor(interactionUserID(i))
+	if task.Owner != "" && task.Owner == actor {
+		return true
+	}
+	agent, err := s.controller.GetAgentInfo(ctx, task.AgentID)
+	if err == nil && agent.Owner != "" && agent.Owner == actor {
+		return true
+	}
+	s.logger.Info("Permission denied: not task owner",
+		zap.String("user_id", interactionUserID(i)),
+		zap.String("task_id", taskID),
+		zap.String("owner", task.Owner),
+		zap.String("action", action),
+	)
+	s.respondEphemeral(i, fmt.Sprintf("권한이 없어요: Task '**%s**'은(는) 만든 사람, 에이전트 소유자 또는 admin만 %s할 수 있어요.", taskID, action))
+	return false
+}
@@ -0,0 +1,124 @@
+package connector
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestPermissionConfigRoleOf(t *testing.T) {
+	cfg := &PermissionConfig{
+		DefaultRole: "viewer",
+		Guilds: map[string]GuildPermissions{
+			"guild-1": {
+				Admins:  []string{"role-admin"},
+				Users:   []string{"role-member", "user-42"},
+				Viewers: []string{"role-guest"},
+			},
+			"guild-2": {
+				DefaultRole: "none",
+			},
+		},
+	}
+
+	require.Equal(t, RoleAdmin, cfg.RoleOf("guild-1", "user-1", []string{"role-member", "role-admin"}))
+	require.Equal(t, RoleUser, cfg.RoleOf("guild-1", "user-1", []string{"role-member"}))
+	require.Equal(t, RoleUser, cfg.RoleOf("guild-1", "user-42", nil))
+	require.Equal(t, RoleViewer, cfg.RoleOf("guild-1", "user-1", []string{"role-guest"}))
+	require.Equal(t, RoleViewer, cfg.RoleOf("guild-1", "user-1", nil))
+	require.Equal(t, RoleNone, cfg.RoleOf("guild-2", "user-1", nil))
+	require.Equal(t, RoleViewer, cfg.RoleOf("unknown-guild", "user-1", nil))
+}
+
+func TestLoadPermissionConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "permissions.yaml")
+	require.NoError(t, os.WriteFile(valid, []byte(`
+default_role: viewer
+guilds:
+  "123":
+    admins: ["999"]
+`), 0o644))
+
+	cfg, err := LoadPermissionConfig(valid)
+	require.NoError(t, err)
+	require.Equal(t, RoleAdmin, cfg.RoleOf("123", "999", nil))
+
+	invalid := filepath.Join(dir, "invalid.yaml")
+	require.NoError(t, os.WriteFile(invalid, []byte("default_role: superuser\n"), 0o644))
+
+	_, err = LoadPermissionConfig(invalid)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unknown role")
+}
+
+func TestPermissionDefaultRole(t *testing.T) {
+	// 권한 파일이 없을 때와 파일에 default_role이 없을 때의 기본 권한은 같습니다.
+	require.Equal(t, DefaultRole, DefaultPermissionConfig().RoleOf("guild-1", "user-1", nil))
+	require.Equal(t, DefaultRole, (&PermissionConfig{}).RoleOf("guild-1", "user-1", nil))
+	require.Equal(t, RoleUser, DefaultRole)
+}
+
+func TestRoleForMemberAdministrator(t *testing.T) {
+	s := &Server{permissions: &PermissionConfig{DefaultRole: "none"}}
+
+	require.Equal(t, RoleNone, s.roleForMember("guild-1", "user-1", nil, 0))
+	require.Equal(t, RoleAdmin, s.roleForMember("guild-1", "user-1", nil, discordgo.PermissionAdministrator))
+	require.Equal(t, RoleNone, s.roleForMember("guild-1", "user-1", nil, discordgo.PermissionSendMessages))
+}
+
+// roundTripFunc는 Discord API 요청을 네트워크 없이 처리합니다.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRequireTaskModify(t *testing.T) {
+	ctrl := controller.NewController(zap.NewNop(), storage.NewMemoryStore())
+	s := NewServer(zap.NewNop(), ctrl, Config{})
+	session, err := discordgo.New("Bot test")
+	require.NoError(t, err)
+	var responses int
+	session.Client = &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		responses++
+		return &http.Response{StatusCode: http.StatusNoContent, Body: io.NopCloser(strings.NewReader("")), Header: make(http.Header), Request: req}, nil
+	})}
+	s.session = session
+
+	ownerCtx := controller.WithActor(context.Background(), controller.DiscordActor("owner"))
+	require.NoError(t, ctrl.CreateAgent(ownerCtx, "writer", "Writer agent", "gpt-4", "Write things"))
+	creatorCtx := controller.WithActor(context.Background(), controller.DiscordActor("creator"))
+	require.NoError(t, ctrl.CreateTask(creatorCtx, "writer", "task-1", "Hello"))
+
+	interaction := func(userID string, perms int64) *discordgo.InteractionCreate {
+		return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+			Type:    discordgo.InteractionMessageComponent,
+			GuildID: "guild-1",
+			Member:  &discordgo.Member{User: &discordgo.User{ID: userID}, Permissions: perms},
+		}}
+	}
+
+	// 작업 생성자, 에이전트 소유자, admin은 허용하고 응답을 보내지 않습니다.
+	require.True(t, s.requireTaskModify(interaction("creator", 0), "task-1", "분기"))
+	require.True(t, s.requireTaskModify(interaction("owner", 0), "task-1", "분기"))
+	require.True(t, s.requireTaskModify(interaction("admin", discordgo.PermissionAdministrator), "task-1", "분기"))
+	require.Zero(t, responses)
+
+	// 다른 사용자는 거절하고 임시 메시지로 알립니다.
+	require.False(t, s.requireTaskModify(interaction("stranger", 0), "task-1", "분기"))
+	require.Equal(t, 1, responses)
+
+	info, err := ctrl.GetTaskInfo(context.Background(), "task-1")
+	require.NoError(t, err)
+	require.Equal(t, controller.DiscordActor("creator"), info.Owner)
+}
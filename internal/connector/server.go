@@ -2,14 +2,20 @@ package connector
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/bwmarrin/discordgo"
 	"github.com/cnap-oss/app/internal/controller"
-	"github.com/joho/godotenv"
+	"github.com/cnap-oss/app/internal/metrics"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
 )
 
@@ -25,37 +31,95 @@ const (
 	prefixModalCreate = "modal_agent_create"
 	prefixModalEdit   = "modal_agent_edit_"
 	prefixButtonEdit  = "edit_agent_"
+	prefixButtonFork  = "fork_task_"
+	prefixButtonRegen = "regen_task_"
+	// 질문 수정 버튼과 모달은 작업 ID와 대화 인덱스를 CustomID에 담습니다.
+	prefixButtonEditMessage = "edit_msg_"
+	prefixModalEditMessage  = "modal_msg_edit_"
 )
 
+// Discord API 한도입니다.
+const (
+	maxAutocompleteChoices = 25
+	maxEmbedFields         = 25
+	maxEmbedFieldValue     = 1024
+	maxMessageLength       = 2000
+	maxTextInputLength     = 4000
+)
+
+// 메트릭 레이블에 사용되는 Discord 이벤트 유형입니다.
+const (
+	eventTypeCommand      = "command"
+	eventTypeComponent    = "component"
+	eventTypeModal        = "modal"
+	eventTypeAutocomplete = "autocomplete"
+	eventTypeMessage      = "message"
+	eventTypeGateway      = "gateway"
+	eventTypeUnknown      = "unknown"
+)
+
+// TaskChannelLabel은 작업의 실행 결과를 게시할 Discord 채널(스레드) ID를 담는 작업 레이블 키입니다.
+// 이 레이블이 없는 작업의 결과는 Discord에 게시하지 않습니다.
+const TaskChannelLabel = "discord/channel"
+
 // Server는 Discord 봇의 세션, 로거, 에이전트 데이터 등 모든 상태를 관리하는 중앙 구조체입니다.
 type Server struct {
 	logger        *zap.Logger
 	session       *discordgo.Session
 	controller    *controller.Controller
 	threadsMutex  sync.RWMutex
-	activeThreads map[string]string
+	activeThreads map[string]threadState
+	permissions   *PermissionConfig
+	connected     atomic.Bool
+	config        Config
+}
+
+// threadState는 대화 스레드에 연결된 에이전트와 스레드를 시작할 때 지정한 프롬프트 변수입니다.
+type threadState struct {
+	agent     string
+	variables map[string]string
+}
+
+// Config는 connector 서버 설정입니다.
+type Config struct {
+	// Token은 Discord 봇 토큰입니다.
+	Token string
+	// PermissionsFile은 길드별 권한 매핑 YAML 파일 경로입니다. 비어 있으면 기본 권한을 사용합니다.
+	PermissionsFile string
 }
 
 // NewServer는 새로운 connector 서버를 생성하고 초기화합니다.
-func NewServer(logger *zap.Logger, ctrl *controller.Controller) *Server {
+func NewServer(logger *zap.Logger, ctrl *controller.Controller, cfg Config) *Server {
 	return &Server{
 		logger:        logger,
+		config:        cfg,
 		controller:    ctrl,
-		activeThreads: make(map[string]string),
+		activeThreads: make(map[string]threadState),
+		permissions:   DefaultPermissionConfig(),
 	}
 }
 
 // Start는 Discord 봇을 시작하고 Discord API에 연결합니다.
-// 환경 변수 로드, 세션 생성, 이벤트 핸들러 등록, 연결 열기 등의 작업을 수행합니다.
+// 권한 설정 로드, 세션 생성, 이벤트 핸들러 등록, 연결 열기 등의 작업을 수행합니다.
 func (s *Server) Start(ctx context.Context) error {
 	s.logger.Info("Starting connector server (Discord Bot)")
 
-	if err := godotenv.Load(); err != nil {
-		s.logger.Warn("Could not load .env file", zap.Error(err))
-	}
-	token := os.Getenv("DISCORD_TOKEN")
+	token := s.config.Token
 	if token == "" {
-		return fmt.Errorf("DISCORD_TOKEN environment variable not set")
+		return fmt.Errorf("discord token not configured (set discord.token or DISCORD_TOKEN)")
+	}
+
+	if path := s.config.PermissionsFile; path != "" {
+		perms, err := LoadPermissionConfig(path)
+		if err != nil {
+			return err
+		}
+		s.permissions = perms
+		s.logger.Info("Loaded Discord permission config", zap.String("path", path), zap.Int("guilds", len(perms.Guilds)))
+	} else {
+		s.logger.Warn("Discord permissions file not set; every member gets the default role and only Discord administrators are admins",
+			zap.String("default_role", DefaultRole.String()),
+		)
 	}
 
 	dg, err := discordgo.New("Bot " + token)
@@ -66,6 +130,8 @@ func (s *Server) Start(ctx context.Context) error {
 	s.session.Identify.Intents = discordgo.IntentsGuilds | discordgo.IntentsGuildMessages
 
 	s.session.AddHandler(s.readyHandler)
+	s.session.AddHandler(s.resumedHandler)
+	s.session.AddHandler(s.disconnectHandler)
 	s.session.AddHandler(s.interactionRouter)
 	s.session.AddHandler(s.messageCreateHandler)
 
@@ -84,9 +150,10 @@ func (s *Server) Start(ctx context.Context) error {
 // Stop은 Discord 세션을 정상적으로 닫고 봇을 종료합니다.
 func (s *Server) Stop(ctx context.Context) error {
 	s.logger.Info("Stopping connector server")
+	s.connected.Store(false)
 	if s.session != nil {
 		if err := s.session.Close(); err != nil {
-			s.logger.Error("Error closing discord session", zap.Error(err))
+			s.logError(eventTypeGateway, "Error closing discord session", zap.Error(err))
 			return err
 		}
 	}
@@ -97,6 +164,7 @@ func (s *Server) Stop(ctx context.Context) error {
 // readyHandler는 봇이 Discord에 성공적으로 연결되었을 때 호출됩니다.
 // 여기서 전역 애플리케이션 명령어를 등록합니다.
 func (s *Server) readyHandler(_ *discordgo.Session, r *discordgo.Ready) {
+	s.connected.Store(true)
 	s.logger.Info("Bot is ready! Registering commands...", zap.String("username", r.User.Username))
 
 	commands := []*discordgo.ApplicationCommand{
@@ -109,21 +177,44 @@ func (s *Server) readyHandler(_ *discordgo.Session, r *discordgo.Ready) {
 				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subCmdView, Description: "특정 에이전트의 상세 정보를 봅니다.", Options: []*discordgo.ApplicationCommandOption{{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "정보를 볼 에이전트의 이름", Required: true, Autocomplete: true}}},
 				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subCmdDelete, Description: "특정 에이전트를 삭제합니다.", Options: []*discordgo.ApplicationCommandOption{{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "삭제할 에이전트의 이름", Required: true, Autocomplete: true}}},
 				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subCmdEdit, Description: "특정 에이전트의 정보를 수정합니다.", Options: []*discordgo.ApplicationCommandOption{{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "수정할 에이전트의 이름", Required: true, Autocomplete: true}}},
-				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subCmdCall, Description: "에이전트와의 대화 스레드를 시작합니다.", Options: []*discordgo.ApplicationCommandOption{{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "호출할 에이전트의 이름", Required: true, Autocomplete: true}}},
+				{Type: discordgo.ApplicationCommandOptionSubCommand, Name: subCmdCall, Description: "에이전트와의 대화 스레드를 시작합니다.", Options: []*discordgo.ApplicationCommandOption{{Type: discordgo.ApplicationCommandOptionString, Name: "name", Description: "호출할 에이전트의 이름", Required: true, Autocomplete: true}, {Type: discordgo.ApplicationCommandOptionString, Name: "vars", Description: "프롬프트 템플릿 변수 (예: Language=Go, Repo=cnap)", Required: false}}},
 			},
 		},
 	}
 
 	_, err := s.session.ApplicationCommandBulkOverwrite(s.session.State.User.ID, "", commands)
 	if err != nil {
-		s.logger.Error("Could not register commands", zap.Error(err))
+		s.logError(eventTypeGateway, "Could not register commands", zap.Error(err))
 	} else {
 		s.logger.Info("Successfully registered commands.")
 	}
 }
 
+// resumedHandler는 끊어진 게이트웨이 세션이 재개되었을 때 호출됩니다.
+func (s *Server) resumedHandler(_ *discordgo.Session, _ *discordgo.Resumed) {
+	s.connected.Store(true)
+	s.logger.Info("Discord gateway session resumed")
+}
+
+// disconnectHandler는 게이트웨이 연결이 끊어졌을 때 호출됩니다.
+// discordgo가 재연결을 시도하며, 성공하면 Ready 또는 Resumed 이벤트가 다시 발생합니다.
+func (s *Server) disconnectHandler(_ *discordgo.Session, _ *discordgo.Disconnect) {
+	s.connected.Store(false)
+	s.logger.Warn("Discord gateway disconnected")
+}
+
+// CheckGateway는 Discord 게이트웨이 연결 상태를 확인하는 헬스 체크입니다.
+func (s *Server) CheckGateway(_ context.Context) error {
+	if !s.connected.Load() {
+		return fmt.Errorf("discord gateway not connected")
+	}
+	return nil
+}
+
 // interactionRouter는 Discord 상호작용을 적절한 핸들러로 라우팅합니다.
 func (s *Server) interactionRouter(_ *discordgo.Session, i *discordgo.InteractionCreate) {
+	metrics.DiscordInteractions.WithLabelValues(interactionKind(i), interactionCommand(i)).Inc()
+
 	switch i.Type {
 	case discordgo.InteractionApplicationCommand:
 		s.handleSlashCommand(i)
@@ -143,20 +234,39 @@ func (s *Server) messageCreateHandler(_ *discordgo.Session, m *discordgo.Message
 	}
 
 	s.threadsMutex.RLock()
-	agentName, ok := s.activeThreads[m.ChannelID]
+	thread, ok := s.activeThreads[m.ChannelID]
 	s.threadsMutex.RUnlock()
+	agentName := thread.agent
 
 	if ok {
-		ctx := context.Background()
+		metrics.DiscordInteractions.WithLabelValues(eventTypeMessage, "").Inc()
+
+		if role := s.roleForMessage(m.Message); role < RoleUser {
+			s.logger.Debug("Ignoring thread message from user without call permission",
+				zap.String("user_id", m.Author.ID),
+				zap.String("role", role.String()),
+			)
+			return
+		}
+
+		ctx, span := tracing.Start(controller.WithActor(context.Background(), controller.DiscordActor(m.Author.ID)),
+			"connector.messageCreateHandler",
+			attribute.String("cnap.agent_id", agentName),
+			attribute.String("discord.channel_id", m.ChannelID),
+			attribute.String("discord.user_id", m.Author.ID),
+		)
+		defer span.End()
+
 		agent, err := s.controller.GetAgentInfo(ctx, agentName)
 		if err != nil {
-			s.logger.Error("Failed to get agent info from controller for message handler", zap.Error(err), zap.String("agent_id", agentName))
+			tracing.RecordError(span, err)
+			s.logError(eventTypeMessage, "Failed to get agent info from controller for message handler", zap.Error(err), zap.String("agent_id", agentName), tracing.TraceField(ctx))
 			if _, sendErr := s.session.ChannelMessageSend(m.ChannelID, "오류: 이 스레드에 연결된 에이전트를 찾을 수 없습니다."); sendErr != nil {
-				s.logger.Error("Failed to send error message to channel", zap.Error(sendErr), zap.String("channel_id", m.ChannelID))
+				s.logError(eventTypeMessage, "Failed to send error message to channel", zap.Error(sendErr), zap.String("channel_id", m.ChannelID), tracing.TraceField(ctx))
 			}
 			return
 		}
-		s.callAgentInThread(m.Message, agent)
+		s.callAgentInThread(ctx, m.Message, agent, thread.variables)
 	}
 }
 
@@ -168,17 +278,35 @@ func (s *Server) handleSlashCommand(i *discordgo.InteractionCreate) {
 	subCommand := i.ApplicationCommandData().Options[0]
 	switch subCommand.Name {
 	case subCmdCreate:
+		if !s.requireRole(i, RoleUser, "생성") {
+			return
+		}
 		s.showCreateOrEditModal(i, "", nil)
 	case subCmdList:
+		if !s.requireRole(i, RoleViewer, "조회") {
+			return
+		}
 		s.showAgentList(i)
 	case subCmdView:
+		if !s.requireRole(i, RoleViewer, "조회") {
+			return
+		}
 		s.showAgentDetails(i, subCommand.Options[0].StringValue())
 	case subCmdDelete:
 		s.deleteAgent(i, subCommand.Options[0].StringValue())
 	case subCmdEdit:
 		s.showEditUI(i, subCommand.Options[0].StringValue())
 	case subCmdCall:
-		s.startAgentThread(i, subCommand.Options[0].StringValue())
+		if !s.requireRole(i, RoleUser, "호출") {
+			return
+		}
+		var vars string
+		for _, opt := range subCommand.Options[1:] {
+			if opt.Name == "vars" {
+				vars = opt.StringValue()
+			}
+		}
+		s.startAgentThread(i, subCommand.Options[0].StringValue(), vars)
 	}
 }
 
@@ -187,22 +315,43 @@ func (s *Server) handleButton(i *discordgo.InteractionCreate) {
 	customID := i.MessageComponentData().CustomID
 	if strings.HasPrefix(customID, prefixButtonEdit) {
 		agentName := strings.TrimPrefix(customID, prefixButtonEdit)
-		ctx := context.Background()
+		ctx := s.interactionContext(i)
 		agent, err := s.controller.GetAgentInfo(ctx, agentName)
 		if err != nil {
-			s.logger.Error("Failed to get agent info from controller for edit button", zap.Error(err), zap.String("agent_id", agentName))
+			s.logError(interactionKind(i), "Failed to get agent info from controller for edit button", zap.Error(err), zap.String("agent_id", agentName))
 			s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 정보를 가져오는 데 실패했어요. 에러: %v", agentName, err))
 			return
 		}
+		if !s.requireAgentModify(i, agent, "수정") {
+			return
+		}
 		s.showCreateOrEditModal(i, agentName, agent)
+		return
+	}
+	if taskID, index, ok := parseTaskButtonID(prefixButtonFork, customID); ok {
+		s.forkTask(i, taskID, index)
+		return
+	}
+	if taskID, index, ok := parseTaskButtonID(prefixButtonRegen, customID); ok {
+		s.regenerateMessage(i, taskID, index)
+		return
+	}
+	if taskID, index, ok := parseTaskButtonID(prefixButtonEditMessage, customID); ok {
+		s.showEditMessageModal(i, taskID, index)
 	}
 }
 
 // handleModal은 모달 제출 상호작용을 처리합니다.
 func (s *Server) handleModal(i *discordgo.InteractionCreate) {
-	ctx := context.Background() // Create a context
+	ctx := s.interactionContext(i)
 	customID := i.ModalSubmitData().CustomID
 	data := i.ModalSubmitData().Components
+	// 질문 수정 모달은 입력 필드가 하나뿐이므로 에이전트 필드를 읽기 전에 처리합니다.
+	if taskID, index, ok := parseTaskButtonID(prefixModalEditMessage, customID); ok {
+		content := data[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
+		s.editMessage(i, taskID, index, content)
+		return
+	}
 	name := data[0].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
 	desc := data[1].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
 	model := data[2].(*discordgo.ActionsRow).Components[0].(*discordgo.TextInput).Value
@@ -210,18 +359,41 @@ func (s *Server) handleModal(i *discordgo.InteractionCreate) {
 
 	switch {
 	case customID == prefixModalCreate:
+		if !s.requireRole(i, RoleUser, "생성") {
+			return
+		}
 		// Assume controller's CreateAgent will handle conflicts (e.g., already exists)
 		if err := s.controller.CreateAgent(ctx, name, desc, model, prompt); err != nil {
-			s.logger.Error("Failed to create agent via controller", zap.Error(err))
+			s.logError(interactionKind(i), "Failed to create agent via controller", zap.Error(err))
 			s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'을(를) 생성하는 데 실패했어요. 에러: %v", name, err))
 			return
 		}
 		s.respondEphemeral(i, fmt.Sprintf("에이전트 '**%s**'이(가) 성공적으로 생성되었어요!", name))
 	case strings.HasPrefix(customID, prefixModalEdit):
-		originalName := strings.TrimPrefix(customID, prefixModalEdit)
-		// Assumes an UpdateAgent function exists in the controller that can handle renames.
-		if err := s.controller.UpdateAgent(ctx, originalName, desc, model, prompt); err != nil {
-			s.logger.Error("Failed to update agent via controller", zap.Error(err), zap.String("original_agent_id", originalName))
+		originalName, version := parseEditModalID(customID)
+		agent, err := s.controller.GetAgentInfo(ctx, originalName)
+		if err != nil {
+			s.logError(interactionKind(i), "Failed to get agent info from controller for edit modal", zap.Error(err), zap.String("agent_id", originalName))
+			s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 정보를 가져오는 데 실패했어요. 에러: %v", originalName, err))
+			return
+		}
+		if !s.requireAgentModify(i, agent, "수정") {
+			return
+		}
+		// 모달을 연 뒤 다른 관리자가 먼저 수정했다면 덮어쓰지 않습니다.
+		// 버전이 없는 모달(이전 버전에서 연 모달)도 같은 이유로 거부합니다.
+		if version > 0 {
+			err = s.controller.UpdateAgent(ctx, originalName, desc, model, prompt, controller.WithExpectedVersion(version))
+		} else {
+			err = &storage.ConflictError{Kind: "agent", ID: originalName, Expected: version, Actual: agent.Version}
+		}
+		if errors.Is(err, storage.ErrConflict) {
+			s.logger.Info("Agent edit rejected due to concurrent modification", zap.String("agent_id", originalName), zap.Error(err))
+			s.respondEphemeral(i, fmt.Sprintf("에이전트 '**%s**'을(를) 다른 사용자가 먼저 수정했어요. `/agent edit`으로 최신 정보를 다시 불러온 뒤 수정해주세요.", originalName))
+			return
+		}
+		if err != nil {
+			s.logError(interactionKind(i), "Failed to update agent via controller", zap.Error(err), zap.String("original_agent_id", originalName))
 			s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'을(를) 수정하는 데 실패했어요. 에러: %v", originalName, err))
 			return
 		}
@@ -233,61 +405,114 @@ func (s *Server) handleModal(i *discordgo.InteractionCreate) {
 func (s *Server) handleAutocomplete(i *discordgo.InteractionCreate) {
 	options := i.ApplicationCommandData().Options[0].Options[0]
 	if options.Focused {
-		ctx := context.Background()
-		agents, err := s.controller.ListAgentsWithInfo(ctx)
+		if s.roleForInteraction(i) < RoleViewer {
+			_ = s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionApplicationCommandAutocompleteResult, Data: &discordgo.InteractionResponseData{Choices: []*discordgo.ApplicationCommandOptionChoice{}}})
+			return
+		}
+		ctx := s.interactionContext(i)
+		// Discord는 자동 완성 선택지를 최대 25개까지 받으므로 접두사가 맞는 25개만 조회합니다.
+		page, err := s.controller.ListAgentsPage(ctx, storage.AgentFilter{
+			NamePrefix: options.StringValue(),
+			Sort:       storage.SortName,
+			Limit:      maxAutocompleteChoices,
+		})
 		if err != nil {
-			s.logger.Error("Failed to list agents from controller for autocomplete", zap.Error(err))
+			s.logError(interactionKind(i), "Failed to list agents from controller for autocomplete", zap.Error(err))
 			// Can't respond with an ephemeral message here, so we just log and return empty choices
 			_ = s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionApplicationCommandAutocompleteResult, Data: &discordgo.InteractionResponseData{Choices: []*discordgo.ApplicationCommandOptionChoice{}}})
 			return
 		}
 
-		var choices []*discordgo.ApplicationCommandOptionChoice
-		for _, agent := range agents {
-			if strings.HasPrefix(strings.ToLower(agent.Name), strings.ToLower(options.StringValue())) {
-				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: agent.Name, Value: agent.Name})
-			}
-		}
-
-		// Discord has a limit of 25 choices for autocomplete
-		if len(choices) > 25 {
-			choices = choices[:25]
+		choices := make([]*discordgo.ApplicationCommandOptionChoice, 0, len(page.Agents))
+		for _, agent := range page.Agents {
+			choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: agent.Name, Value: agent.Name})
 		}
 
 		if err := s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionApplicationCommandAutocompleteResult, Data: &discordgo.InteractionResponseData{Choices: choices}}); err != nil {
-			s.logger.Error("Failed to send autocomplete response", zap.Error(err))
+			s.logError(interactionKind(i), "Failed to send autocomplete response", zap.Error(err))
 		}
 	}
 }
 
+// interactionContext는 상호작용을 보낸 Discord 사용자를 actor로 기록한 컨텍스트를 반환합니다.
+func (s *Server) interactionContext(i *discordgo.InteractionCreate) context.Context {
+	return controller.WithActor(context.Background(), controller.DiscordActor(interactionUserID(i)))
+}
+
+// interactionKind는 상호작용 유형을 메트릭 레이블 값으로 변환합니다.
+func interactionKind(i *discordgo.InteractionCreate) string {
+	switch i.Type {
+	case discordgo.InteractionApplicationCommand:
+		return eventTypeCommand
+	case discordgo.InteractionMessageComponent:
+		return eventTypeComponent
+	case discordgo.InteractionModalSubmit:
+		return eventTypeModal
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		return eventTypeAutocomplete
+	default:
+		return eventTypeUnknown
+	}
+}
+
+// interactionCommand는 슬래시 명령어의 서브커맨드 이름을 반환합니다.
+// 버튼과 모달처럼 명령어가 없는 상호작용은 빈 문자열을 반환합니다.
+func interactionCommand(i *discordgo.InteractionCreate) string {
+	if i.Type != discordgo.InteractionApplicationCommand && i.Type != discordgo.InteractionApplicationCommandAutocomplete {
+		return ""
+	}
+	data := i.ApplicationCommandData()
+	if len(data.Options) == 0 {
+		return data.Name
+	}
+	return data.Name + " " + data.Options[0].Name
+}
+
+// logError는 에러를 기록하고 Discord 에러 메트릭을 증가시킵니다.
+func (s *Server) logError(eventType, msg string, fields ...zap.Field) {
+	s.logger.Error(msg, fields...)
+	metrics.DiscordErrors.WithLabelValues(eventType).Inc()
+}
+
 // respondEphemeral은 사용자에게만 보이는 임시 메시지를 전송합니다.
 func (s *Server) respondEphemeral(i *discordgo.InteractionCreate, content string) {
 	err := s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseChannelMessageWithSource, Data: &discordgo.InteractionResponseData{Content: content, Flags: discordgo.MessageFlagsEphemeral}})
 	if err != nil {
-		s.logger.Error("Failed to send ephemeral message", zap.Error(err))
+		s.logError(interactionKind(i), "Failed to send ephemeral message", zap.Error(err))
 	}
 }
 
 // startAgentThread는 지정된 에이전트와의 새로운 대화 스레드를 시작합니다.
-func (s *Server) startAgentThread(i *discordgo.InteractionCreate, agentName string) {
-	ctx := context.Background()
+// vars는 "Key=Value, Key=Value" 형식의 프롬프트 템플릿 변수이며, 필수 변수가 빠지면 스레드를 만들지 않습니다.
+func (s *Server) startAgentThread(i *discordgo.InteractionCreate, agentName, vars string) {
+	ctx := s.interactionContext(i)
 	agent, err := s.controller.GetAgentInfo(ctx, agentName)
 	if err != nil {
-		s.logger.Error("Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", agentName))
+		s.logError(interactionKind(i), "Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", agentName))
 		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 정보를 가져오는 데 실패했어요. 에러: %v", agentName, err))
 		return
 	}
+	variables, err := parseCallVariables(vars)
+	if err != nil {
+		s.respondEphemeral(i, fmt.Sprintf("오류: 변수 형식이 올바르지 않아요. `Key=Value, Key=Value` 형식으로 입력해주세요. 에러: %v", err))
+		return
+	}
+	systemPrompt, err := controller.RenderPrompt(agent.Prompt, variables)
+	if err != nil {
+		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 프롬프트를 렌더링하지 못했어요. `vars` 옵션을 확인해주세요. 에러: %v", agentName, err))
+		return
+	}
 
 	s.respondEphemeral(i, fmt.Sprintf("'**%s**'와의 대화 스레드를 생성 중...", agentName))
 
 	thread, err := s.session.ThreadStart(i.ChannelID, fmt.Sprintf("[%s] 대화방", agent.Name), discordgo.ChannelTypeGuildPublicThread, 60)
 	if err != nil {
-		s.logger.Error("Failed to create thread", zap.Error(err), zap.String("agent", agentName))
+		s.logError(interactionKind(i), "Failed to create thread", zap.Error(err), zap.String("agent", agentName))
 		return
 	}
 
 	s.threadsMutex.Lock()
-	s.activeThreads[thread.ID] = agent.Name
+	s.activeThreads[thread.ID] = threadState{agent: agent.Name, variables: variables}
 	s.threadsMutex.Unlock()
 
 	embed := &discordgo.MessageEmbed{
@@ -296,73 +521,356 @@ func (s *Server) startAgentThread(i *discordgo.InteractionCreate, agentName stri
 		Color:       0x33cc33, // Green
 		Fields: []*discordgo.MessageEmbedField{
 			{Name: "에이전트 모델", Value: agent.Model, Inline: true},
-			{Name: "역할 정의 (프롬프트)", Value: fmt.Sprintf("```\n%s\n```", agent.Prompt), Inline: false},
+			{Name: "역할 정의 (프롬프트)", Value: fmt.Sprintf("```\n%s\n```", systemPrompt), Inline: false},
 		},
 	}
 	if _, err := s.session.ChannelMessageSendEmbed(thread.ID, embed); err != nil {
-		s.logger.Error("Failed to send initial thread message", zap.Error(err), zap.String("thread_id", thread.ID))
+		s.logError(interactionKind(i), "Failed to send initial thread message", zap.Error(err), zap.String("thread_id", thread.ID))
+	}
+}
+
+// parseCallVariables는 /agent call의 vars 옵션("Language=Go, Repo=cnap")을 변수 맵으로 바꿉니다.
+// 쉼표로 변수를 구분하므로 값에는 쉼표를 쓸 수 없습니다.
+func parseCallVariables(value string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, nil
 	}
+	parts := strings.Split(value, ",")
+	for idx, part := range parts {
+		parts[idx] = strings.TrimSpace(part)
+	}
+	return controller.ParsePromptVariables(parts)
 }
 
-// callAgentInThread는 활성화된 에이전트 스레드 내에서 메시지를 처리합니다.
-func (s *Server) callAgentInThread(m *discordgo.Message, agent *controller.AgentInfo) {
-	if m.Content == "안녕!" {
-		if _, err := s.session.ChannelMessageSend(m.ChannelID, "안녕하세요!"); err != nil {
-			s.logger.Error("Failed to send greeting message", zap.Error(err), zap.String("channel_id", m.ChannelID))
+// callAgentInThread는 스레드 메시지로 에이전트를 실행합니다. 결과는 작업이 끝나면 NotifyTaskResult로 게시됩니다.
+// vars는 스레드를 시작할 때 지정한 프롬프트 변수입니다.
+func (s *Server) callAgentInThread(ctx context.Context, m *discordgo.Message, agent *controller.AgentInfo, vars map[string]string) {
+	taskID, err := s.runThreadTask(ctx, m.ChannelID, m.ID, agent.Name, m.Content, vars)
+	if err != nil {
+		s.logError(eventTypeMessage, "Failed to run agent for thread message", zap.Error(err), zap.String("task_id", taskID), zap.String("channel_id", m.ChannelID), tracing.TraceField(ctx))
+		if _, sendErr := s.session.ChannelMessageSend(m.ChannelID, fmt.Sprintf("오류: 에이전트 '**%s**'를 실행하지 못했어요. 에러: %v", agent.Name, err)); sendErr != nil {
+			s.logError(eventTypeMessage, "Failed to send error message to channel", zap.Error(sendErr), zap.String("channel_id", m.ChannelID), tracing.TraceField(ctx))
 		}
 		return
 	}
+	if err := s.session.ChannelTyping(m.ChannelID); err != nil {
+		s.logger.Debug("Failed to send typing indicator", zap.Error(err), zap.String("channel_id", m.ChannelID))
+	}
+}
+
+// runThreadTask는 스레드 메시지마다 작업을 만들고 실행합니다.
+// 작업에는 스레드 ID를 TaskChannelLabel로 붙여 실행 결과가 같은 스레드에 게시되게 하고,
+// 스레드의 프롬프트 변수를 저장해 작업마다 같은 시스템 프롬프트로 실행되게 합니다.
+func (s *Server) runThreadTask(ctx context.Context, channelID, messageID, agentName, content string, vars map[string]string) (string, error) {
+	taskID := "discord-" + messageID
+	labels := map[string]string{TaskChannelLabel: channelID}
+	if err := s.controller.CreateTask(ctx, agentName, taskID, content, controller.WithTaskLabels(labels), controller.WithTaskVariables(vars)); err != nil {
+		return taskID, err
+	}
+	return taskID, s.controller.SendMessage(ctx, taskID)
+}
+
+// Server는 작업 결과를 TaskChannelLabel의 채널에 게시합니다.
+var _ controller.TaskNotifier = (*Server)(nil)
+
+// NotifyTaskResult는 실행이 끝난 작업의 결과를 작업 레이블에 지정된 채널에 게시합니다.
+func (s *Server) NotifyTaskResult(_ context.Context, info *controller.TaskInfo) error {
+	channelID := info.Labels[TaskChannelLabel]
+	if channelID == "" {
+		return nil
+	}
+	return s.PostTaskResult(channelID, info)
+}
 
+// PostTaskResult는 작업의 실행 결과를 스레드에 게시합니다.
+// 출력은 메시지 본문으로, 상태·모델·소요 시간·오류는 임베드로 보냅니다.
+func (s *Server) PostTaskResult(channelID string, info *controller.TaskInfo) error {
+	if s.session == nil {
+		return fmt.Errorf("connector: discord session is not started")
+	}
+	if info.Output != "" {
+		for _, chunk := range splitMessage(info.Output, maxMessageLength) {
+			if _, err := s.session.ChannelMessageSend(channelID, chunk); err != nil {
+				s.logError(eventTypeMessage, "Failed to send task output", zap.Error(err), zap.String("channel_id", channelID), zap.String("task_id", info.TaskID))
+				return err
+			}
+		}
+	}
+	msg := &discordgo.MessageSend{
+		Embeds:     []*discordgo.MessageEmbed{taskResultEmbed(info)},
+		Components: s.taskResultButtons(info),
+	}
+	if _, err := s.session.ChannelMessageSendComplex(channelID, msg); err != nil {
+		s.logError(eventTypeMessage, "Failed to send task result embed", zap.Error(err), zap.String("channel_id", channelID), zap.String("task_id", info.TaskID))
+		return err
+	}
+	return nil
+}
+
+// taskResultButtons는 결과 메시지에 붙일 분기, 다시 생성, 질문 수정 버튼을 만듭니다.
+// 결과가 대화에 기록되지 않았으면 버튼을 붙이지 않습니다.
+func (s *Server) taskResultButtons(info *controller.TaskInfo) []discordgo.MessageComponent {
+	if info.ResultRef == "" {
+		return nil
+	}
+	messages, err := s.controller.ListMessages(context.Background(), info.TaskID)
+	if err != nil {
+		s.logError(eventTypeMessage, "Failed to list task messages", zap.Error(err), zap.String("task_id", info.TaskID))
+		return nil
+	}
+	result, question, ok := resultIndexes(messages, info.ResultRef)
+	if !ok {
+		return nil
+	}
+	buttons := []discordgo.MessageComponent{
+		discordgo.Button{Label: "여기서 분기", Style: discordgo.SecondaryButton, CustomID: taskButtonID(prefixButtonFork, info.TaskID, result)},
+		discordgo.Button{Label: "다시 생성", Style: discordgo.SecondaryButton, CustomID: taskButtonID(prefixButtonRegen, info.TaskID, result)},
+	}
+	if question >= 0 {
+		buttons = append(buttons, discordgo.Button{Label: "질문 수정", Style: discordgo.SecondaryButton, CustomID: taskButtonID(prefixButtonEditMessage, info.TaskID, question)})
+	}
+	return []discordgo.MessageComponent{discordgo.ActionsRow{Components: buttons}}
+}
+
+// resultIndexes는 결과 메시지의 대화 인덱스와 그 앞의 마지막 user 메시지 인덱스를 찾습니다.
+// 앞에 user 메시지가 없으면 question은 -1입니다.
+func resultIndexes(messages []storage.MessageIndex, resultRef string) (result, question int, ok bool) {
+	question = -1
+	for _, m := range messages {
+		if m.FilePath == resultRef {
+			return m.ConversationIndex, question, true
+		}
+		if m.Role == storage.MessageRoleUser {
+			question = m.ConversationIndex
+		}
+	}
+	return 0, -1, false
+}
+
+// forkTask는 '여기서 분기' 버튼을 누른 지점까지의 대화로 새 작업을 만듭니다.
+func (s *Server) forkTask(i *discordgo.InteractionCreate, taskID string, index int) {
+	if !s.requireTaskModify(i, taskID, "분기") {
+		return
+	}
+	ctx := s.interactionContext(i)
+	fork, err := s.controller.ForkTask(ctx, taskID, index)
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to fork task", zap.Error(err), zap.String("task_id", taskID), zap.Int("index", index))
+		s.respondEphemeral(i, fmt.Sprintf("오류: Task '**%s**'를 분기하지 못했어요. 에러: %v", taskID, err))
+		return
+	}
+	s.respondEphemeral(i, fmt.Sprintf("Task '**%s**'의 #%d 메시지까지 복사해 새 Task '**%s**'를 만들었어요.", taskID, index, fork.TaskID))
+}
+
+// regenerateMessage는 '다시 생성' 버튼을 누른 결과를 다시 생성합니다.
+// 버튼을 게시한 뒤 대화가 이어졌다면 마지막 응답이 다른 메시지이므로 거절합니다.
+func (s *Server) regenerateMessage(i *discordgo.InteractionCreate, taskID string, index int) {
+	if !s.requireTaskModify(i, taskID, "다시 생성") {
+		return
+	}
+	ctx := s.interactionContext(i)
+	messages, err := s.controller.ListMessages(ctx, taskID)
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to list task messages", zap.Error(err), zap.String("task_id", taskID))
+		s.respondEphemeral(i, fmt.Sprintf("오류: Task '**%s**'의 메시지를 가져오지 못했어요. 에러: %v", taskID, err))
+		return
+	}
+	if len(messages) == 0 || messages[len(messages)-1].ConversationIndex != index {
+		s.respondEphemeral(i, fmt.Sprintf("Task '**%s**'의 #%d 메시지는 더 이상 마지막 응답이 아니에요.", taskID, index))
+		return
+	}
+	if _, err := s.controller.RegenerateMessage(ctx, taskID); err != nil {
+		s.logError(interactionKind(i), "Failed to regenerate message", zap.Error(err), zap.String("task_id", taskID), zap.Int("index", index))
+		s.respondEphemeral(i, fmt.Sprintf("오류: Task '**%s**'의 응답을 다시 생성하지 못했어요. 에러: %v", taskID, err))
+		return
+	}
+	s.respondEphemeral(i, fmt.Sprintf("Task '**%s**'의 #%d 응답을 다시 생성하고 있어요.", taskID, index))
+}
+
+// showEditMessageModal은 '질문 수정' 버튼을 누른 user 메시지를 수정하는 모달을 표시합니다.
+func (s *Server) showEditMessageModal(i *discordgo.InteractionCreate, taskID string, index int) {
+	if !s.requireTaskModify(i, taskID, "수정") {
+		return
+	}
+	ctx := s.interactionContext(i)
+	messages, err := s.controller.ListMessagesWithContent(ctx, taskID)
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to list task messages", zap.Error(err), zap.String("task_id", taskID))
+		s.respondEphemeral(i, fmt.Sprintf("오류: Task '**%s**'의 메시지를 가져오지 못했어요. 에러: %v", taskID, err))
+		return
+	}
+	var current *controller.MessageInfo
+	for _, m := range messages {
+		if m.ConversationIndex == index {
+			current = m
+			break
+		}
+	}
+	if current == nil {
+		s.respondEphemeral(i, fmt.Sprintf("Task '**%s**'에 #%d 메시지가 없어요.", taskID, index))
+		return
+	}
+
+	modal := &discordgo.InteractionResponseData{
+		CustomID: taskButtonID(prefixModalEditMessage, taskID, index),
+		Title:    "질문 수정",
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.TextInput{
+				CustomID: "content", Label: fmt.Sprintf("#%d 메시지", index), Style: discordgo.TextInputParagraph,
+				Required: true, MaxLength: maxTextInputLength, Value: truncateRunes(current.Content, maxTextInputLength),
+			}}},
+		},
+	}
+	err = s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: modal})
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to show message edit modal", zap.Error(err))
+	}
+}
+
+// editMessage는 질문 수정 모달에서 제출한 내용으로 메시지를 바꾸고 작업을 다시 실행합니다.
+func (s *Server) editMessage(i *discordgo.InteractionCreate, taskID string, index int, content string) {
+	if !s.requireTaskModify(i, taskID, "수정") {
+		return
+	}
+	ctx := s.interactionContext(i)
+	if err := s.controller.EditMessage(ctx, taskID, index, content); err != nil {
+		s.logError(interactionKind(i), "Failed to edit message", zap.Error(err), zap.String("task_id", taskID), zap.Int("index", index))
+		s.respondEphemeral(i, fmt.Sprintf("오류: Task '**%s**'의 #%d 메시지를 수정하지 못했어요. 에러: %v", taskID, index, err))
+		return
+	}
+	s.respondEphemeral(i, fmt.Sprintf("Task '**%s**'의 #%d 메시지를 수정하고 다시 실행하고 있어요.", taskID, index))
+}
+
+// taskButtonID는 작업의 특정 메시지를 가리키는 버튼이나 모달의 CustomID를 만듭니다.
+func taskButtonID(prefix, taskID string, index int) string {
+	return prefix + taskID + ":" + strconv.Itoa(index)
+}
+
+// parseTaskButtonID는 taskButtonID로 만든 CustomID에서 작업 ID와 대화 인덱스를 꺼냅니다.
+// 작업 ID에 ':'가 있을 수 있으므로 마지막 ':'로 나눕니다.
+func parseTaskButtonID(prefix, customID string) (string, int, bool) {
+	rest, ok := strings.CutPrefix(customID, prefix)
+	if !ok {
+		return "", 0, false
+	}
+	sep := strings.LastIndex(rest, ":")
+	if sep <= 0 {
+		return "", 0, false
+	}
+	index, err := strconv.Atoi(rest[sep+1:])
+	if err != nil || index < 0 {
+		return "", 0, false
+	}
+	return rest[:sep], index, true
+}
+
+// taskResultEmbed는 작업 실행 결과의 요약 임베드를 만듭니다.
+func taskResultEmbed(info *controller.TaskInfo) *discordgo.MessageEmbed {
 	embed := &discordgo.MessageEmbed{
-		Author:      &discordgo.MessageEmbedAuthor{Name: m.Author.Username, IconURL: m.Author.AvatarURL("")},
-		Description: m.Content,
-		Color:       0x0099ff, // Blue
-		Footer:      &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("'%s'에게 전달됨 (실행 기능은 미구현)", agent.Name)},
+		Title: fmt.Sprintf("Task '%s' 실행 결과", info.TaskID),
+		Color: 0x33cc33, // Green
+		Fields: []*discordgo.MessageEmbedField{
+			{Name: "상태", Value: info.Status, Inline: true},
+			{Name: "시도 횟수", Value: strconv.Itoa(info.Attempts), Inline: true},
+		},
+	}
+	if info.Status != storage.TaskStatusCompleted {
+		embed.Color = 0xff3333 // Red
+	}
+	if info.ResultModel != "" {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "응답 모델", Value: info.ResultModel, Inline: true})
+	}
+	if d := info.Duration(); d > 0 {
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{Name: "소요 시간", Value: d.Round(time.Millisecond).String(), Inline: true})
+	}
+	if info.ErrorClass != "" || info.ErrorMessage != "" {
+		message := info.ErrorMessage
+		if message == "" {
+			message = "-"
+		}
+		embed.Fields = append(embed.Fields, &discordgo.MessageEmbedField{
+			Name:  "오류 (" + info.ErrorClass + ")",
+			Value: truncateRunes(message, maxEmbedFieldValue),
+		})
+	}
+	return embed
+}
+
+// splitMessage는 Discord 메시지 길이 제한에 맞게 본문을 나눕니다. 가능하면 줄 단위로 자릅니다.
+func splitMessage(content string, limit int) []string {
+	var chunks []string
+	runes := []rune(content)
+	for len(runes) > limit {
+		cut := limit
+		for i := limit - 1; i > 0; i-- {
+			if runes[i] == '\n' {
+				cut = i + 1
+				break
+			}
+		}
+		chunks = append(chunks, string(runes[:cut]))
+		runes = runes[cut:]
 	}
-	if _, err := s.session.ChannelMessageSendEmbed(m.ChannelID, embed); err != nil {
-		s.logger.Error("Failed to send agent response embed", zap.Error(err), zap.String("channel_id", m.ChannelID))
+	if len(runes) > 0 {
+		chunks = append(chunks, string(runes))
+	}
+	return chunks
+}
+
+// truncateRunes는 문자 수가 limit을 넘으면 잘라내고 말줄임표를 붙입니다.
+func truncateRunes(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
 	}
+	return string(runes[:limit-1]) + "…"
 }
 
 // showAgentList는 현재 등록된 모든 에이전트의 목록을 Discord에 표시합니다.
 func (s *Server) showAgentList(i *discordgo.InteractionCreate) {
-	ctx := context.Background()
-	agents, err := s.controller.ListAgentsWithInfo(ctx)
+	ctx := s.interactionContext(i)
+	// 임베드 필드는 최대 25개이므로 이름순으로 25개까지만 표시합니다.
+	page, err := s.controller.ListAgentsPage(ctx, storage.AgentFilter{Sort: storage.SortName, Limit: maxEmbedFields})
 	if err != nil {
-		s.logger.Error("Failed to list agents from controller", zap.Error(err))
+		s.logError(interactionKind(i), "Failed to list agents from controller", zap.Error(err))
 		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 목록을 불러오는 데 실패했어요. 에러: %v", err))
 		return
 	}
 
-	if len(agents) == 0 {
+	if len(page.Agents) == 0 {
 		s.respondEphemeral(i, "생성된 에이전트가 아직 없어요. `/agent create`로 먼저 생성해주세요!")
 		return
 	}
 	fields := []*discordgo.MessageEmbedField{}
-	for _, agent := range agents {
+	for _, agent := range page.Agents {
 		fields = append(fields, &discordgo.MessageEmbedField{Name: agent.Name, Value: agent.Description, Inline: false})
 	}
+	var footer *discordgo.MessageEmbedFooter
+	if page.NextCursor != "" {
+		footer = &discordgo.MessageEmbedFooter{Text: fmt.Sprintf("처음 %d개만 표시했어요. 전체 목록은 `cnap agent list`로 확인하세요.", maxEmbedFields)}
+	}
 	err = s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{
 		Type: discordgo.InteractionResponseChannelMessageWithSource,
 		Data: &discordgo.InteractionResponseData{
 			Embeds: []*discordgo.MessageEmbed{{
 				Title:  "생성된 에이전트 목록",
 				Fields: fields,
+				Footer: footer,
 				Color:  0x0099ff,
 			}},
 		},
 	})
 	if err != nil {
-		s.logger.Error("Failed to show agent list", zap.Error(err))
+		s.logError(interactionKind(i), "Failed to show agent list", zap.Error(err))
 	}
 }
 
 // showAgentDetails는 특정 에이전트의 상세 정보를 Discord에 표시합니다.
 func (s *Server) showAgentDetails(i *discordgo.InteractionCreate, name string) {
-	ctx := context.Background()
+	ctx := s.interactionContext(i)
 	agent, err := s.controller.GetAgentInfo(ctx, name)
 	if err != nil {
-		s.logger.Error("Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", name))
+		s.logError(interactionKind(i), "Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", name))
 		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 정보를 가져오는 데 실패했어요. 에러: %v", name, err))
 		return
 	}
@@ -378,15 +886,26 @@ func (s *Server) showAgentDetails(i *discordgo.InteractionCreate, name string) {
 	}
 	err = s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseChannelMessageWithSource, Data: &discordgo.InteractionResponseData{Embeds: []*discordgo.MessageEmbed{embed}}})
 	if err != nil {
-		s.logger.Error("Failed to show agent details", zap.Error(err), zap.String("agent", name))
+		s.logError(interactionKind(i), "Failed to show agent details", zap.Error(err), zap.String("agent", name))
 	}
 }
 
 // deleteAgent는 지정된 이름의 에이전트를 삭제합니다.
 func (s *Server) deleteAgent(i *discordgo.InteractionCreate, name string) {
-	ctx := context.Background()
-	if err := s.controller.DeleteAgent(ctx, name); err != nil {
-		s.logger.Error("Failed to delete agent from controller", zap.Error(err), zap.String("agent_id", name))
+	ctx := s.interactionContext(i)
+	agent, err := s.controller.GetAgentInfo(ctx, name)
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", name))
+		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 정보를 가져오는 데 실패했어요. 에러: %v", name, err))
+		return
+	}
+	if !s.requireAgentModify(i, agent, "삭제") {
+		return
+	}
+
+	canceled, err := s.controller.DeleteAgent(ctx, name)
+	if err != nil {
+		s.logError(interactionKind(i), "Failed to delete agent from controller", zap.Error(err), zap.String("agent_id", name))
 		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'을(를) 삭제하는 데 실패했어요. 에러: %v", name, err))
 		return
 	}
@@ -396,24 +915,31 @@ func (s *Server) deleteAgent(i *discordgo.InteractionCreate, name string) {
 	// The controller doesn't know about discord threads. This logic should probably remain here.
 	s.threadsMutex.Lock()
 	defer s.threadsMutex.Unlock()
-	for threadID, agentName := range s.activeThreads {
-		if agentName == name {
+	for threadID, thread := range s.activeThreads {
+		if thread.agent == name {
 			delete(s.activeThreads, threadID)
 			// Maybe notify the thread that the agent is gone? For now, just deleting the link is fine.
 		}
 	}
-	s.respondEphemeral(i, fmt.Sprintf("에이전트 '**%s**'이(가) 성공적으로 삭제되었어요.", name))
+	msg := fmt.Sprintf("에이전트 '**%s**'이(가) 성공적으로 삭제되었어요.", name)
+	if len(canceled) > 0 {
+		msg += fmt.Sprintf(" 진행 중이던 작업 %d개를 취소했어요.", len(canceled))
+	}
+	s.respondEphemeral(i, msg)
 }
 
 // showEditUI는 특정 에이전트의 현재 정보를 임베드 메시지로 표시하고, 수정 모달을 열기 위한 버튼을 제공합니다.
 func (s *Server) showEditUI(i *discordgo.InteractionCreate, name string) {
-	ctx := context.Background()
+	ctx := s.interactionContext(i)
 	agent, err := s.controller.GetAgentInfo(ctx, name)
 	if err != nil {
-		s.logger.Error("Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", name))
+		s.logError(interactionKind(i), "Failed to get agent info from controller", zap.Error(err), zap.String("agent_id", name))
 		s.respondEphemeral(i, fmt.Sprintf("오류: 에이전트 '**%s**'의 정보를 가져오는 데 실패했어요. 에러: %v", name, err))
 		return
 	}
+	if !s.requireAgentModify(i, agent, "수정") {
+		return
+	}
 
 	embed := &discordgo.MessageEmbed{
 		Title: "에이전트 수정: " + agent.Name, Description: "아래는 현재 정보예요. 수정하려면 버튼을 눌러주세요.", Color: 0xffaa00,
@@ -430,8 +956,26 @@ func (s *Server) showEditUI(i *discordgo.InteractionCreate, name string) {
 		}}},
 	}})
 	if err != nil {
-		s.logger.Error("Failed to show edit UI", zap.Error(err), zap.String("agent", name))
+		s.logError(interactionKind(i), "Failed to show edit UI", zap.Error(err), zap.String("agent", name))
+	}
+}
+
+// editModalID는 수정 모달의 CustomID를 만듭니다. 모달을 열 때의 에이전트 버전을 함께 담아
+// 제출 시 그 사이 다른 수정이 있었는지 확인합니다.
+func editModalID(name string, version int) string {
+	return prefixModalEdit + strconv.Itoa(version) + "_" + name
+}
+
+// parseEditModalID는 editModalID로 만든 CustomID에서 에이전트 이름과 버전을 꺼냅니다.
+// 버전이 없는 이전 형식이면 버전은 0입니다.
+func parseEditModalID(customID string) (string, int) {
+	rest := strings.TrimPrefix(customID, prefixModalEdit)
+	if v, name, ok := strings.Cut(rest, "_"); ok {
+		if version, err := strconv.Atoi(v); err == nil && name != "" {
+			return name, version
+		}
 	}
+	return rest, 0
 }
 
 // showCreateOrEditModal은 에이전트 생성/수정 모달을 표시합니다.
@@ -442,7 +986,7 @@ func (s *Server) showCreateOrEditModal(i *discordgo.InteractionCreate, originalN
 
 	if agent != nil { // 수정 모드
 		modalTitle = "에이전트 정보 수정"
-		customID = prefixModalEdit + originalName
+		customID = editModalID(originalName, agent.Version)
 		name, desc, model, prompt = agent.Name, agent.Description, agent.Model, agent.Prompt
 	}
 
@@ -457,6 +1001,6 @@ func (s *Server) showCreateOrEditModal(i *discordgo.InteractionCreate, originalN
 	}
 	err := s.session.InteractionRespond(i.Interaction, &discordgo.InteractionResponse{Type: discordgo.InteractionResponseModal, Data: modal})
 	if err != nil {
-		s.logger.Error("Failed to show create/edit modal", zap.Error(err))
+		s.logError(interactionKind(i), "Failed to show create/edit modal", zap.Error(err))
 	}
 }
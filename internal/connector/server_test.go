@@ -0,0 +1,160 @@
+package connector
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/testutil/mocks"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTaskResultEmbed(t *testing.T) {
+	started := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	finished := started.Add(2500 * time.Millisecond)
+
+	embed := taskResultEmbed(&controller.TaskInfo{
+		TaskID:      "task-1",
+		Status:      storage.TaskStatusCompleted,
+		Attempts:    1,
+		ResultModel: "gpt-4o",
+		StartedAt:   &started,
+		FinishedAt:  &finished,
+	})
+	require.Equal(t, 0x33cc33, embed.Color)
+	fields := map[string]string{}
+	for _, f := range embed.Fields {
+		fields[f.Name] = f.Value
+	}
+	require.Equal(t, map[string]string{
+		"상태":    storage.TaskStatusCompleted,
+		"시도 횟수": "1",
+		"응답 모델": "gpt-4o",
+		"소요 시간": "2.5s",
+	}, fields)
+
+	embed = taskResultEmbed(&controller.TaskInfo{
+		TaskID:       "task-2",
+		Status:       storage.TaskStatusFailed,
+		Attempts:     2,
+		ErrorClass:   "rate_limited",
+		ErrorMessage: strings.Repeat("x", 2000),
+	})
+	require.Equal(t, 0xff3333, embed.Color)
+	last := embed.Fields[len(embed.Fields)-1]
+	require.Equal(t, "오류 (rate_limited)", last.Name)
+	require.Len(t, []rune(last.Value), maxEmbedFieldValue)
+}
+
+func TestSplitMessage(t *testing.T) {
+	require.Equal(t, []string{"short"}, splitMessage("short", 10))
+	require.Empty(t, splitMessage("", 10))
+
+	// 줄바꿈이 있으면 그 뒤에서 자릅니다.
+	require.Equal(t, []string{"가나다\n", "라마바사아자"}, splitMessage("가나다\n라마바사아자", 8))
+	// 줄바꿈이 없으면 제한 길이에서 자릅니다.
+	require.Equal(t, []string{"abcd", "efgh", "ij"}, splitMessage("abcdefghij", 4))
+}
+
+func TestTaskButtonID(t *testing.T) {
+	id := taskButtonID(prefixButtonFork, "team:task-7", 12)
+	for _, prefix := range []string{prefixButtonFork, prefixButtonRegen, prefixButtonEditMessage, prefixModalEditMessage} {
+		require.LessOrEqual(t, len(taskButtonID(prefix, strings.Repeat("t", 64), 99999)), 100, "Discord custom ID limit")
+	}
+
+	taskID, index, ok := parseTaskButtonID(prefixButtonFork, id)
+	require.True(t, ok)
+	require.Equal(t, "team:task-7", taskID)
+	require.Equal(t, 12, index)
+
+	_, _, ok = parseTaskButtonID(prefixButtonRegen, id)
+	require.False(t, ok, "prefix mismatch")
+	for _, invalid := range []string{"edit_agent_x", prefixButtonFork + "task", prefixButtonFork + ":3", prefixButtonFork + "task:-1", prefixButtonFork + "task:x"} {
+		_, _, ok := parseTaskButtonID(prefixButtonFork, invalid)
+		require.False(t, ok, invalid)
+	}
+}
+
+func TestResultIndexes(t *testing.T) {
+	messages := []storage.MessageIndex{
+		{ConversationIndex: 0, Role: storage.MessageRoleUser, FilePath: "a"},
+		{ConversationIndex: 1, Role: storage.MessageRoleAssistant, FilePath: "b"},
+		{ConversationIndex: 3, Role: storage.MessageRoleUser, FilePath: "c"},
+		{ConversationIndex: 4, Role: storage.MessageRoleAssistant, FilePath: "d"},
+	}
+	result, question, ok := resultIndexes(messages, "d")
+	require.True(t, ok)
+	require.Equal(t, 4, result)
+	require.Equal(t, 3, question)
+
+	_, question, ok = resultIndexes(messages[1:], "b")
+	require.True(t, ok)
+	require.Equal(t, -1, question, "no preceding user message")
+
+	_, _, ok = resultIndexes(messages, "missing")
+	require.False(t, ok)
+}
+
+func TestParseCallVariables(t *testing.T) {
+	vars, err := parseCallVariables(" Language=Go , Repo=cnap/app ")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"Language": "Go", "Repo": "cnap/app"}, vars)
+
+	vars, err = parseCallVariables("  ")
+	require.NoError(t, err)
+	require.Empty(t, vars)
+
+	_, err = parseCallVariables("Language")
+	require.Error(t, err)
+	_, err = parseCallVariables("Language=Go, Language=Rust")
+	require.Error(t, err)
+}
+
+func TestNotifyTaskResult(t *testing.T) {
+	s := NewServer(zap.NewNop(), nil, Config{})
+
+	// 채널 레이블이 없는 작업은 게시하지 않습니다.
+	require.NoError(t, s.NotifyTaskResult(context.Background(), &controller.TaskInfo{TaskID: "cli-task"}))
+
+	// 레이블이 있으면 그 채널에 게시하려 하며, 세션이 없으면 에러를 반환합니다.
+	info := &controller.TaskInfo{TaskID: "thread-task", Labels: map[string]string{TaskChannelLabel: "123"}}
+	require.Error(t, s.NotifyTaskResult(context.Background(), info))
+}
+
+func TestRunThreadTask(t *testing.T) {
+	messages, err := msgstore.NewFSStore(t.TempDir())
+	require.NoError(t, err)
+	runner := mocks.NewMockRunner()
+	ctrl := controller.NewController(zap.NewNop(), storage.NewMemoryStore(),
+		controller.WithMessageStore(messages),
+		controller.WithTaskRunner(runner),
+	)
+	s := NewServer(zap.NewNop(), ctrl, Config{})
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "writer", "Writer agent", "gpt-4", "Write in {{.Language}}"))
+
+	// 스레드 메시지마다 스레드 레이블과 스레드의 프롬프트 변수가 붙은 작업을 만들어 실행합니다.
+	vars := map[string]string{"Language": "Korean"}
+	taskID, err := s.runThreadTask(ctx, "thread-1", "1001", "writer", "Write a haiku", vars)
+	require.NoError(t, err)
+	require.Equal(t, "discord-1001", taskID)
+	require.NoError(t, ctrl.Stop(ctx))
+
+	info, err := ctrl.GetTaskInfo(ctx, taskID)
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCompleted, info.Status)
+	require.Equal(t, "thread-1", info.Labels[TaskChannelLabel])
+	require.Equal(t, "Write a haiku", info.Prompt)
+	require.Equal(t, runner.DefaultResponse, info.Output)
+	require.Equal(t, vars, info.Variables)
+	require.Equal(t, "Write in Korean", info.SystemPrompt)
+
+	_, err = s.runThreadTask(ctx, "thread-1", "1002", "missing", "Hello", nil)
+	require.Error(t, err)
+}
@@ -0,0 +1,39 @@
+package controller
+
+import "context"
+
+type actorKey struct{}
+
+// 요청 주체(actor) 식별자에 사용되는 접두사입니다.
+const (
+	ActorPrefixCLI     = "cli:"
+	ActorPrefixDiscord = "discord:"
+	ActorPrefixAPIKey  = "apikey:"
+)
+
+// WithActor는 요청을 수행하는 주체(actor)를 컨텍스트에 기록합니다.
+// actor는 "discord:<user-id>", "cli:<user>"처럼 출처 접두사를 포함한 문자열입니다.
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext는 컨텍스트에 기록된 actor를 반환합니다. 없으면 빈 문자열입니다.
+func ActorFromContext(ctx context.Context) string {
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// DiscordActor는 Discord 사용자 ID에 대한 actor 문자열을 만듭니다.
+func DiscordActor(userID string) string {
+	return ActorPrefixDiscord + userID
+}
+
+// CLIActor는 CLI 사용자 이름에 대한 actor 문자열을 만듭니다.
+func CLIActor(user string) string {
+	return ActorPrefixCLI + user
+}
+
+// APIKeyActor는 API 키 식별자에 대한 actor 문자열을 만듭니다.
+func APIKeyActor(keyID string) string {
+	return ActorPrefixAPIKey + keyID
+}
@@ -0,0 +1,233 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ErrAgentDeleted는 삭제된 에이전트로 새 작업을 만들거나 실행하려 할 때 반환됩니다.
+var ErrAgentDeleted = errors.New("agent is deleted")
+
+// liveAgentStatuses는 목록에 표시되는, 삭제되지 않은 에이전트 상태입니다.
+var liveAgentStatuses = []string{storage.AgentStatusActive, storage.AgentStatusIdle, storage.AgentStatusBusy}
+
+// DeleteOption은 DeleteAgent의 동작을 바꿉니다.
+type DeleteOption func(*deleteOptions)
+
+type deleteOptions struct {
+	drain bool
+}
+
+// WithDrainRunningTasks는 삭제할 에이전트의 작업을 취소하지 않고 남겨 둡니다.
+// 실행 중인 작업은 끝까지 실행되고, 대기 중인 작업은 에이전트를 복구할 때까지 실행할 수 없습니다.
+func WithDrainRunningTasks() DeleteOption {
+	return func(o *deleteOptions) {
+		o.drain = true
+	}
+}
+
+// DeleteAgent는 에이전트를 삭제 상태로 바꿉니다(soft delete).
+// 삭제된 에이전트는 목록과 자동 완성에서 제외되고 새 작업을 만들거나 실행할 수 없으며,
+// RestoreAgent로 되돌리거나 PurgeAgent로 영구 삭제할 수 있습니다.
+// 기본적으로 대기 중이거나 실행 중인 작업을 취소하고 취소한 작업 ID를 반환합니다.
+func (c *Controller) DeleteAgent(ctx context.Context, agent string, opts ...DeleteOption) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "controller.DeleteAgent", attribute.String("cnap.agent_id", agent))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	var o deleteOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	logger.Info("Deleting agent",
+		zap.String("agent", agent),
+		zap.Bool("drain", o.drain),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	before, err := c.repo.GetAgent(ctx, agent)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("agent not found: %s", agent)
+		}
+		return nil, err
+	}
+	if before.Status == storage.AgentStatusDeleted {
+		return nil, fmt.Errorf("agent is already deleted: %s", agent)
+	}
+
+	deleted := *before
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.UpdateAgentStatus(ctx, &deleted, storage.AgentStatusDeleted); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionAgentDelete, storage.AuditTargetAgent, agent, agent, agentAuditState(before), agentAuditState(&deleted))
+	})
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	var canceled []string
+	if !o.drain {
+		canceled, err = c.cancelAgentTasks(ctx, agent)
+		if err != nil {
+			logger.Error("Failed to cancel tasks of deleted agent", zap.Error(err))
+			tracing.RecordError(span, err)
+			return canceled, fmt.Errorf("agent deleted but failed to cancel tasks: %w", err)
+		}
+	}
+
+	logger.Info("Agent deleted successfully",
+		zap.String("agent", agent),
+		zap.Int("canceled_tasks", len(canceled)),
+	)
+	return canceled, nil
+}
+
+// cancelAgentTasks는 에이전트의 대기 중이거나 실행 중인 작업을 모두 취소합니다.
+// 그 사이 상태가 바뀐 작업은 건너뜁니다.
+func (c *Controller) cancelAgentTasks(ctx context.Context, agentID string) ([]string, error) {
+	logger := tracing.Logger(ctx, c.logger)
+
+	var canceled []string
+	filter := storage.TaskFilter{
+		AgentID:  agentID,
+		Statuses: []string{storage.TaskStatusPending, storage.TaskStatusRunning},
+		Limit:    500,
+	}
+	for {
+		tasks, next, err := c.repo.ListTasksPage(ctx, filter)
+		if err != nil {
+			return canceled, err
+		}
+		for i := range tasks {
+			before := tasks[i]
+			task := before
+			err := c.repo.InTx(ctx, func(repo storage.Store) error {
+				if err := repo.UpdateTaskStatus(ctx, &task, storage.TaskStatusCanceled); err != nil {
+					return err
+				}
+				return c.recordAudit(ctx, repo, storage.AuditActionTaskCancel, storage.AuditTargetTask, task.TaskID, agentID, taskAuditState(&before), taskAuditState(&task))
+			})
+			if err != nil {
+				if errors.Is(err, storage.ErrConflict) {
+					logger.Info("Task changed while canceling; skipping", zap.String("task_id", task.TaskID))
+					continue
+				}
+				return canceled, err
+			}
+			c.observeTaskDuration(ctx, &before, storage.TaskStatusCanceled)
+			canceled = append(canceled, task.TaskID)
+		}
+		if next == "" {
+			return canceled, nil
+		}
+		filter.Cursor = next
+	}
+}
+
+// RestoreAgent는 삭제된 에이전트를 다시 활성화합니다. 삭제할 때 취소된 작업은 되살리지 않습니다.
+func (c *Controller) RestoreAgent(ctx context.Context, agent string) error {
+	ctx, span := tracing.Start(ctx, "controller.RestoreAgent", attribute.String("cnap.agent_id", agent))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Restoring agent",
+		zap.String("agent", agent),
+	)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+
+	before, err := c.repo.GetAgent(ctx, agent)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("agent not found: %s", agent)
+		}
+		return err
+	}
+	if before.Status != storage.AgentStatusDeleted {
+		return fmt.Errorf("agent is not deleted: %s", agent)
+	}
+
+	restored := *before
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.UpdateAgentStatus(ctx, &restored, storage.AgentStatusActive); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionAgentRestore, storage.AuditTargetAgent, agent, agent, agentAuditState(before), agentAuditState(&restored))
+	})
+	if err != nil {
+		logger.Error("Failed to restore agent", zap.Error(err))
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	logger.Info("Agent restored successfully",
+		zap.String("agent", agent),
+	)
+	return nil
+}
+
+// PurgeAgent는 삭제된 에이전트를 작업, 메시지, 실행 단계, 체크포인트, 저장된 메시지 파일과 함께 영구 삭제합니다.
+// 실수로 지우지 않도록 먼저 DeleteAgent로 삭제한 에이전트만 영구 삭제할 수 있습니다.
+// 감사 로그는 남기며, 삭제한 메시지 파일 수를 반환합니다.
+func (c *Controller) PurgeAgent(ctx context.Context, agent string) (int, error) {
+	ctx, span := tracing.Start(ctx, "controller.PurgeAgent", attribute.String("cnap.agent_id", agent))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Purging agent",
+		zap.String("agent", agent),
+	)
+
+	if c.repo == nil {
+		return 0, fmt.Errorf("controller: repository is not configured")
+	}
+
+	before, err := c.repo.GetAgent(ctx, agent)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, fmt.Errorf("agent not found: %s", agent)
+		}
+		return 0, err
+	}
+	if before.Status != storage.AgentStatusDeleted {
+		return 0, fmt.Errorf("agent must be deleted before purge: %s", agent)
+	}
+
+	var files []string
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		files, err = repo.PurgeAgent(ctx, agent)
+		if err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionAgentPurge, storage.AuditTargetAgent, agent, agent, agentAuditState(before), nil)
+	})
+	if err != nil {
+		logger.Error("Failed to purge agent", zap.Error(err))
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+
+	removed := c.removeMessageFiles(ctx, files)
+
+	logger.Info("Agent purged successfully",
+		zap.String("agent", agent),
+		zap.Int("messages", len(files)),
+		zap.Int("removed_files", removed),
+	)
+	return removed, nil
+}
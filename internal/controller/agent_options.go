@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/cnap-oss/app/internal/storage"
+)
+
+// AgentOption은 CreateAgent, UpdateAgent에서 모델 파라미터와 도구를 지정합니다.
+type AgentOption func(*agentSpec) error
+
+// WithParameters는 에이전트의 모델 파라미터(temperature 등)를 지정합니다. 비어 있으면 파라미터를 지웁니다.
+func WithParameters(parameters map[string]any) AgentOption {
+	return func(f *agentSpec) error {
+		if len(parameters) == 0 {
+			f.Parameters = ""
+			return nil
+		}
+		data, err := json.Marshal(parameters)
+		if err != nil {
+			return fmt.Errorf("invalid parameters: %w", err)
+		}
+		f.Parameters = string(data)
+		return nil
+	}
+}
+
+// WithTools는 에이전트가 사용할 도구 목록을 지정합니다. 비어 있으면 도구를 지웁니다.
+func WithTools(tools []string) AgentOption {
+	return func(f *agentSpec) error {
+		if len(tools) == 0 {
+			f.Tools = ""
+			return nil
+		}
+		for _, tool := range tools {
+			if strings.TrimSpace(tool) == "" {
+				return fmt.Errorf("invalid tools: empty tool name")
+			}
+		}
+		data, err := json.Marshal(tools)
+		if err != nil {
+			return fmt.Errorf("invalid tools: %w", err)
+		}
+		f.Tools = string(data)
+		return nil
+	}
+}
+
+// WithExpectedVersion은 UpdateAgent가 에이전트의 행 버전이 version일 때만 수정하도록 합니다.
+// UpdateAgent에는 반드시 지정해야 하며, 에이전트를 읽은 뒤 다른 수정이 먼저 반영되었으면 storage.ErrConflict를 반환합니다.
+func WithExpectedVersion(version int) AgentOption {
+	return func(f *agentSpec) error {
+		if version <= 0 {
+			return fmt.Errorf("invalid expected version: %d", version)
+		}
+		f.expectedVersion = version
+		return nil
+	}
+}
+
+// agentSpec은 AgentOption이 채우는 값입니다.
+type agentSpec struct {
+	agentFields
+	// expectedVersion은 수정 전에 확인할 행 버전입니다. UpdateAgent는 0이면 충돌로 처리합니다.
+	expectedVersion int
+	// labels는 WithLabels로 지정한 레이블입니다. nil이면 레이블을 바꾸지 않습니다.
+	labels map[string]string
+}
+
+// agentFields는 리비전으로 기록되는 에이전트 설정입니다.
+// Parameters와 Tools는 저장소와 같은 JSON 문자열이며, 값이 없으면 빈 문자열입니다.
+type agentFields struct {
+	Description string
+	Model       string
+	Prompt      string
+	Parameters  string
+	Tools       string
+}
+
+func applyAgentOptions(fields agentFields, opts []AgentOption) (agentSpec, error) {
+	spec := agentSpec{agentFields: fields}
+	for _, opt := range opts {
+		if err := opt(&spec); err != nil {
+			return agentSpec{}, err
+		}
+	}
+	if _, err := ParsePromptTemplate(spec.Prompt); err != nil {
+		return agentSpec{}, fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return spec, nil
+}
+
+func agentFieldsOf(agent *storage.Agent) agentFields {
+	return agentFields{
+		Description: agent.Description,
+		Model:       agent.Model,
+		Prompt:      agent.Prompt,
+		Parameters:  agent.Parameters,
+		Tools:       agent.Tools,
+	}
+}
+
+func agentFieldsOfRevision(rev *storage.AgentRevision) agentFields {
+	return agentFields{
+		Description: rev.Description,
+		Model:       rev.Model,
+		Prompt:      rev.Prompt,
+		Parameters:  rev.Parameters,
+		Tools:       rev.Tools,
+	}
+}
+
+// diffAgentFields는 값이 달라진 필드의 줄 단위 diff를 반환합니다.
+// JSON 필드는 들여쓰기한 뒤 비교해 바뀐 키가 줄 단위로 드러나게 합니다.
+func diffAgentFields(from, to agentFields) []FieldDiff {
+	fields := []struct {
+		name     string
+		old, new string
+	}{
+		{"description", from.Description, to.Description},
+		{"model", from.Model, to.Model},
+		{"prompt", from.Prompt, to.Prompt},
+		{"parameters", indentJSON(from.Parameters), indentJSON(to.Parameters)},
+		{"tools", indentJSON(from.Tools), indentJSON(to.Tools)},
+	}
+	var diffs []FieldDiff
+	for _, f := range fields {
+		if f.old != f.new {
+			diffs = append(diffs, FieldDiff{Field: f.name, Lines: diffLines(f.old, f.new)})
+		}
+	}
+	return diffs
+}
+
+func indentJSON(value string) string {
+	if value == "" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, []byte(value), "", "  "); err != nil {
+		return value
+	}
+	return buf.String()
+}
+
+// decodeAgentSettings는 저장된 파라미터와 도구 JSON을 해석합니다.
+func decodeAgentSettings(parameters, tools string) (map[string]any, []string, error) {
+	var (
+		params map[string]any
+		names  []string
+	)
+	if parameters != "" {
+		if err := json.Unmarshal([]byte(parameters), &params); err != nil {
+			return nil, nil, fmt.Errorf("invalid stored parameters: %w", err)
+		}
+	}
+	if tools != "" {
+		if err := json.Unmarshal([]byte(tools), &names); err != nil {
+			return nil, nil, fmt.Errorf("invalid stored tools: %w", err)
+		}
+	}
+	return params, names, nil
+}
@@ -0,0 +1,151 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.uber.org/zap"
+)
+
+// systemActor는 actor가 기록되지 않은 컨텍스트에서 사용됩니다.
+const systemActor = "system"
+
+// recordAudit은 쓰기 작업에 대한 감사 로그를 repo에 남깁니다.
+// 쓰기와 같은 트랜잭션(storage.Store.InTx)의 repo를 넘기므로, 감사 로그를 남기지 못하면 쓰기도 되돌려집니다.
+func (c *Controller) recordAudit(ctx context.Context, repo storage.Store, action, targetType, targetID, agentID string, before, after map[string]string) error {
+	actor := ActorFromContext(ctx)
+	if actor == "" {
+		actor = systemActor
+	}
+
+	event := &storage.AuditEvent{
+		Actor:       actor,
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		AgentID:     agentID,
+		BeforeState: marshalAuditState(before),
+		AfterState:  marshalAuditState(after),
+	}
+
+	if err := repo.CreateAuditEvent(ctx, event); err != nil {
+		tracing.Logger(ctx, c.logger).Error("Failed to record audit event",
+			zap.Error(err),
+			zap.String("action", action),
+			zap.String("target_id", targetID),
+			zap.String("actor", actor),
+		)
+		return fmt.Errorf("record audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents는 조건에 맞는 감사 로그를 반환합니다.
+func (c *Controller) ListAuditEvents(ctx context.Context, filter storage.AuditFilter) ([]storage.AuditEvent, error) {
+	ctx, span := tracing.Start(ctx, "controller.ListAuditEvents")
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing audit events",
+		zap.String("agent_id", filter.AgentID),
+		zap.Time("since", filter.Since),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	events, err := c.repo.ListAuditEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Listed audit events", zap.Int("count", len(events)))
+	return events, nil
+}
+
+// AuditDiff는 감사 로그의 before/after 상태에서 값이 바뀐 필드를 반환합니다.
+// 반환값은 필드 이름별 [before, after] 쌍입니다.
+func AuditDiff(event storage.AuditEvent) (map[string][2]string, error) {
+	before, err := unmarshalAuditState(event.BeforeState)
+	if err != nil {
+		return nil, fmt.Errorf("decode before state: %w", err)
+	}
+	after, err := unmarshalAuditState(event.AfterState)
+	if err != nil {
+		return nil, fmt.Errorf("decode after state: %w", err)
+	}
+
+	diff := make(map[string][2]string)
+	for key, value := range after {
+		if before[key] != value {
+			diff[key] = [2]string{before[key], value}
+		}
+	}
+	for key, value := range before {
+		if _, ok := after[key]; !ok {
+			diff[key] = [2]string{value, ""}
+		}
+	}
+	return diff, nil
+}
+
+func agentAuditState(agent *storage.Agent) map[string]string {
+	if agent == nil {
+		return nil
+	}
+	state := map[string]string{
+		"description": agent.Description,
+		"model":       agent.Model,
+		"prompt":      agent.Prompt,
+		"parameters":  agent.Parameters,
+		"tools":       agent.Tools,
+		"status":      agent.Status,
+		"owner":       agent.OwnerID,
+	}
+	if len(agent.Labels) > 0 {
+		state["labels"] = storage.FormatLabels(agent.Labels)
+	}
+	return state
+}
+
+func taskAuditState(task *storage.Task) map[string]string {
+	if task == nil {
+		return nil
+	}
+	state := map[string]string{
+		"agent_id": task.AgentID,
+		"prompt":   task.Prompt,
+		"status":   task.Status,
+		"owner":    task.OwnerID,
+	}
+	if len(task.Labels) > 0 {
+		state["labels"] = storage.FormatLabels(task.Labels)
+	}
+	return state
+}
+
+func marshalAuditState(state map[string]string) string {
+	if state == nil {
+		return ""
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func unmarshalAuditState(raw string) (map[string]string, error) {
+	state := make(map[string]string)
+	if raw == "" {
+		return state, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
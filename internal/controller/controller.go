@@ -4,25 +4,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/cnap-oss/app/internal/metrics"
+	"github.com/cnap-oss/app/internal/msgstore"
+	taskrunner "github.com/cnap-oss/app/internal/runner"
 	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
 // Controller는 에이전트 생성 및 관리를 담당하며, supervisor 기능도 포함합니다.
 type Controller struct {
-	logger *zap.Logger
-	repo   *storage.Repository
+	logger   *zap.Logger
+	repo     storage.Store
+	messages msgstore.MessageStore
+	notifier TaskNotifier
+	runner   taskrunner.TaskRunner
+	runs     sync.WaitGroup
+
+	retentionInterval time.Duration
+	retentionRules    []storage.RetentionRule
+}
+
+// Option은 Controller 생성 시 선택적 구성 요소를 설정합니다.
+type Option func(*Controller)
+
+// WithMessageStore는 메시지 본문을 저장할 저장소를 설정합니다.
+func WithMessageStore(store msgstore.MessageStore) Option {
+	return func(c *Controller) {
+		c.messages = store
+	}
+}
+
+// WithTaskRunner는 SendMessage가 작업을 실행할 TaskRunner를 설정합니다.
+// 설정하지 않으면 작업은 running으로만 바뀌고, 결과는 OnComplete/OnError로 따로 기록해야 합니다.
+func WithTaskRunner(runner taskrunner.TaskRunner) Option {
+	return func(c *Controller) {
+		c.runner = runner
+	}
 }
 
 // NewController는 새로운 Controller를 생성합니다.
-func NewController(logger *zap.Logger, repo *storage.Repository) *Controller {
-	return &Controller{
+func NewController(logger *zap.Logger, repo storage.Store, opts ...Option) *Controller {
+	c := &Controller{
 		logger: logger,
 		repo:   repo,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
 // Start는 controller 서버를 시작합니다.
@@ -33,6 +69,18 @@ func (c *Controller) Start(ctx context.Context) error {
 	ticker := time.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
+	// 보존 정책이 없으면 nil 채널이라 선택되지 않습니다.
+	var retention <-chan time.Time
+	if c.retentionInterval > 0 && len(c.retentionRules) > 0 {
+		retentionTicker := time.NewTicker(c.retentionInterval)
+		defer retentionTicker.Stop()
+		retention = retentionTicker.C
+		c.logger.Info("Retention job enabled",
+			zap.Duration("interval", c.retentionInterval),
+			zap.Int("rules", len(c.retentionRules)),
+		)
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -40,6 +88,8 @@ func (c *Controller) Start(ctx context.Context) error {
 			return ctx.Err()
 		case <-ticker.C:
 			c.logger.Debug("Controller heartbeat")
+		case <-retention:
+			c.runRetention(ctx)
 		}
 	}
 }
@@ -48,19 +98,29 @@ func (c *Controller) Start(ctx context.Context) error {
 func (c *Controller) Stop(ctx context.Context) error {
 	c.logger.Info("Stopping controller server")
 
-	// 정리 작업 수행
+	// 실행 중인 작업의 결과가 기록될 때까지 기다립니다.
+	done := make(chan struct{})
+	go func() {
+		c.runs.Wait()
+		close(done)
+	}()
 	select {
 	case <-ctx.Done():
 		return fmt.Errorf("shutdown timeout exceeded")
-	case <-time.After(100 * time.Millisecond):
+	case <-done:
 		c.logger.Info("Controller server stopped")
 		return nil
 	}
 }
 
 // CreateAgent는 새로운 에이전트를 생성합니다.
-func (c *Controller) CreateAgent(ctx context.Context, agentID, description, model, prompt string) error {
-	c.logger.Info("Creating agent",
+// 모델 파라미터와 도구는 WithParameters, WithTools로 지정합니다.
+func (c *Controller) CreateAgent(ctx context.Context, agentID, description, model, prompt string, opts ...AgentOption) error {
+	ctx, span := tracing.Start(ctx, "controller.CreateAgent", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Creating agent",
 		zap.String("agent_id", agentID),
 		zap.String("model", model),
 	)
@@ -69,55 +129,56 @@ func (c *Controller) CreateAgent(ctx context.Context, agentID, description, mode
 		return fmt.Errorf("controller: repository is not configured")
 	}
 
+	spec, err := applyAgentOptions(agentFields{Description: description, Model: model, Prompt: prompt}, opts)
+	if err != nil {
+		return err
+	}
+	fields := spec.agentFields
+
 	payload := &storage.Agent{
 		AgentID:     agentID,
-		Description: description,
-		Model:       model,
-		Prompt:      prompt,
+		Description: fields.Description,
+		Model:       fields.Model,
+		Prompt:      fields.Prompt,
+		Parameters:  fields.Parameters,
+		Tools:       fields.Tools,
 		Status:      storage.AgentStatusActive,
+		OwnerID:     ActorFromContext(ctx),
+		Labels:      spec.labels,
 	}
 
-	if err := c.repo.CreateAgent(ctx, payload); err != nil {
-		c.logger.Error("Failed to persist agent", zap.Error(err))
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.CreateAgent(ctx, payload); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionAgentCreate, storage.AuditTargetAgent, agentID, agentID, nil, agentAuditState(payload))
+	})
+	if err != nil {
+		logger.Error("Failed to persist agent", zap.Error(err))
+		tracing.RecordError(span, err)
 		return err
 	}
 
-	c.logger.Info("Agent created successfully",
+	logger.Info("Agent created successfully",
 		zap.String("agent", agentID),
 		zap.Int64("id", payload.ID),
 	)
 	return nil
 }
 
-// DeleteAgent는 기존 에이전트를 삭제합니다.
-func (c *Controller) DeleteAgent(ctx context.Context, agent string) error {
-	c.logger.Info("Deleting agent",
-		zap.String("agent", agent),
-	)
-
-	if c.repo == nil {
-		return fmt.Errorf("controller: repository is not configured")
-	}
-
-	if err := c.repo.UpsertAgentStatus(ctx, agent, storage.AgentStatusDeleted); err != nil {
-		return err
-	}
-
-	c.logger.Info("Agent deleted successfully",
-		zap.String("agent", agent),
-	)
-	return nil
-}
-
-// ListAgents는 모든 에이전트 목록을 반환합니다.
+// ListAgents는 삭제되지 않은 모든 에이전트 목록을 반환합니다.
 func (c *Controller) ListAgents(ctx context.Context) ([]string, error) {
-	c.logger.Info("Listing agents")
+	ctx, span := tracing.Start(ctx, "controller.ListAgents")
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing agents")
 
 	if c.repo == nil {
 		return nil, fmt.Errorf("controller: repository is not configured")
 	}
 
-	records, err := c.repo.ListAgents(ctx)
+	records, err := c.repo.ListAgents(ctx, liveAgentStatuses...)
 	if err != nil {
 		return nil, err
 	}
@@ -127,7 +188,7 @@ func (c *Controller) ListAgents(ctx context.Context) ([]string, error) {
 		agents = append(agents, rec.AgentID)
 	}
 
-	c.logger.Info("Listed agents",
+	logger.Info("Listed agents",
 		zap.Int("count", len(agents)),
 	)
 	return agents, nil
@@ -140,13 +201,23 @@ type AgentInfo struct {
 	Model       string
 	Prompt      string
 	Status      string
+	Owner       string
+	Revision    int
+	Version     int
+	Parameters  map[string]any
+	Tools       []string
+	Labels      map[string]string
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 }
 
 // GetAgentInfo는 특정 에이전트의 정보를 반환합니다.
 func (c *Controller) GetAgentInfo(ctx context.Context, agent string) (*AgentInfo, error) {
-	c.logger.Info("Getting agent info",
+	ctx, span := tracing.Start(ctx, "controller.GetAgentInfo", attribute.String("cnap.agent_id", agent))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Getting agent info",
 		zap.String("agent", agent),
 	)
 
@@ -156,23 +227,34 @@ func (c *Controller) GetAgentInfo(ctx context.Context, agent string) (*AgentInfo
 
 	rec, err := c.repo.GetAgent(ctx, agent)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return nil, fmt.Errorf("agent not found: %s", agent)
 		}
 		return nil, err
 	}
 
+	parameters, tools, err := decodeAgentSettings(rec.Parameters, rec.Tools)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", agent, err)
+	}
+
 	info := &AgentInfo{
 		Name:        rec.AgentID,
 		Description: rec.Description,
 		Model:       rec.Model,
 		Prompt:      rec.Prompt,
 		Status:      rec.Status,
+		Owner:       rec.OwnerID,
+		Revision:    rec.Revision,
+		Version:     rec.Version,
+		Parameters:  parameters,
+		Tools:       tools,
+		Labels:      rec.Labels,
 		CreatedAt:   rec.CreatedAt,
 		UpdatedAt:   rec.UpdatedAt,
 	}
 
-	c.logger.Info("Retrieved agent info",
+	logger.Info("Retrieved agent info",
 		zap.String("agent", agent),
 		zap.String("status", info.Status),
 	)
@@ -193,10 +275,14 @@ func (c *Controller) ValidateAgent(agent string) error {
 	return nil
 }
 
-// CreateTask는 프롬프트와 함께 새로운 작업을 생성합니다.
+// CreateTask는 프롬프트와 함께 새로운 작업을 생성합니다. 레이블은 WithTaskLabels로 지정합니다.
 // 생성 후 SendMessage를 호출하기 전까지 실행되지 않습니다.
-func (c *Controller) CreateTask(ctx context.Context, agentID, taskID, prompt string) error {
-	c.logger.Info("Creating task",
+func (c *Controller) CreateTask(ctx context.Context, agentID, taskID, prompt string, opts ...TaskOption) error {
+	ctx, span := tracing.Start(ctx, "controller.CreateTask", attribute.String("cnap.agent_id", agentID), attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Creating task",
 		zap.String("agent_id", agentID),
 		zap.String("task_id", taskID),
 	)
@@ -204,30 +290,60 @@ func (c *Controller) CreateTask(ctx context.Context, agentID, taskID, prompt str
 	if c.repo == nil {
 		return fmt.Errorf("controller: repository is not configured")
 	}
+	spec, err := applyTaskOptions(opts)
+	if err != nil {
+		return err
+	}
 
 	// Agent 존재 여부 확인
-	if _, err := c.repo.GetAgent(ctx, agentID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	agent, err := c.repo.GetAgent(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("agent not found: %s", agentID)
 		}
 		return err
 	}
+	if agent.Status == storage.AgentStatusDeleted {
+		return fmt.Errorf("%w: %s", ErrAgentDeleted, agentID)
+	}
+
+	// 에이전트 프롬프트 템플릿을 작업 변수로 렌더링
+	systemPrompt, err := RenderPrompt(agent.Prompt, spec.variables)
+	if err != nil {
+		return fmt.Errorf("agent %s: %w", agentID, err)
+	}
+	variables, err := encodePromptVariables(spec.variables)
+	if err != nil {
+		return err
+	}
 
 	task := &storage.Task{
-		TaskID:  taskID,
-		AgentID: agentID,
-		Prompt:  prompt,
-		Status:  storage.TaskStatusPending,
+		TaskID:        taskID,
+		AgentID:       agentID,
+		Prompt:        prompt,
+		Status:        storage.TaskStatusPending,
+		AgentRevision: agent.Revision,
+		SystemPrompt:  systemPrompt,
+		Variables:     variables,
+		OwnerID:       ActorFromContext(ctx),
+		Labels:        spec.labels,
 	}
 
-	if err := c.repo.CreateTask(ctx, task); err != nil {
-		c.logger.Error("Failed to create task", zap.Error(err))
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.CreateTask(ctx, task); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionTaskCreate, storage.AuditTargetTask, taskID, agentID, nil, taskAuditState(task))
+	})
+	if err != nil {
+		logger.Error("Failed to create task", zap.Error(err))
+		tracing.RecordError(span, err)
 		return err
 	}
 	
 	// TODO: Create TaskRunner with RunnerManager
 
-	c.logger.Info("Task created successfully",
+	logger.Info("Task created successfully",
 		zap.String("task_id", taskID),
 		zap.String("agent_id", agentID),
 		zap.Int64("id", task.ID),
@@ -237,7 +353,11 @@ func (c *Controller) CreateTask(ctx context.Context, agentID, taskID, prompt str
 
 // GetTask는 작업 정보를 조회합니다.
 func (c *Controller) GetTask(ctx context.Context, taskID string) (*storage.Task, error) {
-	c.logger.Info("Getting task",
+	ctx, span := tracing.Start(ctx, "controller.GetTask", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Getting task",
 		zap.String("task_id", taskID),
 	)
 
@@ -247,22 +367,40 @@ func (c *Controller) GetTask(ctx context.Context, taskID string) (*storage.Task,
 
 	task, err := c.repo.GetTask(ctx, taskID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return nil, fmt.Errorf("task not found: %s", taskID)
 		}
 		return nil, err
 	}
 
-	c.logger.Info("Retrieved task",
+	logger.Info("Retrieved task",
 		zap.String("task_id", taskID),
 		zap.String("status", task.Status),
 	)
 	return task, nil
 }
 
-// UpdateTaskStatus는 작업 상태를 업데이트합니다.
+// UpdateTaskStatus는 실행기처럼 작업을 직접 소유한 쪽이 알린 상태를 기록합니다.
+// 컨트롤러가 방금 읽은 버전을 기준으로 쓰며, 그 사이 다른 수정이 먼저 반영되면 storage.ErrConflict를 반환합니다.
+// 사용자가 본 작업을 바꿀 때는 UpdateTaskStatusIfVersion을 사용합니다.
 func (c *Controller) UpdateTaskStatus(ctx context.Context, taskID, status string) error {
-	c.logger.Info("Updating task status",
+	return c.updateTaskStatus(ctx, taskID, status, nil)
+}
+
+// UpdateTaskStatusIfVersion은 작업의 행 버전이 version일 때만 상태를 업데이트합니다.
+// version은 호출자가 작업을 읽을 때 받은 버전이어야 하며, 그 사이 취소 같은 다른 변경이 있었거나
+// version이 0이면 덮어쓰지 않고 storage.ErrConflict를 반환합니다.
+func (c *Controller) UpdateTaskStatusIfVersion(ctx context.Context, taskID, status string, version int) error {
+	return c.updateTaskStatus(ctx, taskID, status, &version)
+}
+
+// updateTaskStatus는 작업 상태를 업데이트합니다. version이 nil이 아니면 읽은 작업의 버전과 비교합니다.
+func (c *Controller) updateTaskStatus(ctx context.Context, taskID, status string, version *int) error {
+	ctx, span := tracing.Start(ctx, "controller.UpdateTaskStatus", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Updating task status",
 		zap.String("task_id", taskID),
 		zap.String("status", status),
 	)
@@ -274,19 +412,39 @@ func (c *Controller) UpdateTaskStatus(ctx context.Context, taskID, status string
 	// 작업 존재 여부 확인
 	task, err := c.repo.GetTask(ctx, taskID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("task not found: %s", taskID)
 		}
 		return err
 	}
 
+	if version != nil && *version != task.Version {
+		err := &storage.ConflictError{Kind: "task", ID: taskID, Expected: *version, Actual: task.Version}
+		logger.Warn("Task was modified concurrently", zap.Error(err))
+		return err
+	}
+
 	// 상태 업데이트
-	if err := c.repo.UpsertTaskStatus(ctx, taskID, task.AgentID, status); err != nil {
-		c.logger.Error("Failed to update task status", zap.Error(err))
+	action := storage.AuditActionTaskStatus
+	if status == storage.TaskStatusCanceled {
+		action = storage.AuditActionTaskCancel
+	}
+	updated := *task
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.UpdateTaskStatus(ctx, &updated, status); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, action, storage.AuditTargetTask, taskID, task.AgentID, taskAuditState(task), taskAuditState(&updated))
+	})
+	if err != nil {
+		logger.Error("Failed to update task status", zap.Error(err))
+		tracing.RecordError(span, err)
 		return err
 	}
 
-	c.logger.Info("Task status updated successfully",
+	c.observeTaskDuration(ctx, task, status)
+
+	logger.Info("Task status updated successfully",
 		zap.String("task_id", taskID),
 		zap.String("old_status", task.Status),
 		zap.String("new_status", status),
@@ -296,7 +454,11 @@ func (c *Controller) UpdateTaskStatus(ctx context.Context, taskID, status string
 
 // ListTasksByAgent는 에이전트별 작업 목록을 반환합니다.
 func (c *Controller) ListTasksByAgent(ctx context.Context, agentID string) ([]storage.Task, error) {
-	c.logger.Info("Listing tasks by agent",
+	ctx, span := tracing.Start(ctx, "controller.ListTasksByAgent", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing tasks by agent",
 		zap.String("agent_id", agentID),
 	)
 
@@ -309,7 +471,7 @@ func (c *Controller) ListTasksByAgent(ctx context.Context, agentID string) ([]st
 		return nil, err
 	}
 
-	c.logger.Info("Listed tasks by agent",
+	logger.Info("Listed tasks by agent",
 		zap.String("agent_id", agentID),
 		zap.Int("count", len(tasks)),
 	)
@@ -318,17 +480,56 @@ func (c *Controller) ListTasksByAgent(ctx context.Context, agentID string) ([]st
 
 // TaskInfo는 작업 정보를 나타냅니다.
 type TaskInfo struct {
-	TaskID    string
-	AgentID   string
-	Prompt    string
-	Status    string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	TaskID        string
+	AgentID       string
+	AgentRevision int
+	Prompt        string
+	Status        string
+	Version       int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	// 마지막 실행의 결과입니다. ResultRef는 최종 출력 메시지의 저장 키이고,
+	// Output은 그 본문입니다(메시지 저장소가 없거나 본문을 찾지 못하면 비어 있음).
+	ResultRef    string
+	Output       string
+	ErrorMessage string
+	ErrorClass   string
+	ResultModel  string
+	Attempts     int
+	StartedAt    *time.Time
+	FinishedAt   *time.Time
+
+	// ParentTaskID와 ForkIndex는 분기한 작업의 부모 작업과 복사한 마지막 대화 인덱스입니다.
+	// Model은 에이전트 모델 대신 사용할 모델이며 비어 있으면 에이전트 모델을 사용합니다.
+	ParentTaskID string
+	ForkIndex    int
+	Model        string
+
+	// SystemPrompt는 에이전트 프롬프트 템플릿을 Variables로 렌더링한 시스템 프롬프트입니다.
+	SystemPrompt string
+	Variables    map[string]string
+
+	// Owner는 작업을 만든 사용자입니다. 비어 있으면 알 수 없습니다.
+	Owner  string
+	Labels map[string]string
+}
+
+// Duration은 마지막 실행의 소요 시간입니다. 시작 또는 종료 시각이 없으면 0입니다.
+func (t *TaskInfo) Duration() time.Duration {
+	if t.StartedAt == nil || t.FinishedAt == nil {
+		return 0
+	}
+	return t.FinishedAt.Sub(*t.StartedAt)
 }
 
 // GetTaskInfo는 작업의 상세 정보를 반환합니다.
 func (c *Controller) GetTaskInfo(ctx context.Context, taskID string) (*TaskInfo, error) {
-	c.logger.Info("Getting task info",
+	ctx, span := tracing.Start(ctx, "controller.GetTaskInfo", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Getting task info",
 		zap.String("task_id", taskID),
 	)
 
@@ -338,22 +539,56 @@ func (c *Controller) GetTaskInfo(ctx context.Context, taskID string) (*TaskInfo,
 
 	task, err := c.repo.GetTask(ctx, taskID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return nil, fmt.Errorf("task not found: %s", taskID)
 		}
 		return nil, err
 	}
+	vars, err := decodePromptVariables(task.Variables)
+	if err != nil {
+		return nil, err
+	}
 
 	info := &TaskInfo{
-		TaskID:    task.TaskID,
-		AgentID:   task.AgentID,
-		Prompt:    task.Prompt,
-		Status:    task.Status,
-		CreatedAt: task.CreatedAt,
-		UpdatedAt: task.UpdatedAt,
+		TaskID:        task.TaskID,
+		AgentID:       task.AgentID,
+		AgentRevision: task.AgentRevision,
+		Prompt:        task.Prompt,
+		Status:        task.Status,
+		Version:       task.Version,
+		CreatedAt:     task.CreatedAt,
+		UpdatedAt:     task.UpdatedAt,
+		ResultRef:     task.ResultRef,
+		ErrorMessage:  task.ErrorMessage,
+		ErrorClass:    task.ErrorClass,
+		ResultModel:   task.ResultModel,
+		Attempts:      task.Attempts,
+		StartedAt:     task.StartedAt,
+		FinishedAt:    task.FinishedAt,
+		ParentTaskID:  task.ParentTaskID,
+		ForkIndex:     task.ForkIndex,
+		Model:         task.Model,
+		SystemPrompt:  task.SystemPrompt,
+		Variables:     vars,
+		Owner:         task.OwnerID,
+		Labels:        task.Labels,
+	}
+	if task.ResultRef != "" && c.messages != nil {
+		body, err := c.messages.Get(ctx, task.ResultRef)
+		switch {
+		case err == nil:
+			info.Output = body.Content
+		case errors.Is(err, msgstore.ErrNotFound):
+			logger.Warn("Task output missing from store",
+				zap.String("task_id", taskID),
+				zap.String("key", task.ResultRef),
+			)
+		default:
+			return nil, err
+		}
 	}
 
-	c.logger.Info("Retrieved task info",
+	logger.Info("Retrieved task info",
 		zap.String("task_id", taskID),
 		zap.String("status", info.Status),
 	)
@@ -374,8 +609,15 @@ func (c *Controller) ValidateTask(taskID string) error {
 }
 
 // UpdateAgent는 에이전트 정보를 수정합니다.
-func (c *Controller) UpdateAgent(ctx context.Context, agentID, description, model, prompt string) error {
-	c.logger.Info("Updating agent",
+// WithParameters, WithTools를 지정하지 않으면 기존 파라미터와 도구가 유지됩니다.
+// 호출자가 에이전트를 읽을 때 받은 버전을 WithExpectedVersion으로 넘겨야 하며,
+// 그 버전 이후 다른 수정이 있었거나 버전을 넘기지 않았으면 storage.ErrConflict를 반환합니다.
+func (c *Controller) UpdateAgent(ctx context.Context, agentID, description, model, prompt string, opts ...AgentOption) error {
+	ctx, span := tracing.Start(ctx, "controller.UpdateAgent", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Updating agent",
 		zap.String("agent_id", agentID),
 	)
 
@@ -384,38 +626,61 @@ func (c *Controller) UpdateAgent(ctx context.Context, agentID, description, mode
 	}
 
 	// Agent 존재 여부 확인
-	if _, err := c.repo.GetAgent(ctx, agentID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	before, err := c.repo.GetAgent(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("agent not found: %s", agentID)
 		}
 		return err
 	}
 
-	agent := &storage.Agent{
-		AgentID:     agentID,
+	current := agentFieldsOf(before)
+	spec, err := applyAgentOptions(agentFields{
 		Description: description,
 		Model:       model,
 		Prompt:      prompt,
+		Parameters:  current.Parameters,
+		Tools:       current.Tools,
+	}, opts)
+	if err != nil {
+		return err
+	}
+	if spec.expectedVersion != before.Version {
+		err := &storage.ConflictError{Kind: "agent", ID: agentID, Expected: spec.expectedVersion, Actual: before.Version}
+		logger.Warn("Agent was modified concurrently", zap.Error(err))
+		return err
 	}
 
-	if err := c.repo.UpdateAgent(ctx, agent); err != nil {
-		c.logger.Error("Failed to update agent", zap.Error(err))
+	revision, err := c.reviseAgent(ctx, before, spec.agentFields, storage.AuditActionAgentUpdate)
+	if err != nil {
+		logger.Error("Failed to update agent", zap.Error(err))
+		tracing.RecordError(span, err)
 		return err
 	}
+	if spec.labels != nil && !maps.Equal(spec.labels, before.Labels) {
+		if err := c.SetAgentLabels(ctx, agentID, spec.labels); err != nil {
+			tracing.RecordError(span, err)
+			return err
+		}
+	}
 
-	c.logger.Info("Agent updated successfully", zap.String("agent", agentID))
+	logger.Info("Agent updated successfully", zap.String("agent", agentID), zap.Int("revision", revision))
 	return nil
 }
 
-// ListAgentsWithInfo는 상세 정보를 포함한 에이전트 목록을 반환합니다.
+// ListAgentsWithInfo는 삭제되지 않은 에이전트 목록을 상세 정보와 함께 반환합니다.
 func (c *Controller) ListAgentsWithInfo(ctx context.Context) ([]*AgentInfo, error) {
-	c.logger.Info("Listing agents with info")
+	ctx, span := tracing.Start(ctx, "controller.ListAgentsWithInfo")
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing agents with info")
 
 	if c.repo == nil {
 		return nil, fmt.Errorf("controller: repository is not configured")
 	}
 
-	records, err := c.repo.ListAgents(ctx)
+	records, err := c.repo.ListAgents(ctx, liveAgentStatuses...)
 	if err != nil {
 		return nil, err
 	}
@@ -428,12 +693,16 @@ func (c *Controller) ListAgentsWithInfo(ctx context.Context) ([]*AgentInfo, erro
 			Model:       rec.Model,
 			Prompt:      rec.Prompt,
 			Status:      rec.Status,
+			Owner:       rec.OwnerID,
+			Revision:    rec.Revision,
+			Version:     rec.Version,
+			Labels:      rec.Labels,
 			CreatedAt:   rec.CreatedAt,
 			UpdatedAt:   rec.UpdatedAt,
 		})
 	}
 
-	c.logger.Info("Listed agents with info",
+	logger.Info("Listed agents with info",
 		zap.Int("count", len(agents)),
 	)
 	return agents, nil
@@ -442,7 +711,11 @@ func (c *Controller) ListAgentsWithInfo(ctx context.Context) ([]*AgentInfo, erro
 // AddMessage adds a message to an existing task without executing it.
 // The message will be stored and can be sent later using SendMessage.
 func (c *Controller) AddMessage(ctx context.Context, taskID, role, content string) error {
-	c.logger.Info("Adding message to task",
+	ctx, span := tracing.Start(ctx, "controller.AddMessage", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Adding message to task",
 		zap.String("task_id", taskID),
 		zap.String("role", role),
 	)
@@ -450,23 +723,25 @@ func (c *Controller) AddMessage(ctx context.Context, taskID, role, content strin
 	if c.repo == nil {
 		return fmt.Errorf("controller: repository is not configured")
 	}
+	if c.messages == nil {
+		return fmt.Errorf("controller: message store is not configured")
+	}
 
 	// Task 존재 여부 확인
-	if _, err := c.repo.GetTask(ctx, taskID); err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+	task, err := c.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("task not found: %s", taskID)
 		}
 		return err
 	}
 
-	// 메시지를 파일로 저장하고 인덱스 생성
-	filePath := c.saveMessageToFile(taskID, content)
-	if _, err := c.repo.AppendMessageIndex(ctx, taskID, role, filePath); err != nil {
-		c.logger.Error("Failed to add message", zap.Error(err))
+	if _, err := c.appendMessage(ctx, task, role, content); err != nil {
+		tracing.RecordError(span, err)
 		return err
 	}
 
-	c.logger.Info("Message added successfully",
+	logger.Info("Message added successfully",
 		zap.String("task_id", taskID),
 		zap.String("role", role),
 	)
@@ -475,9 +750,13 @@ func (c *Controller) AddMessage(ctx context.Context, taskID, role, content strin
 
 // SendMessage triggers the execution of a task.
 // This method should be called after creating a task and optionally adding messages.
-// The actual execution will be handled by the RunnerManager (to be implemented).
+// With WithTaskRunner the task runs in the background and its result is recorded through OnComplete/OnError.
 func (c *Controller) SendMessage(ctx context.Context, taskID string) error {
-	c.logger.Info("Sending message for task",
+	ctx, span := tracing.Start(ctx, "controller.SendMessage", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Sending message for task",
 		zap.String("task_id", taskID),
 	)
 
@@ -488,7 +767,7 @@ func (c *Controller) SendMessage(ctx context.Context, taskID string) error {
 	// Task 조회
 	task, err := c.repo.GetTask(ctx, taskID)
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
+		if errors.Is(err, storage.ErrNotFound) {
 			return fmt.Errorf("task not found: %s", taskID)
 		}
 		return err
@@ -515,27 +794,68 @@ func (c *Controller) SendMessage(ctx context.Context, taskID string) error {
 		return fmt.Errorf("no prompt or messages to send for task: %s", taskID)
 	}
 
-	// 상태를 running으로 변경
-	if err := c.repo.UpsertTaskStatus(ctx, taskID, task.AgentID, storage.TaskStatusRunning); err != nil {
-		c.logger.Error("Failed to update task status", zap.Error(err))
+	// 이번 실행에 사용할 에이전트 리비전을 기록
+	agent, err := c.repo.GetAgent(ctx, task.AgentID)
+	if err != nil {
+		return fmt.Errorf("failed to get agent: %w", err)
+	}
+	if agent.Status == storage.AgentStatusDeleted {
+		return fmt.Errorf("%w: %s", ErrAgentDeleted, task.AgentID)
+	}
+	vars, err := decodePromptVariables(task.Variables)
+	if err != nil {
+		return err
+	}
+	systemPrompt, err := rerenderPrompt(agent.Prompt, vars)
+	if err != nil {
+		return fmt.Errorf("agent %s: %w", task.AgentID, err)
+	}
+	running := *task
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if agent.Revision != task.AgentRevision || systemPrompt != task.SystemPrompt {
+			if err := repo.SetTaskAgentRevision(ctx, &running, agent.Revision, systemPrompt); err != nil {
+				return err
+			}
+		}
+		// 상태를 running으로 변경하고 실행 시도를 기록
+		if err := repo.StartTaskAttempt(ctx, &running); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionTaskSend, storage.AuditTargetTask, taskID, task.AgentID, taskAuditState(task), taskAuditState(&running))
+	})
+	if err != nil {
+		logger.Error("Failed to start task attempt", zap.Error(err))
+		tracing.RecordError(span, err)
 		return err
 	}
 
-	c.logger.Info("Task execution triggered",
+	logger.Info("Task execution triggered",
 		zap.String("task_id", taskID),
 		zap.String("agent_id", task.AgentID),
+		zap.Int("agent_revision", agent.Revision),
 		zap.Int("message_count", len(messages)),
 	)
 
-	// TODO: RunnerManager를 통해 실제 실행 트리거
-	// 현재는 상태만 변경하고, RunnerManager 연동 후 실제 실행 로직 추가
-
+	if c.runner != nil {
+		model := running.Model
+		if model == "" {
+			model = agent.Model
+		}
+		if err := c.dispatchRun(ctx, &running, model); err != nil {
+			tracing.RecordError(span, err)
+			return err
+		}
+	}
 	return nil
 }
 
 // ListMessages returns all messages for a task in conversation order.
 func (c *Controller) ListMessages(ctx context.Context, taskID string) ([]storage.MessageIndex, error) {
-	c.logger.Info("Listing messages for task",
+	ctx, span := tracing.Start(ctx, "controller.ListMessages", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing messages for task",
 		zap.String("task_id", taskID),
 	)
 
@@ -548,18 +868,170 @@ func (c *Controller) ListMessages(ctx context.Context, taskID string) ([]storage
 		return nil, err
 	}
 
-	c.logger.Info("Listed messages",
+	logger.Info("Listed messages",
 		zap.String("task_id", taskID),
 		zap.Int("count", len(messages)),
 	)
 	return messages, nil
 }
 
-// saveMessageToFile saves message content to a file and returns the file path.
-// Messages are stored in data/messages/{taskID}/{conversationIndex}.json
-func (c *Controller) saveMessageToFile(taskID, content string) string {
-	// TODO: 실제 파일 저장 로직 구현
-	// 현재는 임시로 경로만 반환
-	return fmt.Sprintf("data/messages/%s/%d.json", taskID, time.Now().UnixNano())
+// MessageInfo는 본문을 포함한 메시지 정보를 나타냅니다.
+type MessageInfo struct {
+	ConversationIndex int
+	Role              string
+	Content           string
+	Author            string
+	FilePath          string
+	CreatedAt         time.Time
+	// Revision은 같은 대화 인덱스에서 몇 번째 버전인지를 나타냅니다.
+	Revision int
+	// SupersededAt은 다시 생성하거나 수정해 대체된 시각입니다. 현재 대화의 메시지는 nil입니다.
+	SupersededAt *time.Time
 }
 
+// ListMessagesWithContent는 작업의 메시지를 대화 순서대로 본문과 함께 반환합니다.
+// 본문이 저장소에 없는 메시지는 Content가 비어 있습니다.
+func (c *Controller) ListMessagesWithContent(ctx context.Context, taskID string) ([]*MessageInfo, error) {
+	ctx, span := tracing.Start(ctx, "controller.ListMessagesWithContent", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+
+	if c.messages == nil {
+		return nil, fmt.Errorf("controller: message store is not configured")
+	}
+
+	messages, err := c.ListMessages(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.loadMessageContents(ctx, taskID, messages)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// loadMessageContents는 메시지 참조에 저장소의 본문을 채워 반환합니다.
+func (c *Controller) loadMessageContents(ctx context.Context, taskID string, messages []storage.MessageIndex) ([]*MessageInfo, error) {
+	logger := tracing.Logger(ctx, c.logger)
+
+	result := make([]*MessageInfo, 0, len(messages))
+	for _, m := range messages {
+		info := &MessageInfo{
+			ConversationIndex: m.ConversationIndex,
+			Role:              m.Role,
+			FilePath:          m.FilePath,
+			CreatedAt:         m.CreatedAt,
+			Revision:          m.Revision,
+			SupersededAt:      m.SupersededAt,
+		}
+		body, err := c.messages.Get(ctx, m.FilePath)
+		switch {
+		case err == nil:
+			info.Content = body.Content
+			info.Author = body.Author
+		case errors.Is(err, msgstore.ErrNotFound):
+			logger.Warn("Message content missing from store",
+				zap.String("task_id", taskID),
+				zap.Int("conversation_index", m.ConversationIndex),
+				zap.String("key", m.FilePath),
+			)
+		default:
+			return nil, err
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// saveMessage는 메시지 본문을 저장소에 기록하고 저장 키를 반환합니다.
+// 키는 msg_index.file_path에 기록됩니다.
+func (c *Controller) saveMessage(ctx context.Context, taskID, role, content string) (string, error) {
+	now := time.Now().UTC()
+	key := msgstore.NewKey(taskID, now)
+	err := c.messages.Put(ctx, key, &msgstore.Message{
+		TaskID:    taskID,
+		Role:      role,
+		Content:   content,
+		Author:    ActorFromContext(ctx),
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// appendMessage는 메시지 본문을 저장소에 기록하고 인덱스, 검색 문서, 감사 이벤트를 남깁니다.
+// 인덱스 생성에 실패하면 저장한 본문을 지웁니다.
+func (c *Controller) appendMessage(ctx context.Context, task *storage.Task, role, content string) (*storage.MessageIndex, error) {
+	logger := tracing.Logger(ctx, c.logger)
+	taskID := task.TaskID
+
+	// 본문을 메시지 저장소에 먼저 기록한 뒤 인덱스를 생성합니다.
+	filePath, err := c.saveMessage(ctx, taskID, role, content)
+	if err != nil {
+		logger.Error("Failed to store message content", zap.Error(err))
+		return nil, err
+	}
+	var msg *storage.MessageIndex
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		msg, err = repo.AppendMessageIndex(ctx, taskID, role, filePath)
+		if err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionMessageAdd, storage.AuditTargetTask, taskID, task.AgentID, nil, map[string]string{
+			"role":               msg.Role,
+			"conversation_index": strconv.Itoa(msg.ConversationIndex),
+			"file_path":          msg.FilePath,
+		})
+	})
+	if err != nil {
+		logger.Error("Failed to add message", zap.Error(err))
+		if delErr := c.messages.Delete(ctx, filePath); delErr != nil {
+			logger.Warn("Failed to remove orphaned message content", zap.Error(delErr), zap.String("key", filePath))
+		}
+		return nil, err
+	}
+	// 검색 인덱스는 'cnap task reindex'로 다시 만들 수 있으므로 실패해도 메시지 추가는 성공으로 처리합니다.
+	if err := c.repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+		TaskID:  taskID,
+		AgentID: task.AgentID,
+		Source:  storage.SearchSourceMessage,
+		Ref:     filePath,
+		Body:    content,
+	}); err != nil {
+		logger.Warn("Failed to index message for search", zap.Error(err), zap.String("key", filePath))
+	}
+	return msg, nil
+}
+
+// isTerminalTaskStatus는 더 이상 실행되지 않는 종료 상태인지 확인합니다.
+func isTerminalTaskStatus(status string) bool {
+	switch status {
+	case storage.TaskStatusCompleted, storage.TaskStatusFailed, storage.TaskStatusCanceled:
+		return true
+	}
+	return false
+}
+
+// observeTaskDuration은 running 작업이 종료 상태가 될 때 실행 시간을 메트릭으로 기록합니다.
+// 실행 시작 시각이 기록되지 않은 작업은 마지막 갱신 시각을 시작 시각으로 간주합니다.
+func (c *Controller) observeTaskDuration(ctx context.Context, task *storage.Task, newStatus string) {
+	if task.Status != storage.TaskStatusRunning || !isTerminalTaskStatus(newStatus) {
+		return
+	}
+	model := ""
+	if agent, err := c.repo.GetAgent(ctx, task.AgentID); err == nil {
+		model = agent.Model
+	}
+	started := task.UpdatedAt
+	if task.StartedAt != nil {
+		started = *task.StartedAt
+	}
+	metrics.TaskDuration.
+		WithLabelValues(task.AgentID, model, newStatus).
+		Observe(time.Since(started).Seconds())
+}
@@ -2,40 +2,46 @@ package controller_test
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/cnap-oss/app/internal/controller"
+	"github.com/cnap-oss/app/internal/manifest"
+	"github.com/cnap-oss/app/internal/msgstore"
+	taskrunner "github.com/cnap-oss/app/internal/runner"
 	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/testutil/mocks"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func newTestController(t *testing.T) (*controller.Controller, func()) {
+func newTestController(t *testing.T, opts ...controller.Option) *controller.Controller {
 	t.Helper()
 
-	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
-	require.NoError(t, err)
-	require.NoError(t, storage.AutoMigrate(db))
-
-	repo, err := storage.NewRepository(db)
+	store, err := msgstore.NewFSStore(t.TempDir())
 	require.NoError(t, err)
 
-	ctrl := controller.NewController(zaptest.NewLogger(t), repo)
+	opts = append([]controller.Option{controller.WithMessageStore(store)}, opts...)
+	return controller.NewController(zaptest.NewLogger(t), storage.NewMemoryStore(), opts...)
+}
 
-	cleanup := func() {
-		sqlDB, err := db.DB()
-		require.NoError(t, err)
-		require.NoError(t, sqlDB.Close())
-	}
+// currentVersion은 에이전트의 현재 버전을 읽어 UpdateAgent에 넘길 옵션으로 반환합니다.
+func currentVersion(t *testing.T, ctrl *controller.Controller, agentID string) controller.AgentOption {
+	t.Helper()
 
-	return ctrl, cleanup
+	info, err := ctrl.GetAgentInfo(context.Background(), agentID)
+	require.NoError(t, err)
+	return controller.WithExpectedVersion(info.Version)
 }
 
 func TestControllerCreateAndGetAgent(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -51,8 +57,7 @@ func TestControllerCreateAndGetAgent(t *testing.T) {
 }
 
 func TestControllerListAgents(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 	require.NoError(t, ctrl.CreateAgent(ctx, "agent-a", "Agent A", "gpt-4", "Prompt A"))
@@ -64,8 +69,7 @@ func TestControllerListAgents(t *testing.T) {
 }
 
 func TestControllerCreateTaskWithPrompt(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -85,8 +89,7 @@ func TestControllerCreateTaskWithPrompt(t *testing.T) {
 }
 
 func TestControllerCreateTaskWithoutPrompt(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -102,8 +105,7 @@ func TestControllerCreateTaskWithoutPrompt(t *testing.T) {
 }
 
 func TestControllerAddMessage(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -128,11 +130,19 @@ func TestControllerAddMessage(t *testing.T) {
 	require.Equal(t, "assistant", messages[1].Role)
 	require.Equal(t, 2, messages[2].ConversationIndex)
 	require.Equal(t, "user", messages[2].Role)
+
+	// 본문은 메시지 저장소에서 읽어옵니다.
+	withContent, err := ctrl.ListMessagesWithContent(ctx, "task-001")
+	require.NoError(t, err)
+	require.Len(t, withContent, 3)
+	require.Equal(t, "First message", withContent[0].Content)
+	require.Equal(t, "First response", withContent[1].Content)
+	require.Equal(t, "Second message", withContent[2].Content)
+	require.Equal(t, messages[1].FilePath, withContent[1].FilePath)
 }
 
 func TestControllerSendMessage(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -154,8 +164,7 @@ func TestControllerSendMessage(t *testing.T) {
 }
 
 func TestControllerSendMessageWithoutPromptOrMessages(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -170,8 +179,7 @@ func TestControllerSendMessageWithoutPromptOrMessages(t *testing.T) {
 }
 
 func TestControllerMultiTurnConversation(t *testing.T) {
-	ctrl, cleanup := newTestController(t)
-	defer cleanup()
+	ctrl := newTestController(t)
 
 	ctx := context.Background()
 
@@ -213,3 +221,1022 @@ func TestControllerMultiTurnConversation(t *testing.T) {
 	require.Equal(t, "assistant", messages[0].Role)
 	require.Equal(t, "user", messages[1].Role)
 }
+
+func TestControllerCreateAgentRecordsOwner(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := controller.WithActor(context.Background(), controller.DiscordActor("1234"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "owned", "Owned agent", "gpt-4", "Prompt"))
+
+	info, err := ctrl.GetAgentInfo(context.Background(), "owned")
+	require.NoError(t, err)
+	require.Equal(t, "discord:1234", info.Owner)
+}
+
+func TestControllerTaskRecordsOwner(t *testing.T) {
+	ctrl := newTestController(t)
+
+	require.NoError(t, ctrl.CreateAgent(context.Background(), "guide", "Travel guide", "gpt-4", "Plan trips"))
+	alice := controller.WithActor(context.Background(), controller.CLIActor("alice"))
+	require.NoError(t, ctrl.CreateTask(alice, "guide", "trip", "Plan a trip"))
+	require.NoError(t, ctrl.AddMessage(alice, "trip", "user", "Where to?"))
+
+	// 분기한 작업의 소유자는 부모 작업의 소유자가 아니라 분기한 사용자입니다.
+	bob := controller.WithActor(context.Background(), controller.DiscordActor("42"))
+	fork, err := ctrl.ForkTask(bob, "trip", 0)
+	require.NoError(t, err)
+
+	info, err := ctrl.GetTaskInfo(context.Background(), "trip")
+	require.NoError(t, err)
+	require.Equal(t, "cli:alice", info.Owner)
+	info, err = ctrl.GetTaskInfo(context.Background(), fork.TaskID)
+	require.NoError(t, err)
+	require.Equal(t, "discord:42", info.Owner)
+}
+
+func TestControllerRecordsAuditEvents(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := controller.WithActor(context.Background(), controller.CLIActor("alice"))
+
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-1", "Test agent", "gpt-4", "Old prompt"))
+	require.NoError(t, ctrl.UpdateAgent(ctx, "agent-1", "Test agent", "gpt-4", "New prompt", currentVersion(t, ctrl, "agent-1")))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-1", "Hello"))
+	require.NoError(t, ctrl.UpdateTaskStatus(ctx, "task-1", storage.TaskStatusCanceled))
+
+	events, err := ctrl.ListAuditEvents(context.Background(), storage.AuditFilter{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, events, 4)
+
+	actions := make([]string, 0, len(events))
+	for _, ev := range events {
+		require.Equal(t, "cli:alice", ev.Actor)
+		actions = append(actions, ev.Action)
+	}
+	require.Equal(t, []string{
+		storage.AuditActionAgentCreate,
+		storage.AuditActionAgentUpdate,
+		storage.AuditActionTaskCreate,
+		storage.AuditActionTaskCancel,
+	}, actions)
+
+	diff, err := controller.AuditDiff(events[1])
+	require.NoError(t, err)
+	require.Equal(t, map[string][2]string{"prompt": {"Old prompt", "New prompt"}}, diff)
+}
+
+// failingAuditStore는 감사 로그 기록이 항상 실패하는 저장소입니다.
+type failingAuditStore struct {
+	*storage.MemoryStore
+	enabled bool
+}
+
+func (s *failingAuditStore) CreateAuditEvent(ctx context.Context, event *storage.AuditEvent) error {
+	if s.enabled {
+		return errors.New("audit log unavailable")
+	}
+	return s.MemoryStore.CreateAuditEvent(ctx, event)
+}
+
+func (s *failingAuditStore) InTx(ctx context.Context, fn func(tx storage.Store) error) error {
+	return s.MemoryStore.InTx(ctx, func(storage.Store) error { return fn(s) })
+}
+
+func TestControllerRollsBackWhenAuditFails(t *testing.T) {
+	repo := &failingAuditStore{MemoryStore: storage.NewMemoryStore()}
+	ctrl := controller.NewController(zaptest.NewLogger(t), repo)
+	ctx := context.Background()
+
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-1", "Test agent", "gpt-4", "Old prompt"))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-1", "Hello"))
+	repo.enabled = true
+
+	// 감사 로그를 남기지 못한 쓰기는 반영되지 않습니다.
+	require.Error(t, ctrl.CreateAgent(ctx, "agent-2", "Test agent", "gpt-4", "Prompt"))
+	_, err := ctrl.GetAgentInfo(ctx, "agent-2")
+	require.Error(t, err)
+
+	require.Error(t, ctrl.UpdateTaskStatus(ctx, "task-1", storage.TaskStatusCanceled))
+	task, err := ctrl.GetTaskInfo(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusPending, task.Status)
+
+	require.Error(t, ctrl.UpdateAgent(ctx, "agent-1", "Test agent", "gpt-4", "New prompt", currentVersion(t, ctrl, "agent-1")))
+	agent, err := ctrl.GetAgentInfo(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, "Old prompt", agent.Prompt)
+	require.Equal(t, 1, agent.Revision)
+}
+
+func TestControllerAgentRevisions(t *testing.T) {
+	ctrl := newTestController(t)
+
+	alice := controller.WithActor(context.Background(), controller.CLIActor("alice"))
+	bob := controller.WithActor(context.Background(), controller.DiscordActor("42"))
+
+	require.NoError(t, ctrl.CreateAgent(alice, "agent-1", "Test agent", "gpt-4", "line one\nline two"))
+	require.NoError(t, ctrl.CreateTask(alice, "agent-1", "task-1", "Hello"))
+	require.NoError(t, ctrl.UpdateAgent(bob, "agent-1", "Test agent", "gpt-4", "line one\nline 2\nline three", currentVersion(t, ctrl, "agent-1")))
+	// 값이 같으면 리비전을 만들지 않습니다.
+	require.NoError(t, ctrl.UpdateAgent(bob, "agent-1", "Test agent", "gpt-4", "line one\nline 2\nline three", currentVersion(t, ctrl, "agent-1")))
+	require.NoError(t, ctrl.UpdateAgent(bob, "agent-1", "Test agent", "gpt-5", "line one\nline 2\nline three", currentVersion(t, ctrl, "agent-1")))
+
+	revisions, err := ctrl.ListAgentRevisions(context.Background(), "agent-1")
+	require.NoError(t, err)
+	require.Len(t, revisions, 3)
+	require.Equal(t, "cli:alice", revisions[0].Author)
+	require.Equal(t, "discord:42", revisions[1].Author)
+
+	diff, err := ctrl.DiffAgentRevisions(context.Background(), "agent-1", 1, 2)
+	require.NoError(t, err)
+	require.Len(t, diff.Fields, 1)
+	require.Equal(t, "prompt", diff.Fields[0].Field)
+	require.Equal(t, []controller.DiffLine{
+		{Op: controller.DiffEqual, Text: "line one"},
+		{Op: controller.DiffDelete, Text: "line two"},
+		{Op: controller.DiffInsert, Text: "line 2"},
+		{Op: controller.DiffInsert, Text: "line three"},
+	}, diff.Fields[0].Lines)
+
+	newRevision, err := ctrl.RollbackAgent(alice, "agent-1", 1)
+	require.NoError(t, err)
+	require.Equal(t, 4, newRevision)
+
+	info, err := ctrl.GetAgentInfo(context.Background(), "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, 4, info.Revision)
+	require.Equal(t, "gpt-4", info.Model)
+	require.Equal(t, "line one\nline two", info.Prompt)
+
+	diff, err = ctrl.DiffAgentRevisions(context.Background(), "agent-1", 1, 4)
+	require.NoError(t, err)
+	require.Empty(t, diff.Fields)
+
+	_, err = ctrl.RollbackAgent(alice, "agent-1", 99)
+	require.ErrorContains(t, err, "agent revision not found")
+
+	// 작업은 생성 시점의 리비전을 기록하고, 실행할 때 사용한 리비전으로 갱신됩니다.
+	task, err := ctrl.GetTaskInfo(context.Background(), "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, task.AgentRevision)
+	require.NoError(t, ctrl.SendMessage(alice, "task-1"))
+	task, err = ctrl.GetTaskInfo(context.Background(), "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 4, task.AgentRevision)
+}
+
+func TestControllerApplyAgentManifests(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := controller.WithActor(context.Background(), "cli:alice")
+	require.NoError(t, ctrl.CreateAgent(ctx, "keep", "그대로", "gpt-4", "p"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "change", "이전", "gpt-4", "p"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "stale", "삭제 대상", "gpt-4", "p"))
+
+	keep := manifest.NewAgent("keep")
+	keep.Description, keep.Model, keep.Prompt = "그대로", "gpt-4", "p"
+	change := manifest.NewAgent("change")
+	change.Description, change.Model, change.Prompt = "이후", "gpt-4o", "p"
+	change.Parameters = map[string]any{"temperature": 0.2}
+	change.Tools = []string{"search"}
+	fresh := manifest.NewAgent("fresh")
+	fresh.Model = "gpt-4"
+	manifests := []manifest.Agent{keep, change, fresh}
+
+	actions := func(changes []controller.AgentChange) map[string]controller.ApplyAction {
+		got := map[string]controller.ApplyAction{}
+		for _, c := range changes {
+			got[c.Name] = c.Action
+		}
+		return got
+	}
+
+	// dry run은 계획만 반환하고 아무것도 바꾸지 않습니다.
+	changes, err := ctrl.ApplyAgentManifests(ctx, manifests, controller.ApplyOptions{Prune: true, DryRun: true})
+	require.NoError(t, err)
+	require.Equal(t, map[string]controller.ApplyAction{
+		"keep":   controller.ApplyUnchanged,
+		"change": controller.ApplyUpdate,
+		"fresh":  controller.ApplyCreate,
+		"stale":  controller.ApplyDelete,
+	}, actions(changes))
+	for _, c := range changes {
+		if c.Name == "change" {
+			var fields []string
+			for _, f := range c.Fields {
+				fields = append(fields, f.Field)
+			}
+			require.Equal(t, []string{"description", "model", "parameters", "tools"}, fields)
+		}
+	}
+	_, err = ctrl.GetAgentInfo(ctx, "fresh")
+	require.Error(t, err)
+
+	_, err = ctrl.ApplyAgentManifests(ctx, manifests, controller.ApplyOptions{Prune: true})
+	require.NoError(t, err)
+
+	info, err := ctrl.GetAgentInfo(ctx, "change")
+	require.NoError(t, err)
+	require.Equal(t, "gpt-4o", info.Model)
+	require.Equal(t, map[string]any{"temperature": 0.2}, info.Parameters)
+	require.Equal(t, []string{"search"}, info.Tools)
+	require.Equal(t, 2, info.Revision)
+
+	stale, err := ctrl.GetAgentInfo(ctx, "stale")
+	require.NoError(t, err)
+	require.Equal(t, storage.AgentStatusDeleted, stale.Status)
+
+	// 두 번째 적용은 아무것도 바꾸지 않습니다.
+	changes, err = ctrl.ApplyAgentManifests(ctx, manifests, controller.ApplyOptions{Prune: true})
+	require.NoError(t, err)
+	for _, c := range changes {
+		require.Equal(t, controller.ApplyUnchanged, c.Action, c.Name)
+	}
+	info, err = ctrl.GetAgentInfo(ctx, "change")
+	require.NoError(t, err)
+	require.Equal(t, 2, info.Revision)
+
+	// 내보낸 매니페스트는 적용한 매니페스트와 같습니다.
+	exported, err := ctrl.ExportAgentManifests(ctx)
+	require.NoError(t, err)
+	require.ElementsMatch(t, manifests, exported)
+
+	// 삭제된 에이전트가 매니페스트에 다시 나오면 활성화됩니다.
+	staleManifest := manifest.NewAgent("stale")
+	staleManifest.Description, staleManifest.Model = "복구", "gpt-4"
+	changes, err = ctrl.ApplyAgentManifests(ctx, []manifest.Agent{staleManifest}, controller.ApplyOptions{})
+	require.NoError(t, err)
+	require.Equal(t, controller.ApplyCreate, changes[0].Action)
+	stale, err = ctrl.GetAgentInfo(ctx, "stale")
+	require.NoError(t, err)
+	require.Equal(t, storage.AgentStatusActive, stale.Status)
+	require.Equal(t, "복구", stale.Description)
+}
+
+func TestControllerSearchTasks(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "billing", "", "gpt-4", ""))
+	require.NoError(t, ctrl.CreateAgent(ctx, "support", "", "gpt-4", ""))
+	require.NoError(t, ctrl.CreateTask(ctx, "billing", "task-1", "카드 결제가 두 번 청구되었습니다"))
+	require.NoError(t, ctrl.CreateTask(ctx, "support", "task-2", ""))
+	require.NoError(t, ctrl.AddMessage(ctx, "task-2", storage.MessageRoleUser, "주문한 상품의 배송이 늦어요. 결제는 완료했어요."))
+
+	results, err := ctrl.SearchTasks(ctx, storage.SearchFilter{Query: "결제"})
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+
+	results, err = ctrl.SearchTasks(ctx, storage.SearchFilter{Query: "배송", AgentID: "support"})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	require.Equal(t, "task-2", results[0].TaskID)
+	require.Equal(t, storage.SearchSourceMessage, results[0].Source)
+	require.Contains(t, results[0].Snippet, "배송이 늦어요")
+
+	indexed, err := ctrl.RebuildSearchIndex(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 2, indexed)
+
+	// 전체 작업 목록은 에이전트를 가리지 않습니다.
+	page, err := ctrl.ListTasksPage(ctx, storage.TaskFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 2)
+}
+
+func TestControllerOptimisticConcurrency(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-1", "Test agent", "gpt-4", "v1"))
+	opened, err := ctrl.GetAgentInfo(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, opened.Version)
+
+	// 같은 버전으로 연 두 편집 중 먼저 제출한 쪽만 반영됩니다.
+	require.NoError(t, ctrl.UpdateAgent(ctx, "agent-1", "Test agent", "gpt-4", "alice", controller.WithExpectedVersion(opened.Version)))
+	err = ctrl.UpdateAgent(ctx, "agent-1", "Test agent", "gpt-4", "bob", controller.WithExpectedVersion(opened.Version))
+	require.ErrorIs(t, err, storage.ErrConflict)
+
+	info, err := ctrl.GetAgentInfo(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, "alice", info.Prompt)
+	require.Equal(t, 2, info.Version)
+
+	// 읽은 버전을 넘기지 않은 수정은 충돌로 처리합니다.
+	require.ErrorIs(t, ctrl.UpdateAgent(ctx, "agent-1", "Test agent", "gpt-4", "bob"), storage.ErrConflict)
+
+	// 다시 불러온 버전으로는 수정할 수 있습니다.
+	require.NoError(t, ctrl.UpdateAgent(ctx, "agent-1", "Test agent", "gpt-4", "bob", controller.WithExpectedVersion(info.Version)))
+
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-1", "Hello"))
+	require.NoError(t, ctrl.SendMessage(ctx, "task-1"))
+	running, err := ctrl.GetTaskInfo(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusRunning, running.Status)
+
+	require.NoError(t, ctrl.UpdateTaskStatus(ctx, "task-1", storage.TaskStatusCanceled))
+	err = ctrl.UpdateTaskStatusIfVersion(ctx, "task-1", storage.TaskStatusCompleted, running.Version)
+	require.ErrorIs(t, err, storage.ErrConflict)
+
+	require.ErrorIs(t, ctrl.UpdateTaskStatusIfVersion(ctx, "task-1", storage.TaskStatusPending, 0), storage.ErrConflict)
+
+	task, err := ctrl.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCanceled, task.Status)
+}
+
+func TestControllerAgentDeleteRestorePurge(t *testing.T) {
+	repo := storage.NewMemoryStore()
+	dir := t.TempDir()
+	store, err := msgstore.NewFSStore(dir)
+	require.NoError(t, err)
+	ctrl := controller.NewController(zaptest.NewLogger(t), repo, controller.WithMessageStore(store))
+
+	ctx := context.Background()
+
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-1", "Test agent", "gpt-4", "prompt"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-2", "Other agent", "gpt-4", "prompt"))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-running", "Hello"))
+	require.NoError(t, ctrl.AddMessage(ctx, "task-running", storage.MessageRoleUser, "first"))
+	require.NoError(t, ctrl.SendMessage(ctx, "task-running"))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-pending", "Later"))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-done", "Done"))
+	require.NoError(t, ctrl.UpdateTaskStatus(ctx, "task-done", storage.TaskStatusCompleted))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-2", "task-other", "Keep me"))
+	require.NoError(t, ctrl.AddMessage(ctx, "task-other", storage.MessageRoleUser, "kept"))
+
+	// 삭제하면 목록에서 숨겨지고, 대기·실행 중인 작업은 취소되며 새 작업은 막힙니다.
+	canceled, err := ctrl.DeleteAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"task-running", "task-pending"}, canceled)
+
+	names, err := ctrl.ListAgents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []string{"agent-2"}, names)
+	page, err := ctrl.ListAgentsPage(ctx, storage.AgentFilter{})
+	require.NoError(t, err)
+	require.Len(t, page.Agents, 1)
+
+	for id, status := range map[string]string{
+		"task-running": storage.TaskStatusCanceled,
+		"task-pending": storage.TaskStatusCanceled,
+		"task-done":    storage.TaskStatusCompleted,
+	} {
+		task, err := ctrl.GetTask(ctx, id)
+		require.NoError(t, err)
+		require.Equal(t, status, task.Status, id)
+	}
+	require.ErrorIs(t, ctrl.CreateTask(ctx, "agent-1", "task-new", "blocked"), controller.ErrAgentDeleted)
+	_, err = ctrl.DeleteAgent(ctx, "agent-1")
+	require.Error(t, err)
+
+	// 복구하면 다시 작업을 만들 수 있습니다.
+	require.NoError(t, ctrl.RestoreAgent(ctx, "agent-1"))
+	require.Error(t, ctrl.RestoreAgent(ctx, "agent-1"))
+	require.NoError(t, ctrl.CreateTask(ctx, "agent-1", "task-new", "allowed"))
+
+	// --drain 삭제는 작업을 그대로 두고, 삭제된 동안에는 실행할 수 없습니다.
+	canceled, err = ctrl.DeleteAgent(ctx, "agent-1", controller.WithDrainRunningTasks())
+	require.NoError(t, err)
+	require.Empty(t, canceled)
+	task, err := ctrl.GetTask(ctx, "task-new")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusPending, task.Status)
+	require.ErrorIs(t, ctrl.SendMessage(ctx, "task-new"), controller.ErrAgentDeleted)
+
+	// 영구 삭제는 삭제된 에이전트만 가능하며, 다른 에이전트의 데이터는 남깁니다.
+	_, err = ctrl.PurgeAgent(ctx, "agent-2")
+	require.Error(t, err)
+	removed, err := ctrl.PurgeAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	_, err = ctrl.GetAgentInfo(ctx, "agent-1")
+	require.Error(t, err)
+	_, err = ctrl.GetTask(ctx, "task-running")
+	require.Error(t, err)
+	revisions, err := repo.ListAgentRevisions(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+	results, err := ctrl.SearchTasks(ctx, storage.SearchFilter{Query: "hello"})
+	require.NoError(t, err)
+	require.Empty(t, results)
+
+	kept, err := ctrl.ListMessagesWithContent(ctx, "task-other")
+	require.NoError(t, err)
+	require.Len(t, kept, 1)
+	require.Equal(t, "kept", kept[0].Content)
+
+	var files int
+	require.NoError(t, filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files++
+		}
+		return err
+	}))
+	require.Equal(t, 1, files, "only the other agent's message file remains")
+
+	// 감사 로그는 남습니다.
+	events, err := ctrl.ListAuditEvents(ctx, storage.AuditFilter{AgentID: "agent-1", Action: storage.AuditActionAgentPurge})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func TestControllerApplyRetention(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, storage.Close(db)) }()
+	require.NoError(t, storage.MigrateUp(context.Background(), db))
+	repo, err := storage.NewRepository(db)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	store, err := msgstore.NewFSStore(dir)
+	require.NoError(t, err)
+	ctrl := controller.NewController(zaptest.NewLogger(t), repo, controller.WithMessageStore(store))
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-1", "Test agent", "gpt-4", "prompt"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "agent-2", "Other agent", "gpt-4", "prompt"))
+	for _, tc := range []struct{ agent, task string }{
+		{"agent-1", "old-1"}, {"agent-1", "old-2"}, {"agent-1", "recent"}, {"agent-2", "old-3"},
+	} {
+		require.NoError(t, ctrl.CreateTask(ctx, tc.agent, tc.task, "prompt"))
+		require.NoError(t, ctrl.AddMessage(ctx, tc.task, storage.MessageRoleUser, "hello "+tc.task))
+		require.NoError(t, ctrl.UpdateTaskStatus(ctx, tc.task, storage.TaskStatusCompleted))
+	}
+	require.NoError(t, db.Model(&storage.Task{}).Where("task_id <> ?", "recent").
+		UpdateColumn("updated_at", time.Now().Add(-100*24*time.Hour)).Error)
+
+	// agent-2에는 에이전트별 규칙이 있어 전역 규칙이 적용되지 않습니다.
+	rules := []storage.RetentionRule{
+		{OlderThan: 90 * 24 * time.Hour},
+		{AgentID: "agent-2", KeepLast: 1},
+	}
+
+	report, err := ctrl.ApplyRetention(ctx, rules, true)
+	require.NoError(t, err)
+	require.True(t, report.DryRun)
+	require.Equal(t, int64(2), report.Tasks)
+	require.Equal(t, int64(2), report.Messages)
+	require.Len(t, report.Files, 2)
+	require.Positive(t, report.Bytes)
+	_, err = ctrl.GetTask(ctx, "old-1")
+	require.NoError(t, err)
+
+	report, err = ctrl.ApplyRetention(ctx, rules, false)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), report.Tasks)
+	require.Equal(t, 2, report.RemovedFiles)
+	require.Positive(t, report.Bytes)
+	for id, kept := range map[string]bool{"old-1": false, "old-2": false, "recent": true, "old-3": true} {
+		_, err := ctrl.GetTask(ctx, id)
+		require.Equal(t, kept, err == nil, id)
+	}
+
+	var files int
+	require.NoError(t, filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files++
+		}
+		return err
+	}))
+	require.Equal(t, 2, files)
+
+	events, err := ctrl.ListAuditEvents(ctx, storage.AuditFilter{Action: storage.AuditActionTaskPurge})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	// 다시 적용하면 정리할 것이 없습니다.
+	report, err = ctrl.ApplyRetention(ctx, rules, false)
+	require.NoError(t, err)
+	require.Zero(t, report.Rows())
+}
+
+func TestControllerTaskTimeline(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "planner", "Planner agent", "gpt-4", "Plan things"))
+	require.NoError(t, ctrl.CreateTask(ctx, "planner", "task-timeline", "Plan a trip"))
+
+	base := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		ts := base.Add(d)
+		return &ts
+	}
+	steps := []storage.RunStep{
+		{StepNo: 1, Type: "run", Status: "completed", StartedAt: at(0), FinishedAt: at(5 * time.Second)},
+		{StepNo: 2, ParentStepNo: 1, Type: "llm", Status: "completed", Model: "gpt-4",
+			InputTokens: 120, OutputTokens: 40, StartedAt: at(0), FinishedAt: at(3 * time.Second)},
+		{StepNo: 3, ParentStepNo: 1, Type: "tool", Status: "failed", ErrorClass: "timeout",
+			ErrorMessage: "search timed out", StartedAt: at(3 * time.Second), FinishedAt: at(5 * time.Second)},
+		{StepNo: 4, ParentStepNo: 3, Type: "http", Status: "failed", DurationMS: 1900},
+		{StepNo: 5, ParentStepNo: 9, Type: "orphan", Status: "completed"},
+	}
+	for i := range steps {
+		steps[i].TaskID = "task-timeline"
+		require.NoError(t, ctrl.RecordRunStep(ctx, &steps[i]))
+	}
+
+	// 같은 단계를 다시 기록하면 덮어씁니다.
+	steps[0].Status = "failed"
+	require.NoError(t, ctrl.RecordRunStep(ctx, &steps[0]))
+
+	timeline, err := ctrl.GetTaskTimeline(ctx, "task-timeline")
+	require.NoError(t, err)
+	require.Equal(t, "task-timeline", timeline.Task.TaskID)
+	require.Len(t, timeline.Steps, 2)
+
+	run := timeline.Steps[0]
+	require.Equal(t, 1, run.StepNo)
+	require.Equal(t, "failed", run.Status)
+	require.Equal(t, 5*time.Second, run.Duration())
+	require.Len(t, run.Children, 2)
+	require.Equal(t, "gpt-4", run.Children[0].Model)
+	require.Equal(t, 120, run.Children[0].InputTokens)
+	require.Equal(t, 3*time.Second, run.Children[0].Duration())
+	require.Equal(t, "timeout", run.Children[1].ErrorClass)
+	require.Len(t, run.Children[1].Children, 1)
+	require.Equal(t, 4, run.Children[1].Children[0].StepNo)
+	require.Equal(t, 1900*time.Millisecond, run.Children[1].Children[0].Duration())
+
+	// 상위 단계가 없는 단계는 최상위에 둡니다.
+	require.Equal(t, 5, timeline.Steps[1].StepNo)
+
+	start, end, ok := timeline.Bounds()
+	require.True(t, ok)
+	require.Equal(t, base, start.UTC())
+	require.Equal(t, base.Add(5*time.Second), end.UTC())
+
+	require.Error(t, ctrl.RecordRunStep(ctx, &storage.RunStep{TaskID: "task-timeline", StepNo: 6, ParentStepNo: 6}))
+	require.Error(t, ctrl.RecordRunStep(ctx, &storage.RunStep{TaskID: "missing", StepNo: 1}))
+	_, err = ctrl.GetTaskTimeline(ctx, "missing")
+	require.Error(t, err)
+}
+
+// recordingNotifier는 전달받은 작업 결과를 기록하는 TaskNotifier입니다.
+type recordingNotifier struct {
+	results []*controller.TaskInfo
+	err     error
+}
+
+func (n *recordingNotifier) NotifyTaskResult(_ context.Context, info *controller.TaskInfo) error {
+	n.results = append(n.results, info)
+	return n.err
+}
+
+func TestControllerRecordsTaskResult(t *testing.T) {
+	ctrl := newTestController(t)
+	notifier := &recordingNotifier{}
+	ctrl.SetTaskNotifier(notifier)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "writer", "Writer agent", "gpt-4", "Write things"))
+	require.NoError(t, ctrl.CreateTask(ctx, "writer", "task-ok", "Write a haiku"))
+	require.NoError(t, ctrl.CreateTask(ctx, "writer", "task-fail", "Write a sonnet"))
+
+	info, err := ctrl.GetTaskInfo(ctx, "task-ok")
+	require.NoError(t, err)
+	require.Zero(t, info.Attempts)
+	require.Nil(t, info.StartedAt)
+
+	// 실행이 시작되면 시도 횟수와 시작 시각이 기록됩니다.
+	require.NoError(t, ctrl.SendMessage(ctx, "task-ok"))
+	require.NoError(t, ctrl.OnComplete("task-ok", &taskrunner.RunResult{
+		Success: true,
+		Output:  "Old pond, frog jumps in",
+		Model:   "gpt-4-0613",
+	}))
+
+	info, err = ctrl.GetTaskInfo(ctx, "task-ok")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCompleted, info.Status)
+	require.Equal(t, 1, info.Attempts)
+	require.Equal(t, "gpt-4-0613", info.ResultModel)
+	require.Equal(t, "Old pond, frog jumps in", info.Output)
+	require.NotEmpty(t, info.ResultRef)
+	require.NotNil(t, info.StartedAt)
+	require.NotNil(t, info.FinishedAt)
+	require.Empty(t, info.ErrorMessage)
+
+	// 출력은 assistant 메시지로 대화에 추가됩니다.
+	messages, err := ctrl.ListMessagesWithContent(ctx, "task-ok")
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	require.Equal(t, "assistant", messages[0].Role)
+	require.Equal(t, info.ResultRef, messages[0].FilePath)
+
+	require.NoError(t, ctrl.OnStatusChange("task-fail", storage.TaskStatusRunning))
+	require.NoError(t, ctrl.OnError("task-fail", context.DeadlineExceeded))
+
+	info, err = ctrl.GetTaskInfo(ctx, "task-fail")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusFailed, info.Status)
+	require.Equal(t, 1, info.Attempts)
+	require.Equal(t, taskrunner.ErrorClassTimeout, info.ErrorClass)
+	require.Equal(t, context.DeadlineExceeded.Error(), info.ErrorMessage)
+	require.Empty(t, info.ResultRef)
+	require.Empty(t, info.Output)
+
+	// 실행 중 취소된 작업은 결과가 와도 canceled로 남습니다.
+	require.NoError(t, ctrl.CreateTask(ctx, "writer", "task-cancel", "Write an ode"))
+	require.NoError(t, ctrl.SendMessage(ctx, "task-cancel"))
+	require.NoError(t, ctrl.UpdateTaskStatus(ctx, "task-cancel", storage.TaskStatusCanceled))
+	require.NoError(t, ctrl.OnComplete("task-cancel", &taskrunner.RunResult{Success: true, Output: "O ode", Model: "gpt-4"}))
+	info, err = ctrl.GetTaskInfo(ctx, "task-cancel")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCanceled, info.Status)
+	require.Equal(t, "O ode", info.Output)
+
+	require.Error(t, ctrl.OnError("missing", context.Canceled))
+
+	// 기록된 결과는 notifier에 전달되며, 전달 실패는 결과 기록을 실패시키지 않습니다.
+	require.Len(t, notifier.results, 3)
+	require.Equal(t, "task-ok", notifier.results[0].TaskID)
+	require.Equal(t, "Old pond, frog jumps in", notifier.results[0].Output)
+	require.Equal(t, storage.TaskStatusFailed, notifier.results[1].Status)
+	require.Equal(t, taskrunner.ErrorClassTimeout, notifier.results[1].ErrorClass)
+	require.Equal(t, storage.TaskStatusCanceled, notifier.results[2].Status)
+
+	notifier.err = errors.New("discord unavailable")
+	require.NoError(t, ctrl.CreateTask(ctx, "writer", "task-retry", "Write a limerick"))
+	require.NoError(t, ctrl.SendMessage(ctx, "task-retry"))
+	require.NoError(t, ctrl.OnComplete("task-retry", &taskrunner.RunResult{Success: true, Output: "There once", Model: "gpt-4"}))
+	require.Len(t, notifier.results, 4)
+}
+
+func TestControllerRunsTaskWithRunner(t *testing.T) {
+	runner := mocks.NewMockRunner()
+	runner.Responses["task-ok"] = "Old pond, frog jumps in"
+	runner.Errors["task-fail"] = context.DeadlineExceeded
+	ctrl := newTestController(t, controller.WithTaskRunner(runner))
+	notifier := &recordingNotifier{}
+	ctrl.SetTaskNotifier(notifier)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "writer", "Writer agent", "gpt-4", "Write things"))
+	require.NoError(t, ctrl.CreateTask(ctx, "writer", "task-ok", "Write a haiku"))
+	require.NoError(t, ctrl.SendMessage(ctx, "task-ok"))
+	require.NoError(t, ctrl.Stop(ctx))
+
+	// 실행 결과는 StatusCallback으로 기록되고 notifier에 전달됩니다.
+	info, err := ctrl.GetTaskInfo(ctx, "task-ok")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCompleted, info.Status)
+	require.Equal(t, "Old pond, frog jumps in", info.Output)
+	require.Len(t, notifier.results, 1)
+	require.Len(t, runner.Calls, 1)
+	require.Equal(t, "gpt-4", runner.Calls[0].Model)
+	require.Equal(t, "Write things", runner.Calls[0].SystemPrompt)
+	require.Equal(t, []taskrunner.ChatMessage{{Role: "user", Content: "Write a haiku"}}, runner.Calls[0].Messages)
+
+	// 다시 생성하면 이전 응답을 뺀 대화로 다시 실행합니다.
+	_, err = ctrl.RegenerateMessage(ctx, "task-ok")
+	require.NoError(t, err)
+	require.NoError(t, ctrl.Stop(ctx))
+	require.Len(t, runner.Calls, 2)
+	require.Equal(t, runner.Calls[0].Messages, runner.Calls[1].Messages)
+
+	require.NoError(t, ctrl.CreateTask(ctx, "writer", "task-fail", "Write a sonnet"))
+	require.NoError(t, ctrl.SendMessage(ctx, "task-fail"))
+	require.NoError(t, ctrl.Stop(ctx))
+	info, err = ctrl.GetTaskInfo(ctx, "task-fail")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusFailed, info.Status)
+	require.Equal(t, taskrunner.ErrorClassTimeout, info.ErrorClass)
+}
+
+func TestControllerForkTask(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "guide", "Travel guide", "gpt-4", "Plan trips"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "critic", "Critic", "gpt-4", "Review plans"))
+	require.NoError(t, ctrl.CreateTask(ctx, "guide", "trip", "Plan a trip"))
+	for _, content := range []string{"Where to?", "Busan", "Food?"} {
+		require.NoError(t, ctrl.AddMessage(ctx, "trip", "user", content))
+	}
+
+	fork, err := ctrl.ForkTask(ctx, "trip", 1, controller.WithForkAgent("critic"), controller.WithForkModel("gpt-4o"))
+	require.NoError(t, err)
+	require.Contains(t, fork.TaskID, "trip-fork-")
+	require.Equal(t, "critic", fork.AgentID)
+
+	info, err := ctrl.GetTaskInfo(ctx, fork.TaskID)
+	require.NoError(t, err)
+	require.Equal(t, "trip", info.ParentTaskID)
+	require.Equal(t, 1, info.ForkIndex)
+	require.Equal(t, "gpt-4o", info.Model)
+	require.Equal(t, "Plan a trip", info.Prompt)
+	require.Equal(t, storage.TaskStatusPending, info.Status)
+
+	messages, err := ctrl.ListMessagesWithContent(ctx, fork.TaskID)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "Where to?", messages[0].Content)
+	require.Equal(t, "Busan", messages[1].Content)
+
+	// 분기한 작업의 대화는 복사한 메시지 다음 인덱스부터 이어지고, 부모 작업에는 영향이 없습니다.
+	require.NoError(t, ctrl.AddMessage(ctx, fork.TaskID, "user", "Jeju instead?"))
+	messages, err = ctrl.ListMessagesWithContent(ctx, fork.TaskID)
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	require.Equal(t, 2, messages[2].ConversationIndex)
+	parent, err := ctrl.ListMessagesWithContent(ctx, "trip")
+	require.NoError(t, err)
+	require.Len(t, parent, 3)
+	require.Equal(t, "Food?", parent[2].Content)
+
+	// 분기한 작업의 분기는 모델 재정의를 이어받습니다.
+	nested, err := ctrl.ForkTask(ctx, fork.TaskID, 2, controller.WithForkTaskID("trip-jeju"))
+	require.NoError(t, err)
+	require.Equal(t, "trip-jeju", nested.TaskID)
+	require.Equal(t, "gpt-4o", nested.Model)
+	require.Equal(t, fork.TaskID, nested.ParentTaskID)
+
+	events, err := ctrl.ListAuditEvents(ctx, storage.AuditFilter{Action: storage.AuditActionTaskFork})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	_, err = ctrl.ForkTask(ctx, "trip", 3)
+	require.Error(t, err)
+	_, err = ctrl.ForkTask(ctx, "trip", 0, controller.WithForkTaskID("trip-jeju"))
+	require.Error(t, err, "duplicate task ID")
+	_, err = ctrl.ForkTask(ctx, "trip", 0, controller.WithForkAgent("missing"))
+	require.Error(t, err)
+	_, err = ctrl.ForkTask(ctx, "missing", 0)
+	require.Error(t, err)
+}
+
+func TestControllerRegenerateEditDeleteMessages(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "guide", "Travel guide", "gpt-4", "Plan trips"))
+	require.NoError(t, ctrl.CreateTask(ctx, "guide", "trip", ""))
+	require.NoError(t, ctrl.AddMessage(ctx, "trip", "user", "Where to?"))
+	require.NoError(t, ctrl.SendMessage(ctx, "trip"))
+	require.NoError(t, ctrl.OnComplete("trip", &taskrunner.RunResult{Success: true, Output: "Busan"}))
+
+	require.Error(t, ctrl.EditMessage(ctx, "trip", 1, "Jeju?"), "assistant messages cannot be edited")
+
+	// 다시 생성하면 작업이 다시 실행되고, 새 응답은 같은 인덱스의 다음 revision이 됩니다.
+	index, err := ctrl.RegenerateMessage(ctx, "trip")
+	require.NoError(t, err)
+	require.Equal(t, 1, index)
+	info, err := ctrl.GetTaskInfo(ctx, "trip")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusRunning, info.Status)
+	_, err = ctrl.RegenerateMessage(ctx, "trip")
+	require.Error(t, err, "running task")
+	require.NoError(t, ctrl.OnComplete("trip", &taskrunner.RunResult{Success: true, Output: "Seoul"}))
+
+	messages, err := ctrl.ListMessagesWithContent(ctx, "trip")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "Seoul", messages[1].Content)
+	require.Equal(t, 2, messages[1].Revision)
+
+	// 질문을 수정하면 그 뒤의 응답도 대체되고 작업이 다시 실행됩니다.
+	require.NoError(t, ctrl.EditMessage(ctx, "trip", 0, "Where to eat?"))
+	require.NoError(t, ctrl.OnComplete("trip", &taskrunner.RunResult{Success: true, Output: "Jeonju"}))
+
+	messages, err = ctrl.ListMessagesWithContent(ctx, "trip")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "Where to eat?", messages[0].Content)
+	require.Equal(t, "Jeonju", messages[1].Content)
+
+	versions, err := ctrl.ListMessageVersions(ctx, "trip")
+	require.NoError(t, err)
+	var contents []string
+	for _, v := range versions {
+		contents = append(contents, fmt.Sprintf("%d.%d %s %t", v.ConversationIndex, v.Revision, v.Content, v.SupersededAt != nil))
+	}
+	require.Equal(t, []string{
+		"0.1 Where to? true",
+		"0.2 Where to eat? false",
+		"1.1 Busan true",
+		"1.2 Seoul true",
+		"1.3 Jeonju false",
+	}, contents)
+
+	// 삭제는 대체된 버전까지 지웁니다.
+	require.NoError(t, ctrl.DeleteMessage(ctx, "trip", 1))
+	versions, err = ctrl.ListMessageVersions(ctx, "trip")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.Error(t, ctrl.DeleteMessage(ctx, "trip", 1))
+	_, err = ctrl.RegenerateMessage(ctx, "trip")
+	require.Error(t, err, "last message is not an assistant message")
+
+	for action, count := range map[string]int{
+		storage.AuditActionMessageRegenerate: 1,
+		storage.AuditActionMessageEdit:       1,
+		storage.AuditActionMessageDelete:     1,
+	} {
+		events, err := ctrl.ListAuditEvents(ctx, storage.AuditFilter{Action: action})
+		require.NoError(t, err)
+		require.Len(t, events, count, action)
+	}
+}
+
+func TestControllerRegenerateCanceledTask(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "guide", "Travel guide", "gpt-4", "Plan trips"))
+	require.NoError(t, ctrl.CreateTask(ctx, "guide", "trip", ""))
+	require.NoError(t, ctrl.AddMessage(ctx, "trip", "user", "Where to?"))
+	require.NoError(t, ctrl.SendMessage(ctx, "trip"))
+	require.NoError(t, ctrl.UpdateTaskStatus(ctx, "trip", storage.TaskStatusCanceled))
+	require.NoError(t, ctrl.OnComplete("trip", &taskrunner.RunResult{Success: true, Output: "Busan"}))
+
+	// 취소된 작업은 다시 생성하거나 질문을 수정해 되살리지 않으며, 대화와 상태도 그대로 둡니다.
+	_, err := ctrl.RegenerateMessage(ctx, "trip")
+	require.Error(t, err)
+	require.Error(t, ctrl.EditMessage(ctx, "trip", 0, "Where to eat?"))
+
+	info, err := ctrl.GetTaskInfo(ctx, "trip")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCanceled, info.Status)
+	versions, err := ctrl.ListMessageVersions(ctx, "trip")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	for _, v := range versions {
+		require.Nil(t, v.SupersededAt)
+	}
+
+	// 되돌린 뒤 실행을 시작하지 못해도 작업은 대화를 되돌린 채 pending으로 남아 다시 보낼 수 있습니다.
+	require.NoError(t, ctrl.CreateTask(ctx, "guide", "retry", ""))
+	require.NoError(t, ctrl.AddMessage(ctx, "retry", "user", "Where to?"))
+	require.NoError(t, ctrl.SendMessage(ctx, "retry"))
+	require.NoError(t, ctrl.OnComplete("retry", &taskrunner.RunResult{Success: true, Output: "Busan"}))
+	require.NoError(t, ctrl.UpdateAgent(ctx, "guide", "Travel guide", "gpt-4", "Plan trips in {{.City}}", currentVersion(t, ctrl, "guide")))
+	_, err = ctrl.RegenerateMessage(ctx, "retry")
+	require.ErrorIs(t, err, controller.ErrMissingPromptVariables)
+
+	info, err = ctrl.GetTaskInfo(ctx, "retry")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusPending, info.Status)
+	messages, err := ctrl.ListMessages(ctx, "retry")
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+}
+
+func TestControllerLabels(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.NoError(t, ctrl.CreateAgent(ctx, "infra-bot", "Infra", "gpt-4", "Ops", controller.WithLabels(map[string]string{"team": "infra"})))
+	require.Error(t, ctrl.CreateAgent(ctx, "bad", "", "gpt-4", "", controller.WithLabels(map[string]string{"bad key": "x"})))
+	require.NoError(t, ctrl.CreateTask(ctx, "infra-bot", "t-1", "Hello", controller.WithTaskLabels(map[string]string{"ticket": "ABC-12", "env": "prod"})))
+	require.NoError(t, ctrl.CreateTask(ctx, "infra-bot", "t-2", "Hello", controller.WithTaskLabels(map[string]string{"env": "dev"})))
+	require.Error(t, ctrl.CreateTask(ctx, "infra-bot", "t-3", "Hello", controller.WithTaskLabels(map[string]string{"env": "a b"})))
+
+	info, err := ctrl.GetTaskInfo(ctx, "t-1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ticket": "ABC-12", "env": "prod"}, info.Labels)
+
+	selector, err := storage.ParseLabelSelector("env!=prod")
+	require.NoError(t, err)
+	page, err := ctrl.ListTasksPage(ctx, storage.TaskFilter{Labels: selector})
+	require.NoError(t, err)
+	require.Len(t, page.Tasks, 1)
+	require.Equal(t, "t-2", page.Tasks[0].TaskID)
+
+	// 레이블 변경은 작업 버전을 올리지 않고 감사 로그에 남습니다.
+	labels := controller.UpdateLabels(info.Labels, map[string]string{"env": "staging"}, []string{"ticket"})
+	require.NoError(t, ctrl.SetTaskLabels(ctx, "t-1", labels))
+	updated, err := ctrl.GetTaskInfo(ctx, "t-1")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"env": "staging"}, updated.Labels)
+	require.Equal(t, info.Version, updated.Version)
+	require.Error(t, ctrl.SetTaskLabels(ctx, "missing", labels))
+
+	events, err := ctrl.ListAuditEvents(ctx, storage.AuditFilter{Action: storage.AuditActionTaskLabel})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	diff, err := controller.AuditDiff(events[0])
+	require.NoError(t, err)
+	require.Equal(t, map[string][2]string{"labels": {"env=prod,ticket=ABC-12", "env=staging"}}, diff)
+
+	// 에이전트 레이블은 UpdateAgent의 WithLabels로도 바꿀 수 있으며 리비전을 만들지 않습니다.
+	require.NoError(t, ctrl.UpdateAgent(ctx, "infra-bot", "Infra", "gpt-4", "Ops", controller.WithLabels(map[string]string{"team": "platform"}), currentVersion(t, ctrl, "infra-bot")))
+	agent, err := ctrl.GetAgentInfo(ctx, "infra-bot")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "platform"}, agent.Labels)
+	require.Equal(t, 1, agent.Revision)
+	agents, err := ctrl.ListAgentsPage(ctx, storage.AgentFilter{Labels: storage.LabelSelector{{Key: "team", Operator: storage.LabelOpEquals, Value: "infra"}}})
+	require.NoError(t, err)
+	require.Empty(t, agents.Agents)
+
+	// 분기한 작업은 부모의 레이블을 물려받습니다.
+	require.NoError(t, ctrl.AddMessage(ctx, "t-2", "user", "Hi"))
+	child, err := ctrl.ForkTask(ctx, "t-2", 0, controller.WithForkTaskID("t-2-b"))
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"env": "dev"}, child.Labels)
+}
+
+func TestParsePromptTemplate(t *testing.T) {
+	tmpl, err := controller.ParsePromptTemplate(`You review {{.Language | upper}} code for {{.Repo}}.{{if .Style}} Follow {{.Style}}.{{end}} Branch: {{.Branch | default "main"}}`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Language", "Repo"}, tmpl.Required())
+	require.Equal(t, []string{"Branch", "Style"}, tmpl.Optional())
+
+	out, err := tmpl.Render(map[string]string{"Language": "go", "Repo": "cnap"})
+	require.NoError(t, err)
+	require.Equal(t, "You review GO code for cnap. Branch: main", out)
+
+	out, err = tmpl.Render(map[string]string{"Language": "go", "Repo": "cnap", "Style": "gofmt", "Branch": "dev"})
+	require.NoError(t, err)
+	require.Equal(t, "You review GO code for cnap. Follow gofmt. Branch: dev", out)
+
+	_, err = tmpl.Render(map[string]string{"Language": "go"})
+	require.ErrorIs(t, err, controller.ErrMissingPromptVariables)
+	require.Contains(t, err.Error(), "Repo")
+	_, err = tmpl.Render(map[string]string{"Language": "go", "Repo": "cnap", "Typo": "x"})
+	require.ErrorIs(t, err, controller.ErrUnknownPromptVariables)
+
+	// 템플릿이 없는 프롬프트는 그대로 렌더링됩니다.
+	out, err = controller.RenderPrompt("Plain prompt", nil)
+	require.NoError(t, err)
+	require.Equal(t, "Plain prompt", out)
+
+	// 마이그레이션 0019가 바꾼 이전 프롬프트의 {{"{{"}}는 "{{"로 렌더링됩니다.
+	out, err = controller.RenderPrompt(`Reply as {{"{{"}}name}}`, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Reply as {{name}}", out)
+
+	for _, text := range []string{
+		`{{printf "%10000000d" 1}}`,
+		`{{call .Fn}}`,
+		`{{range .Items}}x{{end}}`,
+		`{{define "x"}}y{{end}}`,
+		`{{template "x"}}`,
+		`{{.Repo.Name}}`,
+		`{{.Language`,
+	} {
+		_, err := controller.ParsePromptTemplate(text)
+		require.Error(t, err, text)
+	}
+
+	vars, err := controller.ParsePromptVariables([]string{"Language=Go", "Query=a=b"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"Language": "Go", "Query": "a=b"}, vars)
+	for _, bad := range [][]string{{"Language"}, {"1x=y"}, {"A=1", "A=2"}} {
+		_, err := controller.ParsePromptVariables(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestControllerPromptTemplates(t *testing.T) {
+	ctrl := newTestController(t)
+
+	ctx := context.Background()
+	require.Error(t, ctrl.CreateAgent(ctx, "bad", "", "gpt-4", "{{printf \"%d\" 1}}"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "reviewer", "Reviewer", "gpt-4", "You review {{.Language}} code for {{.Repo}}"))
+	require.NoError(t, ctrl.CreateAgent(ctx, "plain", "Plain", "gpt-4", "Review {{.Language | default \"any\"}} code"))
+
+	// 필수 변수가 빠지면 작업을 만들지 않습니다.
+	err := ctrl.CreateTask(ctx, "reviewer", "r-0", "Hi", controller.WithTaskVariables(map[string]string{"Language": "Go"}))
+	require.ErrorIs(t, err, controller.ErrMissingPromptVariables)
+	_, err = ctrl.GetTaskInfo(ctx, "r-0")
+	require.Error(t, err)
+
+	vars := map[string]string{"Language": "Go", "Repo": "cnap"}
+	require.NoError(t, ctrl.CreateTask(ctx, "reviewer", "r-1", "Hi", controller.WithTaskVariables(vars)))
+	info, err := ctrl.GetTaskInfo(ctx, "r-1")
+	require.NoError(t, err)
+	require.Equal(t, "You review Go code for cnap", info.SystemPrompt)
+	require.Equal(t, vars, info.Variables)
+
+	// 에이전트 프롬프트가 바뀌면 다음 실행에서 저장한 변수로 다시 렌더링합니다.
+	require.NoError(t, ctrl.UpdateAgent(ctx, "reviewer", "Reviewer", "gpt-4", "Review {{.Repo}} ({{.Language | lower}})", currentVersion(t, ctrl, "reviewer")))
+	require.NoError(t, ctrl.SendMessage(ctx, "r-1"))
+	info, err = ctrl.GetTaskInfo(ctx, "r-1")
+	require.NoError(t, err)
+	require.Equal(t, 2, info.AgentRevision)
+	require.Equal(t, "Review cnap (go)", info.SystemPrompt)
+
+	// 분기 대상 에이전트의 프롬프트를 부모 작업의 변수로 렌더링하며, 쓰지 않는 변수는 무시합니다.
+	require.NoError(t, ctrl.AddMessage(ctx, "r-1", "user", "Check this"))
+	fork, err := ctrl.ForkTask(ctx, "r-1", 0, controller.WithForkTaskID("r-1-b"))
+	require.NoError(t, err)
+	require.Equal(t, "Review cnap (go)", fork.SystemPrompt)
+	fork, err = ctrl.ForkTask(ctx, "r-1", 0, controller.WithForkAgent("plain"))
+	require.NoError(t, err)
+	require.Equal(t, "Review Go code", fork.SystemPrompt)
+	require.Equal(t, `{"Language":"Go","Repo":"cnap"}`, fork.Variables)
+
+	// 변수를 쓰지 않는 작업에 새 필수 변수가 생기면 실행하지 않습니다.
+	require.NoError(t, ctrl.CreateTask(ctx, "plain", "p-1", "Hi"))
+	info, err = ctrl.GetTaskInfo(ctx, "p-1")
+	require.NoError(t, err)
+	require.Equal(t, "Review any code", info.SystemPrompt)
+	require.Empty(t, info.Variables)
+	require.NoError(t, ctrl.UpdateAgent(ctx, "plain", "Plain", "gpt-4", "Review {{.Language}} code", currentVersion(t, ctrl, "plain")))
+	require.ErrorIs(t, ctrl.SendMessage(ctx, "p-1"), controller.ErrMissingPromptVariables)
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ForkOption은 ForkTask에서 분기한 작업의 ID, 에이전트, 모델을 지정합니다.
+type ForkOption func(*forkSpec)
+
+type forkSpec struct {
+	taskID  string
+	agentID string
+	model   string
+}
+
+// WithForkTaskID는 분기한 작업의 ID를 지정합니다. 지정하지 않으면 부모 작업 ID로 만듭니다.
+func WithForkTaskID(taskID string) ForkOption {
+	return func(s *forkSpec) { s.taskID = taskID }
+}
+
+// WithForkAgent는 분기한 작업을 실행할 에이전트를 지정합니다. 지정하지 않으면 부모 작업의 에이전트입니다.
+func WithForkAgent(agentID string) ForkOption {
+	return func(s *forkSpec) { s.agentID = agentID }
+}
+
+// WithForkModel은 분기한 작업에서 에이전트 모델 대신 사용할 모델을 지정합니다.
+// 지정하지 않으면 부모 작업의 모델 재정의를 이어받습니다.
+func WithForkModel(model string) ForkOption {
+	return func(s *forkSpec) { s.model = model }
+}
+
+// ForkTask는 부모 작업의 대화 0..atIndex를 복사한 새 작업을 만듭니다.
+// 메시지 본문도 새 작업의 키로 복사하므로 부모 작업이 정리되어도 분기한 작업의 대화는 남습니다.
+// 새 작업은 pending 상태이며, 메시지를 추가한 뒤 SendMessage로 실행합니다.
+func (c *Controller) ForkTask(ctx context.Context, parentTaskID string, atIndex int, opts ...ForkOption) (*storage.Task, error) {
+	ctx, span := tracing.Start(ctx, "controller.ForkTask",
+		attribute.String("cnap.task_id", parentTaskID),
+		attribute.Int("cnap.fork_index", atIndex),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+	if c.messages == nil {
+		return nil, fmt.Errorf("controller: message store is not configured")
+	}
+
+	parent, err := c.repo.GetTask(ctx, parentTaskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("task not found: %s", parentTaskID)
+		}
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	spec := forkSpec{agentID: parent.AgentID, model: parent.Model}
+	for _, opt := range opts {
+		opt(&spec)
+	}
+	if spec.taskID == "" {
+		spec.taskID = newForkTaskID(parentTaskID, time.Now())
+	}
+	if err := c.ValidateTask(spec.taskID); err != nil {
+		return nil, err
+	}
+
+	agent, err := c.repo.GetAgent(ctx, spec.agentID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("agent not found: %s", spec.agentID)
+		}
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	if agent.Status == storage.AgentStatusDeleted {
+		return nil, fmt.Errorf("%w: %s", ErrAgentDeleted, spec.agentID)
+	}
+	// 부모 작업의 변수로 분기 대상 에이전트의 프롬프트를 다시 렌더링합니다.
+	vars, err := decodePromptVariables(parent.Variables)
+	if err != nil {
+		return nil, err
+	}
+	systemPrompt, err := rerenderPrompt(agent.Prompt, vars)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", agent.AgentID, err)
+	}
+
+	messages, err := c.repo.ListMessageIndexByTask(ctx, parentTaskID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	// 삭제한 메시지가 있으면 인덱스가 비므로 위치가 아니라 대화 인덱스로 자릅니다.
+	cut := slices.IndexFunc(messages, func(m storage.MessageIndex) bool { return m.ConversationIndex == atIndex })
+	if cut < 0 {
+		last := -1
+		if len(messages) > 0 {
+			last = messages[len(messages)-1].ConversationIndex
+		}
+		return nil, fmt.Errorf("conversation index %d out of range for task %s (0..%d)", atIndex, parentTaskID, last)
+	}
+	messages = messages[:cut+1]
+
+	copied, err := c.copyMessages(ctx, spec.taskID, messages)
+	if err != nil {
+		c.removeMessages(ctx, copied)
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to copy messages: %w", err)
+	}
+	refs := make([]storage.MessageIndex, len(copied))
+	for i, msg := range copied {
+		refs[i] = msg.ref
+	}
+
+	child := &storage.Task{
+		TaskID:        spec.taskID,
+		AgentID:       agent.AgentID,
+		Prompt:        parent.Prompt,
+		Status:        storage.TaskStatusPending,
+		AgentRevision: agent.Revision,
+		ParentTaskID:  parentTaskID,
+		ForkIndex:     atIndex,
+		Model:         spec.model,
+		SystemPrompt:  systemPrompt,
+		Variables:     parent.Variables,
+		OwnerID:       ActorFromContext(ctx),
+		Labels:        parent.Labels,
+	}
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.ForkTask(ctx, child, refs); err != nil {
+			return err
+		}
+		after := taskAuditState(child)
+		after["parent_task_id"] = parentTaskID
+		after["fork_index"] = strconv.Itoa(atIndex)
+		after["model"] = child.Model
+		return c.recordAudit(ctx, repo, storage.AuditActionTaskFork, storage.AuditTargetTask, child.TaskID, child.AgentID, nil, after)
+	})
+	if err != nil {
+		c.removeMessages(ctx, copied)
+		logger.Error("Failed to fork task", zap.Error(err))
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	// 검색 인덱스는 'cnap task reindex'로 다시 만들 수 있으므로 실패해도 분기는 성공으로 처리합니다.
+	for _, msg := range copied {
+		if err := c.repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+			TaskID:  child.TaskID,
+			AgentID: child.AgentID,
+			Source:  storage.SearchSourceMessage,
+			Ref:     msg.ref.FilePath,
+			Body:    msg.content,
+		}); err != nil {
+			logger.Warn("Failed to index message for search", zap.Error(err), zap.String("key", msg.ref.FilePath))
+		}
+	}
+
+	logger.Info("Task forked",
+		zap.String("task_id", child.TaskID),
+		zap.String("parent_task_id", parentTaskID),
+		zap.Int("fork_index", atIndex),
+		zap.String("agent_id", child.AgentID),
+		zap.String("model", child.Model),
+	)
+	return child, nil
+}
+
+// copiedMessage는 새 키로 복사한 메시지 본문과 그 참조입니다.
+type copiedMessage struct {
+	ref     storage.MessageIndex
+	content string
+}
+
+// copyMessages는 메시지 본문을 taskID의 새 키로 복사합니다.
+// 실패해도 그때까지 복사한 메시지를 반환하므로 호출자가 정리할 수 있습니다.
+func (c *Controller) copyMessages(ctx context.Context, taskID string, messages []storage.MessageIndex) ([]copiedMessage, error) {
+	copied := make([]copiedMessage, 0, len(messages))
+	// 한 번에 여러 본문을 저장하므로 키가 겹치지 않도록 시각을 1ns씩 늘립니다.
+	base := time.Now().UTC()
+	for i, msg := range messages {
+		body, err := c.messages.Get(ctx, msg.FilePath)
+		if err != nil {
+			return copied, fmt.Errorf("message %d: %w", msg.ConversationIndex, err)
+		}
+		now := base.Add(time.Duration(i))
+		key := msgstore.NewKey(taskID, now)
+		if err := c.messages.Put(ctx, key, &msgstore.Message{
+			TaskID:    taskID,
+			Role:      body.Role,
+			Content:   body.Content,
+			Author:    body.Author,
+			CreatedAt: body.CreatedAt,
+			UpdatedAt: now,
+		}); err != nil {
+			return copied, fmt.Errorf("message %d: %w", msg.ConversationIndex, err)
+		}
+		copied = append(copied, copiedMessage{
+			ref: storage.MessageIndex{
+				Role:      msg.Role,
+				FilePath:  key,
+				CreatedAt: msg.CreatedAt,
+			},
+			content: body.Content,
+		})
+	}
+	return copied, nil
+}
+
+// removeMessages는 복사했지만 인덱스에 연결되지 않은 메시지 본문을 지웁니다.
+func (c *Controller) removeMessages(ctx context.Context, messages []copiedMessage) {
+	for _, msg := range messages {
+		if err := c.messages.Delete(ctx, msg.ref.FilePath); err != nil {
+			tracing.Logger(ctx, c.logger).Warn("Failed to remove orphaned message content", zap.Error(err), zap.String("key", msg.ref.FilePath))
+		}
+	}
+}
+
+// newForkTaskID는 부모 작업 ID와 현재 시각으로 분기한 작업의 ID를 만듭니다.
+// 작업 ID 길이 제한(64자)을 넘지 않도록 부모 작업 ID를 자릅니다.
+func newForkTaskID(parentTaskID string, now time.Time) string {
+	suffix := "-fork-" + strconv.FormatInt(now.UnixMilli(), 36)
+	if limit := 64 - len(suffix); len(parentTaskID) > limit {
+		parentTaskID = parentTaskID[:limit]
+	}
+	return parentTaskID + suffix
+}
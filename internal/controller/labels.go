@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// TaskOption은 CreateTask에서 작업의 부가 정보를 지정합니다.
+type TaskOption func(*taskSpec) error
+
+// taskSpec은 TaskOption이 채우는 값입니다.
+type taskSpec struct {
+	labels    map[string]string
+	variables map[string]string
+}
+
+// WithTaskLabels는 작업에 붙일 레이블을 지정합니다.
+func WithTaskLabels(labels map[string]string) TaskOption {
+	return func(s *taskSpec) error {
+		if err := storage.ValidateLabels(labels); err != nil {
+			return err
+		}
+		s.labels = maps.Clone(labels)
+		return nil
+	}
+}
+
+// WithTaskVariables는 에이전트 프롬프트 템플릿을 렌더링할 변수를 지정합니다.
+func WithTaskVariables(vars map[string]string) TaskOption {
+	return func(s *taskSpec) error {
+		for name := range vars {
+			if !promptVariableNamePattern.MatchString(name) {
+				return fmt.Errorf("invalid variable name %q", name)
+			}
+		}
+		s.variables = maps.Clone(vars)
+		return nil
+	}
+}
+
+func applyTaskOptions(opts []TaskOption) (taskSpec, error) {
+	var spec taskSpec
+	for _, opt := range opts {
+		if err := opt(&spec); err != nil {
+			return taskSpec{}, err
+		}
+	}
+	return spec, nil
+}
+
+// WithLabels는 CreateAgent, UpdateAgent에서 에이전트 레이블을 지정합니다.
+// UpdateAgent에서는 기존 레이블 전체를 바꾸며, 지정하지 않으면 레이블을 그대로 둡니다.
+func WithLabels(labels map[string]string) AgentOption {
+	return func(f *agentSpec) error {
+		if err := storage.ValidateLabels(labels); err != nil {
+			return err
+		}
+		f.labels = maps.Clone(labels)
+		if f.labels == nil {
+			f.labels = map[string]string{}
+		}
+		return nil
+	}
+}
+
+// UpdateLabels는 current에 set을 덮어쓰고 remove의 키를 지운 새 레이블을 반환합니다.
+func UpdateLabels(current, set map[string]string, remove []string) map[string]string {
+	labels := maps.Clone(current)
+	if labels == nil {
+		labels = make(map[string]string, len(set))
+	}
+	maps.Copy(labels, set)
+	for _, key := range remove {
+		delete(labels, key)
+	}
+	return labels
+}
+
+// SetTaskLabels는 작업의 레이블을 labels로 바꿉니다. 레이블은 작업 버전을 올리지 않습니다.
+func (c *Controller) SetTaskLabels(ctx context.Context, taskID string, labels map[string]string) error {
+	ctx, span := tracing.Start(ctx, "controller.SetTaskLabels", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+
+	task, err := c.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("task not found: %s", taskID)
+		}
+		return err
+	}
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.SetLabels(ctx, storage.LabelTargetTask, taskID, labels); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionTaskLabel, storage.AuditTargetTask, taskID, task.AgentID,
+			labelAuditState(task.Labels), labelAuditState(labels))
+	})
+	if err != nil {
+		logger.Error("Failed to set task labels", zap.Error(err))
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	logger.Info("Task labels updated",
+		zap.String("task_id", taskID),
+		zap.String("labels", storage.FormatLabels(labels)),
+	)
+	return nil
+}
+
+// SetAgentLabels는 에이전트의 레이블을 labels로 바꿉니다. 레이블은 에이전트 리비전을 만들지 않습니다.
+func (c *Controller) SetAgentLabels(ctx context.Context, agentID string, labels map[string]string) error {
+	ctx, span := tracing.Start(ctx, "controller.SetAgentLabels", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+
+	agent, err := c.repo.GetAgent(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("agent not found: %s", agentID)
+		}
+		return err
+	}
+	if agent.Status == storage.AgentStatusDeleted {
+		return fmt.Errorf("%w: %s", ErrAgentDeleted, agentID)
+	}
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.SetLabels(ctx, storage.LabelTargetAgent, agentID, labels); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionAgentLabel, storage.AuditTargetAgent, agentID, agentID,
+			labelAuditState(agent.Labels), labelAuditState(labels))
+	})
+	if err != nil {
+		logger.Error("Failed to set agent labels", zap.Error(err))
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	logger.Info("Agent labels updated",
+		zap.String("agent_id", agentID),
+		zap.String("labels", storage.FormatLabels(labels)),
+	)
+	return nil
+}
+
+// labelAuditState는 감사 로그에 남길 레이블 상태입니다.
+func labelAuditState(labels map[string]string) map[string]string {
+	return map[string]string{"labels": storage.FormatLabels(labels)}
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// AgentPage는 에이전트 목록의 한 페이지입니다. NextCursor가 비어 있으면 마지막 페이지입니다.
+// 목록용이므로 AgentInfo의 Prompt, Parameters, Tools는 채워지지 않습니다.
+type AgentPage struct {
+	Agents     []*AgentInfo
+	NextCursor string
+}
+
+// TaskPage는 작업 목록의 한 페이지입니다. NextCursor가 비어 있으면 마지막 페이지입니다.
+type TaskPage struct {
+	Tasks      []storage.Task
+	NextCursor string
+}
+
+// ListAgentsPage는 필터와 정렬을 적용해 에이전트 목록을 한 페이지씩 반환합니다.
+// 다음 페이지는 filter.Cursor에 NextCursor를 넣어 조회합니다.
+func (c *Controller) ListAgentsPage(ctx context.Context, filter storage.AgentFilter) (*AgentPage, error) {
+	ctx, span := tracing.Start(ctx, "controller.ListAgentsPage",
+		attribute.Int("cnap.limit", filter.Limit),
+		attribute.String("cnap.sort", filter.Sort),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing agent page",
+		zap.Strings("statuses", filter.Statuses),
+		zap.String("model", filter.Model),
+		zap.String("prefix", filter.NamePrefix),
+		zap.Stringer("labels", filter.Labels),
+		zap.String("sort", filter.Sort),
+		zap.Int("limit", filter.Limit),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	records, next, err := c.repo.ListAgentsPage(ctx, filter)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	page := &AgentPage{Agents: make([]*AgentInfo, 0, len(records)), NextCursor: next}
+	for _, rec := range records {
+		page.Agents = append(page.Agents, &AgentInfo{
+			Name:        rec.AgentID,
+			Description: rec.Description,
+			Model:       rec.Model,
+			Status:      rec.Status,
+			Owner:       rec.OwnerID,
+			Revision:    rec.Revision,
+			Version:     rec.Version,
+			Labels:      rec.Labels,
+			CreatedAt:   rec.CreatedAt,
+			UpdatedAt:   rec.UpdatedAt,
+		})
+	}
+
+	logger.Info("Listed agent page",
+		zap.Int("count", len(page.Agents)),
+		zap.Bool("has_more", next != ""),
+	)
+	return page, nil
+}
+
+// ListTasksPage는 필터와 정렬을 적용해 작업 목록을 한 페이지씩 반환합니다.
+// filter.AgentID가 비어 있으면 모든 에이전트의 작업을 조회합니다.
+func (c *Controller) ListTasksPage(ctx context.Context, filter storage.TaskFilter) (*TaskPage, error) {
+	ctx, span := tracing.Start(ctx, "controller.ListTasksPage",
+		attribute.String("cnap.agent_id", filter.AgentID),
+		attribute.Int("cnap.limit", filter.Limit),
+		attribute.String("cnap.sort", filter.Sort),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing task page",
+		zap.String("agent_id", filter.AgentID),
+		zap.Strings("statuses", filter.Statuses),
+		zap.Stringer("labels", filter.Labels),
+		zap.String("sort", filter.Sort),
+		zap.Int("limit", filter.Limit),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	tasks, next, err := c.repo.ListTasksPage(ctx, filter)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	logger.Info("Listed task page",
+		zap.String("agent_id", filter.AgentID),
+		zap.Int("count", len(tasks)),
+		zap.Bool("has_more", next != ""),
+	)
+	return &TaskPage{Tasks: tasks, NextCursor: next}, nil
+}
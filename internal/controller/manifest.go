@@ -0,0 +1,256 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/cnap-oss/app/internal/manifest"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// ApplyAction은 매니페스트 적용 시 에이전트 하나에 대해 수행할 작업입니다.
+type ApplyAction string
+
+const (
+	ApplyCreate    ApplyAction = "create"
+	ApplyUpdate    ApplyAction = "update"
+	ApplyDelete    ApplyAction = "delete"
+	ApplyUnchanged ApplyAction = "unchanged"
+)
+
+// ApplyOptions는 ApplyAgentManifests의 동작을 지정합니다.
+type ApplyOptions struct {
+	// Prune이 true이면 매니페스트에 없는 활성 에이전트를 삭제합니다.
+	Prune bool
+	// DryRun이 true이면 계획만 계산하고 데이터베이스를 바꾸지 않습니다.
+	DryRun bool
+}
+
+// AgentChange는 매니페스트 적용 계획의 한 항목입니다.
+// Fields는 현재 상태에서 원하는 상태로 바뀌는 필드의 diff입니다.
+type AgentChange struct {
+	Name   string
+	Action ApplyAction
+	Fields []FieldDiff
+}
+
+// ExportAgentManifests는 에이전트 설정을 매니페스트로 내보냅니다.
+// names를 생략하면 삭제되지 않은 모든 에이전트를 생성 순으로 내보냅니다.
+func (c *Controller) ExportAgentManifests(ctx context.Context, names ...string) ([]manifest.Agent, error) {
+	ctx, span := tracing.Start(ctx, "controller.ExportAgentManifests")
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Exporting agent manifests", zap.Strings("agents", names))
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	var records []storage.Agent
+	if len(names) == 0 {
+		var err error
+		records, err = c.repo.ListAgents(ctx, liveAgentStatuses...)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+	} else {
+		for _, name := range names {
+			rec, err := c.repo.GetAgent(ctx, name)
+			if err != nil {
+				if errors.Is(err, storage.ErrNotFound) {
+					return nil, fmt.Errorf("agent not found: %s", name)
+				}
+				return nil, err
+			}
+			records = append(records, *rec)
+		}
+	}
+
+	manifests := make([]manifest.Agent, 0, len(records))
+	for i := range records {
+		m, err := agentManifest(&records[i])
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+		manifests = append(manifests, m)
+	}
+
+	logger.Info("Exported agent manifests", zap.Int("count", len(manifests)))
+	return manifests, nil
+}
+
+// ApplyAgentManifests는 데이터베이스의 에이전트를 매니페스트와 같은 상태로 맞춥니다.
+// 없는 에이전트는 생성하고(삭제된 에이전트는 다시 활성화), 다른 에이전트는 수정하며,
+// opts.Prune이면 매니페스트에 없는 에이전트를 삭제합니다.
+// 이미 같은 상태이면 아무것도 바꾸지 않으므로 여러 번 적용해도 결과가 같습니다.
+// 계획 전체를 반환하며, 적용 중 실패하면 그때까지의 계획과 에러를 반환합니다.
+func (c *Controller) ApplyAgentManifests(ctx context.Context, manifests []manifest.Agent, opts ApplyOptions) ([]AgentChange, error) {
+	ctx, span := tracing.Start(ctx, "controller.ApplyAgentManifests",
+		attribute.Int("cnap.manifest_count", len(manifests)),
+		attribute.Bool("cnap.dry_run", opts.DryRun),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Applying agent manifests",
+		zap.Int("count", len(manifests)),
+		zap.Bool("prune", opts.Prune),
+		zap.Bool("dry_run", opts.DryRun),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	records, err := c.repo.ListAgents(ctx)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	existing := make(map[string]*storage.Agent, len(records))
+	for i := range records {
+		existing[records[i].AgentID] = &records[i]
+	}
+
+	type step struct {
+		change AgentChange
+		before *storage.Agent
+		fields agentFields
+	}
+	var (
+		steps    []step
+		declared = make(map[string]bool, len(manifests))
+	)
+	for i := range manifests {
+		m := &manifests[i]
+		if err := m.Validate(); err != nil {
+			return nil, err
+		}
+		if declared[m.Name] {
+			return nil, fmt.Errorf("agent %s is declared more than once", m.Name)
+		}
+		declared[m.Name] = true
+
+		spec, err := applyAgentOptions(agentFields{Description: m.Description, Model: m.Model, Prompt: m.Prompt},
+			[]AgentOption{WithParameters(m.Parameters), WithTools(m.Tools)})
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", m.Name, err)
+		}
+		fields := spec.agentFields
+
+		before := existing[m.Name]
+		s := step{change: AgentChange{Name: m.Name}, before: before, fields: fields}
+		switch {
+		case before == nil || before.Status == storage.AgentStatusDeleted:
+			s.change.Action = ApplyCreate
+			s.change.Fields = diffAgentFields(agentFields{}, fields)
+		default:
+			s.change.Fields = diffAgentFields(agentFieldsOf(before), fields)
+			s.change.Action = ApplyUpdate
+			if len(s.change.Fields) == 0 {
+				s.change.Action = ApplyUnchanged
+			}
+		}
+		steps = append(steps, s)
+	}
+	if opts.Prune {
+		for i := range records {
+			rec := &records[i]
+			if declared[rec.AgentID] || rec.Status == storage.AgentStatusDeleted {
+				continue
+			}
+			steps = append(steps, step{
+				change: AgentChange{
+					Name:   rec.AgentID,
+					Action: ApplyDelete,
+					Fields: diffAgentFields(agentFieldsOf(rec), agentFields{}),
+				},
+				before: rec,
+			})
+		}
+	}
+
+	changes := make([]AgentChange, 0, len(steps))
+	for _, s := range steps {
+		changes = append(changes, s.change)
+	}
+	if opts.DryRun {
+		return changes, nil
+	}
+
+	for _, s := range steps {
+		name := s.change.Name
+		var err error
+		switch s.change.Action {
+		case ApplyCreate:
+			if s.before == nil {
+				err = c.CreateAgent(ctx, name, s.fields.Description, s.fields.Model, s.fields.Prompt, withAgentFields(s.fields))
+			} else {
+				err = c.reactivateAgent(ctx, s.before, s.fields)
+			}
+		case ApplyUpdate:
+			_, err = c.reviseAgent(ctx, s.before, s.fields, storage.AuditActionAgentUpdate)
+		case ApplyDelete:
+			_, err = c.DeleteAgent(ctx, name)
+		}
+		if err != nil {
+			logger.Error("Failed to apply agent manifest",
+				zap.String("agent", name),
+				zap.String("action", string(s.change.Action)),
+				zap.Error(err),
+			)
+			tracing.RecordError(span, err)
+			return changes, fmt.Errorf("agent %s %s: %w", name, s.change.Action, err)
+		}
+	}
+
+	logger.Info("Agent manifests applied", zap.Int("changes", len(changes)))
+	return changes, nil
+}
+
+// reactivateAgent는 삭제된 에이전트를 다시 활성화하고 설정을 fields로 바꿉니다.
+func (c *Controller) reactivateAgent(ctx context.Context, before *storage.Agent, fields agentFields) error {
+	active := *before
+	err := c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.UpdateAgentStatus(ctx, &active, storage.AgentStatusActive); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionAgentCreate, storage.AuditTargetAgent, before.AgentID, before.AgentID, agentAuditState(before), agentAuditState(&active))
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = c.reviseAgent(ctx, &active, fields, storage.AuditActionAgentUpdate)
+	return err
+}
+
+// withAgentFields는 이미 인코딩된 파라미터와 도구를 그대로 지정합니다.
+func withAgentFields(fields agentFields) AgentOption {
+	return func(f *agentSpec) error {
+		f.Parameters = fields.Parameters
+		f.Tools = fields.Tools
+		return nil
+	}
+}
+
+func agentManifest(agent *storage.Agent) (manifest.Agent, error) {
+	parameters, tools, err := decodeAgentSettings(agent.Parameters, agent.Tools)
+	if err != nil {
+		return manifest.Agent{}, fmt.Errorf("agent %s: %w", agent.AgentID, err)
+	}
+	m := manifest.NewAgent(agent.AgentID)
+	m.Description = agent.Description
+	m.Model = agent.Model
+	m.Prompt = agent.Prompt
+	m.Parameters = parameters
+	m.Tools = tools
+	return m, nil
+}
@@ -0,0 +1,282 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// RegenerateMessage는 작업의 마지막 assistant 메시지를 대체된 버전으로 남기고 작업을 다시 실행합니다.
+// 새 응답은 같은 대화 인덱스의 다음 revision으로 기록됩니다. 다시 생성할 메시지의 인덱스를 반환합니다.
+func (c *Controller) RegenerateMessage(ctx context.Context, taskID string) (int, error) {
+	ctx, span := tracing.Start(ctx, "controller.RegenerateMessage", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return 0, fmt.Errorf("controller: repository is not configured")
+	}
+
+	task, messages, err := c.editableTask(ctx, taskID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role != storage.MessageRoleAssistant {
+		return 0, fmt.Errorf("no assistant message to regenerate for task: %s", taskID)
+	}
+	last := messages[len(messages)-1]
+	if err := checkRerunnable(task); err != nil {
+		return 0, err
+	}
+
+	// 대화를 되돌리고 작업을 pending으로 바꾸는 것과 감사 로그는 한 트랜잭션에서 함께 반영됩니다.
+	before := *task
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if _, err := repo.RewindMessages(ctx, task, last.ConversationIndex); err != nil {
+			return err
+		}
+		if err := c.recordReopen(ctx, repo, &before, task); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionMessageRegenerate, storage.AuditTargetTask, taskID, task.AgentID, messageAuditState(&last), nil)
+	})
+	if err != nil {
+		logger.Error("Failed to rewind messages", zap.Error(err))
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+
+	logger.Info("Regenerating message",
+		zap.String("task_id", taskID),
+		zap.Int("conversation_index", last.ConversationIndex),
+		zap.Int("revision", last.Revision),
+	)
+	if err := c.SendMessage(ctx, taskID); err != nil {
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+	return last.ConversationIndex, nil
+}
+
+// EditMessage는 index의 user 메시지를 content로 바꾸고 그 지점부터 작업을 다시 실행합니다.
+// 이전 질문과 그 뒤의 대화는 대체된 버전으로 남습니다.
+func (c *Controller) EditMessage(ctx context.Context, taskID string, index int, content string) error {
+	ctx, span := tracing.Start(ctx, "controller.EditMessage",
+		attribute.String("cnap.task_id", taskID),
+		attribute.Int("cnap.conversation_index", index),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+	if c.messages == nil {
+		return fmt.Errorf("controller: message store is not configured")
+	}
+	if content == "" {
+		return fmt.Errorf("message content is required")
+	}
+
+	task, messages, err := c.editableTask(ctx, taskID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	var previous *storage.MessageIndex
+	for i := range messages {
+		if messages[i].ConversationIndex == index {
+			previous = &messages[i]
+			break
+		}
+	}
+	if previous == nil {
+		return fmt.Errorf("message not found: %s #%d", taskID, index)
+	}
+	if previous.Role != storage.MessageRoleUser {
+		return fmt.Errorf("only user messages can be edited: %s #%d is %s", taskID, index, previous.Role)
+	}
+	if err := checkRerunnable(task); err != nil {
+		return err
+	}
+
+	filePath, err := c.saveMessage(ctx, taskID, storage.MessageRoleUser, content)
+	if err != nil {
+		logger.Error("Failed to store message content", zap.Error(err))
+		tracing.RecordError(span, err)
+		return err
+	}
+	// 메시지를 바꾸고 작업을 pending으로 바꾸는 것과 감사 로그는 한 트랜잭션에서 함께 반영됩니다.
+	before := *task
+	var revised *storage.MessageIndex
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		revised, err = repo.ReviseMessage(ctx, task, index, storage.MessageRoleUser, filePath)
+		if err != nil {
+			return err
+		}
+		if err := c.recordReopen(ctx, repo, &before, task); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionMessageEdit, storage.AuditTargetTask, taskID, task.AgentID, messageAuditState(previous), messageAuditState(revised))
+	})
+	if err != nil {
+		logger.Error("Failed to revise message", zap.Error(err))
+		if delErr := c.messages.Delete(ctx, filePath); delErr != nil {
+			logger.Warn("Failed to remove orphaned message content", zap.Error(delErr), zap.String("key", filePath))
+		}
+		tracing.RecordError(span, err)
+		return err
+	}
+	// 검색 인덱스는 'cnap task reindex'로 다시 만들 수 있으므로 실패해도 수정은 성공으로 처리합니다.
+	if err := c.repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+		TaskID:  taskID,
+		AgentID: task.AgentID,
+		Source:  storage.SearchSourceMessage,
+		Ref:     filePath,
+		Body:    content,
+	}); err != nil {
+		logger.Warn("Failed to index message for search", zap.Error(err), zap.String("key", filePath))
+	}
+
+	logger.Info("Message edited",
+		zap.String("task_id", taskID),
+		zap.Int("conversation_index", index),
+		zap.Int("revision", revised.Revision),
+	)
+	if err := c.SendMessage(ctx, taskID); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteMessage는 index의 메시지를 대체된 버전과 본문까지 모두 영구 삭제합니다.
+// 다른 메시지의 인덱스는 바뀌지 않으며 작업을 다시 실행하지 않습니다.
+func (c *Controller) DeleteMessage(ctx context.Context, taskID string, index int) error {
+	ctx, span := tracing.Start(ctx, "controller.DeleteMessage",
+		attribute.String("cnap.task_id", taskID),
+		attribute.Int("cnap.conversation_index", index),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+
+	task, _, err := c.editableTask(ctx, taskID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	var deleted []storage.MessageIndex
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		deleted, err = repo.DeleteMessage(ctx, taskID, index)
+		if err != nil {
+			return err
+		}
+		before := messageAuditState(&deleted[len(deleted)-1])
+		before["revisions"] = strconv.Itoa(len(deleted))
+		return c.recordAudit(ctx, repo, storage.AuditActionMessageDelete, storage.AuditTargetTask, taskID, task.AgentID, before, nil)
+	})
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("message not found: %s #%d", taskID, index)
+		}
+		logger.Error("Failed to delete message", zap.Error(err))
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	// 인덱스에서 지운 본문은 더 이상 참조되지 않으므로, 삭제에 실패해도 경고만 남깁니다.
+	if c.messages != nil {
+		for _, msg := range deleted {
+			if err := c.messages.Delete(ctx, msg.FilePath); err != nil {
+				logger.Warn("Failed to remove deleted message content", zap.Error(err), zap.String("key", msg.FilePath))
+			}
+		}
+	}
+	logger.Info("Message deleted",
+		zap.String("task_id", taskID),
+		zap.Int("conversation_index", index),
+		zap.Int("revisions", len(deleted)),
+	)
+	return nil
+}
+
+// ListMessageVersions는 대체된 버전을 포함한 작업의 모든 메시지를 대화 인덱스, revision 순으로 본문과 함께 반환합니다.
+func (c *Controller) ListMessageVersions(ctx context.Context, taskID string) ([]*MessageInfo, error) {
+	ctx, span := tracing.Start(ctx, "controller.ListMessageVersions", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+	if c.messages == nil {
+		return nil, fmt.Errorf("controller: message store is not configured")
+	}
+
+	messages, err := c.repo.ListMessageVersions(ctx, taskID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	result, err := c.loadMessageContents(ctx, taskID, messages)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// editableTask는 대화를 바꿀 작업과 현재 메시지를 조회합니다. 실행 중인 작업의 대화는 바꿀 수 없습니다.
+func (c *Controller) editableTask(ctx context.Context, taskID string) (*storage.Task, []storage.MessageIndex, error) {
+	task, err := c.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, fmt.Errorf("task not found: %s", taskID)
+		}
+		return nil, nil, err
+	}
+	if task.Status == storage.TaskStatusRunning {
+		return nil, nil, fmt.Errorf("task is running: %s", taskID)
+	}
+	messages, err := c.repo.ListMessageIndexByTask(ctx, taskID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	return task, messages, nil
+}
+
+// checkRerunnable은 대화를 바꾼 뒤 다시 실행할 수 있는 작업인지 확인합니다. 취소된 작업은 다시 실행하지 않습니다.
+func checkRerunnable(task *storage.Task) error {
+	if task.Status == storage.TaskStatusCanceled {
+		return fmt.Errorf("task is canceled: %s", task.TaskID)
+	}
+	return nil
+}
+
+// recordReopen은 대화를 바꾸면서 종료된 작업이 pending으로 돌아갔을 때 상태 변경 감사 로그를 남깁니다.
+func (c *Controller) recordReopen(ctx context.Context, repo storage.Store, before, after *storage.Task) error {
+	if before.Status == after.Status {
+		return nil
+	}
+	return c.recordAudit(ctx, repo, storage.AuditActionTaskStatus, storage.AuditTargetTask, after.TaskID, after.AgentID, taskAuditState(before), taskAuditState(after))
+}
+
+// messageAuditState는 감사 로그에 남길 메시지 참조 필드를 반환합니다.
+func messageAuditState(msg *storage.MessageIndex) map[string]string {
+	return map[string]string{
+		"role":               msg.Role,
+		"conversation_index": strconv.Itoa(msg.ConversationIndex),
+		"revision":           strconv.Itoa(msg.Revision),
+		"file_path":          msg.FilePath,
+	}
+}
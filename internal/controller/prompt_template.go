@@ -0,0 +1,315 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// MaxSystemPromptLength는 렌더링한 시스템 프롬프트의 최대 바이트 수입니다.
+const MaxSystemPromptLength = 64 * 1024
+
+var (
+	// ErrMissingPromptVariables는 프롬프트 템플릿의 필수 변수가 지정되지 않았을 때 반환됩니다.
+	ErrMissingPromptVariables = errors.New("missing prompt variables")
+	// ErrUnknownPromptVariables는 프롬프트 템플릿이 사용하지 않는 변수가 지정되었을 때 반환됩니다.
+	ErrUnknownPromptVariables = errors.New("unknown prompt variables")
+)
+
+var promptVariableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// promptFuncs는 프롬프트 템플릿에서 쓸 수 있는 함수입니다.
+// 파이프라인에서 쓰기 쉽도록 대상 문자열을 마지막 인자로 받습니다.
+var promptFuncs = template.FuncMap{
+	"upper":     strings.ToUpper,
+	"lower":     strings.ToLower,
+	"trim":      strings.TrimSpace,
+	"quote":     func(s string) string { return fmt.Sprintf("%q", s) },
+	"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	"contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+	"hasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"default": func(def, value string) string {
+		if value == "" {
+			return def
+		}
+		return value
+	},
+}
+
+// promptBuiltins는 text/template 내장 함수 중 허용하는 것입니다.
+// printf(폭 지정으로 큰 출력 생성)와 call(임의 함수 호출)은 허용하지 않습니다.
+var promptBuiltins = map[string]bool{
+	"and": true, "or": true, "not": true,
+	"eq": true, "ne": true, "lt": true, "le": true, "gt": true, "ge": true,
+	"len": true, "index": true, "slice": true, "print": true,
+}
+
+// PromptTemplate은 검증을 마친 에이전트 프롬프트 템플릿입니다.
+// 에이전트 프롬프트는 {{.Language}}처럼 변수를 참조하는 Go 템플릿이며, 작업을 만들 때 변수로 렌더링됩니다.
+type PromptTemplate struct {
+	tmpl     *template.Template
+	required []string
+	optional []string
+}
+
+// ParsePromptTemplate은 text를 프롬프트 템플릿으로 파싱하고, 허용하지 않는 함수나 구문을 거부합니다.
+// default 인자나 if/with 조건에서 참조한 변수는 선택 변수이며, 나머지는 필수 변수입니다.
+func ParsePromptTemplate(text string) (*PromptTemplate, error) {
+	tmpl, err := template.New("prompt").Funcs(promptFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	scan := promptScan{referenced: map[string]bool{}, guarded: map[string]bool{}}
+	for _, t := range tmpl.Templates() {
+		if t.Tree == nil {
+			continue
+		}
+		if t.Name() != tmpl.Name() {
+			return nil, fmt.Errorf("template definitions are not allowed: %s", t.Name())
+		}
+		if err := scan.node(t.Root, false); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &PromptTemplate{tmpl: tmpl}
+	for _, name := range slices.Sorted(maps.Keys(scan.referenced)) {
+		if scan.guarded[name] {
+			p.optional = append(p.optional, name)
+		} else {
+			p.required = append(p.required, name)
+		}
+	}
+	return p, nil
+}
+
+// Required는 반드시 지정해야 하는 변수 이름을 정렬해 반환합니다.
+func (p *PromptTemplate) Required() []string {
+	return slices.Clone(p.required)
+}
+
+// Optional은 생략할 수 있는 변수 이름을 정렬해 반환합니다. 생략한 변수는 빈 문자열로 렌더링됩니다.
+func (p *PromptTemplate) Optional() []string {
+	return slices.Clone(p.optional)
+}
+
+// Render는 vars로 템플릿을 렌더링합니다. 필수 변수가 빠졌거나 템플릿이 쓰지 않는 변수가 있으면
+// 각각 ErrMissingPromptVariables, ErrUnknownPromptVariables를 감싼 에러를 반환합니다.
+func (p *PromptTemplate) Render(vars map[string]string) (string, error) {
+	var missing, unknown []string
+	for _, name := range p.required {
+		if _, ok := vars[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		if !slices.Contains(p.required, name) && !slices.Contains(p.optional, name) {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingPromptVariables, strings.Join(missing, ", "))
+	}
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPromptVariables, strings.Join(unknown, ", "))
+	}
+
+	data := make(map[string]string, len(p.required)+len(p.optional))
+	for _, name := range p.optional {
+		data[name] = ""
+	}
+	maps.Copy(data, vars)
+
+	out := &limitedBuffer{limit: MaxSystemPromptLength}
+	if err := p.tmpl.Execute(out, data); err != nil {
+		return "", fmt.Errorf("render prompt template: %w", err)
+	}
+	return out.String(), nil
+}
+
+// RenderPrompt는 에이전트 프롬프트 text를 vars로 렌더링합니다.
+func RenderPrompt(text string, vars map[string]string) (string, error) {
+	tmpl, err := ParsePromptTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	return tmpl.Render(vars)
+}
+
+// rerenderPrompt는 작업에 저장한 변수로 에이전트 프롬프트를 다시 렌더링합니다.
+// 에이전트가 바뀌어 더 이상 쓰지 않는 변수는 무시하며, 새 필수 변수가 없으면 에러를 반환합니다.
+func rerenderPrompt(text string, vars map[string]string) (string, error) {
+	tmpl, err := ParsePromptTemplate(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid prompt template: %w", err)
+	}
+	used := make(map[string]string, len(vars))
+	for name, value := range vars {
+		if slices.Contains(tmpl.required, name) || slices.Contains(tmpl.optional, name) {
+			used[name] = value
+		}
+	}
+	return tmpl.Render(used)
+}
+
+// ParsePromptVariables는 "Key=Value" 형식의 값 목록을 변수 맵으로 바꿉니다. 값에는 '='가 들어갈 수 있습니다.
+func ParsePromptVariables(values []string) (map[string]string, error) {
+	vars := make(map[string]string, len(values))
+	for _, value := range values {
+		name, v, ok := strings.Cut(value, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("invalid variable %q: expected Key=Value", value)
+		}
+		if !promptVariableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		if _, dup := vars[name]; dup {
+			return nil, fmt.Errorf("duplicate variable %q", name)
+		}
+		vars[name] = v
+	}
+	return vars, nil
+}
+
+// encodePromptVariables는 작업에 저장할 변수 JSON입니다. 변수가 없으면 빈 문자열입니다.
+func encodePromptVariables(vars map[string]string) (string, error) {
+	if len(vars) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(vars)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// decodePromptVariables는 작업에 저장된 변수 JSON을 읽습니다.
+func decodePromptVariables(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var vars map[string]string
+	if err := json.Unmarshal([]byte(data), &vars); err != nil {
+		return nil, fmt.Errorf("decode task variables: %w", err)
+	}
+	return vars, nil
+}
+
+// promptScan은 템플릿 트리에서 참조한 변수를 모으고 허용하지 않는 구문을 찾습니다.
+type promptScan struct {
+	referenced map[string]bool
+	guarded    map[string]bool
+}
+
+func (s *promptScan) node(n parse.Node, guarded bool) error {
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			if err := s.node(child, false); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return s.pipe(n.Pipe, false)
+	case *parse.IfNode:
+		return s.branch(&n.BranchNode)
+	case *parse.WithNode:
+		return s.branch(&n.BranchNode)
+	case *parse.RangeNode:
+		return fmt.Errorf("range is not allowed in prompt templates")
+	case *parse.TemplateNode:
+		return fmt.Errorf("template calls are not allowed in prompt templates")
+	case *parse.PipeNode:
+		return s.pipe(n, guarded)
+	case *parse.FieldNode:
+		if len(n.Ident) != 1 {
+			return fmt.Errorf("nested field %s is not allowed in prompt templates", n)
+		}
+		s.reference(n.Ident[0], guarded)
+	case *parse.VariableNode:
+		// $.Name은 최상위 변수를 참조합니다.
+		if len(n.Ident) > 2 || (len(n.Ident) == 2 && n.Ident[0] != "$") {
+			return fmt.Errorf("nested field %s is not allowed in prompt templates", n)
+		}
+		if len(n.Ident) == 2 {
+			s.reference(n.Ident[1], guarded)
+		}
+	case *parse.ChainNode:
+		return fmt.Errorf("field chain %s is not allowed in prompt templates", n)
+	case *parse.IdentifierNode:
+		if _, ok := promptFuncs[n.Ident]; !ok && !promptBuiltins[n.Ident] {
+			return fmt.Errorf("function %q is not allowed in prompt templates", n.Ident)
+		}
+	}
+	return nil
+}
+
+func (s *promptScan) branch(n *parse.BranchNode) error {
+	if err := s.pipe(n.Pipe, true); err != nil {
+		return err
+	}
+	if err := s.node(n.List, false); err != nil {
+		return err
+	}
+	return s.node(n.ElseList, false)
+}
+
+// pipe는 파이프라인을 검사합니다. default로 이어지는 명령과 default의 인자는 선택 변수로 봅니다.
+func (s *promptScan) pipe(p *parse.PipeNode, guarded bool) error {
+	if p == nil {
+		return nil
+	}
+	for i, cmd := range p.Cmds {
+		cmdGuarded := guarded || isDefaultCommand(cmd)
+		for _, next := range p.Cmds[i+1:] {
+			cmdGuarded = cmdGuarded || isDefaultCommand(next)
+		}
+		for _, arg := range cmd.Args {
+			if err := s.node(arg, cmdGuarded); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *promptScan) reference(name string, guarded bool) {
+	s.referenced[name] = true
+	if guarded {
+		s.guarded[name] = true
+	}
+}
+
+func isDefaultCommand(cmd *parse.CommandNode) bool {
+	if len(cmd.Args) == 0 {
+		return false
+	}
+	ident, ok := cmd.Args[0].(*parse.IdentifierNode)
+	return ok && ident.Ident == "default"
+}
+
+// limitedBuffer는 limit 바이트를 넘으면 쓰기를 거부하는 버퍼입니다.
+type limitedBuffer struct {
+	strings.Builder
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, fmt.Errorf("rendered prompt exceeds %d bytes", b.limit)
+	}
+	return b.Builder.Write(p)
+}
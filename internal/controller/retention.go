@@ -0,0 +1,197 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// retentionBatchSize는 보존 정책이 한 트랜잭션에서 지우는 최대 작업 수입니다.
+const retentionBatchSize = 500
+
+// RetentionReport는 보존 정책을 한 번 적용한 결과입니다.
+// DryRun이면 실제로 지우지 않고 지울 대상을 집계한 값입니다.
+type RetentionReport struct {
+	DryRun bool
+	storage.TaskUsage
+	// Bytes는 정리한(또는 정리할) 메시지 본문의 크기 합계입니다.
+	Bytes int64
+	// RemovedFiles는 메시지 저장소에서 실제로 지운 파일 수입니다. DryRun이면 0입니다.
+	RemovedFiles int
+}
+
+// WithRetention은 Start의 supervisor 루프가 interval마다 보존 정책 rules를 적용하도록 설정합니다.
+// interval이 0 이하이거나 rules가 비어 있으면 백그라운드 정리를 하지 않습니다.
+func WithRetention(interval time.Duration, rules []storage.RetentionRule) Option {
+	return func(c *Controller) {
+		c.retentionInterval = interval
+		c.retentionRules = rules
+	}
+}
+
+// ApplyRetention은 보존 정책 rules에 해당하는 작업과 그에 딸린 메시지, 실행 단계, 체크포인트,
+// 검색 문서, 메시지 본문을 영구 삭제합니다. dryRun이면 아무것도 지우지 않고 회수할 행 수와 바이트만 집계합니다.
+// 에이전트별 규칙이 있는 에이전트에는 전역 규칙을 적용하지 않고, 여러 규칙에 해당하는 작업은 한 번만 정리합니다.
+func (c *Controller) ApplyRetention(ctx context.Context, rules []storage.RetentionRule, dryRun bool) (*RetentionReport, error) {
+	ctx, span := tracing.Start(ctx, "controller.ApplyRetention", attribute.Bool("cnap.dry_run", dryRun))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Applying retention rules",
+		zap.Int("rules", len(rules)),
+		zap.Bool("dry_run", dryRun),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	taskIDs, err := c.expiredTasks(ctx, rules)
+	if err != nil {
+		logger.Error("Failed to find expired tasks", zap.Error(err))
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	report := &RetentionReport{DryRun: dryRun}
+	if len(taskIDs) == 0 {
+		return report, nil
+	}
+
+	if dryRun {
+		usage, err := c.repo.TaskUsage(ctx, taskIDs)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return nil, err
+		}
+		report.TaskUsage = *usage
+		report.Bytes = c.messageBytes(ctx, usage.Files)
+		return report, nil
+	}
+
+	// 배치마다 작업 삭제와 감사 로그를 한 트랜잭션에 기록합니다.
+	// 일부 배치만 삭제되고 실패해도 이미 삭제한 작업의 메시지 본문은 정리합니다.
+	usage := &storage.TaskUsage{}
+	for batch := range slices.Chunk(taskIDs, retentionBatchSize) {
+		var part *storage.TaskUsage
+		err = c.repo.InTx(ctx, func(repo storage.Store) error {
+			var deleted []storage.Task
+			part, deleted, err = repo.DeleteTasks(ctx, batch)
+			if err != nil {
+				return err
+			}
+			for i := range deleted {
+				task := &deleted[i]
+				if err := c.recordAudit(ctx, repo, storage.AuditActionTaskPurge, storage.AuditTargetTask, task.TaskID, task.AgentID, taskAuditState(task), nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+		usage.Add(part)
+	}
+	report.TaskUsage = *usage
+	report.Bytes = c.messageBytes(ctx, usage.Files)
+	report.RemovedFiles = c.removeMessageFiles(ctx, usage.Files)
+	if err != nil {
+		logger.Error("Failed to delete expired tasks", zap.Error(err))
+		tracing.RecordError(span, err)
+		return report, err
+	}
+
+	logger.Info("Retention rules applied",
+		zap.Int64("tasks", report.Tasks),
+		zap.Int64("rows", report.Rows()),
+		zap.Int64("bytes", report.Bytes),
+		zap.Int("removed_files", report.RemovedFiles),
+	)
+	return report, nil
+}
+
+// expiredTasks는 규칙별로 정리할 작업 ID를 모아 중복 없이 반환합니다.
+func (c *Controller) expiredTasks(ctx context.Context, rules []storage.RetentionRule) ([]string, error) {
+	var scoped []string
+	for _, rule := range rules {
+		if rule.AgentID != "" {
+			scoped = append(scoped, rule.AgentID)
+		}
+	}
+
+	now := time.Now()
+	seen := make(map[string]struct{})
+	var ids []string
+	for _, rule := range rules {
+		var exclude []string
+		if rule.AgentID == "" {
+			exclude = scoped
+		}
+		expired, err := c.repo.ExpiredTasks(ctx, rule, exclude, now)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range expired {
+			if _, ok := seen[id]; ok {
+				continue
+			}
+			seen[id] = struct{}{}
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// messageBytes는 메시지 본문 크기의 합계를 구합니다. 이미 없는 파일은 0으로 셉니다.
+func (c *Controller) messageBytes(ctx context.Context, files []string) int64 {
+	if c.messages == nil {
+		return 0
+	}
+	var total int64
+	for _, key := range files {
+		size, err := c.messages.Size(ctx, key)
+		if err != nil {
+			if !errors.Is(err, msgstore.ErrNotFound) {
+				tracing.Logger(ctx, c.logger).Warn("Failed to stat message content", zap.Error(err), zap.String("key", key))
+			}
+			continue
+		}
+		total += size
+	}
+	return total
+}
+
+// removeMessageFiles는 메시지 본문을 지우고 지운 파일 수를 반환합니다.
+// 데이터베이스에서 지운 뒤에는 되돌릴 수 없으므로 삭제 실패는 경고만 남깁니다.
+func (c *Controller) removeMessageFiles(ctx context.Context, files []string) int {
+	if c.messages == nil {
+		return 0
+	}
+	removed := 0
+	for _, key := range files {
+		if err := c.messages.Delete(ctx, key); err != nil && !errors.Is(err, msgstore.ErrNotFound) {
+			tracing.Logger(ctx, c.logger).Warn("Failed to remove message content", zap.Error(err), zap.String("key", key))
+			continue
+		}
+		removed++
+	}
+	return removed
+}
+
+// runRetention은 supervisor 루프에서 보존 정책을 적용합니다. 실패해도 루프는 계속됩니다.
+func (c *Controller) runRetention(ctx context.Context) {
+	if _, err := c.ApplyRetention(ctx, c.retentionRules, false); err != nil && ctx.Err() == nil {
+		c.logger.Error("Retention job failed", zap.Error(err))
+	}
+}
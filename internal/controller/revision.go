@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// DiffOp는 diff 줄의 종류입니다.
+type DiffOp string
+
+const (
+	DiffEqual  DiffOp = " "
+	DiffDelete DiffOp = "-"
+	DiffInsert DiffOp = "+"
+)
+
+// DiffLine은 줄 단위 diff의 한 줄입니다.
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// FieldDiff는 값이 달라진 필드의 줄 단위 diff입니다.
+type FieldDiff struct {
+	Field string
+	Lines []DiffLine
+}
+
+// AgentRevisionDiff는 두 에이전트 리비전의 차이를 나타냅니다.
+// 바뀐 필드가 없으면 Fields가 비어 있습니다.
+type AgentRevisionDiff struct {
+	AgentID string
+	From    *storage.AgentRevision
+	To      *storage.AgentRevision
+	Fields  []FieldDiff
+}
+
+// ListAgentRevisions는 에이전트의 리비전 이력을 오래된 순으로 반환합니다.
+func (c *Controller) ListAgentRevisions(ctx context.Context, agentID string) ([]storage.AgentRevision, error) {
+	ctx, span := tracing.Start(ctx, "controller.ListAgentRevisions", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Listing agent revisions", zap.String("agent_id", agentID))
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	if _, err := c.repo.GetAgent(ctx, agentID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("agent not found: %s", agentID)
+		}
+		return nil, err
+	}
+
+	revisions, err := c.repo.ListAgentRevisions(ctx, agentID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	logger.Info("Listed agent revisions",
+		zap.String("agent_id", agentID),
+		zap.Int("count", len(revisions)),
+	)
+	return revisions, nil
+}
+
+// DiffAgentRevisions는 에이전트의 from 리비전과 to 리비전을 비교합니다.
+func (c *Controller) DiffAgentRevisions(ctx context.Context, agentID string, from, to int) (*AgentRevisionDiff, error) {
+	ctx, span := tracing.Start(ctx, "controller.DiffAgentRevisions", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Diffing agent revisions",
+		zap.String("agent_id", agentID),
+		zap.Int("from", from),
+		zap.Int("to", to),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	fromRev, err := c.getAgentRevision(ctx, agentID, from)
+	if err != nil {
+		return nil, err
+	}
+	toRev, err := c.getAgentRevision(ctx, agentID, to)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &AgentRevisionDiff{
+		AgentID: agentID,
+		From:    fromRev,
+		To:      toRev,
+		Fields:  diffAgentFields(agentFieldsOfRevision(fromRev), agentFieldsOfRevision(toRev)),
+	}
+	return diff, nil
+}
+
+// RollbackAgent는 에이전트 설정을 지정한 리비전의 내용으로 되돌립니다.
+// 이력은 지워지지 않으며, 되돌린 내용이 새 리비전으로 추가됩니다. 새 리비전 번호를 반환합니다.
+func (c *Controller) RollbackAgent(ctx context.Context, agentID string, revision int) (int, error) {
+	ctx, span := tracing.Start(ctx, "controller.RollbackAgent", attribute.String("cnap.agent_id", agentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Rolling back agent",
+		zap.String("agent_id", agentID),
+		zap.Int("revision", revision),
+	)
+
+	if c.repo == nil {
+		return 0, fmt.Errorf("controller: repository is not configured")
+	}
+
+	before, err := c.repo.GetAgent(ctx, agentID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return 0, fmt.Errorf("agent not found: %s", agentID)
+		}
+		return 0, err
+	}
+	target, err := c.getAgentRevision(ctx, agentID, revision)
+	if err != nil {
+		return 0, err
+	}
+
+	newRevision, err := c.reviseAgent(ctx, before, agentFieldsOfRevision(target), storage.AuditActionAgentRollback)
+	if err != nil {
+		logger.Error("Failed to roll back agent", zap.Error(err))
+		tracing.RecordError(span, err)
+		return 0, err
+	}
+
+	logger.Info("Agent rolled back successfully",
+		zap.String("agent_id", agentID),
+		zap.Int("target_revision", revision),
+		zap.Int("revision", newRevision),
+	)
+	return newRevision, nil
+}
+
+// reviseAgent는 에이전트 설정을 fields로 바꾸고 새 리비전과 감사 로그를 기록합니다.
+// 값이 바뀌지 않았으면 리비전을 만들지 않고 현재 리비전을 반환합니다.
+// before를 읽은 뒤 다른 수정이 먼저 반영되었으면 storage.ErrConflict를 반환합니다.
+func (c *Controller) reviseAgent(ctx context.Context, before *storage.Agent, fields agentFields, action string) (int, error) {
+	if agentFieldsOf(before) == fields {
+		return before.Revision, nil
+	}
+
+	agent := &storage.Agent{
+		AgentID:     before.AgentID,
+		Description: fields.Description,
+		Model:       fields.Model,
+		Prompt:      fields.Prompt,
+		Parameters:  fields.Parameters,
+		Tools:       fields.Tools,
+		Version:     before.Version,
+	}
+	err := c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.UpdateAgent(ctx, agent, ActorFromContext(ctx)); err != nil {
+			return err
+		}
+		after := *before
+		after.Description = fields.Description
+		after.Model = fields.Model
+		after.Prompt = fields.Prompt
+		after.Parameters = fields.Parameters
+		after.Tools = fields.Tools
+		after.Revision = agent.Revision
+		after.Version = agent.Version
+		return c.recordAudit(ctx, repo, action, storage.AuditTargetAgent, before.AgentID, before.AgentID, agentAuditState(before), agentAuditState(&after))
+	})
+	if err != nil {
+		return 0, err
+	}
+	return agent.Revision, nil
+}
+
+func (c *Controller) getAgentRevision(ctx context.Context, agentID string, revision int) (*storage.AgentRevision, error) {
+	rev, err := c.repo.GetAgentRevision(ctx, agentID, revision)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("agent revision not found: %s r%d", agentID, revision)
+		}
+		return nil, err
+	}
+	return rev, nil
+}
+
+// diffLines는 최장 공통 부분열(LCS)로 두 문자열의 줄 단위 diff를 계산합니다.
+func diffLines(a, b string) []DiffLine {
+	x := splitLines(a)
+	y := splitLines(b)
+
+	// lcs[i][j]는 x[i:]와 y[j:]의 최장 공통 부분열 길이입니다.
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []DiffLine
+	i, j := 0, 0
+	for i < len(x) && j < len(y) {
+		switch {
+		case x[i] == y[j]:
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: x[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: x[i]})
+			i++
+		default:
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: y[j]})
+			j++
+		}
+	}
+	for ; i < len(x); i++ {
+		lines = append(lines, DiffLine{Op: DiffDelete, Text: x[i]})
+	}
+	for ; j < len(y); j++ {
+		lines = append(lines, DiffLine{Op: DiffInsert, Text: y[j]})
+	}
+	return lines
+}
+
+// splitLines는 문자열을 줄로 나눕니다. 빈 문자열은 줄이 없는 것으로 취급합니다.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
@@ -0,0 +1,183 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cnap-oss/app/internal/msgstore"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// snippetRadius는 검색 결과 요약에서 일치한 단어 앞뒤로 보여줄 글자 수입니다.
+const snippetRadius = 40
+
+// SearchResult는 작업 검색 결과 하나입니다.
+type SearchResult struct {
+	TaskID  string
+	AgentID string
+	// Source는 일치한 곳입니다 (storage.SearchSourcePrompt 또는 storage.SearchSourceMessage).
+	Source string
+	// Ref는 메시지 파일 경로입니다. 프롬프트에서 일치하면 비어 있습니다.
+	Ref       string
+	Snippet   string
+	CreatedAt time.Time
+}
+
+// SearchTasks는 모든 에이전트의 작업 프롬프트와 메시지 본문에서 검색어를 찾습니다.
+func (c *Controller) SearchTasks(ctx context.Context, filter storage.SearchFilter) ([]SearchResult, error) {
+	ctx, span := tracing.Start(ctx, "controller.SearchTasks", attribute.String("cnap.agent_id", filter.AgentID))
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Searching tasks",
+		zap.String("query", filter.Query),
+		zap.String("agent_id", filter.AgentID),
+	)
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	docs, err := c.repo.SearchTasks(ctx, filter)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+
+	terms := storage.SearchTerms(filter.Query)
+	results := make([]SearchResult, 0, len(docs))
+	for _, doc := range docs {
+		results = append(results, SearchResult{
+			TaskID:    doc.TaskID,
+			AgentID:   doc.AgentID,
+			Source:    doc.Source,
+			Ref:       doc.Ref,
+			Snippet:   searchSnippet(doc.Body, terms),
+			CreatedAt: doc.CreatedAt,
+		})
+	}
+
+	logger.Info("Searched tasks", zap.Int("count", len(results)))
+	return results, nil
+}
+
+// RebuildSearchIndex는 모든 작업의 프롬프트와 메시지 본문을 검색 인덱스에 다시 등록합니다.
+// 검색 인덱스를 도입하기 전에 저장된 메시지를 색인하거나 인덱스가 어긋났을 때 사용합니다.
+// 색인한 문서 수를 반환합니다.
+func (c *Controller) RebuildSearchIndex(ctx context.Context) (int, error) {
+	ctx, span := tracing.Start(ctx, "controller.RebuildSearchIndex")
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	logger.Info("Rebuilding search index")
+
+	if c.repo == nil {
+		return 0, fmt.Errorf("controller: repository is not configured")
+	}
+	if c.messages == nil {
+		return 0, fmt.Errorf("controller: message store is not configured")
+	}
+
+	indexed := 0
+	filter := storage.TaskFilter{Limit: 500}
+	for {
+		tasks, next, err := c.repo.ListTasksPage(ctx, filter)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return indexed, err
+		}
+		for i := range tasks {
+			n, err := c.indexTask(ctx, &tasks[i])
+			indexed += n
+			if err != nil {
+				tracing.RecordError(span, err)
+				return indexed, fmt.Errorf("task %s: %w", tasks[i].TaskID, err)
+			}
+		}
+		if next == "" {
+			break
+		}
+		filter.Cursor = next
+	}
+
+	logger.Info("Search index rebuilt", zap.Int("documents", indexed))
+	return indexed, nil
+}
+
+// indexTask는 작업 하나의 프롬프트와 메시지를 색인합니다. 저장소에 없는 메시지는 건너뜁니다.
+func (c *Controller) indexTask(ctx context.Context, task *storage.Task) (int, error) {
+	indexed := 0
+	if task.Prompt != "" {
+		if err := c.repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+			TaskID:    task.TaskID,
+			AgentID:   task.AgentID,
+			Source:    storage.SearchSourcePrompt,
+			Body:      task.Prompt,
+			CreatedAt: task.CreatedAt,
+		}); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+
+	messages, err := c.repo.ListMessageIndexByTask(ctx, task.TaskID)
+	if err != nil {
+		return indexed, err
+	}
+	for _, m := range messages {
+		body, err := c.messages.Get(ctx, m.FilePath)
+		if errors.Is(err, msgstore.ErrNotFound) {
+			c.logger.Warn("Message content missing from store; skipping",
+				zap.String("task_id", task.TaskID),
+				zap.String("key", m.FilePath),
+			)
+			continue
+		}
+		if err != nil {
+			return indexed, err
+		}
+		if err := c.repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+			TaskID:    task.TaskID,
+			AgentID:   task.AgentID,
+			Source:    storage.SearchSourceMessage,
+			Ref:       m.FilePath,
+			Body:      body.Content,
+			CreatedAt: m.CreatedAt,
+		}); err != nil {
+			return indexed, err
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+// searchSnippet은 body에서 처음 일치한 검색어 주변을 한 줄로 잘라 반환합니다.
+func searchSnippet(body string, terms []string) string {
+	text := []rune(strings.Join(strings.Fields(body), " "))
+	lower := []rune(strings.ToLower(string(text)))
+
+	start := 0
+	for _, term := range terms {
+		if i := strings.Index(string(lower), term); i >= 0 {
+			start = len([]rune(string(lower)[:i]))
+			break
+		}
+	}
+
+	from := max(start-snippetRadius, 0)
+	to := min(start+2*snippetRadius, len(text))
+	snippet := string(text[from:to])
+	if from > 0 {
+		snippet = "..." + snippet
+	}
+	if to < len(text) {
+		snippet += "..."
+	}
+	return snippet
+}
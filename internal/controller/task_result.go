@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	taskrunner "github.com/cnap-oss/app/internal/runner"
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// Controller는 실행기의 상태 변경과 결과를 작업에 기록합니다.
+var _ taskrunner.StatusCallback = (*Controller)(nil)
+
+// TaskNotifier는 작업의 실행 결과가 기록된 뒤 결과를 전달받습니다.
+type TaskNotifier interface {
+	// NotifyTaskResult는 종료 상태와 결과가 기록된 작업 정보를 받습니다.
+	NotifyTaskResult(ctx context.Context, info *TaskInfo) error
+}
+
+// SetTaskNotifier는 실행 결과를 전달할 notifier를 설정합니다.
+// connector처럼 Controller를 받아 생성되는 쪽을 연결하기 위한 것이며, Start 전에 호출해야 합니다.
+func (c *Controller) SetTaskNotifier(notifier TaskNotifier) {
+	c.notifier = notifier
+}
+
+// dispatchRun은 작업의 대화로 실행 요청을 만들어 백그라운드에서 TaskRunner로 실행합니다.
+// 결과는 taskrunner.Execute가 StatusCallback(OnComplete/OnError)으로 기록합니다.
+// 요청을 만들지 못하면 작업을 실패로 기록하고 에러를 반환합니다.
+func (c *Controller) dispatchRun(ctx context.Context, task *storage.Task, model string) error {
+	logger := tracing.Logger(ctx, c.logger)
+
+	req, err := c.runRequest(ctx, task, model)
+	if err != nil {
+		if cbErr := c.OnError(task.TaskID, err); cbErr != nil {
+			logger.Error("Failed to record task failure", zap.Error(cbErr), zap.String("task_id", task.TaskID))
+		}
+		return err
+	}
+
+	// 실행은 요청한 쪽의 취소와 관계없이 끝까지 진행합니다.
+	runCtx := context.WithoutCancel(ctx)
+	c.runs.Add(1)
+	go func() {
+		defer c.runs.Done()
+		if err := taskrunner.Execute(runCtx, c.runner, req, c); err != nil {
+			logger.Error("Failed to record task run", zap.Error(err), zap.String("task_id", req.TaskID))
+		}
+	}()
+	return nil
+}
+
+// runRequest는 작업 프롬프트와 현재 대화로 실행 요청을 만듭니다.
+func (c *Controller) runRequest(ctx context.Context, task *storage.Task, model string) (*taskrunner.RunRequest, error) {
+	req := &taskrunner.RunRequest{
+		TaskID:       task.TaskID,
+		Model:        model,
+		SystemPrompt: task.SystemPrompt,
+	}
+	if task.Prompt != "" {
+		req.Messages = append(req.Messages, taskrunner.ChatMessage{Role: storage.MessageRoleUser, Content: task.Prompt})
+	}
+
+	messages, err := c.repo.ListMessageIndexByTask(ctx, task.TaskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list messages: %w", err)
+	}
+	if len(messages) == 0 {
+		return req, nil
+	}
+	if c.messages == nil {
+		return nil, fmt.Errorf("controller: message store is not configured")
+	}
+	contents, err := c.loadMessageContents(ctx, task.TaskID, messages)
+	if err != nil {
+		return nil, err
+	}
+	for _, msg := range contents {
+		req.Messages = append(req.Messages, taskrunner.ChatMessage{Role: msg.Role, Content: msg.Content})
+	}
+	return req, nil
+}
+
+// OnStatusChange는 실행기가 알린 작업 상태를 기록합니다.
+// running이면 새 실행 시도로 보고 시작 시각과 시도 횟수를 기록합니다.
+func (c *Controller) OnStatusChange(taskID string, status string) error {
+	ctx := context.Background()
+	if status != storage.TaskStatusRunning {
+		return c.UpdateTaskStatus(ctx, taskID, status)
+	}
+
+	ctx, span := tracing.Start(ctx, "controller.OnStatusChange", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+	task, err := c.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("task not found: %s", taskID)
+		}
+		tracing.RecordError(span, err)
+		return err
+	}
+	if err := c.startTaskAttempt(ctx, task); err != nil {
+		tracing.RecordError(span, err)
+		return err
+	}
+	return nil
+}
+
+// OnComplete는 실행 결과를 작업에 기록합니다. 출력은 assistant 메시지로 대화에 추가되고
+// 그 저장 키가 작업의 결과 참조가 됩니다. result.Error가 있으면 OnError와 같이 처리합니다.
+func (c *Controller) OnComplete(taskID string, result *taskrunner.RunResult) error {
+	if result == nil {
+		return fmt.Errorf("controller: nil run result")
+	}
+	if result.Error != nil || !result.Success {
+		err := result.Error
+		if err == nil {
+			err = errors.New("run finished without success")
+		}
+		return c.finishTask(taskID, storage.TaskStatusFailed, "", result, err)
+	}
+	return c.finishTask(taskID, storage.TaskStatusCompleted, result.Output, result, nil)
+}
+
+// OnError는 실행 실패를 작업에 기록합니다. 에러 분류는 taskrunner.ErrorClass를 따릅니다.
+func (c *Controller) OnError(taskID string, err error) error {
+	if err == nil {
+		return fmt.Errorf("controller: nil run error")
+	}
+	return c.finishTask(taskID, storage.TaskStatusFailed, "", nil, err)
+}
+
+// startTaskAttempt는 작업을 running으로 바꾸고 실행 시작을 기록합니다.
+func (c *Controller) startTaskAttempt(ctx context.Context, task *storage.Task) error {
+	logger := tracing.Logger(ctx, c.logger)
+
+	running := *task
+	err := c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.StartTaskAttempt(ctx, &running); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionTaskStatus, storage.AuditTargetTask, task.TaskID, task.AgentID, taskAuditState(task), taskAuditState(&running))
+	})
+	if err != nil {
+		logger.Error("Failed to start task attempt", zap.Error(err))
+		return err
+	}
+
+	logger.Info("Task attempt started",
+		zap.String("task_id", task.TaskID),
+		zap.Int("attempt", running.Attempts),
+	)
+	*task = running
+	return nil
+}
+
+// finishTask는 작업의 종료 상태와 결과를 기록합니다.
+// 실행 중에 작업이 취소되었으면 상태는 canceled로 두고 결과만 기록합니다.
+func (c *Controller) finishTask(taskID, status, output string, result *taskrunner.RunResult, runErr error) error {
+	ctx, span := tracing.Start(context.Background(), "controller.finishTask",
+		attribute.String("cnap.task_id", taskID),
+		attribute.String("cnap.status", status),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+
+	task, err := c.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("task not found: %s", taskID)
+		}
+		tracing.RecordError(span, err)
+		return err
+	}
+	if task.Status == storage.TaskStatusCanceled {
+		status = storage.TaskStatusCanceled
+	}
+
+	var outcome storage.TaskResult
+	if result != nil {
+		outcome.ResultModel = result.Model
+	}
+	if runErr != nil {
+		outcome.ErrorMessage = runErr.Error()
+		outcome.ErrorClass = taskrunner.ErrorClass(runErr)
+	}
+	if output != "" {
+		if c.messages == nil {
+			return fmt.Errorf("controller: message store is not configured")
+		}
+		msg, err := c.appendMessage(ctx, task, "assistant", output)
+		if err != nil {
+			tracing.RecordError(span, err)
+			return fmt.Errorf("failed to store task output: %w", err)
+		}
+		outcome.ResultRef = msg.FilePath
+	}
+
+	finished := *task
+	err = c.repo.InTx(ctx, func(repo storage.Store) error {
+		if err := repo.FinishTask(ctx, &finished, status, outcome); err != nil {
+			return err
+		}
+		return c.recordAudit(ctx, repo, storage.AuditActionTaskStatus, storage.AuditTargetTask, taskID, task.AgentID, taskAuditState(task), taskAuditState(&finished))
+	})
+	if err != nil {
+		logger.Error("Failed to record task result", zap.Error(err))
+		tracing.RecordError(span, err)
+		return err
+	}
+
+	c.observeTaskDuration(ctx, task, status)
+
+	logger.Info("Task result recorded",
+		zap.String("task_id", taskID),
+		zap.String("status", status),
+		zap.String("result_model", outcome.ResultModel),
+		zap.String("error_class", outcome.ErrorClass),
+	)
+	c.notifyTaskResult(ctx, taskID)
+	return nil
+}
+
+// notifyTaskResult는 기록된 결과를 notifier에 전달합니다.
+// 결과는 이미 저장되었으므로 전달에 실패해도 로그만 남깁니다.
+func (c *Controller) notifyTaskResult(ctx context.Context, taskID string) {
+	if c.notifier == nil {
+		return
+	}
+	logger := tracing.Logger(ctx, c.logger)
+
+	info, err := c.GetTaskInfo(ctx, taskID)
+	if err != nil {
+		logger.Warn("Failed to load task result for notification", zap.String("task_id", taskID), zap.Error(err))
+		return
+	}
+	if err := c.notifier.NotifyTaskResult(ctx, info); err != nil {
+		logger.Warn("Failed to notify task result", zap.String("task_id", taskID), zap.Error(err))
+	}
+}
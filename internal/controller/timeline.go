@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// StepNode는 실행 단계 트리의 노드입니다. Children은 StepNo 순입니다.
+type StepNode struct {
+	storage.RunStep
+	Children []*StepNode
+}
+
+// TaskTimeline은 작업의 실행 단계를 상위 단계 기준으로 묶은 트리입니다.
+type TaskTimeline struct {
+	Task  *storage.Task
+	Steps []*StepNode
+}
+
+// Bounds는 가장 먼저 시작한 단계의 시작 시각과 가장 늦게 끝난 단계의 종료 시각을 반환합니다.
+// 시각이 기록된 단계가 없으면 ok가 false입니다.
+func (t *TaskTimeline) Bounds() (start, end time.Time, ok bool) {
+	var walk func(nodes []*StepNode)
+	walk = func(nodes []*StepNode) {
+		for _, n := range nodes {
+			if n.StartedAt != nil && (start.IsZero() || n.StartedAt.Before(start)) {
+				start = *n.StartedAt
+			}
+			if n.FinishedAt != nil && n.FinishedAt.After(end) {
+				end = *n.FinishedAt
+			}
+			walk(n.Children)
+		}
+	}
+	walk(t.Steps)
+	return start, end, !start.IsZero()
+}
+
+// RecordRunStep은 작업의 실행 단계를 기록합니다. 같은 StepNo로 다시 기록하면 단계 내용을 덮어씁니다.
+// 실행기는 단계를 시작할 때와 끝날 때 각각 호출하며, 상위 단계는 ParentStepNo로 지정합니다.
+func (c *Controller) RecordRunStep(ctx context.Context, step *storage.RunStep) error {
+	if step == nil {
+		return fmt.Errorf("controller: nil run step")
+	}
+	ctx, span := tracing.Start(ctx, "controller.RecordRunStep",
+		attribute.String("cnap.task_id", step.TaskID),
+		attribute.Int("cnap.step_no", step.StepNo),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, c.logger)
+
+	if c.repo == nil {
+		return fmt.Errorf("controller: repository is not configured")
+	}
+	if step.StepNo <= 0 {
+		return fmt.Errorf("invalid step number: %d", step.StepNo)
+	}
+	if step.ParentStepNo == step.StepNo {
+		return fmt.Errorf("step %d cannot be its own parent", step.StepNo)
+	}
+
+	if _, err := c.repo.GetTask(ctx, step.TaskID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return fmt.Errorf("task not found: %s", step.TaskID)
+		}
+		tracing.RecordError(span, err)
+		return err
+	}
+	if err := c.repo.UpsertRunStep(ctx, step); err != nil {
+		tracing.RecordError(span, err)
+		return fmt.Errorf("failed to record run step: %w", err)
+	}
+
+	logger.Debug("Recorded run step",
+		zap.String("task_id", step.TaskID),
+		zap.Int("step_no", step.StepNo),
+		zap.Int("parent_step_no", step.ParentStepNo),
+		zap.String("type", step.Type),
+		zap.String("status", step.Status),
+		zap.Int64("duration_ms", step.DurationMS),
+	)
+	return nil
+}
+
+// GetTaskTimeline은 작업의 실행 단계를 트리로 묶어 반환합니다.
+// 상위 단계가 기록되지 않았거나 순환을 이루는 단계는 최상위에 둡니다.
+func (c *Controller) GetTaskTimeline(ctx context.Context, taskID string) (*TaskTimeline, error) {
+	ctx, span := tracing.Start(ctx, "controller.GetTaskTimeline", attribute.String("cnap.task_id", taskID))
+	defer span.End()
+
+	if c.repo == nil {
+		return nil, fmt.Errorf("controller: repository is not configured")
+	}
+
+	task, err := c.repo.GetTask(ctx, taskID)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, fmt.Errorf("task not found: %s", taskID)
+		}
+		tracing.RecordError(span, err)
+		return nil, err
+	}
+	steps, err := c.repo.ListRunSteps(ctx, taskID)
+	if err != nil {
+		tracing.RecordError(span, err)
+		return nil, fmt.Errorf("failed to list run steps: %w", err)
+	}
+
+	return &TaskTimeline{Task: task, Steps: buildStepTree(steps)}, nil
+}
+
+// buildStepTree는 StepNo 순으로 정렬된 단계를 ParentStepNo에 따라 트리로 묶습니다.
+func buildStepTree(steps []storage.RunStep) []*StepNode {
+	nodes := make(map[int]*StepNode, len(steps))
+	parents := make(map[int]int, len(steps))
+	for _, step := range steps {
+		nodes[step.StepNo] = &StepNode{RunStep: step}
+		parents[step.StepNo] = step.ParentStepNo
+	}
+
+	// 상위 단계를 따라 올라가다 자기 자신을 만나면 순환입니다.
+	cyclic := func(stepNo int) bool {
+		for p, hops := parents[stepNo], 0; p != 0 && hops <= len(steps); p, hops = parents[p], hops+1 {
+			if p == stepNo {
+				return true
+			}
+			if _, ok := nodes[p]; !ok {
+				return false
+			}
+		}
+		return false
+	}
+
+	var roots []*StepNode
+	for _, step := range steps {
+		node := nodes[step.StepNo]
+		parent, ok := nodes[step.ParentStepNo]
+		if step.ParentStepNo == 0 || !ok || cyclic(step.StepNo) {
+			roots = append(roots, node)
+			continue
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	return roots
+}
@@ -0,0 +1,210 @@
+// Package health는 liveness/readiness 검사를 등록하고 실행하는 헬스 체크 서브시스템입니다.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Status는 검사 결과 상태입니다.
+type Status string
+
+// 검사 결과 상태 값입니다.
+const (
+	StatusOK   Status = "ok"
+	StatusFail Status = "fail"
+)
+
+// Kind는 검사가 어느 엔드포인트에 포함되는지 나타냅니다.
+type Kind int
+
+const (
+	// Liveness 검사는 /healthz와 /readyz 모두에 포함됩니다.
+	// 실패하면 프로세스를 재시작해야 하는 상태를 의미합니다.
+	Liveness Kind = iota
+	// Readiness 검사는 /readyz에만 포함됩니다.
+	// 실패하면 요청을 처리할 준비가 되지 않았음을 의미합니다.
+	Readiness
+)
+
+// DefaultTimeout은 검사 하나에 허용되는 기본 실행 시간입니다.
+const DefaultTimeout = 5 * time.Second
+
+// CheckFunc는 정상이면 nil, 아니면 원인을 담은 에러를 반환하는 검사 함수입니다.
+type CheckFunc func(ctx context.Context) error
+
+// CheckResult는 검사 하나의 실행 결과입니다.
+type CheckResult struct {
+	Name       string `json:"name"`
+	Status     Status `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report는 여러 검사의 종합 결과입니다.
+type Report struct {
+	Status Status        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+// OK는 모든 검사가 통과했는지 반환합니다.
+func (r *Report) OK() bool {
+	return r.Status == StatusOK
+}
+
+type check struct {
+	name string
+	kind Kind
+	fn   CheckFunc
+}
+
+// Checker는 등록된 검사를 실행합니다.
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []check
+	timeout time.Duration
+}
+
+// NewChecker는 검사마다 timeout을 적용하는 Checker를 생성합니다.
+// timeout이 0 이하이면 DefaultTimeout을 사용합니다.
+func NewChecker(timeout time.Duration) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{timeout: timeout}
+}
+
+// Register는 이름과 종류를 지정해 검사를 등록합니다.
+func (c *Checker) Register(name string, kind Kind, fn CheckFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks = append(c.checks, check{name: name, kind: kind, fn: fn})
+}
+
+// Live는 liveness 검사만 실행합니다.
+func (c *Checker) Live(ctx context.Context) *Report {
+	return c.run(ctx, Liveness)
+}
+
+// Ready는 liveness와 readiness 검사를 모두 실행합니다.
+func (c *Checker) Ready(ctx context.Context) *Report {
+	return c.run(ctx, Readiness)
+}
+
+// run은 kind 이하의 검사를 동시에 실행하고 이름 순으로 정렬된 결과를 반환합니다.
+func (c *Checker) run(ctx context.Context, kind Kind) *Report {
+	c.mu.RLock()
+	selected := make([]check, 0, len(c.checks))
+	for _, ch := range c.checks {
+		if ch.kind <= kind {
+			selected = append(selected, ch)
+		}
+	}
+	c.mu.RUnlock()
+
+	results := make([]CheckResult, len(selected))
+	var wg sync.WaitGroup
+	for idx, ch := range selected {
+		wg.Add(1)
+		go func(idx int, ch check) {
+			defer wg.Done()
+			results[idx] = c.runOne(ctx, ch)
+		}(idx, ch)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Name < results[j].Name })
+
+	report := &Report{Status: StatusOK, Checks: results}
+	for _, res := range results {
+		if res.Status != StatusOK {
+			report.Status = StatusFail
+			break
+		}
+	}
+	return report
+}
+
+func (c *Checker) runOne(ctx context.Context, ch check) (res CheckResult) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	res = CheckResult{Name: ch.name, Status: StatusOK}
+	defer func() {
+		if r := recover(); r != nil {
+			res.Status = StatusFail
+			res.Error = fmt.Sprintf("panic: %v", r)
+		}
+		res.DurationMS = time.Since(start).Milliseconds()
+	}()
+
+	if err := ch.fn(ctx); err != nil {
+		res.Status = StatusFail
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// LiveHandler는 /healthz 응답을 만드는 HTTP 핸들러를 반환합니다.
+func (c *Checker) LiveHandler() http.Handler {
+	return reportHandler(c.Live)
+}
+
+// ReadyHandler는 /readyz 응답을 만드는 HTTP 핸들러를 반환합니다.
+func (c *Checker) ReadyHandler() http.Handler {
+	return reportHandler(c.Ready)
+}
+
+// reportHandler는 검사 결과를 JSON으로 응답하며, 실패 시 503을 반환합니다.
+func reportHandler(run func(context.Context) *Report) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := run(r.Context())
+		w.Header().Set("Content-Type", "application/json")
+		if report.OK() {
+			w.WriteHeader(http.StatusOK)
+		} else {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(report)
+	})
+}
+
+// Fetch는 실행 중인 인스턴스의 헬스 엔드포인트를 조회합니다.
+// 503 응답도 본문의 Report를 반환하며, 응답을 해석할 수 없을 때만 에러를 반환합니다.
+func Fetch(ctx context.Context, url string) (*Report, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create health request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("query %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	var report Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return nil, fmt.Errorf("decode health response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	return &report, nil
+}
+
+// QueueBacklog는 count가 반환한 status 상태의 작업 수가 max를 넘으면 실패하는 검사를 만듭니다.
+func QueueBacklog(count func(ctx context.Context) (map[string]int64, error), status string, max int64) CheckFunc {
+	return func(ctx context.Context) error {
+		counts, err := count(ctx)
+		if err != nil {
+			return fmt.Errorf("count tasks: %w", err)
+		}
+		if n := counts[status]; n > max {
+			return fmt.Errorf("%d %s tasks exceed backlog limit %d", n, status, max)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,69 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cnap-oss/app/internal/health"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckerLiveAndReady(t *testing.T) {
+	checker := health.NewChecker(50 * time.Millisecond)
+	checker.Register("database", health.Liveness, func(context.Context) error { return nil })
+	checker.Register("discord", health.Readiness, func(context.Context) error { return errors.New("gateway not connected") })
+	checker.Register("provider", health.Readiness, func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	live := checker.Live(context.Background())
+	require.True(t, live.OK())
+	require.Len(t, live.Checks, 1)
+	require.Equal(t, "database", live.Checks[0].Name)
+
+	ready := checker.Ready(context.Background())
+	require.False(t, ready.OK())
+	require.Len(t, ready.Checks, 3)
+	require.Equal(t, []string{"database", "discord", "provider"},
+		[]string{ready.Checks[0].Name, ready.Checks[1].Name, ready.Checks[2].Name})
+	require.Equal(t, health.StatusFail, ready.Checks[1].Status)
+	require.Equal(t, "gateway not connected", ready.Checks[1].Error)
+	require.Equal(t, health.StatusFail, ready.Checks[2].Status)
+	require.Contains(t, ready.Checks[2].Error, "deadline exceeded")
+}
+
+func TestHandlersAndFetch(t *testing.T) {
+	checker := health.NewChecker(0)
+	checker.Register("database", health.Liveness, func(context.Context) error { return nil })
+	checker.Register("queue", health.Readiness, health.QueueBacklog(func(context.Context) (map[string]int64, error) {
+		return map[string]int64{"pending": 5}, nil
+	}, "pending", 3))
+
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", checker.LiveHandler())
+	mux.Handle("/readyz", checker.ReadyHandler())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	report, err := health.Fetch(context.Background(), srv.URL+"/readyz")
+	require.NoError(t, err)
+	require.False(t, report.OK())
+	require.Equal(t, "5 pending tasks exceed backlog limit 3", report.Checks[1].Error)
+
+	report, err = health.Fetch(context.Background(), srv.URL+"/healthz")
+	require.NoError(t, err)
+	require.True(t, report.OK())
+
+	_, err = health.Fetch(context.Background(), srv.URL+"/missing")
+	require.Error(t, err)
+}
@@ -0,0 +1,243 @@
+// Package manifest는 git으로 관리할 수 있는 선언적 에이전트 매니페스트를 읽고 씁니다.
+//
+// 매니페스트는 YAML(여러 문서를 "---"로 구분) 또는 JSON(객체 하나 또는 배열)으로 작성합니다.
+//
+//	apiVersion: cnap/v1
+//	kind: Agent
+//	name: support-bot
+//	description: 고객 지원 챗봇
+//	model: gpt-4
+//	prompt: |
+//	  당신은 친절한 고객 지원 담당자입니다.
+//	parameters:
+//	  temperature: 0.2
+//	tools: [search]
+package manifest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// APIVersion은 현재 매니페스트 형식의 버전입니다.
+	APIVersion = "cnap/v1"
+	// KindAgent는 에이전트 매니페스트의 kind입니다.
+	KindAgent = "Agent"
+)
+
+// 매니페스트 출력 형식입니다.
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// Agent는 에이전트 하나의 원하는 상태를 기술합니다.
+type Agent struct {
+	APIVersion  string         `yaml:"apiVersion" json:"apiVersion"`
+	Kind        string         `yaml:"kind" json:"kind"`
+	Name        string         `yaml:"name" json:"name"`
+	Description string         `yaml:"description,omitempty" json:"description,omitempty"`
+	Model       string         `yaml:"model,omitempty" json:"model,omitempty"`
+	Prompt      string         `yaml:"prompt,omitempty" json:"prompt,omitempty"`
+	Parameters  map[string]any `yaml:"parameters,omitempty" json:"parameters,omitempty"`
+	Tools       []string       `yaml:"tools,omitempty" json:"tools,omitempty"`
+}
+
+// NewAgent는 apiVersion과 kind가 채워진 매니페스트를 생성합니다.
+func NewAgent(name string) Agent {
+	return Agent{APIVersion: APIVersion, Kind: KindAgent, Name: name}
+}
+
+// Validate는 매니페스트의 필수 항목과 버전을 검사합니다.
+func (a *Agent) Validate() error {
+	if a.APIVersion != APIVersion {
+		return fmt.Errorf("unsupported apiVersion %q (expected %s)", a.APIVersion, APIVersion)
+	}
+	if a.Kind != KindAgent {
+		return fmt.Errorf("unsupported kind %q (expected %s)", a.Kind, KindAgent)
+	}
+	if a.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	for _, tool := range a.Tools {
+		if strings.TrimSpace(tool) == "" {
+			return fmt.Errorf("agent %s: empty tool name", a.Name)
+		}
+	}
+	return nil
+}
+
+// Decode는 format 형식의 매니페스트를 읽습니다. 알 수 없는 필드가 있으면 에러를 반환합니다.
+func Decode(r io.Reader, format string) ([]Agent, error) {
+	switch format {
+	case FormatYAML:
+		return decodeYAML(r)
+	case FormatJSON:
+		return decodeJSON(r)
+	default:
+		return nil, fmt.Errorf("unsupported manifest format %q", format)
+	}
+}
+
+func decodeYAML(r io.Reader) ([]Agent, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var agents []Agent
+	for {
+		var a Agent
+		err := dec.Decode(&a)
+		if errors.Is(err, io.EOF) {
+			return agents, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// 빈 문서("---"만 있는 경우)는 건너뜁니다.
+		if a.Name == "" && a.Kind == "" && a.APIVersion == "" {
+			continue
+		}
+		agents = append(agents, a)
+	}
+}
+
+func decodeJSON(r io.Reader) ([]Agent, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+	if data[0] != '[' {
+		data = append(append([]byte("["), data...), ']')
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var agents []Agent
+	if err := dec.Decode(&agents); err != nil {
+		return nil, err
+	}
+	return agents, nil
+}
+
+// Encode는 매니페스트를 format 형식으로 씁니다.
+// YAML은 "---"로 구분된 여러 문서, JSON은 하나면 객체, 여럿이면 배열로 씁니다.
+func Encode(w io.Writer, agents []Agent, format string) error {
+	switch format {
+	case FormatYAML:
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		for i := range agents {
+			if err := enc.Encode(&agents[i]); err != nil {
+				return err
+			}
+		}
+		return enc.Close()
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if len(agents) == 1 {
+			return enc.Encode(&agents[0])
+		}
+		return enc.Encode(agents)
+	default:
+		return fmt.Errorf("unsupported manifest format %q", format)
+	}
+}
+
+// FormatFromPath는 파일 확장자로 매니페스트 형식을 판단합니다.
+func FormatFromPath(path string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return FormatYAML, true
+	case ".json":
+		return FormatJSON, true
+	default:
+		return "", false
+	}
+}
+
+// Load는 파일 하나 또는 디렉터리 아래의 모든 .yaml/.yml/.json 파일에서 매니페스트를 읽습니다.
+// 모든 매니페스트를 검증하며, 같은 이름이 두 번 나오면 에러를 반환합니다.
+func Load(path string) ([]Agent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	if info.IsDir() {
+		err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if p != path && strings.HasPrefix(d.Name(), ".") {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if _, ok := FormatFromPath(p); ok {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+	} else {
+		if _, ok := FormatFromPath(path); !ok {
+			return nil, fmt.Errorf("%s: unsupported file extension (expected .yaml, .yml or .json)", path)
+		}
+		files = []string{path}
+	}
+
+	var (
+		agents []Agent
+		seen   = map[string]string{}
+	)
+	for _, file := range files {
+		loaded, err := loadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range loaded {
+			if err := a.Validate(); err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			if prev, ok := seen[a.Name]; ok {
+				return nil, fmt.Errorf("%s: agent %s is already defined in %s", file, a.Name, prev)
+			}
+			seen[a.Name] = file
+			agents = append(agents, a)
+		}
+	}
+	return agents, nil
+}
+
+func loadFile(path string) ([]Agent, error) {
+	format, _ := FormatFromPath(path)
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	agents, err := Decode(f, format)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return agents, nil
+}
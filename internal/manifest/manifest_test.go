@@ -0,0 +1,70 @@
+package manifest
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	a := NewAgent("support-bot")
+	a.Description = "고객 지원"
+	a.Model = "gpt-4"
+	a.Prompt = "줄 1\n줄 2\n"
+	a.Parameters = map[string]any{"temperature": 0.2, "max_tokens": 512}
+	a.Tools = []string{"search", "calculator"}
+	b := NewAgent("minimal")
+
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		var buf bytes.Buffer
+		require.NoError(t, Encode(&buf, []Agent{a, b}, format))
+		got, err := Decode(&buf, format)
+		require.NoError(t, err, format)
+		require.Len(t, got, 2)
+		require.Equal(t, a.Prompt, got[0].Prompt)
+		require.Equal(t, a.Tools, got[0].Tools)
+		require.EqualValues(t, 0.2, got[0].Parameters["temperature"])
+		require.Equal(t, b, got[1])
+	}
+}
+
+func TestDecodeRejectsUnknownFields(t *testing.T) {
+	_, err := Decode(strings.NewReader("apiVersion: cnap/v1\nkind: Agent\nname: a\nmodle: gpt-4\n"), FormatYAML)
+	require.Error(t, err)
+	_, err = Decode(strings.NewReader(`{"apiVersion":"cnap/v1","kind":"Agent","name":"a","modle":"gpt-4"}`), FormatJSON)
+	require.Error(t, err)
+}
+
+func TestLoadDirectory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	write("a.yaml", "---\napiVersion: cnap/v1\nkind: Agent\nname: a\n---\napiVersion: cnap/v1\nkind: Agent\nname: b\n")
+	write("sub/c.json", `[{"apiVersion":"cnap/v1","kind":"Agent","name":"c"}]`)
+	write(".git/ignored.yaml", "not: a manifest")
+	write("README.md", "# docs")
+
+	agents, err := Load(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, a := range agents {
+		names = append(names, a.Name)
+	}
+	require.Equal(t, []string{"a", "b", "c"}, names)
+
+	write("dup.yml", "apiVersion: cnap/v1\nkind: Agent\nname: c\n")
+	_, err = Load(dir)
+	require.ErrorContains(t, err, "already defined")
+
+	bad := filepath.Join(t.TempDir(), "bad.yaml")
+	require.NoError(t, os.WriteFile(bad, []byte("apiVersion: cnap/v2\nkind: Agent\nname: x\n"), 0o644))
+	_, err = Load(bad)
+	require.ErrorContains(t, err, "unsupported apiVersion")
+}
@@ -0,0 +1,154 @@
+// Package metrics는 CNAP의 Prometheus 메트릭을 정의하고 /metrics 엔드포인트를 제공합니다.
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "cnap"
+
+// Registry는 CNAP 메트릭이 등록되는 레지스트리입니다.
+// 전역 기본 레지스트리 대신 별도 레지스트리를 사용해 테스트 간 충돌을 막습니다.
+var Registry = prometheus.NewRegistry()
+
+var factory = promauto.With(Registry)
+
+var (
+	// TaskDuration은 작업이 running에서 종료 상태로 바뀌기까지 걸린 시간입니다.
+	TaskDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "task_duration_seconds",
+		Help:      "Time from task start to a terminal status, by agent, model and final status.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600, 1800},
+	}, []string{"agent", "model", "status"})
+
+	// ProviderRequestDuration은 모델 제공자 API 호출 지연 시간입니다.
+	ProviderRequestDuration = factory.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "provider_request_duration_seconds",
+		Help:      "Latency of model provider requests, by model and outcome.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"model", "outcome"})
+
+	// ProviderErrors는 모델 제공자 호출 실패 횟수를 에러 분류별로 셉니다.
+	ProviderErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "provider_errors_total",
+		Help:      "Model provider request failures, by model and error class.",
+	}, []string{"model", "class"})
+
+	// Tokens는 모델 제공자가 보고한 토큰 사용량입니다.
+	Tokens = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tokens_total",
+		Help:      "Tokens reported by the model provider, by model and type (prompt, completion).",
+	}, []string{"model", "type"})
+
+	// DiscordInteractions는 처리한 Discord 상호작용 수입니다.
+	DiscordInteractions = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discord_interactions_total",
+		Help:      "Discord interactions handled, by type and command.",
+	}, []string{"type", "command"})
+
+	// DiscordErrors는 Discord 상호작용 처리 중 발생한 에러 수입니다.
+	DiscordErrors = factory.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "discord_errors_total",
+		Help:      "Errors while handling Discord events, by event type.",
+	}, []string{"type"})
+)
+
+// 에러 분류 및 결과 레이블 값입니다.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+
+	TokenTypePrompt     = "prompt"
+	TokenTypeCompletion = "completion"
+)
+
+// TaskCounter는 상태별 작업 수를 반환하는 함수입니다.
+type TaskCounter func(ctx context.Context) (map[string]int64, error)
+
+// taskCollector는 스크레이프 시점에 저장소를 조회해 상태별 작업 수와 대기열 길이를 보고합니다.
+type taskCollector struct {
+	count      TaskCounter
+	queued     []string
+	tasks      *prometheus.Desc
+	queueDepth *prometheus.Desc
+	scrapeErr  *prometheus.Desc
+}
+
+// RegisterTaskCollector는 상태별 작업 수(cnap_tasks)와 대기열 길이(cnap_task_queue_depth)를 등록합니다.
+// queuedStatuses에 해당하는 작업 수의 합이 대기열 길이로 보고됩니다.
+func RegisterTaskCollector(count TaskCounter, queuedStatuses ...string) error {
+	return Registry.Register(&taskCollector{
+		count:  count,
+		queued: queuedStatuses,
+		tasks: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "tasks"),
+			"Number of tasks by status.",
+			[]string{"status"}, nil,
+		),
+		queueDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "task_queue_depth"),
+			"Number of tasks waiting to be executed.",
+			nil, nil,
+		),
+		scrapeErr: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "", "task_stats_scrape_error"),
+			"1 if the last task statistics query failed.",
+			nil, nil,
+		),
+	})
+}
+
+// Describe는 prometheus.Collector 인터페이스를 구현합니다.
+func (c *taskCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.tasks
+	ch <- c.queueDepth
+	ch <- c.scrapeErr
+}
+
+// Collect는 prometheus.Collector 인터페이스를 구현합니다.
+func (c *taskCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	counts, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.scrapeErr, prometheus.GaugeValue, 1)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.scrapeErr, prometheus.GaugeValue, 0)
+
+	for status, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.tasks, prometheus.GaugeValue, float64(n), status)
+	}
+
+	var depth int64
+	for _, status := range c.queued {
+		depth += counts[status]
+	}
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(depth))
+}
+
+// RegisterDBStats는 sql.DB 연결 풀 통계를 cnap_db_* 메트릭으로 등록합니다.
+func RegisterDBStats(db *sql.DB, dbName string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// RegisterRuntime는 Go 런타임 및 프로세스 메트릭을 등록합니다.
+func RegisterRuntime() error {
+	if err := Registry.Register(collectors.NewGoCollector()); err != nil {
+		return err
+	}
+	return Registry.Register(collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+}
@@ -0,0 +1,47 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/cnap-oss/app/internal/metrics"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTaskCollectorAndHandler(t *testing.T) {
+	fail := false
+	require.NoError(t, metrics.RegisterTaskCollector(func(ctx context.Context) (map[string]int64, error) {
+		if fail {
+			return nil, errors.New("db down")
+		}
+		return map[string]int64{"pending": 3, "running": 1, "completed": 7}, nil
+	}, "pending"))
+
+	metrics.TaskDuration.WithLabelValues("agent-1", "gpt-4", "completed").Observe(1.5)
+	metrics.ProviderErrors.WithLabelValues("gpt-4", "rate_limited").Inc()
+
+	body := scrape(t)
+	require.Contains(t, body, `cnap_tasks{status="pending"} 3`)
+	require.Contains(t, body, `cnap_tasks{status="completed"} 7`)
+	require.Contains(t, body, `cnap_task_queue_depth 3`)
+	require.Contains(t, body, `cnap_task_stats_scrape_error 0`)
+	require.Contains(t, body, `cnap_task_duration_seconds_count{agent="agent-1",model="gpt-4",status="completed"} 1`)
+	require.Contains(t, body, `cnap_provider_errors_total{class="rate_limited",model="gpt-4"} 1`)
+
+	fail = true
+	require.Contains(t, scrape(t), `cnap_task_stats_scrape_error 1`)
+}
+
+func scrape(t *testing.T) string {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	require.Equal(t, 200, rec.Code)
+	data, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(data))
+}
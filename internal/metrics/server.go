@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.uber.org/zap"
+)
+
+// Server는 /metrics 엔드포인트와 추가 운영용 핸들러를 제공하는 HTTP 서버입니다.
+type Server struct {
+	logger *zap.Logger
+	addr   string
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewServer는 addr(예: ":9090")에서 수신하는 메트릭 서버를 생성합니다.
+func NewServer(logger *zap.Logger, addr string) *Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", Handler())
+	return &Server{
+		logger: logger,
+		addr:   addr,
+		mux:    mux,
+		server: &http.Server{
+			Addr:              addr,
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		},
+	}
+}
+
+// Handle은 같은 포트에서 제공할 HTTP 핸들러를 추가합니다. Start 전에 호출해야 합니다.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler는 CNAP 레지스트리를 노출하는 HTTP 핸들러를 반환합니다.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Start는 컨텍스트가 취소될 때까지 메트릭 서버를 실행합니다.
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("metrics: listen on %s: %w", s.addr, err)
+	}
+	s.logger.Info("Starting metrics server", zap.String("addr", listener.Addr().String()))
+
+	errChan := make(chan error, 1)
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errChan <- err
+		}
+		close(errChan)
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errChan:
+		return err
+	}
+}
+
+// Stop은 메트릭 서버를 정상적으로 종료합니다.
+func (s *Server) Stop(ctx context.Context) error {
+	s.logger.Info("Stopping metrics server")
+	if err := s.server.Shutdown(ctx); err != nil {
+		return fmt.Errorf("metrics: shutdown: %w", err)
+	}
+	s.logger.Info("Metrics server stopped")
+	return nil
+}
@@ -0,0 +1,136 @@
+package msgstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FSStore는 로컬 파일 시스템 디렉터리에 메시지를 저장합니다.
+type FSStore struct {
+	root string
+}
+
+// NewFSStore는 root 디렉터리 아래에 메시지를 저장하는 FSStore를 생성합니다.
+func NewFSStore(root string) (*FSStore, error) {
+	if root == "" {
+		return nil, fmt.Errorf("msgstore: empty root directory")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, fmt.Errorf("msgstore: create root directory: %w", err)
+	}
+	return &FSStore{root: root}, nil
+}
+
+func (s *FSStore) path(key string) (string, error) {
+	if err := validateKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.root, filepath.FromSlash(key)), nil
+}
+
+// Put은 임시 파일에 쓰고 fsync한 뒤 rename하여 원자적으로 저장합니다.
+// 부분적으로 기록된 파일이 key 경로에 남지 않습니다.
+func (s *FSStore) Put(_ context.Context, key string, msg *Message) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	data, err := encode(msg)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("msgstore: create directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("msgstore: create temp file: %w", err)
+	}
+	tmpName := tmp.Name()
+	cleanup := func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmpName)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		cleanup()
+		return fmt.Errorf("msgstore: write %s: %w", key, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		cleanup()
+		return fmt.Errorf("msgstore: sync %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("msgstore: close %s: %w", key, err)
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("msgstore: rename %s: %w", key, err)
+	}
+	return syncDir(dir)
+}
+
+// Get은 key의 메시지를 읽습니다.
+func (s *FSStore) Get(_ context.Context, key string) (*Message, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("msgstore: read %s: %w", key, err)
+	}
+	return decode(key, data)
+}
+
+// Delete는 key의 메시지 파일을 삭제합니다.
+func (s *FSStore) Delete(_ context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("msgstore: delete %s: %w", key, err)
+	}
+	return nil
+}
+
+// Size는 key의 메시지 파일 크기를 반환합니다.
+func (s *FSStore) Size(_ context.Context, key string) (int64, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, ErrNotFound
+		}
+		return 0, fmt.Errorf("msgstore: stat %s: %w", key, err)
+	}
+	return info.Size(), nil
+}
+
+// syncDir은 rename 결과가 디스크에 반영되도록 디렉터리를 fsync합니다.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("msgstore: open directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("msgstore: sync directory: %w", err)
+	}
+	return nil
+}
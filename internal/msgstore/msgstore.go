@@ -0,0 +1,87 @@
+// Package msgstore는 대화 메시지 본문을 저장하는 blob 저장소를 제공합니다.
+//
+// 데이터베이스의 msg_index 테이블은 메시지 순서와 역할만 기록하고,
+// 본문은 MessageStore 구현체(파일 시스템 또는 S3 호환 저장소)에 버전이 있는 JSON 봉투로 저장합니다.
+package msgstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EnvelopeVersion은 현재 메시지 봉투 형식의 버전입니다.
+const EnvelopeVersion = 1
+
+// ErrNotFound는 키에 해당하는 메시지가 없을 때 반환됩니다.
+var ErrNotFound = errors.New("msgstore: message not found")
+
+// Message는 저장소에 기록되는 메시지 봉투입니다.
+type Message struct {
+	Version   int       `json:"version"`
+	TaskID    string    `json:"task_id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Author    string    `json:"author,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// MessageStore는 메시지 본문 저장소 인터페이스입니다.
+// 키는 "/"로 구분된 상대 경로이며 NewKey로 생성합니다.
+type MessageStore interface {
+	// Put은 key에 메시지를 저장합니다. 같은 키가 있으면 덮어씁니다.
+	Put(ctx context.Context, key string, msg *Message) error
+	// Get은 key의 메시지를 읽습니다. 없으면 ErrNotFound를 반환합니다.
+	Get(ctx context.Context, key string) (*Message, error)
+	// Delete는 key의 메시지를 삭제합니다. 없는 키는 에러가 아닙니다.
+	Delete(ctx context.Context, key string) error
+	// Size는 key에 저장된 본문의 바이트 크기를 반환합니다. 없으면 ErrNotFound를 반환합니다.
+	Size(ctx context.Context, key string) (int64, error)
+}
+
+// NewKey는 작업 메시지의 저장 키를 생성합니다.
+func NewKey(taskID string, now time.Time) string {
+	return fmt.Sprintf("messages/%s/%d.json", taskID, now.UnixNano())
+}
+
+// validateKey는 저장소 밖을 가리키거나 비어 있는 키를 거부합니다.
+func validateKey(key string) error {
+	if key == "" {
+		return fmt.Errorf("msgstore: empty key")
+	}
+	if strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return fmt.Errorf("msgstore: invalid key %q", key)
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("msgstore: invalid key %q", key)
+		}
+	}
+	return nil
+}
+
+// encode는 메시지를 현재 봉투 버전으로 직렬화합니다.
+func encode(msg *Message) ([]byte, error) {
+	if msg == nil {
+		return nil, fmt.Errorf("msgstore: nil message")
+	}
+	envelope := *msg
+	envelope.Version = EnvelopeVersion
+	return json.MarshalIndent(&envelope, "", "  ")
+}
+
+// decode는 봉투를 해석하고 지원하지 않는 버전을 거부합니다.
+func decode(key string, data []byte) (*Message, error) {
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, fmt.Errorf("msgstore: decode %s: %w", key, err)
+	}
+	if msg.Version < 1 || msg.Version > EnvelopeVersion {
+		return nil, fmt.Errorf("msgstore: unsupported envelope version %d in %s", msg.Version, key)
+	}
+	return &msg, nil
+}
@@ -0,0 +1,104 @@
+package taskrunner
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cnap-oss/app/internal/metrics"
+)
+
+// 모델 제공자 호출 실패의 분류입니다. 메트릭 레이블과 작업 결과 기록에 사용됩니다.
+const (
+	ErrorClassTimeout   = "timeout"
+	ErrorClassCanceled  = "canceled"
+	ErrorClassNetwork   = "network"
+	ErrorClassRateLimit = "rate_limited"
+	ErrorClassAuth      = "auth"
+	ErrorClassClient    = "client_error"
+	ErrorClassServer    = "server_error"
+	ErrorClassDecode    = "decode"
+	ErrorClassAPI       = "api_error"
+	ErrorClassUnknown   = "unknown"
+)
+
+// ProviderError는 분류 정보가 포함된 모델 제공자 호출 에러입니다.
+type ProviderError struct {
+	Class      string
+	StatusCode int
+	Err        error
+}
+
+func newProviderError(class string, statusCode int, err error) *ProviderError {
+	return &ProviderError{Class: class, StatusCode: statusCode, Err: err}
+}
+
+// Error는 error 인터페이스를 구현합니다.
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap은 원본 에러를 반환합니다.
+func (e *ProviderError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorClass는 에러의 분류를 반환합니다. ProviderError가 아니면 unknown입니다.
+func ErrorClass(err error) string {
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Class
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorClassTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorClassCanceled
+	}
+	return ErrorClassUnknown
+}
+
+func classifyTransportError(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return ErrorClassCanceled
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrorClassTimeout
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return ErrorClassTimeout
+	}
+	return ErrorClassNetwork
+}
+
+func classifyStatus(code int) string {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return ErrorClassRateLimit
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return ErrorClassAuth
+	case code == http.StatusRequestTimeout || code == http.StatusGatewayTimeout:
+		return ErrorClassTimeout
+	case code >= 500:
+		return ErrorClassServer
+	default:
+		return ErrorClassClient
+	}
+}
+
+// observeProviderRequest는 제공자 호출 지연 시간, 에러, 토큰 사용량을 메트릭으로 기록합니다.
+func observeProviderRequest(model string, elapsed time.Duration, result *RunResult, err error) {
+	if err != nil {
+		metrics.ProviderRequestDuration.WithLabelValues(model, metrics.OutcomeError).Observe(elapsed.Seconds())
+		metrics.ProviderErrors.WithLabelValues(model, ErrorClass(err)).Inc()
+		return
+	}
+	metrics.ProviderRequestDuration.WithLabelValues(model, metrics.OutcomeSuccess).Observe(elapsed.Seconds())
+	if result != nil {
+		metrics.Tokens.WithLabelValues(model, metrics.TokenTypePrompt).Add(float64(result.PromptTokens))
+		metrics.Tokens.WithLabelValues(model, metrics.TokenTypeCompletion).Add(float64(result.CompletionTokens))
+	}
+}
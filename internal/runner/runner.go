@@ -7,13 +7,19 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 	"go.uber.org/zap"
 )
 
+// providerBaseURL은 OpenCode Zen API의 기본 주소입니다.
+const providerBaseURL = "https://opencode.ai/zen/v1"
+
 // AgentInfo는 에이전트 실행에 필요한 정보를 담는 구조체입니다.
 type AgentInfo struct {
 	AgentID string
@@ -67,19 +73,19 @@ type OpenCodeResponse struct {
 		} `json:"message"`
 		FinishReason string `json:"finish_reason"`
 	} `json:"choices"`
+	Usage *struct {
+		PromptTokens     int `json:"prompt_tokens"`
+		CompletionTokens int `json:"completion_tokens"`
+		TotalTokens      int `json:"total_tokens"`
+	} `json:"usage,omitempty"`
 	Error *struct {
 		Type    string `json:"type"`
 		Message string `json:"message"`
 	} `json:"error,omitempty"`
 }
 
-// NewRunner는 새로운 Runner를 생성합니다.
-func NewRunner(logger *zap.Logger) *Runner {
-	apiKey := os.Getenv("OPEN_CODE_API_KEY")
-	if apiKey == "" {
-		logger.Fatal("환경 변수 OPEN_CODE_API_KEY가 설정되어 있지 않습니다")
-	}
-
+// NewRunner는 apiKey로 OpenCode Zen API를 호출하는 새로운 Runner를 생성합니다.
+func NewRunner(logger *zap.Logger, apiKey string) *Runner {
 	return &Runner{
 		logger: logger,
 		apiKey: apiKey,
@@ -88,13 +94,20 @@ func NewRunner(logger *zap.Logger) *Runner {
 
 // RunWithResult는 프롬프트를 OpenCode Zen API의 chat/completions 엔드포인트로 보내고 결과를 반환합니다.
 func (r *Runner) RunWithResult(ctx context.Context, model, name, prompt string) (*RunResult, error) {
+	ctx, span := tracing.Start(ctx, "runner.RunWithResult",
+		attribute.String("cnap.model", model),
+		attribute.String("cnap.task_id", name),
+	)
+	defer span.End()
+	logger := tracing.Logger(ctx, r.logger)
+
 	promptPreview := prompt
 	if len(promptPreview) > 200 {
 		promptPreview = promptPreview[:200] + "..."
 	}
 
 	// 요청 정보 로그 출력
-	r.logger.Info("Sending request to OpenCode Zen API (Chat Completions endpoint)",
+	logger.Info("Sending request to OpenCode Zen API (Chat Completions endpoint)",
 		zap.String("model", model),
 		zap.String("name", name),
 		zap.String("prompt_preview", promptPreview),
@@ -114,44 +127,66 @@ func (r *Runner) RunWithResult(ctx context.Context, model, name, prompt string)
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
-		"https://opencode.ai/zen/v1/chat/completions", bytes.NewReader(body))
+		providerBaseURL+"/chat/completions", bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("요청 생성 실패: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+r.apiKey)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
+	start := time.Now()
+	result, err := r.doRequest(req, logger, model, name)
+	observeProviderRequest(model, time.Since(start), result, err)
+	if err != nil {
+		span.SetAttributes(attribute.String("cnap.error_class", ErrorClass(err)))
+		tracing.RecordError(span, err)
+	} else {
+		span.SetAttributes(
+			attribute.Int("cnap.prompt_tokens", result.PromptTokens),
+			attribute.Int("cnap.completion_tokens", result.CompletionTokens),
+		)
+	}
+	return result, err
+}
+
+// doRequest는 API 요청을 전송하고 응답을 RunResult로 변환합니다.
+// 실패 시 에러 분류를 담은 *ProviderError를 반환합니다.
+func (r *Runner) doRequest(req *http.Request, logger *zap.Logger, model, name string) (*RunResult, error) {
 	client := &http.Client{Timeout: 20 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("API 요청 실패: %w", err)
+		return nil, newProviderError(classifyTransportError(err), 0, fmt.Errorf("API 요청 실패: %w", err))
 	}
 	defer resp.Body.Close()
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("응답 읽기 실패: %w", err)
+		return nil, newProviderError(classifyTransportError(err), resp.StatusCode, fmt.Errorf("응답 읽기 실패: %w", err))
 	}
 
 	contentType := resp.Header.Get("Content-Type")
-	r.logger.Debug("Response received",
+	logger.Debug("Response received",
 		zap.String("content_type", contentType),
 		zap.String("body_preview", summarizeBody(bodyBytes)),
 	)
 
 	if resp.StatusCode/100 != 2 {
-		return nil, fmt.Errorf("API 응답 오류: %s - %s", resp.Status, summarizeBody(bodyBytes))
+		return nil, newProviderError(classifyStatus(resp.StatusCode), resp.StatusCode,
+			fmt.Errorf("API 응답 오류: %s - %s", resp.Status, summarizeBody(bodyBytes)))
 	}
 
 	var apiResp OpenCodeResponse
 	if err := json.Unmarshal(bodyBytes, &apiResp); err != nil {
-		return nil, fmt.Errorf("응답 파싱 실패: %w\n\n[응답 원문]\n%s", err, string(bodyBytes))
+		return nil, newProviderError(ErrorClassDecode, resp.StatusCode,
+			fmt.Errorf("응답 파싱 실패: %w\n\n[응답 원문]\n%s", err, string(bodyBytes)))
 	}
 
 	// 에러 필드 처리
 	if apiResp.Error != nil {
-		return nil, fmt.Errorf("API 에러: %s - %s", apiResp.Error.Type, apiResp.Error.Message)
+		return nil, newProviderError(ErrorClassAPI, resp.StatusCode,
+			fmt.Errorf("API 에러: %s - %s", apiResp.Error.Type, apiResp.Error.Message))
 	}
 
 	output := "(empty result)"
@@ -159,26 +194,61 @@ func (r *Runner) RunWithResult(ctx context.Context, model, name, prompt string)
 		output = apiResp.Choices[0].Message.Content
 	}
 
-	r.logger.Info("OpenCode 응답 수신 완료",
+	logger.Info("OpenCode 응답 수신 완료",
 		zap.String("output_preview", summarizeBody([]byte(output))),
 	)
 
-	return &RunResult{
+	answered := apiResp.Model
+	if answered == "" {
+		answered = model
+	}
+
+	result := &RunResult{
 		Agent:   model,
 		Name:    name,
 		Success: true,
 		Output:  output,
 		Error:   nil,
-	}, nil
+		Model:   answered,
+	}
+	if apiResp.Usage != nil {
+		result.PromptTokens = apiResp.Usage.PromptTokens
+		result.CompletionTokens = apiResp.Usage.CompletionTokens
+	}
+	return result, nil
+}
+
+// CheckProvider는 OpenCode Zen API에 도달할 수 있는지 확인합니다.
+// 5xx가 아닌 HTTP 응답을 받으면 인증 여부와 관계없이 도달 가능한 것으로 봅니다.
+func CheckProvider(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, providerBaseURL+"/models", nil)
+	if err != nil {
+		return fmt.Errorf("요청 생성 실패: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return newProviderError(classifyTransportError(err), 0, fmt.Errorf("API 요청 실패: %w", err))
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return newProviderError(classifyStatus(resp.StatusCode), resp.StatusCode, fmt.Errorf("API 응답 오류: %s", resp.Status))
+	}
+	return nil
 }
 
 // RunResult는 에이전트 실행 결과를 나타냅니다.
 type RunResult struct {
-	Agent   string
-	Name    string
-	Success bool
-	Output  string
-	Error   error
+	Agent            string
+	Name             string
+	Success          bool
+	Output           string
+	Error            error
+	PromptTokens     int
+	CompletionTokens int
+	// Model은 실제로 응답한 모델입니다. 요청한 모델의 별칭이 구체적인 버전으로 바뀌어 있을 수 있습니다.
+	Model string
 }
 
 func summarizeBody(body []byte) string {
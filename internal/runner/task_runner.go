@@ -42,3 +42,13 @@ func (r *Runner) Run(ctx context.Context, req *RunRequest) (*RunResult, error) {
 
 	return r.RunWithResult(ctx, req.Model, req.TaskID, prompt)
 }
+
+// Execute는 req를 runner로 실행하고 결과를 callback에 알립니다.
+// 실행이 실패하면 OnError를, 끝나면 OnComplete를 호출하고 콜백의 오류를 반환합니다.
+func Execute(ctx context.Context, runner TaskRunner, req *RunRequest, callback StatusCallback) error {
+	result, err := runner.Run(ctx, req)
+	if err != nil {
+		return callback.OnError(req.TaskID, err)
+	}
+	return callback.OnComplete(req.TaskID, result)
+}
@@ -1,14 +1,15 @@
 package storage
 
 import (
-	"os"
-	"strconv"
+	"fmt"
+	"strings"
 	"time"
 
 	gormlogger "gorm.io/gorm/logger"
 )
 
 // Config는 GORM 데이터베이스 설정 값을 보관합니다.
+// 값은 internal/config 패키지가 설정 파일, 환경 변수, 플래그를 합쳐 채웁니다.
 type Config struct {
 	DSN                  string
 	LogLevel             gormlogger.LogLevel
@@ -20,98 +21,21 @@ type Config struct {
 	DisableAutomaticPing bool
 }
 
-// ConfigFromEnv는 환경 변수에서 설정을 읽어 Config를 구성합니다.
-// DATABASE_URL이 설정되지 않은 경우, 로컬 개발을 위해 SQLite를 기본으로 사용합니다.
-func ConfigFromEnv() (Config, error) {
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		// DATABASE_URL이 없으면 SQLite 기본값 사용 (로컬 개발용)
-		dsn = getDefaultSQLiteDSN()
-	}
-
-	cfg := Config{
-		DSN:             dsn,
-		LogLevel:        parseLogLevel(os.Getenv("DB_LOG_LEVEL")),
-		MaxIdleConns:    parseIntWithDefault(os.Getenv("DB_MAX_IDLE"), 5),
-		MaxOpenConns:    parseIntWithDefault(os.Getenv("DB_MAX_OPEN"), 20),
-		ConnMaxLifetime: parseDurationWithDefault(os.Getenv("DB_CONN_LIFETIME"), 30*time.Minute),
-		SkipDefaultTxn:  parseBoolWithDefault(os.Getenv("DB_SKIP_DEFAULT_TXN"), true),
-		PrepareStmt:     parseBoolWithDefault(os.Getenv("DB_PREPARE_STMT"), false),
-	}
-
-	if v, ok := lookupEnvBool("DB_DISABLE_AUTO_PING"); ok {
-		cfg.DisableAutomaticPing = v
-	}
-
-	return cfg, nil
-}
-
-// getDefaultSQLiteDSN은 로컬 개발용 기본 SQLite DSN을 반환합니다.
-func getDefaultSQLiteDSN() string {
-	// 환경변수로 SQLite 파일 경로를 재정의할 수 있음
-	if sqlitePath := os.Getenv("SQLITE_DATABASE"); sqlitePath != "" {
-		return sqlitePath
-	}
-	// 기본값: ./data/cnap.db
-	return "./data/cnap.db"
-}
-
-func parseLogLevel(value string) gormlogger.LogLevel {
-	switch value {
-	case "silent", "SILENT":
-		return gormlogger.Silent
-	case "error", "ERROR":
-		return gormlogger.Error
-	case "warn", "WARN":
-		return gormlogger.Warn
-	case "info", "INFO":
-		return gormlogger.Info
+// DefaultSQLitePath는 DSN이 지정되지 않았을 때 사용하는 로컬 개발용 SQLite 파일 경로입니다.
+const DefaultSQLitePath = "./data/cnap.db"
+
+// ParseLogLevel은 GORM 로그 레벨 이름(silent, error, warn, info)을 변환합니다.
+func ParseLogLevel(value string) (gormlogger.LogLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "silent":
+		return gormlogger.Silent, nil
+	case "error":
+		return gormlogger.Error, nil
+	case "warn":
+		return gormlogger.Warn, nil
+	case "info":
+		return gormlogger.Info, nil
 	default:
-		return gormlogger.Warn
-	}
-}
-
-func parseIntWithDefault(value string, def int) int {
-	if value == "" {
-		return def
-	}
-	parsed, err := strconv.Atoi(value)
-	if err != nil {
-		return def
-	}
-	return parsed
-}
-
-func parseDurationWithDefault(value string, def time.Duration) time.Duration {
-	if value == "" {
-		return def
-	}
-	d, err := time.ParseDuration(value)
-	if err != nil {
-		return def
-	}
-	return d
-}
-
-func parseBoolWithDefault(value string, def bool) bool {
-	if value == "" {
-		return def
-	}
-	parsed, err := strconv.ParseBool(value)
-	if err != nil {
-		return def
-	}
-	return parsed
-}
-
-func lookupEnvBool(key string) (bool, bool) {
-	value, ok := os.LookupEnv(key)
-	if !ok {
-		return false, false
-	}
-	parsed, err := strconv.ParseBool(value)
-	if err != nil {
-		return false, false
+		return gormlogger.Warn, fmt.Errorf("unknown db log level: %q (expected silent, error, warn or info)", value)
 	}
-	return parsed, true
 }
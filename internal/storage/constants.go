@@ -25,4 +25,29 @@ const (
 	MessageRoleUser      = "user"
 	MessageRoleAssistant = "assistant"
 	MessageRoleSystem    = "system"
+
+	SearchSourcePrompt  = "prompt"
+	SearchSourceMessage = "message"
+
+	AuditTargetAgent = "agent"
+	AuditTargetTask  = "task"
+
+	AuditActionAgentCreate       = "agent.create"
+	AuditActionAgentUpdate       = "agent.update"
+	AuditActionAgentDelete       = "agent.delete"
+	AuditActionAgentRollback     = "agent.rollback"
+	AuditActionAgentRestore      = "agent.restore"
+	AuditActionAgentPurge        = "agent.purge"
+	AuditActionAgentLabel        = "agent.label"
+	AuditActionTaskCreate        = "task.create"
+	AuditActionTaskStatus        = "task.status"
+	AuditActionTaskCancel        = "task.cancel"
+	AuditActionTaskSend          = "task.send"
+	AuditActionTaskPurge         = "task.purge"
+	AuditActionTaskFork          = "task.fork"
+	AuditActionTaskLabel         = "task.label"
+	AuditActionMessageAdd        = "message.add"
+	AuditActionMessageRegenerate = "message.regenerate"
+	AuditActionMessageEdit       = "message.edit"
+	AuditActionMessageDelete     = "message.delete"
 )
@@ -0,0 +1,200 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCopyBatchSize는 CopyTables가 한 트랜잭션에서 복사하는 기본 행 수입니다.
+const DefaultCopyBatchSize = 1000
+
+// ErrCopyMismatch는 복사 대상에 원본에 없는 행이 있거나, 복사 결과의 행 수나 체크섬이 원본과 다를 때 반환됩니다.
+var ErrCopyMismatch = errors.New("storage: copy target does not match source")
+
+// CopyOptions는 CopyTables의 선택 항목입니다.
+type CopyOptions struct {
+	// BatchSize가 0 이하이면 DefaultCopyBatchSize를 사용합니다.
+	BatchSize int
+	// Progress는 테이블마다 복사를 시작할 때와 배치를 커밋할 때마다 호출됩니다.
+	Progress func(p CopyProgress)
+}
+
+// CopyProgress는 테이블 하나의 복사 진행 상황입니다.
+type CopyProgress struct {
+	Table string
+	// Total은 원본의 행 수입니다.
+	Total int64
+	// Copied는 대상에 있는 행 수입니다. 이전 실행에서 복사한 행을 포함합니다.
+	Copied int64
+	// Resumed는 이번 실행을 시작할 때 대상에 이미 있던 행 수입니다.
+	Resumed int64
+}
+
+// TableComparison은 원본과 대상 테이블의 행 수와 체크섬입니다.
+type TableComparison struct {
+	Table          string
+	SourceRows     int64
+	TargetRows     int64
+	SourceChecksum string
+	TargetChecksum string
+}
+
+// Match는 행 수와 체크섬이 모두 같은지 반환합니다.
+func (c TableComparison) Match() bool {
+	return c.SourceRows == c.TargetRows && c.SourceChecksum == c.TargetChecksum
+}
+
+// CopyTables는 모든 CNAP 테이블을 src에서 dst로 ID 순서대로 배치 복사합니다. ID와 시각은 그대로 유지됩니다.
+// 배치마다 커밋하므로 중단된 뒤 다시 실행하면 대상의 가장 큰 ID 다음부터 이어서 복사합니다.
+// 두 데이터베이스는 같은 스키마 버전이어야 하며, 복사하는 동안 원본에 쓰기가 없어야 합니다.
+func CopyTables(ctx context.Context, src, dst *gorm.DB, opts CopyOptions) error {
+	batch := opts.BatchSize
+	if batch <= 0 {
+		batch = DefaultCopyBatchSize
+	}
+	for _, model := range Models() {
+		if err := copyTable(ctx, src, dst, model, batch, opts.Progress); err != nil {
+			return err
+		}
+	}
+	return ResetSequences(ctx, dst)
+}
+
+// copyTable은 테이블 하나를 이어서 복사합니다.
+func copyTable(ctx context.Context, src, dst *gorm.DB, model interface{}, batch int, progress func(CopyProgress)) error {
+	table, err := TableName(src, model)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	if err := src.WithContext(ctx).Model(model).Count(&total).Error; err != nil {
+		return fmt.Errorf("storage: count source %s: %w", table, err)
+	}
+
+	// 대상의 행은 원본 ID 순서의 앞부분이어야 이어서 복사할 수 있습니다.
+	var resumeState struct {
+		MaxID    sql.NullInt64
+		RowCount int64
+	}
+	if err := dst.WithContext(ctx).Model(model).
+		Select("MAX(id) AS max_id, COUNT(*) AS row_count").
+		Scan(&resumeState).Error; err != nil {
+		return fmt.Errorf("storage: inspect target %s: %w", table, err)
+	}
+	afterID := resumeState.MaxID.Int64
+	if resumeState.RowCount > 0 {
+		var prefix int64
+		if err := src.WithContext(ctx).Model(model).Where("id <= ?", afterID).Count(&prefix).Error; err != nil {
+			return fmt.Errorf("storage: count source %s: %w", table, err)
+		}
+		if prefix != resumeState.RowCount {
+			return fmt.Errorf("%w: %s has %d rows up to id %d, source has %d", ErrCopyMismatch, table, resumeState.RowCount, afterID, prefix)
+		}
+	}
+
+	p := CopyProgress{Table: table, Total: total, Copied: resumeState.RowCount, Resumed: resumeState.RowCount}
+	if progress != nil {
+		progress(p)
+	}
+	for {
+		rows, last, n, err := ReadRows(ctx, src, model, afterID, batch)
+		if err != nil {
+			return fmt.Errorf("storage: read source %s: %w", table, err)
+		}
+		if n == 0 {
+			return nil
+		}
+		if err := dst.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return InsertRows(ctx, tx, rows)
+		}); err != nil {
+			return fmt.Errorf("storage: write target %s: %w", table, err)
+		}
+		afterID = last
+		p.Copied += int64(n)
+		if progress != nil {
+			progress(p)
+		}
+	}
+}
+
+// CompareTables는 모든 CNAP 테이블의 행 수와 체크섬을 원본과 대상에서 계산해 비교합니다.
+// 체크섬은 ID 순서로 모든 컬럼 값을 해시하며, 시각은 드라이버마다 정밀도와 시간대가 다르므로
+// UTC 마이크로초 단위로 맞춘 뒤 계산합니다.
+func CompareTables(ctx context.Context, src, dst *gorm.DB) ([]TableComparison, error) {
+	var result []TableComparison
+	for _, model := range Models() {
+		table, err := TableName(src, model)
+		if err != nil {
+			return nil, err
+		}
+		cmp := TableComparison{Table: table}
+		if cmp.SourceRows, cmp.SourceChecksum, err = TableChecksum(ctx, src, model); err != nil {
+			return nil, fmt.Errorf("storage: checksum source %s: %w", table, err)
+		}
+		if cmp.TargetRows, cmp.TargetChecksum, err = TableChecksum(ctx, dst, model); err != nil {
+			return nil, fmt.Errorf("storage: checksum target %s: %w", table, err)
+		}
+		result = append(result, cmp)
+	}
+	return result, nil
+}
+
+// TableChecksum은 model 테이블의 행 수와 SHA-256 체크섬을 계산합니다.
+func TableChecksum(ctx context.Context, db *gorm.DB, model interface{}) (int64, string, error) {
+	h := sha256.New()
+	var (
+		count   int64
+		afterID int64
+	)
+	for {
+		rows, last, n, err := ReadRows(ctx, db, model, afterID, DefaultCopyBatchSize)
+		if err != nil {
+			return 0, "", err
+		}
+		if n == 0 {
+			break
+		}
+		slice := reflect.ValueOf(rows).Elem()
+		for i := 0; i < n; i++ {
+			if err := hashRow(h, slice.Index(i)); err != nil {
+				return 0, "", err
+			}
+		}
+		count += int64(n)
+		afterID = last
+	}
+	return count, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashRow는 구조체의 필드 값을 선언 순서대로 해시에 씁니다.
+// 시각은 nil이 아닌 포인터도 UTC와 마이크로초 단위로 맞춰 데이터베이스마다 같은 값이 되게 합니다.
+func hashRow(h hash.Hash, row reflect.Value) error {
+	values := make([]interface{}, row.NumField())
+	for i := range values {
+		v := row.Field(i).Interface()
+		if t, ok := v.(*time.Time); ok && t != nil {
+			v = *t
+		}
+		if t, ok := v.(time.Time); ok {
+			v = t.UTC().Truncate(time.Microsecond).Format(time.RFC3339Nano)
+		}
+		values[i] = v
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	_, err = h.Write(append(data, '\n'))
+	return err
+}
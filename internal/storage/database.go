@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -39,6 +40,10 @@ func Open(cfg Config) (*gorm.DB, error) {
 		return nil, fmt.Errorf("storage: open connection: %w", err)
 	}
 
+	if err := EnableTracing(db); err != nil {
+		return nil, fmt.Errorf("storage: enable tracing: %w", err)
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, fmt.Errorf("storage: get sql.DB: %w", err)
@@ -57,20 +62,62 @@ func Open(cfg Config) (*gorm.DB, error) {
 	return db, nil
 }
 
-// AutoMigrate는 CNAP 메시지 상태 관리를 위한 스키마를 마이그레이션합니다.
-func AutoMigrate(db *gorm.DB) error {
-	if db == nil {
-		return fmt.Errorf("storage: nil database handle")
-	}
-
-	if err := db.AutoMigrate(
+// Models는 CNAP 스키마를 구성하는 모델 목록을 반환합니다.
+// 모델을 바꾸면 migrations 디렉터리에 새 마이그레이션도 추가해야 합니다.
+func Models() []interface{} {
+	return []interface{}{
 		&Agent{},
+		&AgentRevision{},
 		&Task{},
 		&MessageIndex{},
 		&RunStep{},
 		&Checkpoint{},
-	); err != nil {
-		return fmt.Errorf("storage: migrate: %w", err)
+		&AuditEvent{},
+		&SearchDocument{},
+		&Label{},
+	}
+}
+
+// CheckSchema는 데이터베이스 스키마 버전이 바이너리의 최신 마이그레이션 버전과 같은지 확인합니다.
+func CheckSchema(ctx context.Context, db *gorm.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	return migrator.CheckVersion(ctx)
+}
+
+// VerifyModels는 모든 모델의 테이블, 컬럼, 인덱스가 데이터베이스에 존재하는지 확인합니다.
+// SQL 마이그레이션과 Go 모델 정의가 어긋난 경우를 찾아냅니다.
+func VerifyModels(db *gorm.DB) error {
+	if db == nil {
+		return fmt.Errorf("storage: nil database handle")
+	}
+	migrator := db.Migrator()
+	var missing []string
+	for _, model := range Models() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("storage: parse model: %w", err)
+		}
+		table := stmt.Schema.Table
+		if !migrator.HasTable(table) {
+			missing = append(missing, table)
+			continue
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !migrator.HasColumn(model, field.DBName) {
+				missing = append(missing, table+"."+field.DBName)
+			}
+		}
+		for _, idx := range stmt.Schema.ParseIndexes() {
+			if !migrator.HasIndex(model, idx.Name) {
+				missing = append(missing, table+"."+idx.Name)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("storage: missing tables, columns or indexes: %s", strings.Join(missing, ", "))
 	}
 	return nil
 }
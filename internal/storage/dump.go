@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Dialect는 db의 드라이버 이름(sqlite 또는 postgres)을 반환합니다.
+func Dialect(db *gorm.DB) string {
+	return db.Dialector.Name()
+}
+
+// TableName은 모델의 테이블 이름을 반환합니다.
+func TableName(db *gorm.DB, model interface{}) (string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(model); err != nil {
+		return "", fmt.Errorf("storage: parse model: %w", err)
+	}
+	return stmt.Schema.Table, nil
+}
+
+// Snapshot은 실행 중인 데이터베이스의 일관된 읽기 전용 스냅샷으로 fn을 호출합니다.
+// SQLite는 VACUUM INTO로 임시 파일에 온라인 백업을 만든 뒤 그 파일을 열고,
+// PostgreSQL은 REPEATABLE READ 읽기 전용 트랜잭션 안에서 fn을 실행합니다. 어느 쪽도 쓰기를 막지 않습니다.
+func Snapshot(ctx context.Context, db *gorm.DB, fn func(snap *gorm.DB) error) error {
+	if Dialect(db) != dialectSQLite {
+		return db.WithContext(ctx).Transaction(fn, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	}
+
+	dir, err := os.MkdirTemp("", "cnap-snapshot-")
+	if err != nil {
+		return fmt.Errorf("storage: create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	if err := db.WithContext(ctx).Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("storage: sqlite online backup: %w", err)
+	}
+	snap, err := gorm.Open(sqlite.Open("file:"+path+"?mode=ro"), &gorm.Config{Logger: db.Logger})
+	if err != nil {
+		return fmt.Errorf("storage: open snapshot: %w", err)
+	}
+	defer Close(snap)
+	return fn(snap.WithContext(ctx))
+}
+
+// ReadRows는 model 테이블에서 ID가 afterID보다 큰 행을 ID 순으로 최대 limit개 읽어
+// 모델 슬라이스 포인터(예: *[]Agent)로 반환합니다. 마지막 행의 ID와 읽은 행 수를 함께 반환합니다.
+func ReadRows(ctx context.Context, db *gorm.DB, model interface{}, afterID int64, limit int) (interface{}, int64, int, error) {
+	rows := NewRows(model)
+	if err := db.WithContext(ctx).
+		Where("id > ?", afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(rows).Error; err != nil {
+		return nil, afterID, 0, err
+	}
+	slice := reflect.ValueOf(rows).Elem()
+	n := slice.Len()
+	if n == 0 {
+		return rows, afterID, 0, nil
+	}
+	last := slice.Index(n - 1).FieldByName("ID").Int()
+	return rows, last, n, nil
+}
+
+// NewRows는 model 타입의 빈 슬라이스 포인터(예: *[]Agent)를 만듭니다.
+func NewRows(model interface{}) interface{} {
+	typ := reflect.TypeOf(model)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	return reflect.New(reflect.SliceOf(typ)).Interface()
+}
+
+// InsertRows는 ReadRows나 NewRows로 만든 모델 슬라이스 포인터의 행을 ID와 시각을 그대로 유지해 추가합니다.
+// 리비전과 감사 로그의 불변 훅, 자동 시각 설정을 거치지 않습니다.
+func InsertRows(ctx context.Context, db *gorm.DB, rows interface{}) error {
+	if reflect.ValueOf(rows).Elem().Len() == 0 {
+		return nil
+	}
+	return db.WithContext(ctx).
+		Session(&gorm.Session{SkipHooks: true}).
+		CreateInBatches(rows, 200).Error
+}
+
+// TruncateTables는 모든 CNAP 테이블의 행을 지웁니다. 스키마와 마이그레이션 이력은 그대로 둡니다.
+// 감사 로그의 추가 전용 트리거를 잠시 내리므로 db는 트랜잭션이어야 하며, 트리거는 커밋 전에 복구됩니다.
+func TruncateTables(ctx context.Context, db *gorm.DB) error {
+	tx := db.WithContext(ctx).Session(&gorm.Session{SkipHooks: true, AllowGlobalUpdate: true})
+	for _, model := range Models() {
+		del := func() error { return tx.Delete(model).Error }
+		if _, ok := model.(*AuditEvent); ok {
+			if err := withoutAuditTriggers(tx, del); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := del(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withoutAuditTriggers는 audit_events의 추가 전용 트리거(마이그레이션 0017)를 내린 채 fn을 실행합니다.
+// PostgreSQL은 트리거를 비활성화했다가 다시 활성화하고, SQLite는 트리거 정의를 읽어 지웠다가 다시 만듭니다.
+// 두 데이터베이스 모두 DDL이 트랜잭션에 포함되므로 다른 연결에는 트리거가 없는 상태가 보이지 않습니다.
+func withoutAuditTriggers(tx *gorm.DB, fn func() error) error {
+	if Dialect(tx) == dialectPostgres {
+		if err := tx.Exec("ALTER TABLE audit_events DISABLE TRIGGER USER").Error; err != nil {
+			return fmt.Errorf("storage: disable audit triggers: %w", err)
+		}
+		if err := fn(); err != nil {
+			return err
+		}
+		if err := tx.Exec("ALTER TABLE audit_events ENABLE TRIGGER USER").Error; err != nil {
+			return fmt.Errorf("storage: enable audit triggers: %w", err)
+		}
+		return nil
+	}
+
+	var triggers []struct {
+		Name string
+		SQL  string
+	}
+	if err := tx.Raw("SELECT name, sql FROM sqlite_master WHERE type = 'trigger' AND tbl_name = 'audit_events'").
+		Scan(&triggers).Error; err != nil {
+		return fmt.Errorf("storage: list audit triggers: %w", err)
+	}
+	for _, trigger := range triggers {
+		if err := tx.Exec(fmt.Sprintf("DROP TRIGGER %q", trigger.Name)).Error; err != nil {
+			return fmt.Errorf("storage: drop audit trigger %s: %w", trigger.Name, err)
+		}
+	}
+	if err := fn(); err != nil {
+		return err
+	}
+	for _, trigger := range triggers {
+		if err := tx.Exec(trigger.SQL).Error; err != nil {
+			return fmt.Errorf("storage: restore audit trigger %s: %w", trigger.Name, err)
+		}
+	}
+	return nil
+}
+
+// ResetSequences는 ID를 지정해 행을 추가한 뒤 PostgreSQL 시퀀스를 각 테이블의 최대 ID 다음 값으로 맞춥니다.
+// SQLite는 최대 ID 다음 값을 자동으로 사용하므로 아무것도 하지 않습니다.
+func ResetSequences(ctx context.Context, db *gorm.DB) error {
+	if Dialect(db) != dialectPostgres {
+		return nil
+	}
+	for _, model := range Models() {
+		table, err := TableName(db, model)
+		if err != nil {
+			return err
+		}
+		// 빈 테이블은 다음 값이 1이 되도록 is_called를 false로 둡니다.
+		query := fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%[1]s', 'id'), COALESCE(MAX(id), 1), MAX(id) IS NOT NULL) FROM %[1]s",
+			strings.ReplaceAll(table, "'", "''"),
+		)
+		if err := db.WithContext(ctx).Exec(query).Error; err != nil {
+			return fmt.Errorf("storage: reset %s sequence: %w", table, err)
+		}
+	}
+	return nil
+}
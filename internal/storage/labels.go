@@ -0,0 +1,320 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 레이블을 붙일 수 있는 대상입니다. 감사 로그의 대상 종류와 같은 값을 사용합니다.
+const (
+	LabelTargetAgent = AuditTargetAgent
+	LabelTargetTask  = AuditTargetTask
+)
+
+// 레이블 키와 값의 최대 길이입니다.
+const (
+	MaxLabelKeyLength   = 63
+	MaxLabelValueLength = 63
+)
+
+var (
+	// 키는 영문자나 숫자로 시작하고 끝나며, 가운데에 '-', '_', '.', '/'를 쓸 수 있습니다(예: team, example.com/ticket).
+	labelKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+	// 값은 비어 있거나, 영문자나 숫자로 시작하고 끝나며 가운데에 '-', '_', '.'를 쓸 수 있습니다(예: ABC-12).
+	labelValuePattern = regexp.MustCompile(`^([A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?)?$`)
+)
+
+// Label은 labels 테이블 레코드입니다. 에이전트나 작업 하나에 키마다 값 하나를 가집니다.
+type Label struct {
+	ID         int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	TargetType string    `gorm:"column:target_type;type:varchar(32);not null;uniqueIndex:idx_labels_target,priority:1;index:idx_labels_selector,priority:1"`
+	TargetID   string    `gorm:"column:target_id;type:varchar(64);not null;uniqueIndex:idx_labels_target,priority:2"`
+	Key        string    `gorm:"column:key;type:varchar(63);not null;uniqueIndex:idx_labels_target,priority:3;index:idx_labels_selector,priority:2"`
+	Value      string    `gorm:"column:value;type:varchar(63);not null;default:'';index:idx_labels_selector,priority:3"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+}
+
+// TableName은 gorm Tabler 인터페이스를 구현합니다.
+func (Label) TableName() string {
+	return "labels"
+}
+
+// ValidateLabels는 레이블 키와 값의 형식과 길이를 확인합니다.
+func ValidateLabels(labels map[string]string) error {
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		if err := validateLabelKey(key); err != nil {
+			return err
+		}
+		if err := validateLabelValue(key, labels[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateLabelKey(key string) error {
+	if len(key) > MaxLabelKeyLength || !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("storage: invalid label key %q (alphanumerics, '-', '_', '.', '/' up to %d characters)", key, MaxLabelKeyLength)
+	}
+	return nil
+}
+
+func validateLabelValue(key, value string) error {
+	if len(value) > MaxLabelValueLength || !labelValuePattern.MatchString(value) {
+		return fmt.Errorf("storage: invalid value %q for label %q (alphanumerics, '-', '_', '.' up to %d characters)", value, key, MaxLabelValueLength)
+	}
+	return nil
+}
+
+// ParseLabels는 "key=value" 목록을 레이블로 해석합니다. 같은 키가 여러 번 나오면 오류입니다.
+func ParseLabels(pairs []string) (map[string]string, error) {
+	labels := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok {
+			return nil, fmt.Errorf("storage: invalid label %q (expected key=value)", pair)
+		}
+		if _, dup := labels[key]; dup {
+			return nil, fmt.Errorf("storage: duplicate label key %q", key)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return nil, err
+	}
+	return labels, nil
+}
+
+// FormatLabels는 레이블을 키 순서대로 "key=value,key=value" 형식으로 만듭니다.
+func FormatLabels(labels map[string]string) string {
+	parts := make([]string, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		parts = append(parts, key+"="+labels[key])
+	}
+	return strings.Join(parts, ",")
+}
+
+// 레이블 셀렉터 연산자입니다.
+const (
+	LabelOpEquals    = "="
+	LabelOpNotEquals = "!="
+	LabelOpExists    = "exists"
+	LabelOpNotExists = "!exists"
+)
+
+// LabelRequirement는 레이블 셀렉터의 조건 하나입니다.
+type LabelRequirement struct {
+	Key      string
+	Operator string
+	// Value는 LabelOpEquals와 LabelOpNotEquals에서만 사용합니다.
+	Value string
+}
+
+// Matches는 labels가 조건을 만족하는지 확인합니다. "!="는 키가 없는 대상도 만족합니다.
+func (r LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case LabelOpEquals:
+		return ok && value == r.Value
+	case LabelOpNotEquals:
+		return !ok || value != r.Value
+	case LabelOpExists:
+		return ok
+	case LabelOpNotExists:
+		return !ok
+	}
+	return false
+}
+
+func (r LabelRequirement) String() string {
+	switch r.Operator {
+	case LabelOpExists:
+		return r.Key
+	case LabelOpNotExists:
+		return "!" + r.Key
+	}
+	return r.Key + r.Operator + r.Value
+}
+
+// LabelSelector는 모든 조건을 만족하는 대상을 고르는 레이블 셀렉터입니다. 비어 있으면 모든 대상과 일치합니다.
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector는 "team=infra,env!=prod,ticket,!archived" 같은 셀렉터를 해석합니다.
+// 조건은 쉼표로 구분하며 "key=value"(또는 "=="), "key!=value", "key"(키가 있음), "!key"(키가 없음)를 지원합니다.
+func ParseLabelSelector(spec string) (LabelSelector, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+	var selector LabelSelector
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		var req LabelRequirement
+		switch {
+		case part == "":
+			return nil, fmt.Errorf("storage: invalid label selector %q: empty requirement", spec)
+		case strings.Contains(part, "!="):
+			key, value, _ := strings.Cut(part, "!=")
+			req = LabelRequirement{Key: strings.TrimSpace(key), Operator: LabelOpNotEquals, Value: strings.TrimSpace(value)}
+		case strings.Contains(part, "="):
+			key, value, _ := strings.Cut(part, "=")
+			req = LabelRequirement{Key: strings.TrimSpace(key), Operator: LabelOpEquals, Value: strings.TrimSpace(strings.TrimPrefix(value, "="))}
+		case strings.HasPrefix(part, "!"):
+			req = LabelRequirement{Key: strings.TrimSpace(part[1:]), Operator: LabelOpNotExists}
+		default:
+			req = LabelRequirement{Key: part, Operator: LabelOpExists}
+		}
+		if err := validateLabelKey(req.Key); err != nil {
+			return nil, fmt.Errorf("%w in selector %q", err, spec)
+		}
+		if err := validateLabelValue(req.Key, req.Value); err != nil {
+			return nil, fmt.Errorf("%w in selector %q", err, spec)
+		}
+		selector = append(selector, req)
+	}
+	return selector, nil
+}
+
+// Matches는 labels가 셀렉터의 모든 조건을 만족하는지 확인합니다.
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, len(s))
+	for i, req := range s {
+		parts[i] = req.String()
+	}
+	return strings.Join(parts, ",")
+}
+
+// whereLabels는 idColumn의 대상이 셀렉터를 만족하도록 q에 조건을 추가합니다.
+// 조건마다 (target_type, key, value) 인덱스를 쓰는 서브쿼리 하나로 바뀝니다.
+func whereLabels(q *gorm.DB, targetType, idColumn string, selector LabelSelector) *gorm.DB {
+	for _, req := range selector {
+		sub := q.Session(&gorm.Session{NewDB: true}).Model(&Label{}).
+			Select("target_id").
+			Where("target_type = ? AND key = ?", targetType, req.Key)
+		switch req.Operator {
+		case LabelOpEquals:
+			q = q.Where(idColumn+" IN (?)", sub.Where("value = ?", req.Value))
+		case LabelOpNotEquals:
+			q = q.Where(idColumn+" NOT IN (?)", sub.Where("value = ?", req.Value))
+		case LabelOpExists:
+			q = q.Where(idColumn+" IN (?)", sub)
+		case LabelOpNotExists:
+			q = q.Where(idColumn+" NOT IN (?)", sub)
+		}
+	}
+	return q
+}
+
+// insertLabels는 대상의 레이블 행을 추가합니다.
+func insertLabels(tx *gorm.DB, targetType, targetID string, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	if err := ValidateLabels(labels); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	rows := make([]Label, 0, len(labels))
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		rows = append(rows, Label{TargetType: targetType, TargetID: targetID, Key: key, Value: labels[key], CreatedAt: now})
+	}
+	return tx.Create(&rows).Error
+}
+
+// loadLabels는 대상 ID별 레이블을 한 번에 읽습니다. 레이블이 없는 대상은 결과에 없습니다.
+func loadLabels(db *gorm.DB, targetType string, targetIDs []string) (map[string]map[string]string, error) {
+	result := make(map[string]map[string]string)
+	for _, batch := range chunkStrings(targetIDs, retentionBatchSize) {
+		var rows []Label
+		if err := db.Where("target_type = ? AND target_id IN ?", targetType, batch).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if result[row.TargetID] == nil {
+				result[row.TargetID] = make(map[string]string)
+			}
+			result[row.TargetID][row.Key] = row.Value
+		}
+	}
+	return result, nil
+}
+
+// attachAgentLabels는 에이전트들에 레이블을 채웁니다.
+func attachAgentLabels(db *gorm.DB, agents []Agent) error {
+	ids := make([]string, len(agents))
+	for i := range agents {
+		ids[i] = agents[i].AgentID
+	}
+	labels, err := loadLabels(db, LabelTargetAgent, ids)
+	if err != nil {
+		return err
+	}
+	for i := range agents {
+		agents[i].Labels = labels[agents[i].AgentID]
+	}
+	return nil
+}
+
+// attachTaskLabels는 작업들에 레이블을 채웁니다.
+func attachTaskLabels(db *gorm.DB, tasks []Task) error {
+	ids := make([]string, len(tasks))
+	for i := range tasks {
+		ids[i] = tasks[i].TaskID
+	}
+	labels, err := loadLabels(db, LabelTargetTask, ids)
+	if err != nil {
+		return err
+	}
+	for i := range tasks {
+		tasks[i].Labels = labels[tasks[i].TaskID]
+	}
+	return nil
+}
+
+// SetLabels는 대상의 레이블을 labels로 바꿉니다. labels에 없는 기존 레이블은 지웁니다.
+// 대상이 없으면 ErrNotFound를 반환합니다. 레이블은 행 버전을 올리지 않습니다.
+func (r *Repository) SetLabels(ctx context.Context, targetType, targetID string, labels map[string]string) error {
+	if err := ValidateLabels(labels); err != nil {
+		return err
+	}
+	var model interface{}
+	column := ""
+	switch targetType {
+	case LabelTargetAgent:
+		model, column = &Agent{}, "agent_id"
+	case LabelTargetTask:
+		model, column = &Task{}, "task_id"
+	default:
+		return fmt.Errorf("storage: unsupported label target %q", targetType)
+	}
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(model).Where(column+" = ?", targetID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return fmt.Errorf("storage: %s %s: %w", targetType, targetID, ErrNotFound)
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", targetType, targetID).Delete(&Label{}).Error; err != nil {
+			return err
+		}
+		return insertLabels(tx, targetType, targetID, labels)
+	})
+}
@@ -0,0 +1,312 @@
+package storage
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidCursor는 페이지 커서를 해석할 수 없거나 다른 정렬 기준으로 만들어졌을 때 반환됩니다.
+var ErrInvalidCursor = errors.New("storage: invalid page cursor")
+
+// 목록 정렬 기준입니다. 앞에 "-"를 붙이면 내림차순입니다(예: "-created").
+const (
+	SortCreated = "created"
+	SortUpdated = "updated"
+	SortName    = "name"
+)
+
+// MaxPageSize는 한 페이지에서 가져올 수 있는 최대 행 수입니다.
+const MaxPageSize = 1000
+
+// AgentFilter는 ListAgentsPage의 조회 조건입니다. 비어 있는 필드는 조건을 적용하지 않습니다.
+type AgentFilter struct {
+	// Statuses가 비어 있으면 삭제된 에이전트를 제외한 모든 에이전트를 조회합니다.
+	Statuses      []string
+	Model         string
+	NamePrefix    string
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// Labels는 에이전트 레이블이 만족해야 하는 셀렉터입니다.
+	Labels LabelSelector
+	// Sort는 SortCreated(기본), SortUpdated, SortName 중 하나이며 "-"를 붙이면 내림차순입니다.
+	Sort string
+	// Limit이 0 이하이면 남은 행을 모두 반환합니다. MaxPageSize를 넘을 수 없습니다.
+	Limit  int
+	Cursor string
+}
+
+// TaskFilter는 ListTasksPage의 조회 조건입니다. AgentID가 비어 있으면 모든 에이전트의 작업을 조회합니다.
+type TaskFilter struct {
+	AgentID  string
+	Statuses []string
+	IDPrefix string
+	// PromptContains는 프롬프트에 포함된 문자열입니다(대소문자 무시).
+	PromptContains string
+	CreatedAfter   time.Time
+	CreatedBefore  time.Time
+	// Labels는 작업 레이블이 만족해야 하는 셀렉터입니다.
+	Labels LabelSelector
+	Sort   string
+	Limit  int
+	Cursor string
+}
+
+// agentSummaryColumns는 목록 조회에서 읽는 에이전트 컬럼입니다. 긴 프롬프트와 설정은 읽지 않습니다.
+var agentSummaryColumns = []string{"id", "agent_id", "description", "model", "status", "owner_id", "revision", "version", "created_at", "updated_at"}
+
+// ListAgentsPage는 필터와 정렬을 적용해 에이전트 한 페이지를 반환합니다.
+// 목록용이므로 Prompt, Parameters, Tools는 채우지 않습니다.
+// 다음 페이지가 있으면 그 커서를, 없으면 빈 문자열을 함께 반환합니다.
+func (r *Repository) ListAgentsPage(ctx context.Context, filter AgentFilter) ([]Agent, string, error) {
+	q := r.db.WithContext(ctx).Model(&Agent{}).Select(agentSummaryColumns)
+	if len(filter.Statuses) > 0 {
+		q = q.Where("status IN ?", filter.Statuses)
+	} else {
+		q = q.Where("status <> ?", AgentStatusDeleted)
+	}
+	if filter.Model != "" {
+		q = q.Where("model = ?", filter.Model)
+	}
+	if filter.NamePrefix != "" {
+		q = q.Where("LOWER(agent_id) LIKE ? ESCAPE '\\'", likePrefix(strings.ToLower(filter.NamePrefix)))
+	}
+	q = whereCreatedRange(q, filter.CreatedAfter, filter.CreatedBefore)
+	q = whereLabels(q, LabelTargetAgent, "agent_id", filter.Labels)
+
+	agents, next, err := paginate(q, filter.Sort, "agent_id", filter.Limit, filter.Cursor, func(a *Agent) keyset {
+		return keyset{ID: a.ID, Name: a.AgentID, Created: a.CreatedAt, Updated: a.UpdatedAt}
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := attachAgentLabels(r.db.WithContext(ctx), agents); err != nil {
+		return nil, "", err
+	}
+	return agents, next, nil
+}
+
+// ListTasksPage는 필터와 정렬을 적용해 작업 한 페이지를 반환합니다.
+// 다음 페이지가 있으면 그 커서를, 없으면 빈 문자열을 함께 반환합니다.
+func (r *Repository) ListTasksPage(ctx context.Context, filter TaskFilter) ([]Task, string, error) {
+	q := r.db.WithContext(ctx).Model(&Task{})
+	if filter.AgentID != "" {
+		q = q.Where("agent_id = ?", filter.AgentID)
+	}
+	if len(filter.Statuses) > 0 {
+		q = q.Where("status IN ?", filter.Statuses)
+	}
+	if filter.IDPrefix != "" {
+		q = q.Where("task_id LIKE ? ESCAPE '\\'", likePrefix(filter.IDPrefix))
+	}
+	if filter.PromptContains != "" {
+		q = q.Where("LOWER(prompt) LIKE ? ESCAPE '\\'", "%"+likePrefix(strings.ToLower(filter.PromptContains)))
+	}
+	q = whereCreatedRange(q, filter.CreatedAfter, filter.CreatedBefore)
+	q = whereLabels(q, LabelTargetTask, "task_id", filter.Labels)
+
+	tasks, next, err := paginate(q, filter.Sort, "task_id", filter.Limit, filter.Cursor, func(t *Task) keyset {
+		return keyset{ID: t.ID, Name: t.TaskID, Created: t.CreatedAt, Updated: t.UpdatedAt}
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if err := attachTaskLabels(r.db.WithContext(ctx), tasks); err != nil {
+		return nil, "", err
+	}
+	return tasks, next, nil
+}
+
+// keyset은 한 행의 정렬 키입니다. 커서는 마지막 행의 keyset을 인코딩합니다.
+type keyset struct {
+	ID      int64
+	Name    string
+	Created time.Time
+	Updated time.Time
+}
+
+// cursor는 페이지 커서의 내용입니다. 정렬 기준이 바뀌면 커서를 재사용할 수 없습니다.
+type cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int64  `json:"id"`
+}
+
+// ParseSort는 "-created" 같은 정렬 지정을 기준과 내림차순 여부로 나눕니다. 빈 값은 SortCreated입니다.
+func ParseSort(spec string) (string, bool, error) {
+	desc := strings.HasPrefix(spec, "-")
+	field := strings.TrimPrefix(spec, "-")
+	switch field {
+	case "":
+		return SortCreated, desc, nil
+	case SortCreated, SortUpdated, SortName:
+		return field, desc, nil
+	default:
+		return "", false, fmt.Errorf("storage: unsupported sort %q (expected created, updated or name)", spec)
+	}
+}
+
+// pageRequest는 정렬 지정과 페이지 커서를 해석한 결과입니다.
+type pageRequest struct {
+	field      string
+	desc       bool
+	normalized string
+	// after는 이전 페이지 마지막 행의 정렬 키입니다. 첫 페이지면 nil입니다.
+	after *keyset
+}
+
+// parsePage는 정렬 지정과 커서를 해석합니다. 커서가 다른 정렬 기준으로 만들어졌으면 ErrInvalidCursor를 반환합니다.
+func parsePage(sortSpec, rawCursor string) (pageRequest, error) {
+	field, desc, err := ParseSort(sortSpec)
+	if err != nil {
+		return pageRequest{}, err
+	}
+	p := pageRequest{field: field, desc: desc, normalized: field}
+	if desc {
+		p.normalized = "-" + field
+	}
+	if rawCursor == "" {
+		return p, nil
+	}
+
+	c, err := decodeCursor(rawCursor)
+	if err != nil {
+		return pageRequest{}, err
+	}
+	if c.Sort != p.normalized {
+		return pageRequest{}, fmt.Errorf("%w: cursor was issued for sort %q", ErrInvalidCursor, c.Sort)
+	}
+	after := keyset{ID: c.ID, Name: c.Value}
+	if field != SortName {
+		t, err := time.Parse(time.RFC3339Nano, c.Value)
+		if err != nil {
+			return pageRequest{}, ErrInvalidCursor
+		}
+		after.Created, after.Updated = t, t
+	}
+	p.after = &after
+	return p, nil
+}
+
+// value는 정렬 기준에 해당하는 k의 값입니다.
+func (p pageRequest) value(k keyset) any {
+	switch p.field {
+	case SortName:
+		return k.Name
+	case SortUpdated:
+		return k.Updated
+	default:
+		return k.Created
+	}
+}
+
+// nextCursor는 last 다음 행부터 읽는 커서를 만듭니다.
+func (p pageRequest) nextCursor(last keyset) string {
+	c := cursor{Sort: p.normalized, ID: last.ID}
+	switch v := p.value(last).(type) {
+	case string:
+		c.Value = v
+	case time.Time:
+		c.Value = v.Format(time.RFC3339Nano)
+	}
+	return encodeCursor(c)
+}
+
+// compare는 정렬 기준과 id로 a와 b의 오름차순 순서를 비교합니다.
+func (p pageRequest) compare(a, b keyset) int {
+	var c int
+	switch p.field {
+	case SortName:
+		c = strings.Compare(a.Name, b.Name)
+	case SortUpdated:
+		c = a.Updated.Compare(b.Updated)
+	default:
+		c = a.Created.Compare(b.Created)
+	}
+	if c != 0 {
+		return c
+	}
+	switch {
+	case a.ID < b.ID:
+		return -1
+	case a.ID > b.ID:
+		return 1
+	}
+	return 0
+}
+
+// paginate는 q에 정렬과 커서 조건을 적용해 limit+1개를 읽고, 다음 페이지 커서를 계산합니다.
+// 정렬 키가 같은 행은 id로 순서를 정하므로 페이지 사이에서 행이 빠지거나 겹치지 않습니다.
+func paginate[T any](q *gorm.DB, sortSpec, nameColumn string, limit int, rawCursor string, key func(*T) keyset) ([]T, string, error) {
+	p, err := parsePage(sortSpec, rawCursor)
+	if err != nil {
+		return nil, "", err
+	}
+	column := map[string]string{SortCreated: "created_at", SortUpdated: "updated_at", SortName: nameColumn}[p.field]
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	cmp, dir := ">", "ASC"
+	if p.desc {
+		cmp, dir = "<", "DESC"
+	}
+	if p.after != nil {
+		value := p.value(*p.after)
+		q = q.Where(fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", column, cmp), value, value, p.after.ID)
+	}
+
+	q = q.Order(column + " " + dir).Order("id " + dir)
+	if limit > 0 {
+		q = q.Limit(limit + 1)
+	}
+	var rows []T
+	if err := q.Find(&rows).Error; err != nil {
+		return nil, "", err
+	}
+	if limit <= 0 || len(rows) <= limit {
+		return rows, "", nil
+	}
+
+	rows = rows[:limit]
+	return rows, p.nextCursor(key(&rows[limit-1])), nil
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor{}, ErrInvalidCursor
+	}
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort == "" {
+		return cursor{}, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+func whereCreatedRange(q *gorm.DB, after, before time.Time) *gorm.DB {
+	if !after.IsZero() {
+		q = q.Where("created_at >= ?", after)
+	}
+	if !before.IsZero() {
+		q = q.Where("created_at < ?", before)
+	}
+	return q
+}
+
+// likePrefix는 LIKE 와일드카드를 이스케이프한 접두사 패턴을 만듭니다.
+func likePrefix(prefix string) string {
+	r := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return r.Replace(prefix) + "%"
+}
@@ -0,0 +1,1201 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore는 Store의 스레드 안전한 메모리 구현입니다.
+// 데이터베이스 없이 컨트롤러와 커넥터를 테스트할 때 사용하며, 프로세스가 끝나면 데이터가 사라집니다.
+// 반환하는 레코드는 복사본이므로 호출자가 수정해도 저장된 값은 바뀌지 않습니다.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	txMu   sync.Mutex
+	lastID int64
+
+	agents      map[string]*Agent
+	revisions   map[string][]AgentRevision
+	tasks       map[string]*Task
+	messages    map[string][]MessageIndex
+	runSteps    map[string][]RunStep
+	checkpoints map[string][]Checkpoint
+	searchDocs  map[string][]SearchDocument
+	audit       []AuditEvent
+}
+
+// NewMemoryStore는 비어 있는 MemoryStore를 생성합니다.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		agents:      make(map[string]*Agent),
+		revisions:   make(map[string][]AgentRevision),
+		tasks:       make(map[string]*Task),
+		messages:    make(map[string][]MessageIndex),
+		runSteps:    make(map[string][]RunStep),
+		checkpoints: make(map[string][]Checkpoint),
+		searchDocs:  make(map[string][]SearchDocument),
+	}
+}
+
+// nextID는 새 행의 ID를 할당합니다. 모든 테이블이 하나의 증가하는 카운터를 공유합니다.
+func (m *MemoryStore) nextID() int64 {
+	m.lastID++
+	return m.lastID
+}
+
+// InTx는 fn을 실행하고, fn이 오류를 반환하면 저장소를 실행 전 상태로 되돌립니다.
+// 트랜잭션끼리는 차례로 실행되지만, 그 사이 다른 고루틴이 InTx 밖에서 쓴 내용도 함께 되돌려집니다.
+func (m *MemoryStore) InTx(_ context.Context, fn func(tx Store) error) error {
+	m.txMu.Lock()
+	defer m.txMu.Unlock()
+
+	m.mu.RLock()
+	saved := m.snapshot()
+	m.mu.RUnlock()
+
+	if err := fn(m); err != nil {
+		m.mu.Lock()
+		m.restore(saved)
+		m.mu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// memorySnapshot은 InTx가 되돌릴 저장소 상태입니다.
+type memorySnapshot struct {
+	lastID      int64
+	agents      map[string]*Agent
+	revisions   map[string][]AgentRevision
+	tasks       map[string]*Task
+	messages    map[string][]MessageIndex
+	runSteps    map[string][]RunStep
+	checkpoints map[string][]Checkpoint
+	searchDocs  map[string][]SearchDocument
+	audit       []AuditEvent
+}
+
+// snapshot은 현재 상태를 복사합니다. 레코드는 제자리에서 수정되므로 값으로 복사합니다.
+func (m *MemoryStore) snapshot() *memorySnapshot {
+	return &memorySnapshot{
+		lastID:      m.lastID,
+		agents:      cloneRecords(m.agents),
+		revisions:   cloneSlices(m.revisions),
+		tasks:       cloneRecords(m.tasks),
+		messages:    cloneSlices(m.messages),
+		runSteps:    cloneSlices(m.runSteps),
+		checkpoints: cloneSlices(m.checkpoints),
+		searchDocs:  cloneSlices(m.searchDocs),
+		audit:       slices.Clone(m.audit),
+	}
+}
+
+// restore는 snapshot으로 저장한 상태로 되돌립니다.
+func (m *MemoryStore) restore(s *memorySnapshot) {
+	m.lastID = s.lastID
+	m.agents = s.agents
+	m.revisions = s.revisions
+	m.tasks = s.tasks
+	m.messages = s.messages
+	m.runSteps = s.runSteps
+	m.checkpoints = s.checkpoints
+	m.searchDocs = s.searchDocs
+	m.audit = s.audit
+}
+
+func cloneRecords[T any](records map[string]*T) map[string]*T {
+	out := make(map[string]*T, len(records))
+	for k, v := range records {
+		c := *v
+		out[k] = &c
+	}
+	return out
+}
+
+func cloneSlices[T any](records map[string][]T) map[string][]T {
+	out := make(map[string][]T, len(records))
+	for k, v := range records {
+		out[k] = slices.Clone(v)
+	}
+	return out
+}
+
+// stamp는 데이터베이스의 autoCreateTime/autoUpdateTime처럼 비어 있는 시각을 now로 채웁니다.
+func stamp(now time.Time, times ...*time.Time) {
+	for _, t := range times {
+		if t.IsZero() {
+			*t = now
+		}
+	}
+}
+
+// CreateAgent는 새로운 에이전트 레코드와 첫 리비전을 저장합니다.
+func (m *MemoryStore) CreateAgent(_ context.Context, agent *Agent) error {
+	if agent == nil {
+		return fmt.Errorf("storage: nil agent payload")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.agents[agent.AgentID]; ok {
+		return fmt.Errorf("storage: agent %q already exists", agent.AgentID)
+	}
+	if err := ValidateLabels(agent.Labels); err != nil {
+		return err
+	}
+
+	agent.ID = m.nextID()
+	agent.Revision = 1
+	agent.Version = 1
+	if agent.Status == "" {
+		agent.Status = AgentStatusActive
+	}
+	now := time.Now()
+	stamp(now, &agent.CreatedAt, &agent.UpdatedAt)
+	stored := copyAgent(agent)
+	m.agents[agent.AgentID] = &stored
+	m.revisions[agent.AgentID] = []AgentRevision{newAgentRevision(m.nextID(), agent, agent.OwnerID, now)}
+	return nil
+}
+
+// newAgentRevision은 agent의 현재 설정으로 리비전을 만듭니다.
+func newAgentRevision(id int64, agent *Agent, author string, now time.Time) AgentRevision {
+	return AgentRevision{
+		ID:          id,
+		AgentID:     agent.AgentID,
+		Revision:    agent.Revision,
+		Description: agent.Description,
+		Model:       agent.Model,
+		Prompt:      agent.Prompt,
+		Parameters:  agent.Parameters,
+		Tools:       agent.Tools,
+		Author:      author,
+		CreatedAt:   now,
+	}
+}
+
+// UpdateAgentStatus는 agent.Version이 현재 버전과 같을 때만 에이전트 상태를 바꿉니다.
+func (m *MemoryStore) UpdateAgentStatus(_ context.Context, agent *Agent, status string) error {
+	if agent == nil {
+		return fmt.Errorf("storage: nil agent payload")
+	}
+	if agent.AgentID == "" {
+		return fmt.Errorf("storage: empty agentID")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := checkVersion(m.agents, "agent", agent.AgentID, agent.Version, func(a *Agent) int { return a.Version })
+	if err != nil {
+		return err
+	}
+	current.Status = status
+	current.UpdatedAt = time.Now()
+	current.Version++
+	agent.Status = status
+	agent.Version++
+	return nil
+}
+
+// UpdateAgent는 에이전트 설정을 업데이트하고 author가 작성한 새 리비전을 추가합니다.
+// agent.Version이 현재 버전과 다르면(0 포함) *ConflictError를 반환합니다.
+func (m *MemoryStore) UpdateAgent(_ context.Context, agent *Agent, author string) error {
+	if agent == nil {
+		return fmt.Errorf("storage: nil agent payload")
+	}
+	if agent.AgentID == "" {
+		return fmt.Errorf("storage: empty agentID")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := checkVersion(m.agents, "agent", agent.AgentID, agent.Version, func(a *Agent) int { return a.Version })
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	current.Description = agent.Description
+	current.Model = agent.Model
+	current.Prompt = agent.Prompt
+	current.Parameters = agent.Parameters
+	current.Tools = agent.Tools
+	current.Revision++
+	current.Version++
+	current.UpdatedAt = now
+	m.revisions[agent.AgentID] = append(m.revisions[agent.AgentID], newAgentRevision(m.nextID(), current, author, now))
+	agent.Revision = current.Revision
+	agent.Version = current.Version
+	return nil
+}
+
+// checkVersion은 id의 레코드를 찾아 버전이 expected인지 확인합니다.
+// 레코드가 없으면 ErrNotFound를, 버전이 다르면 *ConflictError를 반환합니다.
+func checkVersion[T any](rows map[string]*T, kind, id string, expected int, version func(*T) int) (*T, error) {
+	row, ok := rows[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if actual := version(row); actual != expected {
+		return nil, &ConflictError{Kind: kind, ID: id, Expected: expected, Actual: actual}
+	}
+	return row, nil
+}
+
+// GetAgent는 식별자로 에이전트를 조회합니다.
+func (m *MemoryStore) GetAgent(_ context.Context, agentID string) (*Agent, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	agent, ok := m.agents[agentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := copyAgent(agent)
+	return &copied, nil
+}
+
+// ListAgents는 상태 필터를 적용해 에이전트 목록을 생성 순으로 반환합니다.
+func (m *MemoryStore) ListAgents(_ context.Context, statuses ...string) ([]Agent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var agents []Agent
+	for _, agent := range m.agents {
+		if len(statuses) == 0 || slices.Contains(statuses, agent.Status) {
+			agents = append(agents, copyAgent(agent))
+		}
+	}
+	sortByCreated(agents, func(a *Agent) (time.Time, int64) { return a.CreatedAt, a.ID })
+	return agents, nil
+}
+
+// ListAgentsPage는 필터와 정렬을 적용해 에이전트 한 페이지를 반환합니다.
+// Repository와 같이 Prompt, Parameters, Tools는 채우지 않습니다.
+func (m *MemoryStore) ListAgentsPage(_ context.Context, filter AgentFilter) ([]Agent, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	prefix := strings.ToLower(filter.NamePrefix)
+	var agents []Agent
+	for _, agent := range m.agents {
+		if len(filter.Statuses) > 0 {
+			if !slices.Contains(filter.Statuses, agent.Status) {
+				continue
+			}
+		} else if agent.Status == AgentStatusDeleted {
+			continue
+		}
+		if filter.Model != "" && agent.Model != filter.Model {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(agent.AgentID), prefix) {
+			continue
+		}
+		if !inCreatedRange(agent.CreatedAt, filter.CreatedAfter, filter.CreatedBefore) {
+			continue
+		}
+		if !filter.Labels.Matches(agent.Labels) {
+			continue
+		}
+		summary := copyAgent(agent)
+		summary.Prompt, summary.Parameters, summary.Tools = "", "", ""
+		agents = append(agents, summary)
+	}
+	return paginateRows(agents, filter.Sort, filter.Limit, filter.Cursor, func(a *Agent) keyset {
+		return keyset{ID: a.ID, Name: a.AgentID, Created: a.CreatedAt, Updated: a.UpdatedAt}
+	})
+}
+
+// PurgeAgent는 에이전트와 그에 딸린 리비전, 작업과 작업 데이터를 영구 삭제하고,
+// 삭제한 메시지의 저장소 경로를 반환합니다. 감사 로그는 남깁니다.
+// 삭제 상태가 아닌 에이전트는 지우지 않고 ErrConflict를 반환합니다.
+func (m *MemoryStore) PurgeAgent(_ context.Context, agentID string) ([]string, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	agent, ok := m.agents[agentID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if agent.Status != AgentStatusDeleted {
+		return nil, fmt.Errorf("storage: agent %q is not deleted: %w", agentID, ErrConflict)
+	}
+	var ids []string
+	for id, task := range m.tasks {
+		if task.AgentID == agentID {
+			ids = append(ids, id)
+		}
+	}
+	usage := m.deleteTaskData(ids)
+	for _, id := range ids {
+		delete(m.tasks, id)
+	}
+	delete(m.agents, agentID)
+	delete(m.revisions, agentID)
+	return usage.Files, nil
+}
+
+// ListAgentRevisions는 에이전트의 리비전을 오래된 순으로 반환합니다.
+func (m *MemoryStore) ListAgentRevisions(_ context.Context, agentID string) ([]AgentRevision, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.revisions[agentID]), nil
+}
+
+// GetAgentRevision은 에이전트의 특정 리비전을 조회합니다.
+func (m *MemoryStore) GetAgentRevision(_ context.Context, agentID string, revision int) (*AgentRevision, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, rev := range m.revisions[agentID] {
+		if rev.Revision == revision {
+			return &rev, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CreateTask는 새로운 작업 레코드를 추가하고, 프롬프트가 있으면 검색 인덱스에 함께 등록합니다.
+func (m *MemoryStore) CreateTask(_ context.Context, task *Task) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.createTask(task)
+}
+
+// ForkTask는 분기한 작업을 만들고 복사할 메시지 참조를 함께 추가합니다.
+func (m *MemoryStore) ForkTask(_ context.Context, task *Task, messages []MessageIndex) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	prepareForkMessages(task, messages)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.createTask(task); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for i := range messages {
+		messages[i].ID = m.nextID()
+		stamp(now, &messages[i].CreatedAt, &messages[i].UpdatedAt)
+		m.messages[task.TaskID] = append(m.messages[task.TaskID], messages[i])
+	}
+	return nil
+}
+
+// createTask는 작업을 추가하고, 프롬프트가 있으면 검색 인덱스에 함께 등록합니다. m.mu를 잡고 호출합니다.
+func (m *MemoryStore) createTask(task *Task) error {
+	if _, ok := m.tasks[task.TaskID]; ok {
+		return fmt.Errorf("storage: task %q already exists", task.TaskID)
+	}
+	if err := ValidateLabels(task.Labels); err != nil {
+		return err
+	}
+	if task.Version == 0 {
+		task.Version = 1
+	}
+	task.ID = m.nextID()
+	stamp(time.Now(), &task.CreatedAt, &task.UpdatedAt)
+	stored := copyTask(task)
+	m.tasks[task.TaskID] = &stored
+	if task.Prompt != "" {
+		m.upsertSearchDocument(&SearchDocument{
+			TaskID:    task.TaskID,
+			AgentID:   task.AgentID,
+			Source:    SearchSourcePrompt,
+			Body:      task.Prompt,
+			CreatedAt: task.CreatedAt,
+		})
+	}
+	return nil
+}
+
+// UpdateTaskStatus는 task.Version이 현재 버전과 같을 때만 작업 상태를 바꿉니다.
+func (m *MemoryStore) UpdateTaskStatus(_ context.Context, task *Task, status string) error {
+	return m.updateTask(task, func(current *Task) {
+		current.Status = status
+		task.Status = status
+	})
+}
+
+// SetTaskAgentRevision은 작업이 실행된 에이전트 리비전과 렌더링한 시스템 프롬프트를 기록합니다.
+func (m *MemoryStore) SetTaskAgentRevision(_ context.Context, task *Task, revision int, systemPrompt string) error {
+	return m.updateTask(task, func(current *Task) {
+		current.AgentRevision = revision
+		current.SystemPrompt = systemPrompt
+		task.AgentRevision = revision
+		task.SystemPrompt = systemPrompt
+	})
+}
+
+// StartTaskAttempt는 작업을 running으로 바꾸고 실행 시작을 기록합니다.
+func (m *MemoryStore) StartTaskAttempt(_ context.Context, task *Task) error {
+	now := time.Now()
+	return m.updateTask(task, func(current *Task) {
+		current.startAttempt(now)
+		task.startAttempt(now)
+	})
+}
+
+// FinishTask는 작업을 종료 상태로 바꾸고 실행 결과와 종료 시각을 기록합니다.
+func (m *MemoryStore) FinishTask(_ context.Context, task *Task, status string, result TaskResult) error {
+	now := time.Now()
+	return m.updateTask(task, func(current *Task) {
+		current.finish(status, result, now)
+		task.finish(status, result, now)
+	})
+}
+
+// updateTask는 task.Version을 확인한 뒤 apply로 작업을 수정하고 버전을 1 올립니다.
+func (m *MemoryStore) updateTask(task *Task, apply func(current *Task)) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return fmt.Errorf("storage: empty taskID")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := checkVersion(m.tasks, "task", task.TaskID, task.Version, func(t *Task) int { return t.Version })
+	if err != nil {
+		return err
+	}
+	apply(current)
+	current.UpdatedAt = time.Now()
+	current.Version++
+	task.Version++
+	return nil
+}
+
+// GetTask는 작업 식별자로 레코드를 조회합니다.
+func (m *MemoryStore) GetTask(_ context.Context, taskID string) (*Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	copied := copyTask(task)
+	return &copied, nil
+}
+
+// ListTasksByAgent는 에이전트별 작업 목록을 생성 순으로 반환합니다.
+func (m *MemoryStore) ListTasksByAgent(_ context.Context, agentID string) ([]Task, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var tasks []Task
+	for _, task := range m.tasks {
+		if task.AgentID == agentID {
+			tasks = append(tasks, copyTask(task))
+		}
+	}
+	sortByCreated(tasks, func(t *Task) (time.Time, int64) { return t.CreatedAt, t.ID })
+	return tasks, nil
+}
+
+// ListTasksPage는 필터와 정렬을 적용해 작업 한 페이지를 반환합니다.
+func (m *MemoryStore) ListTasksPage(_ context.Context, filter TaskFilter) ([]Task, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	contains := strings.ToLower(filter.PromptContains)
+	var tasks []Task
+	for _, task := range m.tasks {
+		if filter.AgentID != "" && task.AgentID != filter.AgentID {
+			continue
+		}
+		if len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, task.Status) {
+			continue
+		}
+		if !strings.HasPrefix(task.TaskID, filter.IDPrefix) {
+			continue
+		}
+		if !strings.Contains(strings.ToLower(task.Prompt), contains) {
+			continue
+		}
+		if !inCreatedRange(task.CreatedAt, filter.CreatedAfter, filter.CreatedBefore) {
+			continue
+		}
+		if !filter.Labels.Matches(task.Labels) {
+			continue
+		}
+		tasks = append(tasks, copyTask(task))
+	}
+	return paginateRows(tasks, filter.Sort, filter.Limit, filter.Cursor, func(t *Task) keyset {
+		return keyset{ID: t.ID, Name: t.TaskID, Created: t.CreatedAt, Updated: t.UpdatedAt}
+	})
+}
+
+// CountTasksByStatus는 상태별 작업 수를 반환합니다.
+func (m *MemoryStore) CountTasksByStatus(_ context.Context) (map[string]int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	counts := make(map[string]int64)
+	for _, task := range m.tasks {
+		counts[task.Status]++
+	}
+	return counts, nil
+}
+
+// IndexSearchDocument는 검색 인덱스 문서를 추가하거나, 같은 작업·출처·참조의 문서가 있으면 본문을 바꿉니다.
+func (m *MemoryStore) IndexSearchDocument(_ context.Context, doc *SearchDocument) error {
+	if doc == nil {
+		return fmt.Errorf("storage: nil search document")
+	}
+	if doc.TaskID == "" || doc.Source == "" {
+		return fmt.Errorf("storage: search document requires task and source")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.upsertSearchDocument(doc)
+	return nil
+}
+
+func (m *MemoryStore) upsertSearchDocument(doc *SearchDocument) {
+	docs := m.searchDocs[doc.TaskID]
+	for i := range docs {
+		if docs[i].Source == doc.Source && docs[i].Ref == doc.Ref {
+			docs[i].AgentID = doc.AgentID
+			docs[i].Body = doc.Body
+			return
+		}
+	}
+	doc.ID = m.nextID()
+	stamp(time.Now(), &doc.CreatedAt)
+	m.searchDocs[doc.TaskID] = append(docs, *doc)
+}
+
+// SearchTasks는 모든 검색어가 단어의 접두사로 포함된 문서를 최신 순으로 반환합니다.
+// 관련도 순위는 계산하지 않으므로 SQLite FTS4와 같은 순서입니다.
+func (m *MemoryStore) SearchTasks(_ context.Context, filter SearchFilter) ([]SearchDocument, error) {
+	terms := SearchTerms(filter.Query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("storage: empty search query")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found []SearchDocument
+	for _, docs := range m.searchDocs {
+		for _, doc := range docs {
+			if filter.AgentID != "" && doc.AgentID != filter.AgentID {
+				continue
+			}
+			if matchTerms(SearchTerms(doc.Body), terms) {
+				found = append(found, doc)
+			}
+		}
+	}
+	sortByCreated(found, func(d *SearchDocument) (time.Time, int64) { return d.CreatedAt, d.ID })
+	slices.Reverse(found)
+	if len(found) > limit {
+		found = found[:limit]
+	}
+	return found, nil
+}
+
+// matchTerms는 모든 검색어가 words 중 하나의 접두사인지 확인합니다.
+func matchTerms(words, terms []string) bool {
+	for _, term := range terms {
+		if !slices.ContainsFunc(words, func(w string) bool { return strings.HasPrefix(w, term) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// ExpiredTasks는 rule에 따라 정리할 작업 ID를 반환합니다.
+// 전역 규칙이면 excludeAgents의 에이전트는 건너뜁니다. 대기 중이거나 실행 중인 작업은 반환하지 않습니다.
+func (m *MemoryStore) ExpiredTasks(_ context.Context, rule RetentionRule, excludeAgents []string, now time.Time) ([]string, error) {
+	if rule.OlderThan <= 0 && rule.KeepLast <= 0 {
+		return nil, fmt.Errorf("storage: retention rule needs older_than or keep_last")
+	}
+	statuses := rule.Statuses
+	if len(statuses) == 0 {
+		statuses = TerminalTaskStatuses
+	}
+
+	m.mu.RLock()
+	var candidates []Task
+	for _, task := range m.tasks {
+		if !slices.Contains(statuses, task.Status) || task.Status == TaskStatusPending || task.Status == TaskStatusRunning {
+			continue
+		}
+		if rule.AgentID != "" {
+			if task.AgentID != rule.AgentID {
+				continue
+			}
+		} else if slices.Contains(excludeAgents, task.AgentID) {
+			continue
+		}
+		if !rule.Labels.Matches(task.Labels) {
+			continue
+		}
+		candidates = append(candidates, *task)
+	}
+	m.mu.RUnlock()
+
+	// 에이전트별로 최근 작업부터 세어 KeepLast개를 남깁니다.
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID > b.ID
+	})
+	cutoff := now.Add(-rule.OlderThan)
+	var (
+		expired []string
+		agent   string
+		seen    int
+	)
+	for _, task := range candidates {
+		if task.AgentID != agent {
+			agent, seen = task.AgentID, 0
+		}
+		seen++
+		if rule.KeepLast > 0 && seen <= rule.KeepLast {
+			continue
+		}
+		if rule.OlderThan > 0 && !task.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		expired = append(expired, task.TaskID)
+	}
+	return expired, nil
+}
+
+// TaskUsage는 작업들과 그에 딸린 행 수, 메시지 저장소 경로를 집계합니다. 아무것도 지우지 않습니다.
+func (m *MemoryStore) TaskUsage(_ context.Context, taskIDs []string) (*TaskUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	usage := &TaskUsage{}
+	var messages []MessageIndex
+	for _, id := range uniqueStrings(taskIDs) {
+		if _, ok := m.tasks[id]; ok {
+			usage.Tasks++
+		}
+		messages = append(messages, m.messages[id]...)
+		usage.RunSteps += int64(len(m.runSteps[id]))
+		usage.Checkpoints += int64(len(m.checkpoints[id]))
+		usage.SearchDocuments += int64(len(m.searchDocs[id]))
+	}
+	usage.Messages = int64(len(messages))
+	usage.Files = messageFiles(messages)
+	return usage, nil
+}
+
+// DeleteTasks는 작업과 그에 딸린 메시지 인덱스, 실행 단계, 체크포인트, 검색 문서를 영구 삭제하고
+// 삭제한 행 수와 메시지 저장소 경로, 삭제한 작업을 반환합니다. 대기 중이거나 실행 중인 작업은 건너뜁니다.
+func (m *MemoryStore) DeleteTasks(_ context.Context, taskIDs []string) (*TaskUsage, []Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var (
+		ids     []string
+		deleted []Task
+	)
+	for _, id := range uniqueStrings(taskIDs) {
+		task, ok := m.tasks[id]
+		if !ok || task.Status == TaskStatusPending || task.Status == TaskStatusRunning {
+			continue
+		}
+		ids = append(ids, id)
+		deleted = append(deleted, copyTask(task))
+	}
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].ID < deleted[j].ID })
+
+	usage := m.deleteTaskData(ids)
+	for _, id := range ids {
+		delete(m.tasks, id)
+	}
+	usage.Tasks = int64(len(ids))
+	return usage, deleted, nil
+}
+
+// deleteTaskData는 작업들의 메시지 인덱스, 실행 단계, 체크포인트, 검색 문서를 삭제합니다. 작업은 지우지 않습니다.
+func (m *MemoryStore) deleteTaskData(taskIDs []string) *TaskUsage {
+	usage := &TaskUsage{}
+	var messages []MessageIndex
+	for _, id := range taskIDs {
+		messages = append(messages, m.messages[id]...)
+		usage.RunSteps += int64(len(m.runSteps[id]))
+		usage.Checkpoints += int64(len(m.checkpoints[id]))
+		usage.SearchDocuments += int64(len(m.searchDocs[id]))
+		delete(m.messages, id)
+		delete(m.runSteps, id)
+		delete(m.checkpoints, id)
+		delete(m.searchDocs, id)
+	}
+	usage.Messages = int64(len(messages))
+	usage.Files = messageFiles(messages)
+	return usage
+}
+
+// messageFiles는 메시지를 추가된 순서로 정렬해 저장소 경로를 반환합니다.
+func messageFiles(messages []MessageIndex) []string {
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	var files []string
+	for _, msg := range messages {
+		files = append(files, msg.FilePath)
+	}
+	return files
+}
+
+// GetNextConversationIndex는 해당 Task의 다음 ConversationIndex를 반환합니다. 작업이 없으면 0입니다.
+func (m *MemoryStore) GetNextConversationIndex(_ context.Context, taskID string) (int, error) {
+	if taskID == "" {
+		return 0, fmt.Errorf("storage: empty taskID")
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if task, ok := m.tasks[taskID]; ok {
+		return task.NextMessageIndex, nil
+	}
+	return 0, nil
+}
+
+// AppendMessageIndex는 작업의 메시지 카운터로 인덱스를 할당해 새 메시지를 대화에 추가합니다.
+// 작업이 없으면 ErrNotFound를 반환합니다.
+func (m *MemoryStore) AppendMessageIndex(_ context.Context, taskID, role, filePath string) (*MessageIndex, error) {
+	if taskID == "" {
+		return nil, fmt.Errorf("storage: empty taskID")
+	}
+	if role == "" {
+		return nil, fmt.Errorf("storage: empty role")
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("storage: empty filePath")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.appendMessageIndex(taskID, role, filePath)
+}
+
+// appendMessageIndex는 카운터로 인덱스를 할당하고, 그 인덱스에 남아 있는 대체 버전 다음 revision으로 메시지를 추가합니다.
+// m.mu를 잡고 호출합니다.
+func (m *MemoryStore) appendMessageIndex(taskID, role, filePath string) (*MessageIndex, error) {
+	task, ok := m.tasks[taskID]
+	if !ok {
+		return nil, fmt.Errorf("storage: task %s: %w", taskID, ErrNotFound)
+	}
+	revision := 1
+	for _, msg := range m.messages[taskID] {
+		if msg.ConversationIndex == task.NextMessageIndex && msg.Revision >= revision {
+			revision = msg.Revision + 1
+		}
+	}
+	now := time.Now().UTC()
+	msg := MessageIndex{
+		ID:                m.nextID(),
+		TaskID:            taskID,
+		ConversationIndex: task.NextMessageIndex,
+		Role:              role,
+		FilePath:          filePath,
+		Revision:          revision,
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	task.NextMessageIndex++
+	m.messages[taskID] = append(m.messages[taskID], msg)
+	return &msg, nil
+}
+
+// ListMessageIndexByTask는 작업의 현재 대화에 속한 메시지 참조 목록을 순서대로 반환합니다.
+func (m *MemoryStore) ListMessageIndexByTask(_ context.Context, taskID string) ([]MessageIndex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var rows []MessageIndex
+	for _, msg := range m.messages[taskID] {
+		if msg.SupersededAt == nil {
+			rows = append(rows, msg)
+		}
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].ConversationIndex < rows[j].ConversationIndex })
+	return rows, nil
+}
+
+// ListMessageVersions는 대체된 버전을 포함한 작업의 모든 메시지 참조를 대화 인덱스, revision 순으로 반환합니다.
+func (m *MemoryStore) ListMessageVersions(_ context.Context, taskID string) ([]MessageIndex, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	rows := slices.Clone(m.messages[taskID])
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].ConversationIndex != rows[j].ConversationIndex {
+			return rows[i].ConversationIndex < rows[j].ConversationIndex
+		}
+		return rows[i].Revision < rows[j].Revision
+	})
+	return rows, nil
+}
+
+// RewindMessages는 fromIndex 이후의 현재 메시지를 대체된 버전으로 표시하고 메시지 카운터를 fromIndex로 되돌리며,
+// task.Version을 확인해 작업을 pending으로 되돌립니다. 실패하면 아무것도 바꾸지 않습니다.
+func (m *MemoryStore) RewindMessages(_ context.Context, task *Task, fromIndex int) (int, error) {
+	if task == nil {
+		return 0, fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return 0, fmt.Errorf("storage: empty taskID")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := checkVersion(m.tasks, "task", task.TaskID, task.Version, func(t *Task) int { return t.Version })
+	if err != nil {
+		return 0, err
+	}
+	rewound, err := m.rewindMessages(task.TaskID, fromIndex)
+	if err != nil {
+		return 0, err
+	}
+	m.reopenTask(current, task)
+	return rewound, nil
+}
+
+// ReviseMessage는 index의 현재 메시지를 새 버전으로 바꾸고 RewindMessages와 같이 작업을 pending으로 되돌립니다.
+// index에 현재 메시지가 없으면 ErrNotFound를 반환합니다.
+func (m *MemoryStore) ReviseMessage(_ context.Context, task *Task, index int, role, filePath string) (*MessageIndex, error) {
+	if task == nil {
+		return nil, fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return nil, fmt.Errorf("storage: empty taskID")
+	}
+	if role == "" {
+		return nil, fmt.Errorf("storage: empty role")
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("storage: empty filePath")
+	}
+	taskID := task.TaskID
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	current, err := checkVersion(m.tasks, "task", taskID, task.Version, func(t *Task) int { return t.Version })
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(m.messages[taskID], func(msg MessageIndex) bool {
+		return msg.ConversationIndex == index && msg.SupersededAt == nil
+	}) {
+		return nil, fmt.Errorf("storage: message %d of task %s: %w", index, taskID, ErrNotFound)
+	}
+	if _, err := m.rewindMessages(taskID, index); err != nil {
+		return nil, err
+	}
+	revised, err := m.appendMessageIndex(taskID, role, filePath)
+	if err != nil {
+		return nil, err
+	}
+	m.reopenTask(current, task)
+	return revised, nil
+}
+
+// reopenTask는 current를 pending으로 바꾸고 버전을 올립니다. m.mu를 잡고 호출합니다.
+func (m *MemoryStore) reopenTask(current, task *Task) {
+	current.Status = TaskStatusPending
+	current.UpdatedAt = time.Now()
+	current.Version++
+	task.Status = TaskStatusPending
+	task.Version = current.Version
+}
+
+// rewindMessages는 메시지를 대체하고 카운터를 되돌립니다. m.mu를 잡고 호출합니다.
+func (m *MemoryStore) rewindMessages(taskID string, fromIndex int) (int, error) {
+	if fromIndex < 0 {
+		return 0, fmt.Errorf("storage: invalid conversation index %d", fromIndex)
+	}
+	task, ok := m.tasks[taskID]
+	if !ok || task.NextMessageIndex < fromIndex {
+		return 0, fmt.Errorf("storage: task %s has no message %d: %w", taskID, fromIndex, ErrNotFound)
+	}
+	task.NextMessageIndex = fromIndex
+	now := time.Now().UTC()
+	rewound := 0
+	messages := m.messages[taskID]
+	for i := range messages {
+		if messages[i].ConversationIndex >= fromIndex && messages[i].SupersededAt == nil {
+			superseded := now
+			messages[i].SupersededAt = &superseded
+			messages[i].UpdatedAt = now
+			rewound++
+		}
+	}
+	return rewound, nil
+}
+
+// DeleteMessage는 index의 메시지를 대체된 버전까지 모두 영구 삭제하고, 그 메시지의 검색 문서도 지웁니다.
+func (m *MemoryStore) DeleteMessage(_ context.Context, taskID string, index int) ([]MessageIndex, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted, kept []MessageIndex
+	for _, msg := range m.messages[taskID] {
+		if msg.ConversationIndex == index {
+			deleted = append(deleted, msg)
+		} else {
+			kept = append(kept, msg)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil, fmt.Errorf("storage: message %d of task %s: %w", index, taskID, ErrNotFound)
+	}
+	m.messages[taskID] = kept
+	sort.Slice(deleted, func(i, j int) bool { return deleted[i].Revision < deleted[j].Revision })
+	m.searchDocs[taskID] = slices.DeleteFunc(m.searchDocs[taskID], func(doc SearchDocument) bool {
+		return doc.Source == SearchSourceMessage && slices.ContainsFunc(deleted, func(msg MessageIndex) bool { return msg.FilePath == doc.Ref })
+	})
+	return deleted, nil
+}
+
+// UpsertRunStep은 실행 단계를 생성하거나, 같은 번호의 단계가 있으면 생성 시각을 제외한 모든 필드를 덮어씁니다.
+func (m *MemoryStore) UpsertRunStep(_ context.Context, step *RunStep) error {
+	if step == nil {
+		return fmt.Errorf("storage: nil run step payload")
+	}
+	if step.CreatedAt.IsZero() {
+		step.CreatedAt = time.Now().UTC()
+	}
+	step.fillDuration()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	steps := m.runSteps[step.TaskID]
+	for i := range steps {
+		if steps[i].StepNo == step.StepNo {
+			updated := cloneRunStep(*step)
+			updated.ID, updated.CreatedAt = steps[i].ID, steps[i].CreatedAt
+			steps[i] = updated
+			step.ID = updated.ID
+			return nil
+		}
+	}
+	step.ID = m.nextID()
+	m.runSteps[step.TaskID] = append(steps, cloneRunStep(*step))
+	return nil
+}
+
+// copyAgent와 copyTask는 레이블 맵까지 복사해 저장된 레코드와 호출자의 값이 공유되지 않도록 합니다.
+func copyAgent(agent *Agent) Agent {
+	copied := *agent
+	copied.Labels = maps.Clone(agent.Labels)
+	return copied
+}
+
+func copyTask(task *Task) Task {
+	copied := *task
+	copied.Labels = maps.Clone(task.Labels)
+	return copied
+}
+
+// cloneRunStep은 시각 포인터까지 복사해 저장된 단계와 호출자의 값이 공유되지 않도록 합니다.
+func cloneRunStep(step RunStep) RunStep {
+	for _, t := range []**time.Time{&step.StartedAt, &step.FinishedAt} {
+		if *t != nil {
+			copied := **t
+			*t = &copied
+		}
+	}
+	return step
+}
+
+// ListRunSteps는 작업별 실행 단계 목록을 번호 순으로 반환합니다.
+func (m *MemoryStore) ListRunSteps(_ context.Context, taskID string) ([]RunStep, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var steps []RunStep
+	for _, step := range m.runSteps[taskID] {
+		steps = append(steps, cloneRunStep(step))
+	}
+	sort.Slice(steps, func(i, j int) bool { return steps[i].StepNo < steps[j].StepNo })
+	return steps, nil
+}
+
+// CreateCheckpoint는 작업에 대한 체크포인트를 기록합니다. 같은 Git 해시가 있으면 무시합니다.
+func (m *MemoryStore) CreateCheckpoint(_ context.Context, checkpoint *Checkpoint) error {
+	if checkpoint == nil {
+		return fmt.Errorf("storage: nil checkpoint payload")
+	}
+	if checkpoint.CreatedAt.IsZero() {
+		checkpoint.CreatedAt = time.Now().UTC()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	checkpoints := m.checkpoints[checkpoint.TaskID]
+	for _, existing := range checkpoints {
+		if existing.GitHash == checkpoint.GitHash {
+			return nil
+		}
+	}
+	checkpoint.ID = m.nextID()
+	m.checkpoints[checkpoint.TaskID] = append(checkpoints, *checkpoint)
+	return nil
+}
+
+// ListCheckpoints는 작업별 체크포인트를 생성 시간 순으로 반환합니다.
+func (m *MemoryStore) ListCheckpoints(_ context.Context, taskID string) ([]Checkpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	checkpoints := slices.Clone(m.checkpoints[taskID])
+	sortByCreated(checkpoints, func(c *Checkpoint) (time.Time, int64) { return c.CreatedAt, c.ID })
+	return checkpoints, nil
+}
+
+// SetLabels는 대상의 레이블을 labels로 바꿉니다. 대상이 없으면 ErrNotFound를 반환합니다.
+func (m *MemoryStore) SetLabels(_ context.Context, targetType, targetID string, labels map[string]string) error {
+	if err := ValidateLabels(labels); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var target *map[string]string
+	switch targetType {
+	case LabelTargetAgent:
+		if agent, ok := m.agents[targetID]; ok {
+			target = &agent.Labels
+		}
+	case LabelTargetTask:
+		if task, ok := m.tasks[targetID]; ok {
+			target = &task.Labels
+		}
+	default:
+		return fmt.Errorf("storage: unsupported label target %q", targetType)
+	}
+	if target == nil {
+		return fmt.Errorf("storage: %s %s: %w", targetType, targetID, ErrNotFound)
+	}
+	*target = nil
+	if len(labels) > 0 {
+		*target = maps.Clone(labels)
+	}
+	return nil
+}
+
+// CreateAuditEvent는 감사 로그를 추가합니다.
+func (m *MemoryStore) CreateAuditEvent(_ context.Context, event *AuditEvent) error {
+	if event == nil {
+		return fmt.Errorf("storage: nil audit event payload")
+	}
+	if event.Action == "" {
+		return fmt.Errorf("storage: empty audit action")
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = m.nextID()
+	m.audit = append(m.audit, *event)
+	return nil
+}
+
+// ListAuditEvents는 조건에 맞는 감사 로그를 발생 순서대로 반환합니다.
+// Limit이 지정되면 가장 최근 이벤트부터 Limit개만 반환합니다.
+func (m *MemoryStore) ListAuditEvents(_ context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	m.mu.RLock()
+	var events []AuditEvent
+	for _, event := range m.audit {
+		if filter.AgentID != "" && event.AgentID != filter.AgentID {
+			continue
+		}
+		if filter.Actor != "" && event.Actor != filter.Actor {
+			continue
+		}
+		if filter.Action != "" && event.Action != filter.Action {
+			continue
+		}
+		if !inCreatedRange(event.CreatedAt, filter.Since, filter.Until) {
+			continue
+		}
+		events = append(events, event)
+	}
+	m.mu.RUnlock()
+
+	sortByCreated(events, func(e *AuditEvent) (time.Time, int64) { return e.CreatedAt, e.ID })
+	if filter.Limit > 0 && len(events) > filter.Limit {
+		events = events[len(events)-filter.Limit:]
+	}
+	return events, nil
+}
+
+// paginateRows는 paginate의 메모리 버전입니다. rows를 정렬하고 커서 다음 행부터 limit개를 반환합니다.
+func paginateRows[T any](rows []T, sortSpec string, limit int, rawCursor string, key func(*T) keyset) ([]T, string, error) {
+	p, err := parsePage(sortSpec, rawCursor)
+	if err != nil {
+		return nil, "", err
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	// 내림차순이면 비교 결과를 뒤집어 정렬과 커서 조건을 함께 처리합니다.
+	order := func(a, b keyset) int {
+		if p.desc {
+			return p.compare(b, a)
+		}
+		return p.compare(a, b)
+	}
+	sort.Slice(rows, func(i, j int) bool { return order(key(&rows[i]), key(&rows[j])) < 0 })
+	if p.after != nil {
+		start := sort.Search(len(rows), func(i int) bool { return order(key(&rows[i]), *p.after) > 0 })
+		rows = rows[start:]
+	}
+	if limit <= 0 || len(rows) <= limit {
+		return rows, "", nil
+	}
+	rows = rows[:limit]
+	return rows, p.nextCursor(key(&rows[limit-1])), nil
+}
+
+// sortByCreated는 rows를 생성 시각, 같으면 ID 순으로 정렬합니다.
+func sortByCreated[T any](rows []T, key func(*T) (time.Time, int64)) {
+	sort.Slice(rows, func(i, j int) bool {
+		ti, idi := key(&rows[i])
+		tj, idj := key(&rows[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return idi < idj
+	})
+}
+
+// inCreatedRange는 t가 [after, before) 범위에 있는지 확인합니다. 비어 있는 경계는 무시합니다.
+func inCreatedRange(t, after, before time.Time) bool {
+	if !after.IsZero() && t.Before(after) {
+		return false
+	}
+	if !before.IsZero() && !t.Before(before) {
+		return false
+	}
+	return true
+}
+
+// uniqueStrings는 values에서 중복을 제거합니다.
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	var out []string
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
@@ -0,0 +1,388 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// ErrSchemaVersionMismatch는 데이터베이스 스키마 버전이 바이너리가 기대하는 버전과 다를 때 반환됩니다.
+var ErrSchemaVersionMismatch = errors.New("storage: schema version mismatch")
+
+const (
+	dialectSQLite   = "sqlite"
+	dialectPostgres = "postgres"
+
+	schemaMigrationsTable = "schema_migrations"
+
+	// migrationLockKey는 PostgreSQL advisory lock 키입니다 ("cnap").
+	migrationLockKey = 0x636e6170
+	// sqliteBusyTimeout은 SQLite에서 다른 프로세스의 마이그레이션을 기다리는 최대 시간입니다.
+	sqliteBusyTimeout = 30 * time.Second
+)
+
+var migrationFileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// fallbackMarker는 up 파일을 기본 SQL과 대체 SQL로 나누는 줄입니다.
+const fallbackMarker = "-- +cnap:fallback"
+
+// Migration은 번호가 붙은 스키마 변경 한 단계입니다.
+// Fallback이 있으면 Up이 실패했을 때 Up의 변경을 되돌리고 Fallback을 대신 적용합니다.
+// 빌드 옵션에 따라 없을 수 있는 기능(예: SQLite FTS5)을 대체할 때 사용합니다.
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Fallback string
+	Down     string
+}
+
+// MigrationStatus는 마이그레이션의 적용 여부를 나타냅니다.
+type MigrationStatus struct {
+	Version   int
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// LoadMigrations는 dialect(sqlite, postgres)용으로 내장된 마이그레이션을 버전 순으로 반환합니다.
+// 버전은 1부터 빈 번호 없이 이어져야 하며, 모든 버전에 up/down 파일이 있어야 합니다.
+func LoadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("storage: unsupported migration dialect %q", dialect)
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationFileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("storage: invalid migration file name %s", entry.Name())
+		}
+		version, _ := strconv.Atoi(match[1])
+		data, err := fs.ReadFile(migrationFiles, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("storage: read migration %s: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		} else if m.Name != match[2] {
+			return nil, fmt.Errorf("storage: migration %d has conflicting names %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.Up, m.Fallback = splitFallback(string(data))
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("storage: migration %d is missing", i+1)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("storage: migration %d_%s needs both up and down files", m.Version, m.Name)
+		}
+	}
+	return migrations, nil
+}
+
+// Migrator는 schema_migrations 테이블로 적용 이력을 관리하며 내장 마이그레이션을 실행합니다.
+type Migrator struct {
+	db         *gorm.DB
+	sqlDB      *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+// NewMigrator는 db의 드라이버에 맞는 마이그레이션으로 Migrator를 생성합니다.
+func NewMigrator(db *gorm.DB) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("storage: nil database handle")
+	}
+	dialect := db.Dialector.Name()
+	migrations, err := LoadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("storage: get sql.DB: %w", err)
+	}
+	return &Migrator{db: db, sqlDB: sqlDB, dialect: dialect, migrations: migrations}, nil
+}
+
+// Latest는 바이너리에 내장된 최신 스키마 버전을 반환합니다.
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version은 데이터베이스에 적용된 스키마 버전을 반환합니다. 적용 이력이 없으면 0입니다.
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	applied, err := m.committedVersions(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return maxVersion(applied), nil
+}
+
+// CheckVersion은 데이터베이스 스키마가 최신 버전인지 확인합니다.
+func (m *Migrator) CheckVersion(ctx context.Context) error {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if version != m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, binary expects %d", ErrSchemaVersionMismatch, version, m.Latest())
+	}
+	return nil
+}
+
+// Status는 모든 마이그레이션의 적용 여부를 버전 순으로 반환합니다.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.committedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		appliedAt, ok := applied[mig.Version]
+		statuses = append(statuses, MigrationStatus{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: appliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Up은 적용되지 않은 마이그레이션을 모두 적용하고, 적용한 마이그레이션을 반환합니다.
+// 전체가 하나의 트랜잭션으로 실행되므로 실패하면 아무것도 적용되지 않습니다.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		current := maxVersion(applied)
+		if current > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, newer than binary version %d", ErrSchemaVersionMismatch, current, m.Latest())
+		}
+		for _, mig := range m.migrations[current:] {
+			if err := applyUp(ctx, conn, mig); err != nil {
+				return fmt.Errorf("storage: apply migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			insert := fmt.Sprintf("INSERT INTO %s (version, name, applied_at) VALUES (%s, %s, %s)",
+				schemaMigrationsTable, m.placeholder(1), m.placeholder(2), m.placeholder(3))
+			if _, err := conn.ExecContext(ctx, insert, mig.Version, mig.Name, time.Now().UTC()); err != nil {
+				return fmt.Errorf("storage: record migration %d: %w", mig.Version, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// Down은 최근에 적용된 마이그레이션부터 steps개를 되돌리고, 되돌린 마이그레이션을 반환합니다.
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	if steps < 1 {
+		return nil, fmt.Errorf("storage: steps must be positive")
+	}
+	var done []Migration
+	err := m.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := m.appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		current := maxVersion(applied)
+		if current > m.Latest() {
+			return fmt.Errorf("%w: database is at version %d, newer than binary version %d", ErrSchemaVersionMismatch, current, m.Latest())
+		}
+		for version := current; version > 0 && len(done) < steps; version-- {
+			mig := m.migrations[version-1]
+			if _, err := conn.ExecContext(ctx, mig.Down); err != nil {
+				return fmt.Errorf("storage: revert migration %d_%s: %w", mig.Version, mig.Name, err)
+			}
+			remove := fmt.Sprintf("DELETE FROM %s WHERE version = %s", schemaMigrationsTable, m.placeholder(1))
+			if _, err := conn.ExecContext(ctx, remove, mig.Version); err != nil {
+				return fmt.Errorf("storage: remove migration record %d: %w", mig.Version, err)
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return done, nil
+}
+
+// withLock은 한 인스턴스만 마이그레이션하도록 잠금을 잡은 트랜잭션 안에서 fn을 실행합니다.
+// PostgreSQL은 세션 advisory lock을, SQLite는 BEGIN IMMEDIATE의 쓰기 잠금을 사용하며
+// 두 잠금 모두 프로세스가 비정상 종료되면 자동으로 해제됩니다.
+func (m *Migrator) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("storage: acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	begin := "BEGIN"
+	switch m.dialect {
+	case dialectPostgres:
+		if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockKey); err != nil {
+			return fmt.Errorf("storage: acquire migration lock: %w", err)
+		}
+		defer func() {
+			_, _ = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockKey)
+		}()
+	case dialectSQLite:
+		pragma := fmt.Sprintf("PRAGMA busy_timeout = %d", sqliteBusyTimeout.Milliseconds())
+		if _, err := conn.ExecContext(ctx, pragma); err != nil {
+			return fmt.Errorf("storage: set busy timeout: %w", err)
+		}
+		begin = "BEGIN IMMEDIATE"
+	}
+
+	if _, err := conn.ExecContext(ctx, begin); err != nil {
+		return fmt.Errorf("storage: acquire migration lock: %w", err)
+	}
+	create := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    version    BIGINT PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`, schemaMigrationsTable)
+	if _, err := conn.ExecContext(ctx, create); err != nil {
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		return fmt.Errorf("storage: create %s: %w", schemaMigrationsTable, err)
+	}
+	if err := fn(conn); err != nil {
+		_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return fmt.Errorf("storage: commit migrations: %w", err)
+	}
+	return nil
+}
+
+// applyUp은 마이그레이션의 up SQL을 실행합니다. Fallback이 있으면 savepoint 안에서 Up을 먼저 시도합니다.
+func applyUp(ctx context.Context, conn *sql.Conn, mig Migration) error {
+	if mig.Fallback == "" {
+		_, err := conn.ExecContext(ctx, mig.Up)
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "SAVEPOINT cnap_migration"); err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, mig.Up); err != nil {
+		if _, rbErr := conn.ExecContext(ctx, "ROLLBACK TO SAVEPOINT cnap_migration"); rbErr != nil {
+			return rbErr
+		}
+		if _, fbErr := conn.ExecContext(ctx, mig.Fallback); fbErr != nil {
+			return fmt.Errorf("%w (fallback after: %v)", fbErr, err)
+		}
+	}
+	_, err := conn.ExecContext(ctx, "RELEASE SAVEPOINT cnap_migration")
+	return err
+}
+
+// splitFallback은 up SQL을 fallbackMarker 줄 앞뒤로 나눕니다.
+func splitFallback(up string) (string, string) {
+	lines := strings.Split(up, "\n")
+	for i, line := range lines {
+		if strings.TrimSpace(line) == fallbackMarker {
+			return strings.Join(lines[:i], "\n"), strings.Join(lines[i+1:], "\n")
+		}
+	}
+	return up, ""
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// committedVersions는 잠금 없이 적용 이력을 읽습니다. 이력 테이블이 없으면 빈 맵을 반환합니다.
+func (m *Migrator) committedVersions(ctx context.Context) (map[int]time.Time, error) {
+	if !m.db.WithContext(ctx).Migrator().HasTable(schemaMigrationsTable) {
+		return map[int]time.Time{}, nil
+	}
+	return m.appliedVersions(ctx, m.sqlDB)
+}
+
+// appliedVersions는 적용된 버전과 적용 시각을 반환합니다.
+func (m *Migrator) appliedVersions(ctx context.Context, q queryer) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM "+schemaMigrationsTable)
+	if err != nil {
+		return nil, fmt.Errorf("storage: read %s: %w", schemaMigrationsTable, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			version   int
+			appliedAt time.Time
+		)
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("storage: scan %s: %w", schemaMigrationsTable, err)
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func (m *Migrator) placeholder(n int) string {
+	if m.dialect == dialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
+
+func maxVersion(applied map[int]time.Time) int {
+	current := 0
+	for version := range applied {
+		if version > current {
+			current = version
+		}
+	}
+	return current
+}
+
+// MigrateUp은 내장 마이그레이션을 최신 버전까지 적용합니다.
+func MigrateUp(ctx context.Context, db *gorm.DB) error {
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = migrator.Up(ctx)
+	return err
+}
@@ -0,0 +1,59 @@
+-- 초기 스키마: 마이그레이션 도입 전 GORM AutoMigrate로 생성되던 테이블과 동일합니다.
+-- 기존 데이터베이스에서도 안전하게 적용되도록 IF NOT EXISTS를 사용합니다.
+-- 이미 있는 테이블은 건너뛰므로, 이후에 추가한 컬럼과 테이블은 반드시 별도 마이그레이션에 둡니다.
+
+CREATE TABLE IF NOT EXISTS agents (
+    id          BIGSERIAL PRIMARY KEY,
+    agent_id    VARCHAR(64) NOT NULL,
+    description TEXT,
+    model       VARCHAR(64),
+    prompt      TEXT,
+    status      VARCHAR(32) NOT NULL DEFAULT 'active',
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_agent_id ON agents (agent_id);
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id         BIGSERIAL PRIMARY KEY,
+    task_id    VARCHAR(64) NOT NULL,
+    agent_id   VARCHAR(64) NOT NULL,
+    prompt     TEXT,
+    status     VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_task_id ON tasks (task_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_agent_id ON tasks (agent_id);
+
+CREATE TABLE IF NOT EXISTS msg_index (
+    id                 BIGSERIAL PRIMARY KEY,
+    task_id            VARCHAR(64) NOT NULL,
+    conversation_index INTEGER NOT NULL,
+    role               VARCHAR(32) NOT NULL,
+    file_path          TEXT NOT NULL,
+    created_at         TIMESTAMPTZ NOT NULL,
+    updated_at         TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_msg_index_task ON msg_index (task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_msg_idx_task_conv ON msg_index (task_id, conversation_index);
+
+CREATE TABLE IF NOT EXISTS run_steps (
+    id         BIGSERIAL PRIMARY KEY,
+    task_id    VARCHAR(64) NOT NULL,
+    step_no    INTEGER NOT NULL,
+    type       VARCHAR(32) NOT NULL,
+    status     VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_run_steps_task ON run_steps (task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_run_steps_task_step ON run_steps (task_id, step_no);
+
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id         BIGSERIAL PRIMARY KEY,
+    task_id    VARCHAR(64) NOT NULL,
+    git_hash   VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_checkpoints_task ON checkpoints (task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkpoints_task_git ON checkpoints (task_id, git_hash);
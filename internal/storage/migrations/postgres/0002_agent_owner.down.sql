@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_agents_owner_id;
+ALTER TABLE agents DROP COLUMN owner_id;
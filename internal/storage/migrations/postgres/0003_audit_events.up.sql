@@ -0,0 +1,18 @@
+-- 컨트롤러의 쓰기 작업을 기록하는 감사 로그입니다.
+
+CREATE TABLE audit_events (
+    id           BIGSERIAL PRIMARY KEY,
+    actor        VARCHAR(128) NOT NULL,
+    action       VARCHAR(64) NOT NULL,
+    target_type  VARCHAR(32) NOT NULL,
+    target_id    VARCHAR(64) NOT NULL,
+    agent_id     VARCHAR(64),
+    before_state TEXT,
+    after_state  TEXT,
+    created_at   TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_audit_events_actor ON audit_events (actor);
+CREATE INDEX idx_audit_events_action ON audit_events (action);
+CREATE INDEX idx_audit_events_target ON audit_events (target_type, target_id);
+CREATE INDEX idx_audit_events_agent ON audit_events (agent_id);
+CREATE INDEX idx_audit_events_created_at ON audit_events (created_at);
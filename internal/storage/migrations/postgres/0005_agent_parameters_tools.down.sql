@@ -0,0 +1,4 @@
+ALTER TABLE agent_revisions DROP COLUMN tools;
+ALTER TABLE agent_revisions DROP COLUMN parameters;
+ALTER TABLE agents DROP COLUMN tools;
+ALTER TABLE agents DROP COLUMN parameters;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_tasks_status_created;
+DROP INDEX IF EXISTS idx_tasks_agent_created;
+DROP INDEX IF EXISTS idx_tasks_updated;
+DROP INDEX IF EXISTS idx_tasks_created;
+DROP INDEX IF EXISTS idx_agents_model;
+DROP INDEX IF EXISTS idx_agents_status_created;
+DROP INDEX IF EXISTS idx_agents_created;
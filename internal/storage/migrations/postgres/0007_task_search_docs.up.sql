@@ -0,0 +1,18 @@
+-- 작업 검색 인덱스의 문서입니다. 작업 프롬프트와 메시지 본문이 한 행씩 저장되며,
+-- tasks와 메시지 저장소에서 다시 만들 수 있는 파생 데이터입니다.
+
+CREATE TABLE task_search_docs (
+    id         BIGSERIAL PRIMARY KEY,
+    task_id    VARCHAR(64) NOT NULL,
+    agent_id   VARCHAR(64) NOT NULL,
+    source     VARCHAR(16) NOT NULL,
+    ref        TEXT NOT NULL DEFAULT '',
+    body       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+CREATE INDEX idx_search_docs_task ON task_search_docs (task_id);
+CREATE UNIQUE INDEX idx_search_docs_source ON task_search_docs (task_id, source, ref);
+
+-- 기존 작업의 프롬프트를 색인합니다. 메시지 본문은 'cnap task reindex'로 색인합니다.
+INSERT INTO task_search_docs (task_id, agent_id, source, ref, body, created_at)
+SELECT task_id, agent_id, 'prompt', '', prompt, created_at FROM tasks WHERE prompt IS NOT NULL AND prompt <> '';
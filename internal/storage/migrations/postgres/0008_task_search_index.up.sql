@@ -0,0 +1,4 @@
+-- 작업 검색용 전문 검색 인덱스입니다.
+-- 한국어에는 형태소 사전이 없으므로 어간 추출 없이 'simple' 설정으로 단어를 나누고 접두사로 검색합니다.
+
+CREATE INDEX idx_search_docs_fts ON task_search_docs USING GIN (to_tsvector('simple', body));
@@ -0,0 +1,9 @@
+-- 작업별 다음 대화 인덱스 카운터입니다. 메시지를 추가할 때 같은 트랜잭션에서 증가시켜
+-- 동시에 추가된 메시지가 같은 인덱스를 받지 않도록 합니다.
+
+ALTER TABLE tasks ADD COLUMN next_message_index INTEGER NOT NULL DEFAULT 0;
+
+UPDATE tasks SET next_message_index = COALESCE(
+    (SELECT MAX(m.conversation_index) + 1 FROM msg_index m WHERE m.task_id = tasks.task_id),
+    0
+);
@@ -0,0 +1,11 @@
+ALTER TABLE run_steps DROP COLUMN duration_ms;
+ALTER TABLE run_steps DROP COLUMN finished_at;
+ALTER TABLE run_steps DROP COLUMN started_at;
+ALTER TABLE run_steps DROP COLUMN output_tokens;
+ALTER TABLE run_steps DROP COLUMN input_tokens;
+ALTER TABLE run_steps DROP COLUMN error_class;
+ALTER TABLE run_steps DROP COLUMN error_message;
+ALTER TABLE run_steps DROP COLUMN output_ref;
+ALTER TABLE run_steps DROP COLUMN input_ref;
+ALTER TABLE run_steps DROP COLUMN model;
+ALTER TABLE run_steps DROP COLUMN parent_step_no;
@@ -0,0 +1,7 @@
+ALTER TABLE tasks DROP COLUMN finished_at;
+ALTER TABLE tasks DROP COLUMN started_at;
+ALTER TABLE tasks DROP COLUMN attempts;
+ALTER TABLE tasks DROP COLUMN result_model;
+ALTER TABLE tasks DROP COLUMN error_class;
+ALTER TABLE tasks DROP COLUMN error_message;
+ALTER TABLE tasks DROP COLUMN result_ref;
@@ -0,0 +1,10 @@
+-- 작업의 최종 결과입니다. 출력 본문은 메시지 저장소에 두고 키만 result_ref에 기록합니다.
+-- attempts는 실행을 시작한 횟수이며, result_model은 실제로 응답한 모델입니다.
+
+ALTER TABLE tasks ADD COLUMN result_ref TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN error_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN error_class VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN result_model VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN started_at TIMESTAMPTZ;
+ALTER TABLE tasks ADD COLUMN finished_at TIMESTAMPTZ;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_tasks_parent_task_id;
+
+ALTER TABLE tasks DROP COLUMN model;
+ALTER TABLE tasks DROP COLUMN fork_index;
+ALTER TABLE tasks DROP COLUMN parent_task_id;
@@ -0,0 +1,8 @@
+-- 대화 분기(fork)로 만든 작업의 계보입니다. parent_task_id가 비어 있으면 분기한 작업이 아닙니다.
+-- fork_index는 부모 작업에서 복사한 마지막 대화 인덱스이고, model은 에이전트 모델 대신 사용할 모델입니다.
+
+ALTER TABLE tasks ADD COLUMN parent_task_id VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN fork_index INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN model VARCHAR(64) NOT NULL DEFAULT '';
+
+CREATE INDEX idx_tasks_parent_task_id ON tasks (parent_task_id);
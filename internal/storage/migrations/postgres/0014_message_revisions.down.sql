@@ -0,0 +1,8 @@
+-- 대체 버전은 되돌릴 수 없으므로 지웁니다. 본문은 메시지 저장소에 남습니다.
+DELETE FROM msg_index WHERE superseded_at IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_msg_idx_task_conv;
+CREATE UNIQUE INDEX idx_msg_idx_task_conv ON msg_index (task_id, conversation_index);
+
+ALTER TABLE msg_index DROP COLUMN superseded_at;
+ALTER TABLE msg_index DROP COLUMN revision;
@@ -0,0 +1,2 @@
+ALTER TABLE tasks DROP COLUMN variables;
+ALTER TABLE tasks DROP COLUMN system_prompt;
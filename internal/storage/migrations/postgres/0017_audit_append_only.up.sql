@@ -0,0 +1,14 @@
+-- 감사 로그를 DB에서도 추가만 가능하게 합니다. GORM 훅을 건너뛰는 경로(SkipHooks, Exec)의 수정, 삭제, TRUNCATE도 거부됩니다.
+-- 백업 복원(storage.TruncateTables)만 같은 트랜잭션 안에서 트리거를 잠시 비활성화합니다.
+
+CREATE OR REPLACE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_modify BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
@@ -0,0 +1,5 @@
+-- 에이전트 프롬프트는 Go 템플릿으로 렌더링되므로, 이전에 저장한 프롬프트의 "{{"는 템플릿 구문으로 해석되어
+-- 작업 생성이 실패합니다. 기존 프롬프트와 리비전의 "{{"를 그대로 출력되는 {{"{{"}}로 바꿉니다.
+
+UPDATE agents SET prompt = REPLACE(prompt, '{{', '{{"{{"}}') WHERE prompt LIKE '%{{%';
+UPDATE agent_revisions SET prompt = REPLACE(prompt, '{{', '{{"{{"}}') WHERE prompt LIKE '%{{%';
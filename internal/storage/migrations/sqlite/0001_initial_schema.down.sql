@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS checkpoints;
+DROP TABLE IF EXISTS run_steps;
+DROP TABLE IF EXISTS msg_index;
+DROP TABLE IF EXISTS tasks;
+DROP TABLE IF EXISTS agents;
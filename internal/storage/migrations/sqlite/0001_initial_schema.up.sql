@@ -0,0 +1,139 @@
+-- 초기 스키마: 마이그레이션 도입 전 GORM AutoMigrate로 생성되던 테이블과 동일합니다.
+-- 기존 데이터베이스에서도 안전하게 적용되도록 IF NOT EXISTS를 사용합니다.
+-- 이미 있는 테이블은 건너뛰므로, 이후에 추가한 컬럼과 테이블은 반드시 별도 마이그레이션에 둡니다.
+
+CREATE TABLE IF NOT EXISTS agents (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id    VARCHAR(64) NOT NULL,
+    description TEXT,
+    model       VARCHAR(64),
+    prompt      TEXT,
+    status      VARCHAR(32) NOT NULL DEFAULT 'active',
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_agents_agent_id ON agents (agent_id);
+
+CREATE TABLE IF NOT EXISTS tasks (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id    VARCHAR(64) NOT NULL,
+    agent_id   VARCHAR(64) NOT NULL,
+    prompt     TEXT,
+    status     VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tasks_task_id ON tasks (task_id);
+CREATE INDEX IF NOT EXISTS idx_tasks_agent_id ON tasks (agent_id);
+
+CREATE TABLE IF NOT EXISTS msg_index (
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id            VARCHAR(64) NOT NULL,
+    conversation_index INTEGER NOT NULL,
+    role               VARCHAR(32) NOT NULL,
+    file_path          TEXT NOT NULL,
+    created_at         DATETIME NOT NULL,
+    updated_at         DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_msg_index_task ON msg_index (task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_msg_idx_task_conv ON msg_index (task_id, conversation_index);
+
+CREATE TABLE IF NOT EXISTS run_steps (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id    VARCHAR(64) NOT NULL,
+    step_no    INTEGER NOT NULL,
+    type       VARCHAR(32) NOT NULL,
+    status     VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_run_steps_task ON run_steps (task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_run_steps_task_step ON run_steps (task_id, step_no);
+
+CREATE TABLE IF NOT EXISTS checkpoints (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id    VARCHAR(64) NOT NULL,
+    git_hash   VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_checkpoints_task ON checkpoints (task_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_checkpoints_task_git ON checkpoints (task_id, git_hash);
+
+-- AutoMigrate는 SQLite에서 id를 bigserial로 선언해 id가 rowid 별칭이 되지 않았고, 새 행의 id가 비어 있었습니다.
+-- 모든 테이블을 INTEGER PRIMARY KEY로 다시 만들고 기존 행을 옮깁니다. id가 없던 행에는 새 id가 부여됩니다.
+-- 새 데이터베이스에서는 위에서 만든 빈 테이블을 다시 만들 뿐입니다.
+
+CREATE TABLE agents_rebuild (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id    VARCHAR(64) NOT NULL,
+    description TEXT,
+    model       VARCHAR(64),
+    prompt      TEXT,
+    status      VARCHAR(32) NOT NULL DEFAULT 'active',
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+INSERT INTO agents_rebuild (id, agent_id, description, model, prompt, status, created_at, updated_at)
+SELECT id, agent_id, description, model, prompt, status, created_at, updated_at FROM agents ORDER BY id IS NULL, id;
+DROP TABLE agents;
+ALTER TABLE agents_rebuild RENAME TO agents;
+CREATE UNIQUE INDEX idx_agents_agent_id ON agents (agent_id);
+
+CREATE TABLE tasks_rebuild (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id    VARCHAR(64) NOT NULL,
+    agent_id   VARCHAR(64) NOT NULL,
+    prompt     TEXT,
+    status     VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL
+);
+INSERT INTO tasks_rebuild (id, task_id, agent_id, prompt, status, created_at, updated_at)
+SELECT id, task_id, agent_id, prompt, status, created_at, updated_at FROM tasks ORDER BY id IS NULL, id;
+DROP TABLE tasks;
+ALTER TABLE tasks_rebuild RENAME TO tasks;
+CREATE UNIQUE INDEX idx_tasks_task_id ON tasks (task_id);
+CREATE INDEX idx_tasks_agent_id ON tasks (agent_id);
+
+CREATE TABLE msg_index_rebuild (
+    id                 INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id            VARCHAR(64) NOT NULL,
+    conversation_index INTEGER NOT NULL,
+    role               VARCHAR(32) NOT NULL,
+    file_path          TEXT NOT NULL,
+    created_at         DATETIME NOT NULL,
+    updated_at         DATETIME NOT NULL
+);
+INSERT INTO msg_index_rebuild (id, task_id, conversation_index, role, file_path, created_at, updated_at)
+SELECT id, task_id, conversation_index, role, file_path, created_at, updated_at FROM msg_index ORDER BY id IS NULL, id;
+DROP TABLE msg_index;
+ALTER TABLE msg_index_rebuild RENAME TO msg_index;
+CREATE INDEX idx_msg_index_task ON msg_index (task_id);
+CREATE UNIQUE INDEX idx_msg_idx_task_conv ON msg_index (task_id, conversation_index);
+
+CREATE TABLE run_steps_rebuild (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id    VARCHAR(64) NOT NULL,
+    step_no    INTEGER NOT NULL,
+    type       VARCHAR(32) NOT NULL,
+    status     VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+INSERT INTO run_steps_rebuild (id, task_id, step_no, type, status, created_at)
+SELECT id, task_id, step_no, type, status, created_at FROM run_steps ORDER BY id IS NULL, id;
+DROP TABLE run_steps;
+ALTER TABLE run_steps_rebuild RENAME TO run_steps;
+CREATE INDEX idx_run_steps_task ON run_steps (task_id);
+CREATE UNIQUE INDEX idx_run_steps_task_step ON run_steps (task_id, step_no);
+
+CREATE TABLE checkpoints_rebuild (
+    id         INTEGER PRIMARY KEY AUTOINCREMENT,
+    task_id    VARCHAR(64) NOT NULL,
+    git_hash   VARCHAR(64) NOT NULL,
+    created_at DATETIME NOT NULL
+);
+INSERT INTO checkpoints_rebuild (id, task_id, git_hash, created_at)
+SELECT id, task_id, git_hash, created_at FROM checkpoints ORDER BY id IS NULL, id;
+DROP TABLE checkpoints;
+ALTER TABLE checkpoints_rebuild RENAME TO checkpoints;
+CREATE INDEX idx_checkpoints_task ON checkpoints (task_id);
+CREATE UNIQUE INDEX idx_checkpoints_task_git ON checkpoints (task_id, git_hash);
@@ -0,0 +1,4 @@
+-- 에이전트를 만든 사용자를 기록합니다. 이전에 만든 에이전트는 소유자가 없습니다.
+
+ALTER TABLE agents ADD COLUMN owner_id VARCHAR(128);
+CREATE INDEX idx_agents_owner_id ON agents (owner_id);
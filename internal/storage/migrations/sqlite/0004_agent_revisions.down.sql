@@ -0,0 +1,3 @@
+ALTER TABLE tasks DROP COLUMN agent_revision;
+ALTER TABLE agents DROP COLUMN revision;
+DROP TABLE IF EXISTS agent_revisions;
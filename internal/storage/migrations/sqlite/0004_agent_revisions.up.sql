@@ -0,0 +1,22 @@
+-- 에이전트 설정 변경 이력을 변경 불가능한 리비전으로 기록합니다.
+
+CREATE TABLE agent_revisions (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    agent_id    VARCHAR(64) NOT NULL,
+    revision    INTEGER NOT NULL,
+    description TEXT,
+    model       VARCHAR(64),
+    prompt      TEXT,
+    author      VARCHAR(128),
+    created_at  DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX idx_agent_revisions_agent_rev ON agent_revisions (agent_id, revision);
+
+ALTER TABLE agents ADD COLUMN revision INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE tasks ADD COLUMN agent_revision INTEGER NOT NULL DEFAULT 0;
+
+-- 기존 에이전트의 현재 설정을 첫 리비전으로 기록합니다.
+-- 이전에 생성된 작업은 어떤 리비전으로 실행되었는지 알 수 없으므로 0으로 남겨 둡니다.
+INSERT INTO agent_revisions (agent_id, revision, description, model, prompt, author, created_at)
+SELECT agent_id, 1, description, model, prompt, owner_id, updated_at FROM agents;
+UPDATE agents SET revision = 1;
@@ -0,0 +1,6 @@
+-- 에이전트 매니페스트의 parameters(JSON 객체)와 tools(JSON 배열)를 저장합니다.
+
+ALTER TABLE agents ADD COLUMN parameters TEXT;
+ALTER TABLE agents ADD COLUMN tools TEXT;
+ALTER TABLE agent_revisions ADD COLUMN parameters TEXT;
+ALTER TABLE agent_revisions ADD COLUMN tools TEXT;
@@ -0,0 +1,10 @@
+-- 목록 조회의 커서 기반 페이지네이션과 필터를 위한 인덱스입니다.
+-- 정렬 키 뒤에 id를 두어 같은 시각에 만들어진 행도 인덱스 순서로 읽습니다.
+
+CREATE INDEX IF NOT EXISTS idx_agents_created ON agents (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_agents_status_created ON agents (status, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_agents_model ON agents (model);
+CREATE INDEX IF NOT EXISTS idx_tasks_created ON tasks (created_at, id);
+CREATE INDEX IF NOT EXISTS idx_tasks_updated ON tasks (updated_at, id);
+CREATE INDEX IF NOT EXISTS idx_tasks_agent_created ON tasks (agent_id, created_at, id);
+CREATE INDEX IF NOT EXISTS idx_tasks_status_created ON tasks (status, created_at, id);
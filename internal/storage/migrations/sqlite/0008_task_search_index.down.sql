@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS task_search_docs_au;
+DROP TRIGGER IF EXISTS task_search_docs_ad;
+DROP TRIGGER IF EXISTS task_search_docs_ai;
+DROP TRIGGER IF EXISTS task_search_docs_bu;
+DROP TRIGGER IF EXISTS task_search_docs_bd;
+DROP TABLE IF EXISTS task_search_fts;
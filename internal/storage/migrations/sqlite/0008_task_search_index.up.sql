@@ -0,0 +1,31 @@
+-- 작업 검색용 전문 검색 인덱스입니다. task_search_docs를 외부 콘텐츠로 사용하며 트리거로 동기화합니다.
+-- FTS5는 sqlite_fts5 빌드 태그가 있어야 사용할 수 있으므로, 없으면 아래 FTS4 정의로 대체합니다.
+
+CREATE VIRTUAL TABLE task_search_fts USING fts5(body, content='task_search_docs', content_rowid='id');
+CREATE TRIGGER task_search_docs_ai AFTER INSERT ON task_search_docs BEGIN
+    INSERT INTO task_search_fts (rowid, body) VALUES (new.id, new.body);
+END;
+CREATE TRIGGER task_search_docs_ad AFTER DELETE ON task_search_docs BEGIN
+    INSERT INTO task_search_fts (task_search_fts, rowid, body) VALUES ('delete', old.id, old.body);
+END;
+CREATE TRIGGER task_search_docs_au AFTER UPDATE ON task_search_docs BEGIN
+    INSERT INTO task_search_fts (task_search_fts, rowid, body) VALUES ('delete', old.id, old.body);
+    INSERT INTO task_search_fts (rowid, body) VALUES (new.id, new.body);
+END;
+INSERT INTO task_search_fts (task_search_fts) VALUES ('rebuild');
+
+-- +cnap:fallback
+CREATE VIRTUAL TABLE task_search_fts USING fts4(body, content='task_search_docs');
+CREATE TRIGGER task_search_docs_bd BEFORE DELETE ON task_search_docs BEGIN
+    DELETE FROM task_search_fts WHERE docid = old.id;
+END;
+CREATE TRIGGER task_search_docs_bu BEFORE UPDATE ON task_search_docs BEGIN
+    DELETE FROM task_search_fts WHERE docid = old.id;
+END;
+CREATE TRIGGER task_search_docs_ai AFTER INSERT ON task_search_docs BEGIN
+    INSERT INTO task_search_fts (docid, body) VALUES (new.id, new.body);
+END;
+CREATE TRIGGER task_search_docs_au AFTER UPDATE ON task_search_docs BEGIN
+    INSERT INTO task_search_fts (docid, body) VALUES (new.id, new.body);
+END;
+INSERT INTO task_search_fts (task_search_fts) VALUES ('rebuild');
@@ -0,0 +1,4 @@
+-- 낙관적 동시성 제어용 행 버전입니다. 에이전트와 작업을 수정할 때마다 1씩 증가합니다.
+
+ALTER TABLE agents ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,14 @@
+-- 실행 단계의 상위 단계, 시작·종료 시각과 소요 시간, 모델, 입력·출력 참조, 에러, 토큰 사용량입니다.
+-- 시각은 기록되지 않았으면 NULL이고, 나머지 컬럼은 빈 값으로 채워집니다.
+
+ALTER TABLE run_steps ADD COLUMN parent_step_no INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE run_steps ADD COLUMN model VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE run_steps ADD COLUMN input_ref TEXT NOT NULL DEFAULT '';
+ALTER TABLE run_steps ADD COLUMN output_ref TEXT NOT NULL DEFAULT '';
+ALTER TABLE run_steps ADD COLUMN error_message TEXT NOT NULL DEFAULT '';
+ALTER TABLE run_steps ADD COLUMN error_class VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE run_steps ADD COLUMN input_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE run_steps ADD COLUMN output_tokens INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE run_steps ADD COLUMN started_at DATETIME;
+ALTER TABLE run_steps ADD COLUMN finished_at DATETIME;
+ALTER TABLE run_steps ADD COLUMN duration_ms BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,8 @@
+-- 메시지 버전입니다. 같은 대화 인덱스에 다시 생성하거나 수정한 메시지를 새 revision으로 추가하고,
+-- 이전 버전은 superseded_at을 기록해 대체 버전으로 남깁니다. superseded_at이 NULL인 행이 현재 대화입니다.
+
+ALTER TABLE msg_index ADD COLUMN revision INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE msg_index ADD COLUMN superseded_at DATETIME;
+
+DROP INDEX IF EXISTS idx_msg_idx_task_conv;
+CREATE UNIQUE INDEX idx_msg_idx_task_conv ON msg_index (task_id, conversation_index, revision);
@@ -0,0 +1,13 @@
+-- 에이전트와 작업의 키/값 레이블입니다. 프로젝트, 티켓, 고객별로 묶어 조회하거나 보존 정책을 적용할 때 사용합니다.
+-- target_type은 'agent' 또는 'task'이고, 레이블 셀렉터는 (target_type, key, value) 인덱스로 대상을 찾습니다.
+
+CREATE TABLE labels (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type VARCHAR(32) NOT NULL,
+    target_id   VARCHAR(64) NOT NULL,
+    key         VARCHAR(63) NOT NULL,
+    value       VARCHAR(63) NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL
+);
+CREATE UNIQUE INDEX idx_labels_target ON labels (target_type, target_id, key);
+CREATE INDEX idx_labels_selector ON labels (target_type, key, value);
@@ -0,0 +1,5 @@
+-- 작업을 만들 때 에이전트 프롬프트 템플릿을 변수로 렌더링한 시스템 프롬프트를 저장합니다.
+-- variables는 렌더링에 사용한 변수(JSON 객체)이며, 에이전트가 바뀌어 다시 렌더링할 때 사용합니다.
+
+ALTER TABLE tasks ADD COLUMN system_prompt TEXT NOT NULL DEFAULT '';
+ALTER TABLE tasks ADD COLUMN variables TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,12 @@
+-- 감사 로그를 DB에서도 추가만 가능하게 합니다. GORM 훅을 건너뛰는 경로(SkipHooks, Exec)의 수정과 삭제도 거부됩니다.
+-- 백업 복원(storage.TruncateTables)만 같은 트랜잭션 안에서 트리거를 잠시 내렸다가 다시 만듭니다.
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+    SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
@@ -0,0 +1,3 @@
+-- 작업을 만든 사용자를 기록합니다. 이전에 만든 작업은 소유자가 없습니다.
+
+ALTER TABLE tasks ADD COLUMN owner_id VARCHAR(128) NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+UPDATE agents SET prompt = REPLACE(prompt, '{{"{{"}}', '{{') WHERE prompt LIKE '%{{"{{"}}%';
+UPDATE agent_revisions SET prompt = REPLACE(prompt, '{{"{{"}}', '{{') WHERE prompt LIKE '%{{"{{"}}%';
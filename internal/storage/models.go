@@ -1,33 +1,179 @@
 package storage
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrAuditEventImmutable은 감사 로그를 수정하거나 삭제하려 할 때 반환됩니다.
+var ErrAuditEventImmutable = errors.New("storage: audit events are append-only")
+
+// ErrConflict는 레코드를 읽은 뒤 다른 수정이 먼저 반영되었을 때 반환됩니다.
+// 실제로 반환되는 에러는 *ConflictError이며 errors.Is로 확인합니다.
+var ErrConflict = errors.New("storage: record was modified concurrently")
+
+// ConflictError는 낙관적 동시성 검사에 실패한 레코드를 나타냅니다.
+// Agent와 Task의 Version은 상태 변경을 포함해 레코드를 수정할 때마다 1씩 증가합니다.
+// Expected는 호출자가 읽었던 버전, Actual은 현재 버전입니다.
+type ConflictError struct {
+	Kind     string
+	ID       string
+	Expected int
+	Actual   int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("storage: %s %q was modified concurrently (expected version %d, current %d)", e.Kind, e.ID, e.Expected, e.Actual)
+}
+
+// Is는 errors.Is(err, ErrConflict)가 참이 되도록 합니다.
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// ErrAgentRevisionImmutable은 에이전트 리비전을 수정하거나 삭제하려 할 때 반환됩니다.
+var ErrAgentRevisionImmutable = errors.New("storage: agent revisions are immutable")
 
 // Agent는 agents 테이블 레코드를 나타냅니다.
 type Agent struct {
+	ID          int64  `gorm:"column:id;type:bigserial;primaryKey;index:idx_agents_created,priority:2;index:idx_agents_status_created,priority:3"`
+	AgentID     string `gorm:"column:agent_id;type:varchar(64);not null;uniqueIndex:idx_agents_agent_id"`
+	Description string `gorm:"column:description;type:text"`
+	Model       string `gorm:"column:model;type:varchar(64);index:idx_agents_model"`
+	Prompt      string `gorm:"column:prompt;type:text"`
+	// Parameters는 모델 파라미터 JSON 객체, Tools는 도구 이름 JSON 배열입니다. 비어 있으면 설정 없음입니다.
+	Parameters string    `gorm:"column:parameters;type:text"`
+	Tools      string    `gorm:"column:tools;type:text"`
+	Status     string    `gorm:"column:status;type:varchar(32);not null;default:'active';index:idx_agents_status_created,priority:1"`
+	OwnerID    string    `gorm:"column:owner_id;type:varchar(128);index:idx_agents_owner_id"`
+	Revision   int       `gorm:"column:revision;type:int;not null;default:0"`
+	Version    int       `gorm:"column:version;type:int;not null;default:1"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;autoCreateTime;index:idx_agents_created,priority:1;index:idx_agents_status_created,priority:2"`
+	UpdatedAt  time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+	// Labels는 labels 테이블에 저장되는 키/값 레이블입니다. 레이블이 없으면 nil입니다.
+	Labels map[string]string `gorm:"-"`
+}
+
+// TableName은 gorm Tabler 인터페이스를 구현합니다.
+func (Agent) TableName() string {
+	return "agents"
+}
+
+// AgentRevision은 에이전트 설정의 변경 불가능한 리비전을 기록합니다.
+// 에이전트를 생성하거나 수정할 때마다 1부터 증가하는 리비전이 추가됩니다.
+type AgentRevision struct {
 	ID          int64     `gorm:"column:id;type:bigserial;primaryKey"`
-	AgentID     string    `gorm:"column:agent_id;type:varchar(64);not null;uniqueIndex:idx_agents_agent_id"`
+	AgentID     string    `gorm:"column:agent_id;type:varchar(64);not null;uniqueIndex:idx_agent_revisions_agent_rev,priority:1"`
+	Revision    int       `gorm:"column:revision;type:int;not null;uniqueIndex:idx_agent_revisions_agent_rev,priority:2"`
 	Description string    `gorm:"column:description;type:text"`
 	Model       string    `gorm:"column:model;type:varchar(64)"`
 	Prompt      string    `gorm:"column:prompt;type:text"`
-	Status      string    `gorm:"column:status;type:varchar(32);not null;default:'active'"`
+	Parameters  string    `gorm:"column:parameters;type:text"`
+	Tools       string    `gorm:"column:tools;type:text"`
+	Author      string    `gorm:"column:author;type:varchar(128)"`
 	CreatedAt   time.Time `gorm:"column:created_at;not null;autoCreateTime"`
-	UpdatedAt   time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
 }
 
 // TableName은 gorm Tabler 인터페이스를 구현합니다.
-func (Agent) TableName() string {
-	return "agents"
+func (AgentRevision) TableName() string {
+	return "agent_revisions"
+}
+
+// BeforeUpdate는 리비전 수정을 막습니다.
+func (AgentRevision) BeforeUpdate(*gorm.DB) error {
+	return ErrAgentRevisionImmutable
+}
+
+// BeforeDelete는 리비전 삭제를 막습니다.
+func (AgentRevision) BeforeDelete(*gorm.DB) error {
+	return ErrAgentRevisionImmutable
 }
 
 // Task는 tasks 테이블 레코드를 나타냅니다.
 type Task struct {
-	ID        int64     `gorm:"column:id;type:bigserial;primaryKey"`
-	TaskID    string    `gorm:"column:task_id;type:varchar(64);not null;uniqueIndex:idx_tasks_task_id"`
-	AgentID   string    `gorm:"column:agent_id;type:varchar(64);not null;index:idx_tasks_agent_id"`
-	Prompt    string    `gorm:"column:prompt;type:text"`
-	Status    string    `gorm:"column:status;type:varchar(32);not null"`
-	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
-	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+	ID      int64  `gorm:"column:id;type:bigserial;primaryKey;index:idx_tasks_created,priority:2;index:idx_tasks_updated,priority:2;index:idx_tasks_agent_created,priority:3;index:idx_tasks_status_created,priority:3"`
+	TaskID  string `gorm:"column:task_id;type:varchar(64);not null;uniqueIndex:idx_tasks_task_id"`
+	AgentID string `gorm:"column:agent_id;type:varchar(64);not null;index:idx_tasks_agent_id;index:idx_tasks_agent_created,priority:1"`
+	Prompt  string `gorm:"column:prompt;type:text"`
+	Status  string `gorm:"column:status;type:varchar(32);not null;index:idx_tasks_status_created,priority:1"`
+	// AgentRevision은 작업이 실행된 에이전트 리비전입니다. 0이면 알 수 없습니다.
+	AgentRevision int       `gorm:"column:agent_revision;type:int;not null;default:0"`
+	Version       int       `gorm:"column:version;type:int;not null;default:1"`
+	CreatedAt     time.Time `gorm:"column:created_at;not null;autoCreateTime;index:idx_tasks_created,priority:1;index:idx_tasks_agent_created,priority:2;index:idx_tasks_status_created,priority:2"`
+	UpdatedAt     time.Time `gorm:"column:updated_at;not null;autoUpdateTime;index:idx_tasks_updated,priority:1"`
+	// NextMessageIndex는 다음에 추가할 메시지의 ConversationIndex입니다. 행 버전은 올리지 않습니다.
+	NextMessageIndex int `gorm:"column:next_message_index;type:int;not null;default:0"`
+	// ResultRef는 최종 출력이 저장된 메시지 저장소 키입니다. 출력이 없으면 비어 있습니다.
+	ResultRef string `gorm:"column:result_ref;type:text;not null;default:''"`
+	// ErrorClass는 실패 원인의 분류(예: timeout, rate_limited)이고 ErrorMessage는 에러 내용입니다.
+	ErrorMessage string `gorm:"column:error_message;type:text;not null;default:''"`
+	ErrorClass   string `gorm:"column:error_class;type:varchar(64);not null;default:''"`
+	// ResultModel은 실제로 응답한 모델입니다. 에이전트에 설정된 모델과 다를 수 있습니다.
+	ResultModel string `gorm:"column:result_model;type:varchar(64);not null;default:''"`
+	// Attempts는 실행을 시작한 횟수입니다.
+	Attempts int `gorm:"column:attempts;type:int;not null;default:0"`
+	// StartedAt은 마지막 실행의 시작 시각, FinishedAt은 종료 시각입니다. 기록되지 않았으면 nil입니다.
+	StartedAt  *time.Time `gorm:"column:started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+	// ParentTaskID는 이 작업을 분기한 부모 작업이고, ForkIndex는 부모에서 복사한 마지막 대화 인덱스입니다.
+	// ParentTaskID가 비어 있으면 분기한 작업이 아닙니다.
+	ParentTaskID string `gorm:"column:parent_task_id;type:varchar(64);not null;default:'';index:idx_tasks_parent_task_id"`
+	ForkIndex    int    `gorm:"column:fork_index;type:int;not null;default:0"`
+	// Model은 에이전트 모델 대신 사용할 모델입니다. 비어 있으면 에이전트 모델을 사용합니다.
+	Model string `gorm:"column:model;type:varchar(64);not null;default:''"`
+	// SystemPrompt는 에이전트 프롬프트 템플릿을 Variables로 렌더링한 시스템 프롬프트입니다.
+	// Variables는 렌더링에 사용한 변수 JSON 객체이며, 변수가 없으면 비어 있습니다.
+	SystemPrompt string `gorm:"column:system_prompt;type:text;not null;default:''"`
+	Variables    string `gorm:"column:variables;type:text;not null;default:''"`
+	// OwnerID는 작업을 만든 사용자(예: "discord:1234", "cli:alice")입니다. 비어 있으면 알 수 없습니다.
+	OwnerID string `gorm:"column:owner_id;type:varchar(128);not null;default:''"`
+	// Labels는 labels 테이블에 저장되는 키/값 레이블입니다. 레이블이 없으면 nil입니다.
+	Labels map[string]string `gorm:"-"`
+}
+
+// TaskResult는 실행을 마친 작업에 기록할 결과입니다.
+type TaskResult struct {
+	ResultRef    string
+	ErrorMessage string
+	ErrorClass   string
+	ResultModel  string
+}
+
+// prepareForkMessages는 분기한 작업에 복사할 메시지 참조의 작업 ID와 대화 인덱스를 다시 매기고,
+// 작업의 메시지 카운터를 그 다음 값으로 설정합니다.
+func prepareForkMessages(task *Task, messages []MessageIndex) {
+	for i := range messages {
+		messages[i].ID = 0
+		messages[i].TaskID = task.TaskID
+		messages[i].ConversationIndex = i
+		messages[i].Revision = 1
+		messages[i].SupersededAt = nil
+	}
+	task.NextMessageIndex = len(messages)
+}
+
+// startAttempt는 새 실행의 시작을 반영합니다. 이전 실행의 결과와 에러는 지웁니다.
+func (t *Task) startAttempt(now time.Time) {
+	started := now
+	t.Status = TaskStatusRunning
+	t.Attempts++
+	t.StartedAt = &started
+	t.FinishedAt = nil
+	t.ResultRef, t.ErrorMessage, t.ErrorClass, t.ResultModel = "", "", "", ""
+}
+
+// finish는 실행 종료 상태와 결과를 반영합니다.
+func (t *Task) finish(status string, result TaskResult, now time.Time) {
+	finished := now
+	t.Status = status
+	t.FinishedAt = &finished
+	t.ResultRef = result.ResultRef
+	t.ErrorMessage = result.ErrorMessage
+	t.ErrorClass = result.ErrorClass
+	t.ResultModel = result.ResultModel
 }
 
 // TableName은 gorm Tabler 인터페이스를 구현합니다.
@@ -44,6 +190,10 @@ type MessageIndex struct {
 	FilePath          string    `gorm:"column:file_path;type:text;not null"`
 	CreatedAt         time.Time `gorm:"column:created_at;not null;autoCreateTime"`
 	UpdatedAt         time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+	// Revision은 같은 대화 인덱스에서 몇 번째 버전인지를 나타냅니다(1부터).
+	Revision int `gorm:"column:revision;type:int;not null;default:1;uniqueIndex:idx_msg_idx_task_conv,priority:3"`
+	// SupersededAt은 다시 생성하거나 수정해 대체된 시각입니다. nil이면 현재 대화에 속한 메시지입니다.
+	SupersededAt *time.Time `gorm:"column:superseded_at"`
 }
 
 // TableName은 gorm Tabler 인터페이스를 구현합니다.
@@ -51,14 +201,43 @@ func (MessageIndex) TableName() string {
 	return "msg_index"
 }
 
-// RunStep은 작업 실행 단계를 기록합니다.
+// RunStep은 작업 실행 단계를 기록합니다. 단계는 작업 안에서 StepNo로 식별되며,
+// ParentStepNo로 다른 단계 아래에 중첩될 수 있습니다(예: 모델 호출 안의 도구 호출).
 type RunStep struct {
-	ID        int64     `gorm:"column:id;type:bigserial;primaryKey"`
-	TaskID    string    `gorm:"column:task_id;type:varchar(64);not null;index:idx_run_steps_task;uniqueIndex:idx_run_steps_task_step,priority:1"`
-	StepNo    int       `gorm:"column:step_no;type:int;not null;uniqueIndex:idx_run_steps_task_step,priority:2"`
-	Type      string    `gorm:"column:type;type:varchar(32);not null"`
-	Status    string    `gorm:"column:status;type:varchar(32);not null"`
-	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+	ID     int64  `gorm:"column:id;type:bigserial;primaryKey"`
+	TaskID string `gorm:"column:task_id;type:varchar(64);not null;index:idx_run_steps_task;uniqueIndex:idx_run_steps_task_step,priority:1"`
+	StepNo int    `gorm:"column:step_no;type:int;not null;uniqueIndex:idx_run_steps_task_step,priority:2"`
+	// ParentStepNo는 상위 단계의 StepNo입니다. 0이면 최상위 단계입니다.
+	ParentStepNo int    `gorm:"column:parent_step_no;type:int;not null;default:0"`
+	Type         string `gorm:"column:type;type:varchar(32);not null"`
+	Status       string `gorm:"column:status;type:varchar(32);not null"`
+	Model        string `gorm:"column:model;type:varchar(64);not null;default:''"`
+	// InputRef와 OutputRef는 단계의 입력과 출력이 저장된 위치(예: 메시지 저장소 경로)입니다.
+	InputRef  string `gorm:"column:input_ref;type:text;not null;default:''"`
+	OutputRef string `gorm:"column:output_ref;type:text;not null;default:''"`
+	// ErrorClass는 실패 원인의 분류(예: timeout, rate_limit)이고 ErrorMessage는 에러 내용입니다.
+	ErrorMessage string `gorm:"column:error_message;type:text;not null;default:''"`
+	ErrorClass   string `gorm:"column:error_class;type:varchar(64);not null;default:''"`
+	InputTokens  int    `gorm:"column:input_tokens;type:int;not null;default:0"`
+	OutputTokens int    `gorm:"column:output_tokens;type:int;not null;default:0"`
+	// StartedAt과 FinishedAt은 기록되지 않았으면 nil입니다.
+	StartedAt  *time.Time `gorm:"column:started_at"`
+	FinishedAt *time.Time `gorm:"column:finished_at"`
+	// DurationMS는 단계의 소요 시간(밀리초)입니다. 비어 있으면 저장할 때 StartedAt과 FinishedAt으로 계산합니다.
+	DurationMS int64     `gorm:"column:duration_ms;type:bigint;not null;default:0"`
+	CreatedAt  time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+}
+
+// Duration은 단계의 소요 시간입니다.
+func (s *RunStep) Duration() time.Duration {
+	return time.Duration(s.DurationMS) * time.Millisecond
+}
+
+// fillDuration은 DurationMS가 비어 있고 시작·종료 시각이 모두 있으면 소요 시간을 계산합니다.
+func (s *RunStep) fillDuration() {
+	if s.DurationMS == 0 && s.StartedAt != nil && s.FinishedAt != nil {
+		s.DurationMS = s.FinishedAt.Sub(*s.StartedAt).Milliseconds()
+	}
 }
 
 // TableName은 gorm Tabler 인터페이스를 구현합니다.
@@ -78,3 +257,52 @@ type Checkpoint struct {
 func (Checkpoint) TableName() string {
 	return "checkpoints"
 }
+
+// AuditEvent는 audit_events 테이블 레코드를 나타냅니다.
+// 감사 로그는 추가만 가능합니다. 훅은 GORM 모델을 통한 수정과 삭제를 먼저 거부하고,
+// 훅을 건너뛰는 경로(SkipHooks, Exec)는 마이그레이션 0017의 DB 트리거가 거부합니다.
+type AuditEvent struct {
+	ID          int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	Actor       string    `gorm:"column:actor;type:varchar(128);not null;index:idx_audit_events_actor"`
+	Action      string    `gorm:"column:action;type:varchar(64);not null;index:idx_audit_events_action"`
+	TargetType  string    `gorm:"column:target_type;type:varchar(32);not null;index:idx_audit_events_target,priority:1"`
+	TargetID    string    `gorm:"column:target_id;type:varchar(64);not null;index:idx_audit_events_target,priority:2"`
+	AgentID     string    `gorm:"column:agent_id;type:varchar(64);index:idx_audit_events_agent"`
+	BeforeState string    `gorm:"column:before_state;type:text"`
+	AfterState  string    `gorm:"column:after_state;type:text"`
+	CreatedAt   time.Time `gorm:"column:created_at;not null;autoCreateTime;index:idx_audit_events_created_at"`
+}
+
+// TableName은 gorm Tabler 인터페이스를 구현합니다.
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// BeforeUpdate는 감사 로그 수정을 막습니다.
+func (AuditEvent) BeforeUpdate(*gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// BeforeDelete는 감사 로그 삭제를 막습니다.
+func (AuditEvent) BeforeDelete(*gorm.DB) error {
+	return ErrAuditEventImmutable
+}
+
+// SearchDocument는 작업 검색 인덱스의 문서입니다. 작업 프롬프트와 메시지 본문이 한 행씩 저장되며,
+// tasks와 메시지 저장소에서 다시 만들 수 있는 파생 데이터입니다.
+type SearchDocument struct {
+	ID      int64  `gorm:"column:id;type:bigserial;primaryKey"`
+	TaskID  string `gorm:"column:task_id;type:varchar(64);not null;index:idx_search_docs_task;uniqueIndex:idx_search_docs_source,priority:1"`
+	AgentID string `gorm:"column:agent_id;type:varchar(64);not null"`
+	// Source는 SearchSourcePrompt 또는 SearchSourceMessage입니다.
+	Source string `gorm:"column:source;type:varchar(16);not null;uniqueIndex:idx_search_docs_source,priority:2"`
+	// Ref는 메시지 파일 경로입니다. 프롬프트는 빈 문자열입니다.
+	Ref       string    `gorm:"column:ref;type:text;not null;default:'';uniqueIndex:idx_search_docs_source,priority:3"`
+	Body      string    `gorm:"column:body;type:text;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+}
+
+// TableName은 gorm Tabler 인터페이스를 구현합니다.
+func (SearchDocument) TableName() string {
+	return "task_search_docs"
+}
@@ -27,28 +27,74 @@ func (r *Repository) DB() *gorm.DB {
 	return r.db
 }
 
-// CreateAgent는 새로운 에이전트 레코드를 저장합니다.
+// InTx는 하나의 데이터베이스 트랜잭션 안에서 fn을 실행합니다.
+// fn에 넘긴 Repository의 쓰기는 fn이 오류를 반환하면 모두 롤백됩니다.
+func (r *Repository) InTx(ctx context.Context, fn func(tx Store) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&Repository{db: tx})
+	})
+}
+
+// Ping은 데이터베이스 연결이 살아 있는지 확인합니다.
+func (r *Repository) Ping(ctx context.Context) error {
+	sqlDB, err := r.db.DB()
+	if err != nil {
+		return fmt.Errorf("storage: get sql.DB: %w", err)
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// CheckSchema는 스키마 버전이 최신인지 확인합니다.
+func (r *Repository) CheckSchema(ctx context.Context) error {
+	return CheckSchema(ctx, r.db.WithContext(ctx))
+}
+
+// CreateAgent는 새로운 에이전트 레코드와 첫 리비전을 저장합니다.
+// 리비전 작성자는 agent.OwnerID입니다.
 func (r *Repository) CreateAgent(ctx context.Context, agent *Agent) error {
 	if agent == nil {
 		return fmt.Errorf("storage: nil agent payload")
 	}
-	return r.db.WithContext(ctx).Create(agent).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		agent.Revision = 1
+		agent.Version = 1
+		if err := tx.Create(agent).Error; err != nil {
+			return err
+		}
+		if err := insertLabels(tx, LabelTargetAgent, agent.AgentID, agent.Labels); err != nil {
+			return err
+		}
+		return tx.Create(&AgentRevision{
+			AgentID:     agent.AgentID,
+			Revision:    agent.Revision,
+			Description: agent.Description,
+			Model:       agent.Model,
+			Prompt:      agent.Prompt,
+			Parameters:  agent.Parameters,
+			Tools:       agent.Tools,
+			Author:      agent.OwnerID,
+		}).Error
+	})
 }
 
-// UpsertAgentStatus는 agentID로 에이전트 상태를 갱신하거나 생성합니다.
-func (r *Repository) UpsertAgentStatus(ctx context.Context, agentID, status string) error {
-	if agentID == "" {
+// UpdateAgentStatus는 agent.Version이 현재 버전과 같을 때만 에이전트 상태를 바꿉니다.
+// 성공하면 agent.Status와 agent.Version이 갱신되고, 그 사이 다른 수정이 있었으면 *ConflictError를 반환합니다.
+func (r *Repository) UpdateAgentStatus(ctx context.Context, agent *Agent, status string) error {
+	if agent == nil {
+		return fmt.Errorf("storage: nil agent payload")
+	}
+	if agent.AgentID == "" {
 		return fmt.Errorf("storage: empty agentID")
 	}
-	return r.db.WithContext(ctx).
-		Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "agent_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"status", "updated_at"}),
-		}).
-		Create(&Agent{
-			AgentID: agentID,
-			Status:  status,
-		}).Error
+	if err := updateVersioned(r.db.WithContext(ctx), &Agent{}, "agent", "agent_id", agent.AgentID, agent.Version, map[string]interface{}{
+		"status":     status,
+		"updated_at": time.Now(),
+	}); err != nil {
+		return err
+	}
+	agent.Status = status
+	agent.Version++
+	return nil
 }
 
 // GetAgent는 식별자로 에이전트를 조회합니다.
@@ -56,13 +102,15 @@ func (r *Repository) GetAgent(ctx context.Context, agentID string) (*Agent, erro
 	if agentID == "" {
 		return nil, fmt.Errorf("storage: empty agentID")
 	}
-	var agent Agent
-	if err := r.db.WithContext(ctx).
-		Where("agent_id = ?", agentID).
-		First(&agent).Error; err != nil {
+	db := r.db.WithContext(ctx)
+	agents := make([]Agent, 1)
+	if err := db.Where("agent_id = ?", agentID).First(&agents[0]).Error; err != nil {
+		return nil, err
+	}
+	if err := attachAgentLabels(db, agents); err != nil {
 		return nil, err
 	}
-	return &agent, nil
+	return &agents[0], nil
 }
 
 // ListAgents는 상태 필터를 적용해 에이전트 목록을 반환합니다.
@@ -75,98 +123,355 @@ func (r *Repository) ListAgents(ctx context.Context, statuses ...string) ([]Agen
 	if err := q.Order("created_at ASC").Find(&agents).Error; err != nil {
 		return nil, err
 	}
+	if err := attachAgentLabels(r.db.WithContext(ctx), agents); err != nil {
+		return nil, err
+	}
 	return agents, nil
 }
 
-// UpdateAgent는 에이전트 정보를 업데이트합니다.
-func (r *Repository) UpdateAgent(ctx context.Context, agent *Agent) error {
+// UpdateAgent는 에이전트 설정(설명, 모델, 프롬프트, 파라미터, 도구)을 업데이트하고
+// author가 작성한 새 리비전을 추가합니다.
+// agent.Version은 호출자가 읽었던 행 버전이며, 성공하면 agent.Revision과 agent.Version에 새 값이 설정됩니다.
+// 그 사이 다른 수정이 먼저 반영되었거나 버전이 0이면 *ConflictError를 반환합니다.
+func (r *Repository) UpdateAgent(ctx context.Context, agent *Agent, author string) error {
 	if agent == nil {
 		return fmt.Errorf("storage: nil agent payload")
 	}
 	if agent.AgentID == "" {
 		return fmt.Errorf("storage: empty agentID")
 	}
-	return r.db.WithContext(ctx).
-		Model(&Agent{}).
-		Where("agent_id = ?", agent.AgentID).
-		Updates(map[string]interface{}{
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var current Agent
+		if err := tx.Where("agent_id = ?", agent.AgentID).First(&current).Error; err != nil {
+			return err
+		}
+		expected := agent.Version
+		next := current.Revision + 1
+
+		if err := updateVersioned(tx, &Agent{}, "agent", "agent_id", agent.AgentID, expected, map[string]interface{}{
 			"description": agent.Description,
 			"model":       agent.Model,
 			"prompt":      agent.Prompt,
+			"parameters":  agent.Parameters,
+			"tools":       agent.Tools,
+			"revision":    next,
 			"updated_at":  time.Now(),
-		}).Error
+		}); err != nil {
+			return err
+		}
+
+		if err := tx.Create(&AgentRevision{
+			AgentID:     agent.AgentID,
+			Revision:    next,
+			Description: agent.Description,
+			Model:       agent.Model,
+			Prompt:      agent.Prompt,
+			Parameters:  agent.Parameters,
+			Tools:       agent.Tools,
+			Author:      author,
+		}).Error; err != nil {
+			return err
+		}
+		agent.Revision = next
+		agent.Version = expected + 1
+		return nil
+	})
+}
+
+// PurgeAgent는 에이전트와 리비전, 작업, 메시지 인덱스, 실행 단계, 체크포인트, 검색 문서를
+// 한 트랜잭션에서 영구 삭제하고, 삭제한 메시지의 저장소 경로를 반환합니다.
+// 메시지 본문은 저장소에 남아 있으므로 호출자가 반환된 경로를 지워야 합니다. 감사 로그는 남깁니다.
+// 삭제 상태가 아닌 에이전트는 지우지 않고 ErrConflict를 반환합니다.
+func (r *Repository) PurgeAgent(ctx context.Context, agentID string) ([]string, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	var files []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("agent_id = ? AND status = ?", agentID, AgentStatusDeleted).Delete(&Agent{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&Agent{}).Where("agent_id = ?", agentID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return fmt.Errorf("storage: agent %q is not deleted: %w", agentID, ErrConflict)
+		}
+
+		tasks := tx.Model(&Task{}).Select("task_id").Where("agent_id = ?", agentID)
+		usage, err := deleteTaskData(tx, tasks)
+		if err != nil {
+			return err
+		}
+		files = usage.Files
+		if err := tx.Where("agent_id = ?", agentID).Delete(&Task{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("target_type = ? AND target_id = ?", LabelTargetAgent, agentID).Delete(&Label{}).Error; err != nil {
+			return err
+		}
+		// 리비전은 훅으로 수정과 삭제를 막으므로 영구 삭제할 때만 훅을 건너뜁니다.
+		return tx.Session(&gorm.Session{SkipHooks: true}).
+			Where("agent_id = ?", agentID).
+			Delete(&AgentRevision{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// ListAgentRevisions는 에이전트의 리비전을 오래된 순으로 반환합니다.
+func (r *Repository) ListAgentRevisions(ctx context.Context, agentID string) ([]AgentRevision, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	var revisions []AgentRevision
+	if err := r.db.WithContext(ctx).
+		Where("agent_id = ?", agentID).
+		Order("revision ASC").
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
 }
 
-// CreateTask는 새로운 작업 레코드를 추가합니다.
+// GetAgentRevision은 에이전트의 특정 리비전을 조회합니다.
+func (r *Repository) GetAgentRevision(ctx context.Context, agentID string, revision int) (*AgentRevision, error) {
+	if agentID == "" {
+		return nil, fmt.Errorf("storage: empty agentID")
+	}
+	var rev AgentRevision
+	if err := r.db.WithContext(ctx).
+		Where("agent_id = ? AND revision = ?", agentID, revision).
+		First(&rev).Error; err != nil {
+		return nil, err
+	}
+	return &rev, nil
+}
+
+// CreateTask는 새로운 작업 레코드를 추가하고, 프롬프트가 있으면 검색 인덱스에 함께 등록합니다.
 func (r *Repository) CreateTask(ctx context.Context, task *Task) error {
 	if task == nil {
 		return fmt.Errorf("storage: nil task payload")
 	}
-	return r.db.WithContext(ctx).Create(task).Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createTask(tx, task)
+	})
 }
 
-// UpsertTaskStatus는 작업 레코드를 만들거나 상태를 갱신합니다.
-func (r *Repository) UpsertTaskStatus(ctx context.Context, taskID, agentID, status string) error {
-	if taskID == "" {
+// ForkTask는 분기한 작업을 만들고 복사할 메시지 참조를 같은 트랜잭션에서 추가합니다.
+// messages는 순서대로 대화 인덱스 0부터 다시 번호가 매겨지고, 작업의 메시지 카운터는 그 다음 값으로 설정됩니다.
+// 메시지 본문은 호출자가 미리 저장해 두어야 합니다.
+func (r *Repository) ForkTask(ctx context.Context, task *Task, messages []MessageIndex) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	prepareForkMessages(task, messages)
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := createTask(tx, task); err != nil {
+			return err
+		}
+		if len(messages) == 0 {
+			return nil
+		}
+		return tx.Create(&messages).Error
+	})
+}
+
+// createTask는 작업을 추가하고, 프롬프트가 있으면 검색 인덱스에 함께 등록합니다.
+func createTask(tx *gorm.DB, task *Task) error {
+	if task.Version == 0 {
+		task.Version = 1
+	}
+	if err := tx.Create(task).Error; err != nil {
+		return err
+	}
+	if err := insertLabels(tx, LabelTargetTask, task.TaskID, task.Labels); err != nil {
+		return err
+	}
+	if task.Prompt == "" {
+		return nil
+	}
+	return upsertSearchDocument(tx, &SearchDocument{
+		TaskID:    task.TaskID,
+		AgentID:   task.AgentID,
+		Source:    SearchSourcePrompt,
+		Body:      task.Prompt,
+		CreatedAt: task.CreatedAt,
+	})
+}
+
+// UpdateTaskStatus는 task.Version이 현재 버전과 같을 때만 작업 상태를 바꿉니다.
+// 성공하면 task.Status와 task.Version이 갱신되고, 그 사이 다른 수정이 있었으면 *ConflictError를 반환합니다.
+func (r *Repository) UpdateTaskStatus(ctx context.Context, task *Task, status string) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
 		return fmt.Errorf("storage: empty taskID")
 	}
-	return r.db.WithContext(ctx).
-		Clauses(clause.OnConflict{
-			Columns:   []clause.Column{{Name: "task_id"}},
-			DoUpdates: clause.AssignmentColumns([]string{"status", "updated_at"}),
-		}).
-		Create(&Task{
-			TaskID:  taskID,
-			AgentID: agentID,
-			Status:  status,
-		}).Error
+	if err := updateVersioned(r.db.WithContext(ctx), &Task{}, "task", "task_id", task.TaskID, task.Version, map[string]interface{}{
+		"status":     status,
+		"updated_at": time.Now(),
+	}); err != nil {
+		return err
+	}
+	task.Status = status
+	task.Version++
+	return nil
+}
+
+// SetTaskAgentRevision은 작업이 실행된 에이전트 리비전과 그 리비전의 프롬프트로 렌더링한 시스템 프롬프트를 기록합니다.
+// UpdateTaskStatus와 같이 task.Version을 확인하고, 성공하면 task.AgentRevision, task.SystemPrompt, task.Version을 갱신합니다.
+func (r *Repository) SetTaskAgentRevision(ctx context.Context, task *Task, revision int, systemPrompt string) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return fmt.Errorf("storage: empty taskID")
+	}
+	if err := updateVersioned(r.db.WithContext(ctx), &Task{}, "task", "task_id", task.TaskID, task.Version, map[string]interface{}{
+		"agent_revision": revision,
+		"system_prompt":  systemPrompt,
+		"updated_at":     time.Now(),
+	}); err != nil {
+		return err
+	}
+	task.AgentRevision = revision
+	task.SystemPrompt = systemPrompt
+	task.Version++
+	return nil
+}
+
+// StartTaskAttempt는 작업을 running으로 바꾸고 실행 시작을 기록합니다.
+// 시도 횟수를 1 올리고 이전 실행의 결과와 에러는 지웁니다. task.Version 확인은 UpdateTaskStatus와 같습니다.
+func (r *Repository) StartTaskAttempt(ctx context.Context, task *Task) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return fmt.Errorf("storage: empty taskID")
+	}
+	now := time.Now()
+	if err := updateVersioned(r.db.WithContext(ctx), &Task{}, "task", "task_id", task.TaskID, task.Version, map[string]interface{}{
+		"status":        TaskStatusRunning,
+		"attempts":      gorm.Expr("attempts + 1"),
+		"started_at":    now,
+		"finished_at":   nil,
+		"result_ref":    "",
+		"error_message": "",
+		"error_class":   "",
+		"result_model":  "",
+		"updated_at":    now,
+	}); err != nil {
+		return err
+	}
+	task.startAttempt(now)
+	task.Version++
+	return nil
+}
+
+// FinishTask는 작업을 종료 상태로 바꾸고 실행 결과와 종료 시각을 기록합니다.
+// task.Version 확인은 UpdateTaskStatus와 같습니다.
+func (r *Repository) FinishTask(ctx context.Context, task *Task, status string, result TaskResult) error {
+	if task == nil {
+		return fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return fmt.Errorf("storage: empty taskID")
+	}
+	now := time.Now()
+	if err := updateVersioned(r.db.WithContext(ctx), &Task{}, "task", "task_id", task.TaskID, task.Version, map[string]interface{}{
+		"status":        status,
+		"finished_at":   now,
+		"result_ref":    result.ResultRef,
+		"error_message": result.ErrorMessage,
+		"error_class":   result.ErrorClass,
+		"result_model":  result.ResultModel,
+		"updated_at":    now,
+	}); err != nil {
+		return err
+	}
+	task.finish(status, result, now)
+	task.Version++
+	return nil
 }
 
 // GetTask는 작업 식별자로 레코드를 조회합니다.
 func (r *Repository) GetTask(ctx context.Context, taskID string) (*Task, error) {
-	var task Task
-	if err := r.db.WithContext(ctx).
-		Where("task_id = ?", taskID).
-		First(&task).Error; err != nil {
+	db := r.db.WithContext(ctx)
+	tasks := make([]Task, 1)
+	if err := db.Where("task_id = ?", taskID).First(&tasks[0]).Error; err != nil {
+		return nil, err
+	}
+	if err := attachTaskLabels(db, tasks); err != nil {
 		return nil, err
 	}
-	return &task, nil
+	return &tasks[0], nil
 }
 
 // ListTasksByAgent는 에이전트별 작업 목록을 반환합니다.
 func (r *Repository) ListTasksByAgent(ctx context.Context, agentID string) ([]Task, error) {
+	db := r.db.WithContext(ctx)
 	var tasks []Task
-	if err := r.db.WithContext(ctx).
-		Where("agent_id = ?", agentID).
+	if err := db.Where("agent_id = ?", agentID).
 		Order("created_at ASC").
 		Find(&tasks).Error; err != nil {
 		return nil, err
 	}
+	if err := attachTaskLabels(db, tasks); err != nil {
+		return nil, err
+	}
 	return tasks, nil
 }
 
+// CountTasksByStatus는 상태별 작업 수를 반환합니다.
+func (r *Repository) CountTasksByStatus(ctx context.Context) (map[string]int64, error) {
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&Task{}).
+		Select("status, COUNT(*) AS count").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
 // GetNextConversationIndex는 해당 Task의 다음 ConversationIndex를 반환합니다.
 func (r *Repository) GetNextConversationIndex(ctx context.Context, taskID string) (int, error) {
 	if taskID == "" {
 		return 0, fmt.Errorf("storage: empty taskID")
 	}
-	var maxIndex struct {
-		MaxIndex *int
-	}
+	var next []int
 	if err := r.db.WithContext(ctx).
-		Model(&MessageIndex{}).
-		Select("MAX(conversation_index) as max_index").
+		Model(&Task{}).
 		Where("task_id = ?", taskID).
-		Scan(&maxIndex).Error; err != nil {
+		Pluck("next_message_index", &next).Error; err != nil {
 		return 0, err
 	}
-	if maxIndex.MaxIndex == nil {
+	if len(next) == 0 {
 		return 0, nil
 	}
-	return *maxIndex.MaxIndex + 1, nil
+	return next[0], nil
 }
 
 // AppendMessageIndex는 새로운 메시지를 대화에 추가합니다 (ConversationIndex 자동 증가).
+// 인덱스는 같은 트랜잭션에서 작업의 카운터를 증가시켜 할당하므로, 여러 요청이 동시에 추가해도
+// 메시지가 같은 인덱스를 받거나 유실되지 않습니다. 작업이 없으면 gorm.ErrRecordNotFound를 반환합니다.
 func (r *Repository) AppendMessageIndex(ctx context.Context, taskID, role, filePath string) (*MessageIndex, error) {
 	if taskID == "" {
 		return nil, fmt.Errorf("storage: empty taskID")
@@ -178,32 +483,70 @@ func (r *Repository) AppendMessageIndex(ctx context.Context, taskID, role, fileP
 		return nil, fmt.Errorf("storage: empty filePath")
 	}
 
-	nextIndex, err := r.GetNextConversationIndex(ctx, taskID)
+	var payload *MessageIndex
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		payload, err = appendMessageIndex(tx, taskID, role, filePath)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("storage: failed to get next conversation index: %w", err)
+		return nil, err
 	}
+	return payload, nil
+}
 
+// appendMessageIndex는 카운터로 인덱스를 할당하고, 그 인덱스에 남아 있는 대체 버전 다음 revision으로 메시지를 추가합니다.
+func appendMessageIndex(tx *gorm.DB, taskID, role, filePath string) (*MessageIndex, error) {
+	index, err := allocateConversationIndex(tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	var revision []int
+	if err := tx.Model(&MessageIndex{}).
+		Where("task_id = ? AND conversation_index = ?", taskID, index).
+		Pluck("COALESCE(MAX(revision), 0)", &revision).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
 	payload := &MessageIndex{
 		TaskID:            taskID,
-		ConversationIndex: nextIndex,
+		ConversationIndex: index,
 		Role:              role,
 		FilePath:          filePath,
-		CreatedAt:         time.Now().UTC(),
-		UpdatedAt:         time.Now().UTC(),
+		Revision:          1,
+		CreatedAt:         now,
+		UpdatedAt:         now,
 	}
-
-	if err := r.db.WithContext(ctx).Create(payload).Error; err != nil {
+	if len(revision) > 0 {
+		payload.Revision = revision[0] + 1
+	}
+	if err := tx.Create(payload).Error; err != nil {
 		return nil, err
 	}
-
 	return payload, nil
 }
 
-// ListMessageIndexByTask는 작업에 연결된 메시지 참조 목록을 순서대로 반환합니다.
+// allocateConversationIndex는 작업의 메시지 카운터를 1 올리고 증가 전 값을 반환합니다.
+// 카운터 행을 먼저 갱신하므로 PostgreSQL에서는 행 잠금이, SQLite에서는 쓰기 잠금(busy timeout 동안 대기)이
+// 트랜잭션이 끝날 때까지 같은 작업의 다른 추가를 기다리게 합니다.
+func allocateConversationIndex(tx *gorm.DB, taskID string) (int, error) {
+	var next []int
+	if err := tx.Raw("UPDATE tasks SET next_message_index = next_message_index + 1 WHERE task_id = ? RETURNING next_message_index", taskID).
+		Scan(&next).Error; err != nil {
+		return 0, fmt.Errorf("storage: failed to allocate conversation index: %w", err)
+	}
+	if len(next) == 0 {
+		return 0, fmt.Errorf("storage: task %s: %w", taskID, gorm.ErrRecordNotFound)
+	}
+	return next[0] - 1, nil
+}
+
+// ListMessageIndexByTask는 작업의 현재 대화에 속한 메시지 참조 목록을 순서대로 반환합니다.
+// 다시 생성하거나 수정해 대체된 버전은 ListMessageVersions로 조회합니다.
 func (r *Repository) ListMessageIndexByTask(ctx context.Context, taskID string) ([]MessageIndex, error) {
 	var rows []MessageIndex
 	if err := r.db.WithContext(ctx).
-		Where("task_id = ?", taskID).
+		Where("task_id = ? AND superseded_at IS NULL", taskID).
 		Order("conversation_index ASC").
 		Find(&rows).Error; err != nil {
 		return nil, err
@@ -211,7 +554,157 @@ func (r *Repository) ListMessageIndexByTask(ctx context.Context, taskID string)
 	return rows, nil
 }
 
-// UpsertRunStep은 실행 단계를 생성하거나 갱신합니다.
+// ListMessageVersions는 대체된 버전을 포함한 작업의 모든 메시지 참조를 대화 인덱스, revision 순으로 반환합니다.
+func (r *Repository) ListMessageVersions(ctx context.Context, taskID string) ([]MessageIndex, error) {
+	var rows []MessageIndex
+	if err := r.db.WithContext(ctx).
+		Where("task_id = ?", taskID).
+		Order("conversation_index ASC, revision ASC").
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// RewindMessages는 fromIndex 이후의 현재 메시지를 대체된 버전으로 표시하고 메시지 카운터를 fromIndex로 되돌립니다.
+// 같은 트랜잭션에서 task를 다시 실행할 수 있도록 pending으로 되돌리며, UpdateTaskStatus와 같이 task.Version을 확인합니다.
+// 버전이 다르면 *ConflictError를 반환하고 아무것도 바꾸지 않습니다. 대체한 메시지 수를 반환합니다.
+func (r *Repository) RewindMessages(ctx context.Context, task *Task, fromIndex int) (int, error) {
+	if task == nil {
+		return 0, fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return 0, fmt.Errorf("storage: empty taskID")
+	}
+	var rewound int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := reopenTask(tx, task); err != nil {
+			return err
+		}
+		var err error
+		rewound, err = rewindMessages(tx, task.TaskID, fromIndex)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	task.Status = TaskStatusPending
+	task.Version++
+	return int(rewound), nil
+}
+
+// ReviseMessage는 index의 현재 메시지를 새 버전으로 바꿉니다. 그 뒤의 메시지는 모두 대체된 버전이 되고,
+// 새 메시지는 같은 인덱스의 다음 revision으로 추가됩니다. index에 현재 메시지가 없으면 ErrNotFound를 반환합니다.
+// RewindMessages와 같이 같은 트랜잭션에서 task를 pending으로 되돌립니다.
+func (r *Repository) ReviseMessage(ctx context.Context, task *Task, index int, role, filePath string) (*MessageIndex, error) {
+	if task == nil {
+		return nil, fmt.Errorf("storage: nil task payload")
+	}
+	if task.TaskID == "" {
+		return nil, fmt.Errorf("storage: empty taskID")
+	}
+	if role == "" {
+		return nil, fmt.Errorf("storage: empty role")
+	}
+	if filePath == "" {
+		return nil, fmt.Errorf("storage: empty filePath")
+	}
+
+	taskID := task.TaskID
+	var payload *MessageIndex
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := reopenTask(tx, task); err != nil {
+			return err
+		}
+		var active int64
+		if err := tx.Model(&MessageIndex{}).
+			Where("task_id = ? AND conversation_index = ? AND superseded_at IS NULL", taskID, index).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active == 0 {
+			return fmt.Errorf("storage: message %d of task %s: %w", index, taskID, ErrNotFound)
+		}
+		if _, err := rewindMessages(tx, taskID, index); err != nil {
+			return err
+		}
+		var err error
+		payload, err = appendMessageIndex(tx, taskID, role, filePath)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	task.Status = TaskStatusPending
+	task.Version++
+	return payload, nil
+}
+
+// reopenTask는 task의 버전이 같을 때만 상태를 pending으로 바꿉니다.
+func reopenTask(tx *gorm.DB, task *Task) error {
+	return updateVersioned(tx, &Task{}, "task", "task_id", task.TaskID, task.Version, map[string]interface{}{
+		"status":     TaskStatusPending,
+		"updated_at": time.Now(),
+	})
+}
+
+// rewindMessages는 메시지 카운터를 먼저 갱신해 같은 작업의 다른 추가를 기다리게 한 뒤 메시지를 대체합니다.
+func rewindMessages(tx *gorm.DB, taskID string, fromIndex int) (int64, error) {
+	if fromIndex < 0 {
+		return 0, fmt.Errorf("storage: invalid conversation index %d", fromIndex)
+	}
+	res := tx.Exec("UPDATE tasks SET next_message_index = ? WHERE task_id = ? AND next_message_index >= ?", fromIndex, taskID, fromIndex)
+	if res.Error != nil {
+		return 0, fmt.Errorf("storage: failed to rewind conversation index: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		return 0, fmt.Errorf("storage: task %s has no message %d: %w", taskID, fromIndex, ErrNotFound)
+	}
+	res = tx.Model(&MessageIndex{}).
+		Where("task_id = ? AND conversation_index >= ? AND superseded_at IS NULL", taskID, fromIndex).
+		Updates(map[string]any{"superseded_at": time.Now().UTC()})
+	return res.RowsAffected, res.Error
+}
+
+// DeleteMessage는 index의 메시지를 대체된 버전까지 모두 영구 삭제하고, 그 메시지의 검색 문서도 지웁니다.
+// 삭제한 메시지 참조를 반환하므로 호출자가 메시지 본문을 지울 수 있습니다. 다른 메시지의 인덱스는 바뀌지 않습니다.
+func (r *Repository) DeleteMessage(ctx context.Context, taskID string, index int) ([]MessageIndex, error) {
+	var rows []MessageIndex
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ? AND conversation_index = ?", taskID, index).
+			Order("revision ASC").
+			Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return fmt.Errorf("storage: message %d of task %s: %w", index, taskID, ErrNotFound)
+		}
+		if err := tx.Where("task_id = ? AND conversation_index = ?", taskID, index).
+			Delete(&MessageIndex{}).Error; err != nil {
+			return err
+		}
+		refs := make([]string, len(rows))
+		for i, row := range rows {
+			refs[i] = row.FilePath
+		}
+		return tx.Where("task_id = ? AND source = ? AND ref IN ?", taskID, SearchSourceMessage, refs).
+			Delete(&SearchDocument{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// runStepColumns는 같은 번호의 실행 단계를 다시 기록할 때 덮어쓰는 컬럼입니다.
+var runStepColumns = []string{
+	"parent_step_no", "type", "status", "model", "input_ref", "output_ref",
+	"error_message", "error_class", "input_tokens", "output_tokens",
+	"started_at", "finished_at", "duration_ms",
+}
+
+// UpsertRunStep은 실행 단계를 생성하거나, 같은 번호의 단계가 있으면 생성 시각을 제외한 모든 필드를 덮어씁니다.
+// DurationMS가 비어 있고 StartedAt과 FinishedAt이 있으면 소요 시간을 계산해 함께 저장합니다.
 func (r *Repository) UpsertRunStep(ctx context.Context, step *RunStep) error {
 	if step == nil {
 		return fmt.Errorf("storage: nil run step payload")
@@ -219,10 +712,11 @@ func (r *Repository) UpsertRunStep(ctx context.Context, step *RunStep) error {
 	if step.CreatedAt.IsZero() {
 		step.CreatedAt = time.Now().UTC()
 	}
+	step.fillDuration()
 	return r.db.WithContext(ctx).
 		Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "task_id"}, {Name: "step_no"}},
-			DoUpdates: clause.AssignmentColumns([]string{"type", "status"}),
+			DoUpdates: clause.AssignmentColumns(runStepColumns),
 		}).
 		Create(step).Error
 }
@@ -266,3 +760,87 @@ func (r *Repository) ListCheckpoints(ctx context.Context, taskID string) ([]Chec
 	}
 	return checkpoints, nil
 }
+
+// AuditFilter는 감사 로그 조회 조건입니다. 비어 있는 필드는 무시됩니다.
+type AuditFilter struct {
+	AgentID string
+	Actor   string
+	Action  string
+	Since   time.Time
+	Until   time.Time
+	Limit   int
+}
+
+// CreateAuditEvent는 감사 로그를 추가합니다.
+func (r *Repository) CreateAuditEvent(ctx context.Context, event *AuditEvent) error {
+	if event == nil {
+		return fmt.Errorf("storage: nil audit event payload")
+	}
+	if event.Action == "" {
+		return fmt.Errorf("storage: empty audit action")
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now().UTC()
+	}
+	return r.db.WithContext(ctx).Create(event).Error
+}
+
+// ListAuditEvents는 조건에 맞는 감사 로그를 발생 순서대로 반환합니다.
+// Limit이 지정되면 가장 최근 이벤트부터 Limit개만 반환합니다.
+func (r *Repository) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error) {
+	q := r.db.WithContext(ctx).Model(&AuditEvent{})
+	if filter.AgentID != "" {
+		q = q.Where("agent_id = ?", filter.AgentID)
+	}
+	if filter.Actor != "" {
+		q = q.Where("actor = ?", filter.Actor)
+	}
+	if filter.Action != "" {
+		q = q.Where("action = ?", filter.Action)
+	}
+	if !filter.Since.IsZero() {
+		q = q.Where("created_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		q = q.Where("created_at < ?", filter.Until)
+	}
+	var events []AuditEvent
+	if filter.Limit > 0 {
+		// 최근 이벤트 Limit개를 가져온 뒤 발생 순서로 되돌립니다.
+		if err := q.Order("created_at DESC").Order("id DESC").Limit(filter.Limit).Find(&events).Error; err != nil {
+			return nil, err
+		}
+		for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+			events[i], events[j] = events[j], events[i]
+		}
+		return events, nil
+	}
+	if err := q.Order("created_at ASC").Order("id ASC").Find(&events).Error; err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// updateVersioned는 keyColumn이 id이고 버전이 expected인 행만 updates로 갱신하며 버전을 1 올립니다.
+// 갱신된 행이 없으면 행이 사라졌는지 확인해 gorm.ErrRecordNotFound 또는 *ConflictError를 반환합니다.
+func updateVersioned(db *gorm.DB, model interface{}, kind, keyColumn, id string, expected int, updates map[string]interface{}) error {
+	updates["version"] = gorm.Expr("version + 1")
+	res := db.Model(model).
+		Where(keyColumn+" = ? AND version = ?", id, expected).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 {
+		return nil
+	}
+
+	var versions []int
+	if err := db.Model(model).Where(keyColumn+" = ?", id).Pluck("version", &versions).Error; err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return &ConflictError{Kind: kind, ID: id, Expected: expected, Actual: versions[0]}
+}
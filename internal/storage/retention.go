@@ -0,0 +1,240 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// retentionBatchSize는 보존 정책으로 작업을 조회하거나 삭제할 때 한 번에 다루는 작업 수입니다.
+const retentionBatchSize = 500
+
+// TerminalTaskStatuses는 더 이상 실행되지 않아 보존 정책으로 정리할 수 있는 작업 상태입니다.
+var TerminalTaskStatuses = []string{TaskStatusCompleted, TaskStatusFailed, TaskStatusCanceled}
+
+// RetentionRule은 작업과 그에 딸린 메시지, 실행 단계, 체크포인트를 얼마나 보관할지 정하는 규칙입니다.
+// OlderThan과 KeepLast를 함께 지정하면 두 조건을 모두 만족하는 작업만 정리합니다.
+type RetentionRule struct {
+	// AgentID가 비어 있으면 에이전트별 규칙이 없는 모든 에이전트에 적용되는 전역 규칙입니다.
+	AgentID string
+	// Statuses가 비어 있으면 TerminalTaskStatuses를 사용합니다.
+	Statuses []string
+	// OlderThan은 작업이 마지막으로 갱신된 뒤 지나야 하는 기간입니다. 0이면 기간을 따지지 않습니다.
+	OlderThan time.Duration
+	// KeepLast는 에이전트마다 남겨 둘 최근 작업 수입니다. 0이면 개수를 따지지 않습니다.
+	KeepLast int
+	// Labels가 있으면 셀렉터와 일치하는 작업에만 규칙을 적용합니다. KeepLast도 일치하는 작업 중에서 셉니다.
+	Labels LabelSelector
+}
+
+// TaskUsage는 작업들이 차지하는 행 수와 메시지 저장소 경로입니다.
+type TaskUsage struct {
+	Tasks           int64
+	Messages        int64
+	RunSteps        int64
+	Checkpoints     int64
+	SearchDocuments int64
+	// Files는 메시지 본문의 저장소 경로입니다.
+	Files []string
+}
+
+// Add는 other의 행 수와 경로를 u에 더합니다.
+func (u *TaskUsage) Add(other *TaskUsage) {
+	u.Tasks += other.Tasks
+	u.Messages += other.Messages
+	u.RunSteps += other.RunSteps
+	u.Checkpoints += other.Checkpoints
+	u.SearchDocuments += other.SearchDocuments
+	u.Files = append(u.Files, other.Files...)
+}
+
+// Rows는 모든 테이블의 행 수 합계입니다.
+func (u *TaskUsage) Rows() int64 {
+	return u.Tasks + u.Messages + u.RunSteps + u.Checkpoints + u.SearchDocuments
+}
+
+// ExpiredTasks는 rule에 따라 정리할 작업 ID를 반환합니다.
+// 전역 규칙이면 excludeAgents의 에이전트는 건너뜁니다. 대기 중이거나 실행 중인 작업은 반환하지 않습니다.
+func (r *Repository) ExpiredTasks(ctx context.Context, rule RetentionRule, excludeAgents []string, now time.Time) ([]string, error) {
+	if rule.OlderThan <= 0 && rule.KeepLast <= 0 {
+		return nil, fmt.Errorf("storage: retention rule needs older_than or keep_last")
+	}
+	statuses := rule.Statuses
+	if len(statuses) == 0 {
+		statuses = TerminalTaskStatuses
+	}
+
+	q := r.db.WithContext(ctx).Model(&Task{}).
+		Select("task_id", "agent_id", "updated_at").
+		Where("status IN ?", statuses).
+		Where("status NOT IN ?", []string{TaskStatusPending, TaskStatusRunning})
+	if rule.AgentID != "" {
+		q = q.Where("agent_id = ?", rule.AgentID)
+	} else if len(excludeAgents) > 0 {
+		q = q.Where("agent_id NOT IN ?", excludeAgents)
+	}
+	q = whereLabels(q, LabelTargetTask, "task_id", rule.Labels)
+	cutoff := now.Add(-rule.OlderThan)
+	if rule.KeepLast <= 0 {
+		// 개수를 세지 않아도 되면 기간 조건을 데이터베이스에서 바로 거릅니다.
+		q = q.Where("updated_at < ?", cutoff)
+	}
+
+	rows, err := q.Order("agent_id ASC").Order("created_at DESC").Order("id DESC").Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var (
+		expired []string
+		agent   string
+		seen    int
+	)
+	for rows.Next() {
+		var task Task
+		if err := r.db.ScanRows(rows, &task); err != nil {
+			return nil, err
+		}
+		if task.AgentID != agent {
+			agent, seen = task.AgentID, 0
+		}
+		seen++
+		if rule.KeepLast > 0 && seen <= rule.KeepLast {
+			continue
+		}
+		if rule.OlderThan > 0 && !task.UpdatedAt.Before(cutoff) {
+			continue
+		}
+		expired = append(expired, task.TaskID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return expired, nil
+}
+
+// TaskUsage는 작업들과 그에 딸린 행 수, 메시지 저장소 경로를 집계합니다. 아무것도 지우지 않습니다.
+func (r *Repository) TaskUsage(ctx context.Context, taskIDs []string) (*TaskUsage, error) {
+	usage := &TaskUsage{}
+	db := r.db.WithContext(ctx)
+	for _, batch := range chunkStrings(taskIDs, retentionBatchSize) {
+		var part TaskUsage
+		counts := []struct {
+			model interface{}
+			dst   *int64
+		}{
+			{&Task{}, &part.Tasks},
+			{&MessageIndex{}, &part.Messages},
+			{&RunStep{}, &part.RunSteps},
+			{&Checkpoint{}, &part.Checkpoints},
+			{&SearchDocument{}, &part.SearchDocuments},
+		}
+		for _, c := range counts {
+			if err := db.Model(c.model).Where("task_id IN ?", batch).Count(c.dst).Error; err != nil {
+				return nil, err
+			}
+		}
+		if err := db.Model(&MessageIndex{}).
+			Where("task_id IN ?", batch).
+			Order("id ASC").
+			Pluck("file_path", &part.Files).Error; err != nil {
+			return nil, err
+		}
+		usage.Add(&part)
+	}
+	return usage, nil
+}
+
+// DeleteTasks는 작업과 그에 딸린 메시지 인덱스, 실행 단계, 체크포인트, 검색 문서를 영구 삭제하고
+// 삭제한 행 수와 메시지 저장소 경로, 삭제한 작업을 반환합니다. 그 사이 다시 실행된 작업은 건너뜁니다.
+// 메시지 본문은 저장소에 남아 있으므로 호출자가 반환된 경로를 지워야 합니다.
+func (r *Repository) DeleteTasks(ctx context.Context, taskIDs []string) (*TaskUsage, []Task, error) {
+	usage := &TaskUsage{}
+	var deleted []Task
+	for _, batch := range chunkStrings(taskIDs, retentionBatchSize) {
+		var (
+			part  *TaskUsage
+			tasks []Task
+		)
+		err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("task_id IN ?", batch).
+				Where("status NOT IN ?", []string{TaskStatusPending, TaskStatusRunning}).
+				Order("id ASC").
+				Find(&tasks).Error; err != nil {
+				return err
+			}
+			if len(tasks) == 0 {
+				return nil
+			}
+			ids := make([]string, len(tasks))
+			for i := range tasks {
+				ids[i] = tasks[i].TaskID
+			}
+			var err error
+			part, err = deleteTaskData(tx, ids)
+			if err != nil {
+				return err
+			}
+			res := tx.Where("task_id IN ?", ids).Delete(&Task{})
+			part.Tasks = res.RowsAffected
+			return res.Error
+		})
+		if err != nil {
+			// 앞선 배치는 이미 커밋되었으므로 지금까지 삭제한 결과를 함께 반환합니다.
+			return usage, deleted, err
+		}
+		if part != nil {
+			usage.Add(part)
+			deleted = append(deleted, tasks...)
+		}
+	}
+	return usage, deleted, nil
+}
+
+// deleteTaskData는 tasks(작업 ID 목록 또는 작업 ID 서브쿼리)에 속한 메시지 인덱스, 실행 단계,
+// 체크포인트, 검색 문서, 레이블을 삭제하고 삭제한 행 수와 메시지 저장소 경로를 반환합니다. 작업 행은 지우지 않습니다.
+func deleteTaskData(tx *gorm.DB, tasks interface{}) (*TaskUsage, error) {
+	usage := &TaskUsage{}
+	if err := tx.Model(&MessageIndex{}).
+		Where("task_id IN (?)", tasks).
+		Order("id ASC").
+		Pluck("file_path", &usage.Files).Error; err != nil {
+		return nil, err
+	}
+	deletes := []struct {
+		model interface{}
+		dst   *int64
+	}{
+		{&MessageIndex{}, &usage.Messages},
+		{&RunStep{}, &usage.RunSteps},
+		{&Checkpoint{}, &usage.Checkpoints},
+		{&SearchDocument{}, &usage.SearchDocuments},
+	}
+	for _, d := range deletes {
+		res := tx.Where("task_id IN (?)", tasks).Delete(d.model)
+		if res.Error != nil {
+			return nil, res.Error
+		}
+		*d.dst = res.RowsAffected
+	}
+	if err := tx.Where("target_type = ? AND target_id IN (?)", LabelTargetTask, tasks).Delete(&Label{}).Error; err != nil {
+		return nil, err
+	}
+	return usage, nil
+}
+
+// chunkStrings는 values를 size개씩 나눕니다.
+func chunkStrings(values []string, size int) [][]string {
+	var chunks [][]string
+	for len(values) > size {
+		chunks = append(chunks, values[:size])
+		values = values[size:]
+	}
+	if len(values) > 0 {
+		chunks = append(chunks, values)
+	}
+	return chunks
+}
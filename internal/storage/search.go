@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SearchFilter는 SearchTasks의 검색 조건입니다.
+type SearchFilter struct {
+	// Query는 공백으로 구분한 검색어입니다. 모든 단어가 (접두사로) 포함된 문서를 찾습니다.
+	Query   string
+	AgentID string
+	// Limit이 0 이하이면 20개를 반환합니다. MaxPageSize를 넘을 수 없습니다.
+	Limit int
+}
+
+const defaultSearchLimit = 20
+
+// SearchTerms는 검색어를 전문 검색에 사용할 단어로 나눕니다.
+// 글자와 숫자가 아닌 문자는 구분자로 취급하며, 단어는 소문자로 바꿉니다.
+func SearchTerms(query string) []string {
+	return strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// IndexSearchDocument는 검색 인덱스 문서를 추가하거나, 같은 작업·출처·참조의 문서가 있으면 본문을 바꿉니다.
+func (r *Repository) IndexSearchDocument(ctx context.Context, doc *SearchDocument) error {
+	if doc == nil {
+		return fmt.Errorf("storage: nil search document")
+	}
+	if doc.TaskID == "" || doc.Source == "" {
+		return fmt.Errorf("storage: search document requires task and source")
+	}
+	return upsertSearchDocument(r.db.WithContext(ctx), doc)
+}
+
+func upsertSearchDocument(db *gorm.DB, doc *SearchDocument) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "task_id"}, {Name: "source"}, {Name: "ref"}},
+		DoUpdates: clause.AssignmentColumns([]string{"agent_id", "body"}),
+	}).Create(doc).Error
+}
+
+// SearchTasks는 작업 프롬프트와 메시지 본문을 전문 검색합니다.
+// PostgreSQL은 tsvector GIN 인덱스를, SQLite는 FTS5(없으면 FTS4) 테이블을 사용하며,
+// FTS5와 PostgreSQL은 관련도 순, FTS4는 최신 순으로 반환합니다.
+func (r *Repository) SearchTasks(ctx context.Context, filter SearchFilter) ([]SearchDocument, error) {
+	terms := SearchTerms(filter.Query)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("storage: empty search query")
+	}
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > MaxPageSize {
+		limit = MaxPageSize
+	}
+
+	q := r.db.WithContext(ctx).Table("task_search_docs AS d").Select("d.*")
+	switch r.db.Dialector.Name() {
+	case dialectPostgres:
+		prefixed := make([]string, len(terms))
+		for i, term := range terms {
+			prefixed[i] = term + ":*"
+		}
+		tsquery := strings.Join(prefixed, " & ")
+		q = q.Where("to_tsvector('simple', d.body) @@ to_tsquery('simple', ?)", tsquery).
+			Order(clause.OrderBy{Expression: clause.Expr{
+				SQL:  "ts_rank(to_tsvector('simple', d.body), to_tsquery('simple', ?)) DESC",
+				Vars: []any{tsquery},
+			}})
+	default:
+		prefixed := make([]string, len(terms))
+		for i, term := range terms {
+			prefixed[i] = term + "*"
+		}
+		q = q.Joins("JOIN task_search_fts ON task_search_fts.rowid = d.id").
+			Where("task_search_fts MATCH ?", strings.Join(prefixed, " "))
+		fts5, err := r.sqliteFTS5(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if fts5 {
+			q = q.Order("task_search_fts.rank")
+		}
+	}
+	if filter.AgentID != "" {
+		q = q.Where("d.agent_id = ?", filter.AgentID)
+	}
+
+	var docs []SearchDocument
+	if err := q.Order("d.created_at DESC").Order("d.id DESC").Limit(limit).Find(&docs).Error; err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
+
+// sqliteFTS5는 SQLite 검색 테이블이 FTS5로 만들어졌는지 확인합니다.
+func (r *Repository) sqliteFTS5(ctx context.Context) (bool, error) {
+	var ddl string
+	if err := r.db.WithContext(ctx).
+		Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'task_search_fts'").
+		Scan(&ddl).Error; err != nil {
+		return false, err
+	}
+	return strings.Contains(strings.ToLower(ddl), "using fts5"), nil
+}
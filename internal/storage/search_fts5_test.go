@@ -0,0 +1,34 @@
+//go:build sqlite_fts5
+
+package storage_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// sqlite_fts5 태그로 빌드하면 검색 인덱스는 FTS4 대체 정의가 아니라 FTS5로 만들어지고, 결과는 관련도 순입니다.
+// make test-fts5가 이 테스트를 실행합니다.
+func TestRepositorySearchTasksFTS5(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	var ddl string
+	require.NoError(t, repo.DB().Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'task_search_fts'").Scan(&ddl).Error)
+	require.Contains(t, strings.ToLower(ddl), "using fts5")
+
+	// FTS4 대체 정의는 최신순이므로, 먼저 만든 관련도 높은 작업이 앞에 오는 것은 FTS5 순위에서만 가능합니다.
+	ctx := context.Background()
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "t1", AgentID: "support", Status: storage.TaskStatusPending, Prompt: "refund refund refund"}))
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "t2", AgentID: "support", Status: storage.TaskStatusPending, Prompt: "refund policy for orders shipped abroad last month"}))
+
+	docs, err := repo.SearchTasks(ctx, storage.SearchFilter{Query: "refund"})
+	require.NoError(t, err)
+	require.Len(t, docs, 2)
+	require.Equal(t, "t1", docs[0].TaskID)
+	require.Equal(t, "t2", docs[1].TaskID)
+}
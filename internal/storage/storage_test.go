@@ -2,9 +2,15 @@ package storage_test
 
 import (
 	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/cnap-oss/app/internal/storage"
+	"github.com/cnap-oss/app/internal/storage/storagetest"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
@@ -16,7 +22,7 @@ func newTestRepository(t *testing.T) (*storage.Repository, func()) {
 	db, err := gorm.Open(sqlite.Open("file::memory:?cache=shared"), &gorm.Config{})
 	require.NoError(t, err)
 
-	require.NoError(t, storage.AutoMigrate(db))
+	require.NoError(t, storage.MigrateUp(context.Background(), db))
 
 	repo, err := storage.NewRepository(db)
 	require.NoError(t, err)
@@ -47,8 +53,8 @@ func TestRepositoryAgentLifecycle(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, storage.AgentStatusActive, agent.Status)
 
-	// 상태 upsert
-	err = repo.UpsertAgentStatus(ctx, "agent-test", storage.AgentStatusBusy)
+	// 상태 변경
+	err = repo.UpdateAgentStatus(ctx, agent, storage.AgentStatusBusy)
 	require.NoError(t, err)
 
 	updated, err := repo.GetAgent(ctx, "agent-test")
@@ -80,7 +86,7 @@ func TestRepositoryTaskAndMessages(t *testing.T) {
 	}
 	require.NoError(t, repo.CreateTask(ctx, task))
 
-	require.NoError(t, repo.UpsertTaskStatus(ctx, "task-1", "agent-1", storage.TaskStatusRunning))
+	require.NoError(t, repo.UpdateTaskStatus(ctx, task, storage.TaskStatusRunning))
 
 	fetchedTask, err := repo.GetTask(ctx, "task-1")
 	require.NoError(t, err)
@@ -161,6 +167,83 @@ func TestRepositoryMessageIndexAutoIncrement(t *testing.T) {
 	require.Equal(t, storage.MessageRoleUser, messages[2].Role)
 }
 
+func TestRepositoryAppendMessageIndexConcurrent(t *testing.T) {
+	const writers, perWriter = 8, 25
+
+	// 공유 캐시(cache=shared) DB는 연결 사이의 테이블 잠금(SQLITE_LOCKED)을 busy timeout으로 기다리지 않으므로,
+	// 인메모리 케이스는 여러 연결이 일반 파일 잠금으로 공유하는 memdb VFS를 사용합니다.
+	for name, dsn := range map[string]func(t *testing.T) string{
+		"memory": func(t *testing.T) string {
+			return fmt.Sprintf("file:/%s?vfs=memdb&_busy_timeout=5000", strings.ReplaceAll(t.Name(), "/", "-"))
+		},
+		"file": func(t *testing.T) string {
+			return filepath.Join(t.TempDir(), "cnap.db") + "?_journal_mode=WAL&_busy_timeout=5000"
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			db, err := gorm.Open(sqlite.Open(dsn(t)), &gorm.Config{})
+			require.NoError(t, err)
+			defer func() { require.NoError(t, storage.Close(db)) }()
+			require.NoError(t, storage.MigrateUp(context.Background(), db))
+			repo, err := storage.NewRepository(db)
+			require.NoError(t, err)
+			sqlDB, err := db.DB()
+			require.NoError(t, err)
+			sqlDB.SetMaxIdleConns(writers)
+
+			ctx := context.Background()
+			require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "agent-1", Status: storage.TaskStatusRunning}))
+
+			// 여러 사용자가 같은 작업에 동시에 메시지를 추가합니다.
+			var wg sync.WaitGroup
+			errs := make(chan error, writers*perWriter)
+			// 다른 연결이 쓰기 잠금을 잡고 있는 동안 시작해, 작성자들이 busy timeout으로 잠금을 기다리게 합니다.
+			lock := db.Begin()
+			require.NoError(t, lock.Error)
+			require.NoError(t, lock.Exec("UPDATE tasks SET updated_at = updated_at WHERE task_id = ?", "task-1").Error)
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func(w int) {
+					defer wg.Done()
+					for i := 0; i < perWriter; i++ {
+						if _, err := repo.AppendMessageIndex(ctx, "task-1", storage.MessageRoleUser, fmt.Sprintf("w%d/%03d", w, i)); err != nil {
+							errs <- err
+						}
+					}
+				}(w)
+			}
+			time.Sleep(50 * time.Millisecond)
+			require.NoError(t, lock.Commit().Error)
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				require.NoError(t, err)
+			}
+
+			// 모든 메시지가 빠짐없이 연속된 인덱스를 받고, 작성자별 순서가 유지되어야 합니다.
+			messages, err := repo.ListMessageIndexByTask(ctx, "task-1")
+			require.NoError(t, err)
+			require.Len(t, messages, writers*perWriter)
+			last := map[string]string{}
+			for i, m := range messages {
+				require.Equal(t, i, m.ConversationIndex)
+				writer, seq, _ := strings.Cut(m.FilePath, "/")
+				require.Greater(t, seq, last[writer], "messages from %s were reordered", writer)
+				last[writer] = seq
+			}
+			require.Len(t, last, writers)
+			require.Greater(t, sqlDB.Stats().OpenConnections, 1, "writers should use separate connections")
+
+			next, err := repo.GetNextConversationIndex(ctx, "task-1")
+			require.NoError(t, err)
+			require.Equal(t, writers*perWriter, next)
+
+			_, err = repo.AppendMessageIndex(ctx, "task-missing", storage.MessageRoleUser, "x")
+			require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+		})
+	}
+}
+
 func TestRepositoryRunStepsAndCheckpoints(t *testing.T) {
 	repo, cleanup := newTestRepository(t)
 	defer cleanup()
@@ -206,3 +289,762 @@ func TestRepositoryRunStepsAndCheckpoints(t *testing.T) {
 	require.Len(t, checkpoints, 1)
 	require.Equal(t, "abc123", checkpoints[0].GitHash)
 }
+
+func TestRepositoryAuditEvents(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	require.NoError(t, repo.CreateAuditEvent(ctx, &storage.AuditEvent{
+		Actor:      "cli:alice",
+		Action:     storage.AuditActionAgentCreate,
+		TargetType: storage.AuditTargetAgent,
+		TargetID:   "agent-1",
+		AgentID:    "agent-1",
+		AfterState: `{"model":"gpt-4"}`,
+	}))
+	require.NoError(t, repo.CreateAuditEvent(ctx, &storage.AuditEvent{
+		Actor:      "discord:42",
+		Action:     storage.AuditActionTaskCancel,
+		TargetType: storage.AuditTargetTask,
+		TargetID:   "task-1",
+		AgentID:    "agent-1",
+	}))
+	require.NoError(t, repo.CreateAuditEvent(ctx, &storage.AuditEvent{
+		Actor:      "cli:alice",
+		Action:     storage.AuditActionAgentCreate,
+		TargetType: storage.AuditTargetAgent,
+		TargetID:   "agent-2",
+		AgentID:    "agent-2",
+	}))
+
+	events, err := repo.ListAuditEvents(ctx, storage.AuditFilter{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, storage.AuditActionAgentCreate, events[0].Action)
+	require.Equal(t, storage.AuditActionTaskCancel, events[1].Action)
+
+	events, err = repo.ListAuditEvents(ctx, storage.AuditFilter{Actor: "cli:alice", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "agent-2", events[0].TargetID)
+
+	// 감사 로그는 수정/삭제할 수 없음
+	err = repo.DB().Model(&events[0]).Update("actor", "someone-else").Error
+	require.ErrorIs(t, err, storage.ErrAuditEventImmutable)
+	err = repo.DB().Delete(&events[0]).Error
+	require.ErrorIs(t, err, storage.ErrAuditEventImmutable)
+
+	// 훅을 건너뛰는 경로는 DB 트리거가 거부합니다.
+	raw := repo.DB().Session(&gorm.Session{SkipHooks: true})
+	require.ErrorContains(t, raw.Model(&events[0]).Update("actor", "someone-else").Error, "append-only")
+	require.ErrorContains(t, raw.Delete(&events[0]).Error, "append-only")
+	require.ErrorContains(t, repo.DB().Exec("DELETE FROM audit_events").Error, "append-only")
+
+	// 백업 복원의 TruncateTables만 트랜잭션 안에서 트리거를 내렸다가 복구합니다.
+	require.NoError(t, repo.DB().Transaction(func(tx *gorm.DB) error {
+		return storage.TruncateTables(ctx, tx)
+	}))
+	events, err = repo.ListAuditEvents(ctx, storage.AuditFilter{})
+	require.NoError(t, err)
+	require.Empty(t, events)
+	require.NoError(t, repo.CreateAuditEvent(ctx, &storage.AuditEvent{
+		Actor:      "cli:alice",
+		Action:     storage.AuditActionAgentCreate,
+		TargetType: storage.AuditTargetAgent,
+		TargetID:   "agent-3",
+	}))
+	require.ErrorContains(t, repo.DB().Exec("DELETE FROM audit_events").Error, "append-only")
+}
+
+func TestRepositoryAgentRevisions(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	agent := &storage.Agent{AgentID: "agent-rev", Prompt: "v1", Status: storage.AgentStatusActive, OwnerID: "cli:alice"}
+	require.NoError(t, repo.CreateAgent(ctx, agent))
+	require.Equal(t, 1, agent.Revision)
+
+	update := &storage.Agent{AgentID: "agent-rev", Prompt: "v2", Version: agent.Version}
+	require.NoError(t, repo.UpdateAgent(ctx, update, "cli:bob"))
+	require.Equal(t, 2, update.Revision)
+
+	revisions, err := repo.ListAgentRevisions(ctx, "agent-rev")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, "cli:alice", revisions[0].Author)
+	require.Equal(t, "v1", revisions[0].Prompt)
+	require.Equal(t, "cli:bob", revisions[1].Author)
+	require.Equal(t, "v2", revisions[1].Prompt)
+
+	current, err := repo.GetAgent(ctx, "agent-rev")
+	require.NoError(t, err)
+	require.Equal(t, 2, current.Revision)
+	require.Equal(t, "v2", current.Prompt)
+
+	// 리비전은 변경할 수 없습니다.
+	rev, err := repo.GetAgentRevision(ctx, "agent-rev", 1)
+	require.NoError(t, err)
+	require.ErrorIs(t, repo.DB().Model(rev).Update("prompt", "tampered").Error, storage.ErrAgentRevisionImmutable)
+	require.ErrorIs(t, repo.DB().Delete(rev).Error, storage.ErrAgentRevisionImmutable)
+}
+
+func TestRepositoryOptimisticConcurrency(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	agent := &storage.Agent{AgentID: "agent-occ", Prompt: "v1", Status: storage.AgentStatusActive}
+	require.NoError(t, repo.CreateAgent(ctx, agent))
+	require.Equal(t, 1, agent.Version)
+
+	// 두 관리자가 같은 버전을 읽고 수정하면 나중 수정은 충돌합니다.
+	alice := &storage.Agent{AgentID: "agent-occ", Prompt: "alice", Version: agent.Version}
+	bob := &storage.Agent{AgentID: "agent-occ", Prompt: "bob", Version: agent.Version}
+	require.NoError(t, repo.UpdateAgent(ctx, alice, "cli:alice"))
+	require.Equal(t, 2, alice.Version)
+
+	err := repo.UpdateAgent(ctx, bob, "cli:bob")
+	require.ErrorIs(t, err, storage.ErrConflict)
+	var conflict *storage.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, storage.ConflictError{Kind: "agent", ID: "agent-occ", Expected: 1, Actual: 2}, *conflict)
+
+	current, err := repo.GetAgent(ctx, "agent-occ")
+	require.NoError(t, err)
+	require.Equal(t, "alice", current.Prompt)
+	require.Equal(t, 2, current.Revision)
+
+	// 상태 변경도 버전을 올립니다.
+	other, err := repo.GetAgent(ctx, "agent-occ")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateAgentStatus(ctx, other, storage.AgentStatusBusy))
+	require.ErrorIs(t, repo.UpdateAgentStatus(ctx, current, storage.AgentStatusIdle), storage.ErrConflict)
+
+	task := &storage.Task{TaskID: "task-occ", AgentID: "agent-occ", Status: storage.TaskStatusRunning}
+	require.NoError(t, repo.CreateTask(ctx, task))
+	require.Equal(t, 1, task.Version)
+
+	// 실행기가 읽은 뒤 작업이 취소되면 completed로 덮어쓰지 않습니다.
+	runner, err := repo.GetTask(ctx, "task-occ")
+	require.NoError(t, err)
+	canceler, err := repo.GetTask(ctx, "task-occ")
+	require.NoError(t, err)
+	require.NoError(t, repo.UpdateTaskStatus(ctx, canceler, storage.TaskStatusCanceled))
+	require.Equal(t, 2, canceler.Version)
+	require.ErrorIs(t, repo.UpdateTaskStatus(ctx, runner, storage.TaskStatusCompleted), storage.ErrConflict)
+	require.ErrorIs(t, repo.SetTaskAgentRevision(ctx, runner, 2, ""), storage.ErrConflict)
+
+	fetched, err := repo.GetTask(ctx, "task-occ")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCanceled, fetched.Status)
+	require.Equal(t, 2, fetched.Version)
+
+	// 없는 레코드는 충돌이 아니라 ErrRecordNotFound입니다.
+	missing := &storage.Task{TaskID: "task-missing", Version: 1}
+	require.ErrorIs(t, repo.UpdateTaskStatus(ctx, missing, storage.TaskStatusCanceled), gorm.ErrRecordNotFound)
+}
+
+func TestMigrationsMatchModels(t *testing.T) {
+	for _, dialect := range []string{"sqlite", "postgres"} {
+		migrations, err := storage.LoadMigrations(dialect)
+		require.NoError(t, err, dialect)
+		require.NotEmpty(t, migrations, dialect)
+	}
+
+	sqliteMigrations, err := storage.LoadMigrations("sqlite")
+	require.NoError(t, err)
+	postgresMigrations, err := storage.LoadMigrations("postgres")
+	require.NoError(t, err)
+	require.Len(t, postgresMigrations, len(sqliteMigrations), "dialects must ship the same migration versions")
+
+	// SQL 마이그레이션으로 만든 스키마가 Go 모델과 일치해야 합니다.
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+	require.NoError(t, storage.VerifyModels(repo.DB()))
+	require.NoError(t, repo.CheckSchema(context.Background()))
+}
+
+func TestMigratorUpDownStatus(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cnap.db")), &gorm.Config{})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, storage.Close(db)) }()
+
+	migrator, err := storage.NewMigrator(db)
+	require.NoError(t, err)
+	latest := migrator.Latest()
+
+	version, err := migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+	require.ErrorIs(t, storage.CheckSchema(ctx, db), storage.ErrSchemaVersionMismatch)
+
+	applied, err := migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Len(t, applied, latest)
+	require.NoError(t, storage.CheckSchema(ctx, db))
+
+	// 이미 최신이면 아무것도 적용하지 않습니다.
+	applied, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.Empty(t, applied)
+
+	statuses, err := migrator.Status(ctx)
+	require.NoError(t, err)
+	require.Len(t, statuses, latest)
+	for _, s := range statuses {
+		require.True(t, s.Applied, s.Name)
+		require.False(t, s.AppliedAt.IsZero(), s.Name)
+	}
+
+	reverted, err := migrator.Down(ctx, latest)
+	require.NoError(t, err)
+	require.Len(t, reverted, latest)
+	version, err = migrator.Version(ctx)
+	require.NoError(t, err)
+	require.Zero(t, version)
+	require.False(t, db.Migrator().HasTable("agents"))
+
+	// 되돌린 뒤 다시 적용할 수 있어야 합니다.
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+	require.NoError(t, storage.VerifyModels(db))
+}
+
+func TestMigratorConcurrentUp(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cnap.db")
+
+	var wg sync.WaitGroup
+	results := make([][]storage.Migration, 4)
+	errs := make([]error, 4)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer func() { _ = storage.Close(db) }()
+			migrator, err := storage.NewMigrator(db)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			results[i], errs[i] = migrator.Up(context.Background())
+		}(i)
+	}
+	wg.Wait()
+
+	// 잠금 덕분에 한 인스턴스만 마이그레이션을 적용합니다.
+	total := 0
+	for i := range results {
+		require.NoError(t, errs[i])
+		total += len(results[i])
+	}
+	migrations, err := storage.LoadMigrations("sqlite")
+	require.NoError(t, err)
+	require.Equal(t, len(migrations), total)
+}
+
+func TestRepositoryListPages(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, a := range []struct{ id, model, status string }{
+		{"bot_a", "gpt-4", storage.AgentStatusActive},
+		{"Bot-b", "gpt-4o", storage.AgentStatusActive},
+		{"botxc", "gpt-4", storage.AgentStatusDeleted},
+		{"other", "gpt-4", storage.AgentStatusIdle},
+	} {
+		require.NoError(t, repo.CreateAgent(ctx, &storage.Agent{
+			AgentID:   a.id,
+			Model:     a.model,
+			Prompt:    "긴 프롬프트",
+			Status:    a.status,
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	// 여러 작업이 같은 시각에 생성되어도 id 순으로 빠짐없이 페이지가 나뉘어야 합니다.
+	for i := 0; i < 7; i++ {
+		status := storage.TaskStatusPending
+		if i%2 == 1 {
+			status = storage.TaskStatusCompleted
+		}
+		require.NoError(t, repo.CreateTask(ctx, &storage.Task{
+			TaskID:    fmt.Sprintf("task-%d", i),
+			AgentID:   "bot_a",
+			Status:    status,
+			CreatedAt: base.Add(time.Duration(i/3) * time.Minute),
+		}))
+	}
+
+	collect := func(filter storage.TaskFilter) []string {
+		var ids []string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 10)
+			tasks, next, err := repo.ListTasksPage(ctx, filter)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(tasks), filter.Limit)
+			for _, task := range tasks {
+				ids = append(ids, task.TaskID)
+			}
+			if next == "" {
+				return ids
+			}
+			filter.Cursor = next
+		}
+	}
+	all := []string{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5", "task-6"}
+	require.Equal(t, all, collect(storage.TaskFilter{AgentID: "bot_a", Limit: 2}))
+	require.Equal(t, []string{"task-6", "task-5", "task-4", "task-3", "task-2", "task-1", "task-0"},
+		collect(storage.TaskFilter{AgentID: "bot_a", Sort: "-created", Limit: 3}))
+	require.Equal(t, []string{"task-1", "task-3", "task-5"},
+		collect(storage.TaskFilter{Statuses: []string{storage.TaskStatusCompleted}, Sort: "name", Limit: 1}))
+	require.Equal(t, []string{"task-3", "task-4", "task-5"},
+		collect(storage.TaskFilter{CreatedAfter: base.Add(time.Minute), CreatedBefore: base.Add(2 * time.Minute), Limit: 10}))
+
+	// 다른 정렬 기준의 커서는 거부합니다.
+	_, next, err := repo.ListTasksPage(ctx, storage.TaskFilter{Limit: 1})
+	require.NoError(t, err)
+	_, _, err = repo.ListTasksPage(ctx, storage.TaskFilter{Limit: 1, Sort: "name", Cursor: next})
+	require.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = repo.ListTasksPage(ctx, storage.TaskFilter{Cursor: "not-a-cursor"})
+	require.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = repo.ListTasksPage(ctx, storage.TaskFilter{Sort: "status"})
+	require.Error(t, err)
+
+	// 이름 접두사는 대소문자를 무시하고 LIKE 와일드카드를 문자 그대로 취급합니다.
+	agents, next, err := repo.ListAgentsPage(ctx, storage.AgentFilter{NamePrefix: "bot_", Sort: storage.SortName})
+	require.NoError(t, err)
+	require.Empty(t, next)
+	require.Len(t, agents, 1)
+	require.Equal(t, "bot_a", agents[0].AgentID)
+	require.Empty(t, agents[0].Prompt, "목록 조회는 프롬프트를 읽지 않습니다")
+
+	agents, _, err = repo.ListAgentsPage(ctx, storage.AgentFilter{NamePrefix: "BOT", Model: "gpt-4", Statuses: []string{storage.AgentStatusActive, storage.AgentStatusDeleted}})
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+	require.Equal(t, "bot_a", agents[0].AgentID)
+	require.Equal(t, "botxc", agents[1].AgentID)
+
+	agents, next, err = repo.ListAgentsPage(ctx, storage.AgentFilter{Sort: "-created", Limit: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+	require.Equal(t, "other", agents[0].AgentID)
+
+	// 상태를 지정하지 않으면 삭제된 에이전트는 제외합니다.
+	agents, _, err = repo.ListAgentsPage(ctx, storage.AgentFilter{Sort: storage.SortName})
+	require.NoError(t, err)
+	names := make([]string, 0, len(agents))
+	for _, a := range agents {
+		names = append(names, a.AgentID)
+	}
+	require.Equal(t, []string{"Bot-b", "bot_a", "other"}, names)
+}
+
+func TestRepositorySearchTasks(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "t1", AgentID: "billing", Status: storage.TaskStatusPending, Prompt: "결제가 실패했어요"}))
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "t2", AgentID: "support", Status: storage.TaskStatusPending, Prompt: "Refund my ORDER please"}))
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "t3", AgentID: "support", Status: storage.TaskStatusPending}))
+	require.NoError(t, repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+		TaskID: "t3", AgentID: "support", Source: storage.SearchSourceMessage, Ref: "messages/t3/1.json", Body: "order status?",
+	}))
+
+	ids := func(filter storage.SearchFilter) []string {
+		docs, err := repo.SearchTasks(ctx, filter)
+		require.NoError(t, err)
+		var out []string
+		for _, d := range docs {
+			out = append(out, d.TaskID)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"t1"}, ids(storage.SearchFilter{Query: "결제"}))
+	require.ElementsMatch(t, []string{"t2", "t3"}, ids(storage.SearchFilter{Query: "order"}))
+	require.Equal(t, []string{"t2"}, ids(storage.SearchFilter{Query: "refund, ord"}))
+	require.Equal(t, []string{"t3"}, ids(storage.SearchFilter{Query: "ORDER stat"}))
+	require.Empty(t, ids(storage.SearchFilter{Query: "order", AgentID: "billing"}))
+
+	// 같은 문서를 다시 색인하면 본문이 바뀌고 이전 단어로는 찾을 수 없습니다.
+	require.NoError(t, repo.IndexSearchDocument(ctx, &storage.SearchDocument{
+		TaskID: "t3", AgentID: "support", Source: storage.SearchSourceMessage, Ref: "messages/t3/1.json", Body: "배송 문의",
+	}))
+	require.Equal(t, []string{"t2"}, ids(storage.SearchFilter{Query: "order"}))
+	require.Equal(t, []string{"t3"}, ids(storage.SearchFilter{Query: "배송"}))
+
+	_, err := repo.SearchTasks(ctx, storage.SearchFilter{Query: " ,. "})
+	require.Error(t, err)
+
+	// 프롬프트 부분 문자열로 목록을 거를 수 있습니다.
+	tasks, _, err := repo.ListTasksPage(ctx, storage.TaskFilter{PromptContains: "refund my"})
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+	require.Equal(t, "t2", tasks[0].TaskID)
+}
+
+func TestParseLabelsAndSelector(t *testing.T) {
+	labels, err := storage.ParseLabels([]string{"ticket=ABC-12", "team = infra", "example.com/owner=alice", "flag="})
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"ticket": "ABC-12", "team": "infra", "example.com/owner": "alice", "flag": ""}, labels)
+	require.Equal(t, "example.com/owner=alice,flag=,team=infra,ticket=ABC-12", storage.FormatLabels(labels))
+
+	for _, bad := range [][]string{{"ticket"}, {"a=1", "a=2"}, {"-team=x"}, {"team=a b"}, {strings.Repeat("k", 64) + "=v"}} {
+		_, err := storage.ParseLabels(bad)
+		require.Error(t, err, bad)
+	}
+
+	selector, err := storage.ParseLabelSelector(" team=infra, env!=prod,tier==gold,ticket,!archived ")
+	require.NoError(t, err)
+	require.Equal(t, storage.LabelSelector{
+		{Key: "team", Operator: storage.LabelOpEquals, Value: "infra"},
+		{Key: "env", Operator: storage.LabelOpNotEquals, Value: "prod"},
+		{Key: "tier", Operator: storage.LabelOpEquals, Value: "gold"},
+		{Key: "ticket", Operator: storage.LabelOpExists},
+		{Key: "archived", Operator: storage.LabelOpNotExists},
+	}, selector)
+	require.Equal(t, "team=infra,env!=prod,tier=gold,ticket,!archived", selector.String())
+	require.True(t, selector.Matches(map[string]string{"team": "infra", "tier": "gold", "ticket": "1"}))
+	require.False(t, selector.Matches(map[string]string{"team": "infra", "tier": "gold", "ticket": "1", "env": "prod"}))
+	require.False(t, selector.Matches(map[string]string{"team": "infra", "tier": "gold", "ticket": "1", "archived": ""}))
+
+	selector, err = storage.ParseLabelSelector("")
+	require.NoError(t, err)
+	require.True(t, selector.Matches(nil))
+	for _, bad := range []string{"team=infra,", "!", "team=a b", "=x"} {
+		_, err := storage.ParseLabelSelector(bad)
+		require.Error(t, err, bad)
+	}
+}
+
+func TestRepositoryRetention(t *testing.T) {
+	repo, cleanup := newTestRepository(t)
+	defer cleanup()
+	ctx := context.Background()
+	now := time.Now()
+
+	require.NoError(t, repo.CreateAgent(ctx, &storage.Agent{AgentID: "a", Status: storage.AgentStatusActive}))
+	require.NoError(t, repo.CreateAgent(ctx, &storage.Agent{AgentID: "b", Status: storage.AgentStatusActive}))
+	// a-1이 가장 오래되었고 a-4가 가장 최근 작업입니다. a-4는 아직 실행 중입니다.
+	for i, status := range []string{storage.TaskStatusCompleted, storage.TaskStatusFailed, storage.TaskStatusCompleted, storage.TaskStatusRunning} {
+		id := fmt.Sprintf("a-%d", i+1)
+		require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: id, AgentID: "a", Status: status}))
+		age := time.Duration(4-i) * 24 * time.Hour
+		require.NoError(t, repo.DB().Model(&storage.Task{}).Where("task_id = ?", id).
+			UpdateColumns(map[string]interface{}{"created_at": now.Add(-age), "updated_at": now.Add(-age)}).Error)
+	}
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "b-1", AgentID: "b", Status: storage.TaskStatusCompleted}))
+	require.NoError(t, repo.DB().Model(&storage.Task{}).Where("task_id = ?", "b-1").
+		UpdateColumn("updated_at", now.Add(-10*24*time.Hour)).Error)
+
+	expired := func(rule storage.RetentionRule, exclude ...string) []string {
+		ids, err := repo.ExpiredTasks(ctx, rule, exclude, now)
+		require.NoError(t, err)
+		return ids
+	}
+
+	// 실행 중인 작업은 기간이 지나도 정리하지 않습니다.
+	require.ElementsMatch(t, []string{"a-1", "a-2", "b-1"}, expired(storage.RetentionRule{OlderThan: 60 * time.Hour}))
+	require.ElementsMatch(t, []string{"a-1", "a-2"}, expired(storage.RetentionRule{OlderThan: 60 * time.Hour}, "b"))
+	require.Equal(t, []string{"a-2"}, expired(storage.RetentionRule{AgentID: "a", Statuses: []string{storage.TaskStatusFailed}, OlderThan: time.Hour}))
+	// keep_last는 에이전트마다 최근 작업을 남기고, older_than과 함께 쓰면 두 조건을 모두 봅니다.
+	require.ElementsMatch(t, []string{"a-2", "a-1"}, expired(storage.RetentionRule{KeepLast: 1}))
+	require.Equal(t, []string{"a-1"}, expired(storage.RetentionRule{KeepLast: 1, OlderThan: 80 * time.Hour}))
+	_, err := repo.ExpiredTasks(ctx, storage.RetentionRule{}, nil, now)
+	require.Error(t, err)
+
+	for _, id := range []string{"a-1", "a-4"} {
+		_, err := repo.AppendMessageIndex(ctx, id, storage.MessageRoleUser, "messages/"+id+"/1.json")
+		require.NoError(t, err)
+		require.NoError(t, repo.UpsertRunStep(ctx, &storage.RunStep{TaskID: id, StepNo: 1, Type: storage.RunStepTypeModel, Status: storage.RunStepStatusCompleted}))
+	}
+
+	usage, err := repo.TaskUsage(ctx, []string{"a-1", "b-1"})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), usage.Tasks)
+	require.Equal(t, int64(1), usage.Messages)
+	require.Equal(t, int64(1), usage.RunSteps)
+	require.Equal(t, []string{"messages/a-1/1.json"}, usage.Files)
+
+	// 실행 중인 작업은 목록에 있어도 삭제하지 않습니다.
+	usage, deleted, err := repo.DeleteTasks(ctx, []string{"a-1", "a-4", "missing"})
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, "a-1", deleted[0].TaskID)
+	require.Equal(t, int64(3), usage.Rows())
+	require.Equal(t, []string{"messages/a-1/1.json"}, usage.Files)
+
+	_, err = repo.GetTask(ctx, "a-1")
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+	steps, err := repo.ListRunSteps(ctx, "a-4")
+	require.NoError(t, err)
+	require.Len(t, steps, 1)
+}
+
+func TestCopyTablesResumeAndVerify(t *testing.T) {
+	ctx := context.Background()
+	open := func(name string) (*gorm.DB, *storage.Repository) {
+		db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), name)), &gorm.Config{})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, storage.Close(db)) })
+		require.NoError(t, storage.MigrateUp(ctx, db))
+		repo, err := storage.NewRepository(db)
+		require.NoError(t, err)
+		return db, repo
+	}
+	srcDB, src := open("src.db")
+	dstDB, dst := open("dst.db")
+
+	require.NoError(t, src.CreateAgent(ctx, &storage.Agent{AgentID: "bot", Model: "gpt-4", Status: storage.AgentStatusActive}))
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("task-%d", i)
+		require.NoError(t, src.CreateTask(ctx, &storage.Task{TaskID: id, AgentID: "bot", Prompt: "hello " + id, Status: storage.TaskStatusPending}))
+		_, err := src.AppendMessageIndex(ctx, id, storage.MessageRoleUser, "messages/"+id+"/1.json")
+		require.NoError(t, err)
+	}
+
+	// 두 번째 배치를 커밋한 뒤 중단합니다.
+	stopCtx, stop := context.WithCancel(ctx)
+	batches := 0
+	err := storage.CopyTables(stopCtx, srcDB, dstDB, storage.CopyOptions{
+		BatchSize: 2,
+		Progress: func(p storage.CopyProgress) {
+			if p.Table == "tasks" && p.Copied > 0 {
+				if batches++; batches == 2 {
+					stop()
+				}
+			}
+		},
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	var copied int64
+	require.NoError(t, dstDB.Model(&storage.Task{}).Count(&copied).Error)
+	require.Equal(t, int64(4), copied)
+
+	// 다시 실행하면 남은 행부터 이어서 복사합니다.
+	var resumed []storage.CopyProgress
+	require.NoError(t, storage.CopyTables(ctx, srcDB, dstDB, storage.CopyOptions{
+		BatchSize: 2,
+		Progress: func(p storage.CopyProgress) {
+			if p.Table == "tasks" {
+				resumed = append(resumed, p)
+			}
+		},
+	}))
+	require.Equal(t, int64(4), resumed[0].Resumed)
+	require.Equal(t, int64(5), resumed[len(resumed)-1].Copied)
+
+	comparisons, err := storage.CompareTables(ctx, srcDB, dstDB)
+	require.NoError(t, err)
+	for _, c := range comparisons {
+		require.True(t, c.Match(), c.Table)
+	}
+	task, err := dst.GetTask(ctx, "task-3")
+	require.NoError(t, err)
+	srcTask, err := src.GetTask(ctx, "task-3")
+	require.NoError(t, err)
+	require.Equal(t, srcTask.ID, task.ID)
+	require.Equal(t, 1, task.NextMessageIndex)
+
+	// 대상이 바뀌면 체크섬이 달라지고, 원본에 없는 행이 있으면 이어서 복사하지 않습니다.
+	require.NoError(t, dstDB.Model(&storage.Task{}).Where("task_id = ?", "task-3").UpdateColumn("prompt", "changed").Error)
+	comparisons, err = storage.CompareTables(ctx, srcDB, dstDB)
+	require.NoError(t, err)
+	for _, c := range comparisons {
+		require.Equal(t, c.Table != "tasks", c.Match(), c.Table)
+	}
+	require.NoError(t, dst.CreateAgent(ctx, &storage.Agent{AgentID: "extra", Model: "gpt-4", Status: storage.AgentStatusActive}))
+	require.NoError(t, dstDB.Where("agent_id = ?", "bot").Delete(&storage.Agent{}).Error)
+	require.ErrorIs(t, storage.CopyTables(ctx, srcDB, dstDB, storage.CopyOptions{}), storage.ErrCopyMismatch)
+}
+
+func TestCompareTablesNormalizesTimePointers(t *testing.T) {
+	ctx := context.Background()
+	open := func(name string) *gorm.DB {
+		db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), name)), &gorm.Config{})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, storage.Close(db)) })
+		require.NoError(t, storage.MigrateUp(ctx, db))
+		return db
+	}
+	srcDB := open("src.db")
+	dstDB := open("dst.db")
+	src, err := storage.NewRepository(srcDB)
+	require.NoError(t, err)
+
+	require.NoError(t, src.CreateAgent(ctx, &storage.Agent{AgentID: "bot", Model: "gpt-4", Status: storage.AgentStatusActive}))
+	require.NoError(t, src.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "bot", Status: storage.TaskStatusPending}))
+	_, err = src.AppendMessageIndex(ctx, "task-1", storage.MessageRoleUser, "messages/task-1/0.json")
+	require.NoError(t, err)
+	require.NoError(t, src.UpsertRunStep(ctx, &storage.RunStep{TaskID: "task-1", StepNo: 1, Type: storage.RunStepTypeModel, Status: storage.RunStepStatusCompleted}))
+	require.NoError(t, storage.CopyTables(ctx, srcDB, dstDB, storage.CopyOptions{}))
+
+	// 원본은 나노초와 지역 시간대를, 대상은 PostgreSQL처럼 UTC 마이크로초를 돌려줘도 같은 행으로 봅니다.
+	setTimes := func(db *gorm.DB, at time.Time) {
+		require.NoError(t, db.Model(&storage.Task{}).Where("task_id = ?", "task-1").
+			UpdateColumns(map[string]interface{}{"started_at": at, "finished_at": at}).Error)
+		require.NoError(t, db.Model(&storage.RunStep{}).Where("task_id = ?", "task-1").
+			UpdateColumns(map[string]interface{}{"started_at": at, "finished_at": at}).Error)
+		require.NoError(t, db.Model(&storage.MessageIndex{}).Where("task_id = ?", "task-1").
+			UpdateColumn("superseded_at", at).Error)
+	}
+	at := time.Date(2026, 1, 2, 3, 4, 5, 123456789, time.FixedZone("KST", 9*60*60))
+	setTimes(srcDB, at)
+	setTimes(dstDB, at.UTC().Truncate(time.Microsecond))
+
+	comparisons, err := storage.CompareTables(ctx, srcDB, dstDB)
+	require.NoError(t, err)
+	for _, c := range comparisons {
+		require.True(t, c.Match(), c.Table)
+	}
+
+	setTimes(dstDB, at.Add(time.Second))
+	comparisons, err = storage.CompareTables(ctx, srcDB, dstDB)
+	require.NoError(t, err)
+	for _, c := range comparisons {
+		require.Equal(t, c.Table != "tasks" && c.Table != "run_steps" && c.Table != "msg_index", c.Match(), c.Table)
+	}
+}
+
+func TestRepositoryConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		db, err := gorm.Open(sqlite.Open("file:"+filepath.Join(t.TempDir(), "cnap.db")), &gorm.Config{})
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, storage.Close(db)) })
+		require.NoError(t, storage.MigrateUp(context.Background(), db))
+		repo, err := storage.NewRepository(db)
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestMemoryStoreConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Store {
+		return storage.NewMemoryStore()
+	})
+}
+
+// baseline 모델은 마이그레이션 도입 전 storage.AutoMigrate가 만들던 스키마입니다.
+// 그때 만든 데이터베이스도 마이그레이션으로 올릴 수 있어야 합니다.
+type baselineAgent struct {
+	ID          int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	AgentID     string    `gorm:"column:agent_id;type:varchar(64);not null;uniqueIndex:idx_agents_agent_id"`
+	Description string    `gorm:"column:description;type:text"`
+	Model       string    `gorm:"column:model;type:varchar(64)"`
+	Prompt      string    `gorm:"column:prompt;type:text"`
+	Status      string    `gorm:"column:status;type:varchar(32);not null;default:'active'"`
+	CreatedAt   time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+	UpdatedAt   time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+}
+
+func (baselineAgent) TableName() string { return "agents" }
+
+type baselineTask struct {
+	ID        int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	TaskID    string    `gorm:"column:task_id;type:varchar(64);not null;uniqueIndex:idx_tasks_task_id"`
+	AgentID   string    `gorm:"column:agent_id;type:varchar(64);not null;index:idx_tasks_agent_id"`
+	Prompt    string    `gorm:"column:prompt;type:text"`
+	Status    string    `gorm:"column:status;type:varchar(32);not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+	UpdatedAt time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+}
+
+func (baselineTask) TableName() string { return "tasks" }
+
+type baselineMessageIndex struct {
+	ID                int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	TaskID            string    `gorm:"column:task_id;type:varchar(64);not null;index:idx_msg_index_task;uniqueIndex:idx_msg_idx_task_conv,priority:1"`
+	ConversationIndex int       `gorm:"column:conversation_index;type:int;not null;uniqueIndex:idx_msg_idx_task_conv,priority:2"`
+	Role              string    `gorm:"column:role;type:varchar(32);not null"`
+	FilePath          string    `gorm:"column:file_path;type:text;not null"`
+	CreatedAt         time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+	UpdatedAt         time.Time `gorm:"column:updated_at;not null;autoUpdateTime"`
+}
+
+func (baselineMessageIndex) TableName() string { return "msg_index" }
+
+type baselineRunStep struct {
+	ID        int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	TaskID    string    `gorm:"column:task_id;type:varchar(64);not null;index:idx_run_steps_task;uniqueIndex:idx_run_steps_task_step,priority:1"`
+	StepNo    int       `gorm:"column:step_no;type:int;not null;uniqueIndex:idx_run_steps_task_step,priority:2"`
+	Type      string    `gorm:"column:type;type:varchar(32);not null"`
+	Status    string    `gorm:"column:status;type:varchar(32);not null"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+}
+
+func (baselineRunStep) TableName() string { return "run_steps" }
+
+type baselineCheckpoint struct {
+	ID        int64     `gorm:"column:id;type:bigserial;primaryKey"`
+	TaskID    string    `gorm:"column:task_id;type:varchar(64);not null;index:idx_checkpoints_task;uniqueIndex:idx_checkpoints_task_git,priority:1"`
+	GitHash   string    `gorm:"column:git_hash;type:varchar(64);not null;uniqueIndex:idx_checkpoints_task_git,priority:2"`
+	CreatedAt time.Time `gorm:"column:created_at;not null;autoCreateTime"`
+}
+
+func (baselineCheckpoint) TableName() string { return "checkpoints" }
+
+func TestMigrateUpFromBaselineSchema(t *testing.T) {
+	ctx := context.Background()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "cnap.db")), &gorm.Config{})
+	require.NoError(t, err)
+	defer func() { require.NoError(t, storage.Close(db)) }()
+
+	require.NoError(t, db.AutoMigrate(&baselineAgent{}, &baselineTask{}, &baselineMessageIndex{}, &baselineRunStep{}, &baselineCheckpoint{}))
+	require.NoError(t, db.Create(&baselineAgent{ID: 1, AgentID: "legacy", Model: "gpt-4", Prompt: "Reply as {{name}}", Status: "active"}).Error)
+	// SQLite의 bigserial id는 rowid 별칭이 아니어서 AutoMigrate 시절 행은 id 없이 저장됐습니다.
+	require.NoError(t, db.Create(&baselineAgent{AgentID: "legacy-noid", Status: "active"}).Error)
+	require.NoError(t, db.Create(&baselineTask{ID: 1, TaskID: "legacy-task", AgentID: "legacy", Status: "completed"}).Error)
+	require.NoError(t, db.Create(&baselineMessageIndex{ID: 1, TaskID: "legacy-task", ConversationIndex: 0, Role: "user", FilePath: "legacy/0.md"}).Error)
+	require.NoError(t, db.Create(&baselineMessageIndex{ID: 2, TaskID: "legacy-task", ConversationIndex: 1, Role: "assistant", FilePath: "legacy/1.md"}).Error)
+
+	require.NoError(t, storage.MigrateUp(ctx, db))
+	require.NoError(t, storage.VerifyModels(db))
+	require.NoError(t, storage.CheckSchema(ctx, db))
+
+	repo, err := storage.NewRepository(db)
+	require.NoError(t, err)
+
+	// 기존 레코드는 첫 리비전과 버전을 받고, 대화는 이어서 쓸 수 있습니다.
+	agent, err := repo.GetAgent(ctx, "legacy")
+	require.NoError(t, err)
+	require.Equal(t, 1, agent.Revision)
+	require.Equal(t, 1, agent.Version)
+	require.Empty(t, agent.OwnerID)
+	noID, err := repo.GetAgent(ctx, "legacy-noid")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), noID.ID)
+	revisions, err := repo.ListAgentRevisions(ctx, "legacy")
+	require.NoError(t, err)
+	require.Len(t, revisions, 1)
+	// 템플릿 이전에 저장한 프롬프트의 "{{"는 그대로 출력되도록 바뀝니다.
+	require.Equal(t, `Reply as {{"{{"}}name}}`, agent.Prompt)
+	require.Equal(t, agent.Prompt, revisions[0].Prompt)
+
+	msg, err := repo.AppendMessageIndex(ctx, "legacy-task", "user", "legacy/2.md")
+	require.NoError(t, err)
+	require.Equal(t, 2, msg.ConversationIndex)
+
+	// 마이그레이션 이후의 쓰기 경로도 그대로 동작합니다.
+	created := &storage.Agent{AgentID: "new", Status: storage.AgentStatusActive, OwnerID: "cli:alice"}
+	require.NoError(t, repo.CreateAgent(ctx, created))
+	require.Equal(t, int64(3), created.ID)
+	require.NoError(t, repo.CreateTask(ctx, &storage.Task{TaskID: "new-task", AgentID: "new", Status: storage.TaskStatusPending}))
+	fetched, err := repo.GetAgent(ctx, "new")
+	require.NoError(t, err)
+	require.Equal(t, "cli:alice", fetched.OwnerID)
+	require.NoError(t, repo.CreateAuditEvent(ctx, &storage.AuditEvent{Actor: "cli:alice", Action: storage.AuditActionAgentCreate, TargetType: storage.AuditTargetAgent, TargetID: "new"}))
+}
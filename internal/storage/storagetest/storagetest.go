@@ -0,0 +1,1080 @@
+// Package storagetest는 storage.Store 구현이 공통으로 지켜야 하는 동작을 검사하는 적합성 테스트를 제공합니다.
+// 새 저장소 구현은 자신의 테스트에서 Run을 호출해 Repository(GORM)와 같은 동작을 보장해야 합니다.
+package storagetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cnap-oss/app/internal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+// Run은 적합성 테스트를 하위 테스트로 실행합니다. newStore는 하위 테스트마다 비어 있는 저장소를 만들어야 합니다.
+func Run(t *testing.T, newStore func(t *testing.T) storage.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s storage.Store)
+	}{
+		{"Agents", testAgents},
+		{"AgentRevisions", testAgentRevisions},
+		{"OptimisticConcurrency", testOptimisticConcurrency},
+		{"Tasks", testTasks},
+		{"TaskResults", testTaskResults},
+		{"Messages", testMessages},
+		{"MessageRevisions", testMessageRevisions},
+		{"ForkTask", testForkTask},
+		{"ConcurrentMessages", testConcurrentMessages},
+		{"RunStepsAndCheckpoints", testRunStepsAndCheckpoints},
+		{"AuditEvents", testAuditEvents},
+		{"ListPages", testListPages},
+		{"Search", testSearch},
+		{"Retention", testRetention},
+		{"PurgeAgent", testPurgeAgent},
+		{"Labels", testLabels},
+		{"Transactions", testTransactions},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.fn(t, newStore(t))
+		})
+	}
+}
+
+func testAgents(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	agent := &storage.Agent{AgentID: "agent-1", Model: "gpt-4", Prompt: "prompt", OwnerID: "cli:alice"}
+	require.NoError(t, s.CreateAgent(ctx, agent))
+	require.NotZero(t, agent.ID)
+	require.Equal(t, 1, agent.Revision)
+	require.Equal(t, 1, agent.Version)
+	require.False(t, agent.CreatedAt.IsZero())
+	require.Error(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "agent-1"}), "duplicate agent")
+	require.Error(t, s.CreateAgent(ctx, nil))
+
+	got, err := s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.AgentStatusActive, got.Status, "status defaults to active")
+	require.Equal(t, "prompt", got.Prompt)
+	require.Equal(t, "cli:alice", got.OwnerID)
+
+	// 반환된 레코드를 바꿔도 저장된 값은 그대로입니다.
+	got.Prompt = "changed"
+	again, err := s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, "prompt", again.Prompt)
+
+	_, err = s.GetAgent(ctx, "missing")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.GetAgent(ctx, "")
+	require.Error(t, err)
+
+	require.NoError(t, s.UpdateAgentStatus(ctx, got, storage.AgentStatusBusy))
+	require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "agent-2", Status: storage.AgentStatusIdle}))
+	got, err = s.GetAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.AgentStatusBusy, got.Status)
+	require.Equal(t, 2, got.Version)
+
+	require.NoError(t, s.UpdateAgentStatus(ctx, got, storage.AgentStatusDeleted))
+	require.Equal(t, storage.AgentStatusDeleted, got.Status)
+	require.Equal(t, 3, got.Version)
+
+	agents, err := s.ListAgents(ctx)
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+	require.Equal(t, "agent-1", agents[0].AgentID)
+	require.Equal(t, "agent-2", agents[1].AgentID)
+
+	agents, err = s.ListAgents(ctx, storage.AgentStatusActive, storage.AgentStatusIdle)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	require.Equal(t, "agent-2", agents[0].AgentID)
+}
+
+func testAgentRevisions(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "agent-rev", Prompt: "v1", OwnerID: "cli:alice"}))
+	update := &storage.Agent{AgentID: "agent-rev", Model: "gpt-4o", Prompt: "v2", Tools: `["search"]`, Version: 1}
+	require.NoError(t, s.UpdateAgent(ctx, update, "cli:bob"))
+	require.Equal(t, 2, update.Revision)
+	require.Equal(t, 2, update.Version)
+
+	current, err := s.GetAgent(ctx, "agent-rev")
+	require.NoError(t, err)
+	require.Equal(t, "v2", current.Prompt)
+	require.Equal(t, "gpt-4o", current.Model)
+	require.Equal(t, `["search"]`, current.Tools)
+	require.Equal(t, 2, current.Revision)
+
+	revisions, err := s.ListAgentRevisions(ctx, "agent-rev")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	require.Equal(t, 1, revisions[0].Revision)
+	require.Equal(t, "v1", revisions[0].Prompt)
+	require.Equal(t, "cli:alice", revisions[0].Author)
+	require.Equal(t, 2, revisions[1].Revision)
+	require.Equal(t, "v2", revisions[1].Prompt)
+	require.Equal(t, "cli:bob", revisions[1].Author)
+
+	rev, err := s.GetAgentRevision(ctx, "agent-rev", 1)
+	require.NoError(t, err)
+	require.Equal(t, "v1", rev.Prompt)
+	_, err = s.GetAgentRevision(ctx, "agent-rev", 3)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	require.ErrorIs(t, s.UpdateAgent(ctx, &storage.Agent{AgentID: "missing"}, "cli:bob"), storage.ErrNotFound)
+}
+
+func testOptimisticConcurrency(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	agent := &storage.Agent{AgentID: "agent-occ", Prompt: "v1"}
+	require.NoError(t, s.CreateAgent(ctx, agent))
+
+	// 같은 버전을 읽은 두 수정 중 나중 수정은 충돌합니다.
+	alice := &storage.Agent{AgentID: "agent-occ", Prompt: "alice", Version: agent.Version}
+	bob := &storage.Agent{AgentID: "agent-occ", Prompt: "bob", Version: agent.Version}
+	require.NoError(t, s.UpdateAgent(ctx, alice, "cli:alice"))
+	err := s.UpdateAgent(ctx, bob, "cli:bob")
+	require.ErrorIs(t, err, storage.ErrConflict)
+	var conflict *storage.ConflictError
+	require.ErrorAs(t, err, &conflict)
+	require.Equal(t, storage.ConflictError{Kind: "agent", ID: "agent-occ", Expected: 1, Actual: 2}, *conflict)
+
+	// 버전을 넘기지 않은 수정은 현재 버전으로 대신하지 않고 충돌로 처리합니다.
+	require.ErrorIs(t, s.UpdateAgent(ctx, &storage.Agent{AgentID: "agent-occ", Prompt: "carol"}, "cli:carol"), storage.ErrConflict)
+
+	current, err := s.GetAgent(ctx, "agent-occ")
+	require.NoError(t, err)
+	require.Equal(t, "alice", current.Prompt)
+	other, err := s.GetAgent(ctx, "agent-occ")
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateAgentStatus(ctx, other, storage.AgentStatusBusy))
+	require.ErrorIs(t, s.UpdateAgentStatus(ctx, current, storage.AgentStatusIdle), storage.ErrConflict)
+	require.Equal(t, 2, current.Version, "failed update leaves the payload unchanged")
+
+	task := &storage.Task{TaskID: "task-occ", AgentID: "agent-occ", Status: storage.TaskStatusRunning}
+	require.NoError(t, s.CreateTask(ctx, task))
+	require.Equal(t, 1, task.Version)
+
+	runner, err := s.GetTask(ctx, "task-occ")
+	require.NoError(t, err)
+	canceler, err := s.GetTask(ctx, "task-occ")
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateTaskStatus(ctx, canceler, storage.TaskStatusCanceled))
+	require.Equal(t, storage.TaskStatusCanceled, canceler.Status)
+	require.Equal(t, 2, canceler.Version)
+	require.ErrorIs(t, s.UpdateTaskStatus(ctx, runner, storage.TaskStatusCompleted), storage.ErrConflict)
+	require.ErrorIs(t, s.SetTaskAgentRevision(ctx, runner, 2, "v2"), storage.ErrConflict)
+
+	require.NoError(t, s.SetTaskAgentRevision(ctx, canceler, 2, "v2"))
+	require.Equal(t, 3, canceler.Version)
+	require.Equal(t, "v2", canceler.SystemPrompt)
+	fetched, err := s.GetTask(ctx, "task-occ")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCanceled, fetched.Status)
+	require.Equal(t, 2, fetched.AgentRevision)
+	require.Equal(t, "v2", fetched.SystemPrompt)
+	require.Equal(t, 3, fetched.Version)
+
+	missing := &storage.Task{TaskID: "task-missing", Version: 1}
+	require.ErrorIs(t, s.UpdateTaskStatus(ctx, missing, storage.TaskStatusCanceled), storage.ErrNotFound)
+	require.ErrorIs(t, s.UpdateAgentStatus(ctx, &storage.Agent{AgentID: "missing", Version: 1}, storage.AgentStatusIdle), storage.ErrNotFound)
+}
+
+func testTasks(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	task := &storage.Task{TaskID: "task-1", AgentID: "agent-1", Prompt: "hello", Status: storage.TaskStatusPending}
+	require.NoError(t, s.CreateTask(ctx, task))
+	require.NotZero(t, task.ID)
+	require.False(t, task.CreatedAt.IsZero())
+	require.Error(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "agent-1", Status: storage.TaskStatusPending}), "duplicate task")
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-2", AgentID: "agent-1", Status: storage.TaskStatusPending}))
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-3", AgentID: "agent-2", Status: storage.TaskStatusCompleted}))
+
+	got, err := s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, "hello", got.Prompt)
+	require.Equal(t, 1, got.Version)
+	_, err = s.GetTask(ctx, "missing")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	// 상태 변경은 읽은 버전을 확인하고 버전을 올립니다.
+	require.NoError(t, s.UpdateTaskStatus(ctx, got, storage.TaskStatusRunning))
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-4", AgentID: "agent-2", Status: storage.TaskStatusFailed}))
+	got, err = s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusRunning, got.Status)
+	require.Equal(t, 2, got.Version)
+	got, err = s.GetTask(ctx, "task-4")
+	require.NoError(t, err)
+	require.Equal(t, "agent-2", got.AgentID)
+	require.Equal(t, 1, got.Version)
+
+	tasks, err := s.ListTasksByAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Len(t, tasks, 2)
+	require.Equal(t, "task-1", tasks[0].TaskID)
+	require.Equal(t, "task-2", tasks[1].TaskID)
+
+	counts, err := s.CountTasksByStatus(ctx)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int64{
+		storage.TaskStatusRunning:   1,
+		storage.TaskStatusPending:   1,
+		storage.TaskStatusCompleted: 1,
+		storage.TaskStatusFailed:    1,
+	}, counts)
+}
+
+func testTaskResults(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	task := &storage.Task{TaskID: "task-result", AgentID: "agent-1", Status: storage.TaskStatusPending}
+	require.NoError(t, s.CreateTask(ctx, task))
+	got, err := s.GetTask(ctx, "task-result")
+	require.NoError(t, err)
+	require.Zero(t, got.Attempts)
+	require.Nil(t, got.StartedAt)
+	require.Nil(t, got.FinishedAt)
+
+	require.NoError(t, s.StartTaskAttempt(ctx, task))
+	require.Equal(t, storage.TaskStatusRunning, task.Status)
+	require.Equal(t, 1, task.Attempts)
+	require.Equal(t, 2, task.Version)
+	require.NotNil(t, task.StartedAt)
+
+	require.NoError(t, s.FinishTask(ctx, task, storage.TaskStatusFailed, storage.TaskResult{
+		ErrorMessage: "upstream timed out",
+		ErrorClass:   "timeout",
+		ResultModel:  "gpt-4",
+	}))
+	got, err = s.GetTask(ctx, "task-result")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusFailed, got.Status)
+	require.Equal(t, "upstream timed out", got.ErrorMessage)
+	require.Equal(t, "timeout", got.ErrorClass)
+	require.Equal(t, "gpt-4", got.ResultModel)
+	require.Equal(t, 1, got.Attempts)
+	require.Equal(t, 3, got.Version)
+	require.NotNil(t, got.StartedAt)
+	require.NotNil(t, got.FinishedAt)
+	require.False(t, got.FinishedAt.Before(*got.StartedAt))
+
+	// 다시 실행하면 시도 횟수가 오르고 이전 결과는 지워집니다.
+	require.NoError(t, s.StartTaskAttempt(ctx, got))
+	got, err = s.GetTask(ctx, "task-result")
+	require.NoError(t, err)
+	require.Equal(t, 2, got.Attempts)
+	require.Empty(t, got.ErrorMessage)
+	require.Empty(t, got.ErrorClass)
+	require.Nil(t, got.FinishedAt)
+
+	require.NoError(t, s.FinishTask(ctx, got, storage.TaskStatusCompleted, storage.TaskResult{
+		ResultRef:   "tasks/task-result/out.json",
+		ResultModel: "gpt-4o",
+	}))
+	got, err = s.GetTask(ctx, "task-result")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusCompleted, got.Status)
+	require.Equal(t, "tasks/task-result/out.json", got.ResultRef)
+	require.Equal(t, "gpt-4o", got.ResultModel)
+	require.Empty(t, got.ErrorMessage)
+
+	// 오래된 버전으로는 시작하거나 끝낼 수 없습니다.
+	require.ErrorIs(t, s.StartTaskAttempt(ctx, task), storage.ErrConflict)
+	require.ErrorIs(t, s.FinishTask(ctx, task, storage.TaskStatusCompleted, storage.TaskResult{}), storage.ErrConflict)
+	require.ErrorIs(t, s.StartTaskAttempt(ctx, &storage.Task{TaskID: "missing", Version: 1}), storage.ErrNotFound)
+}
+
+func testMessages(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "agent-1", Status: storage.TaskStatusRunning}))
+
+	next, err := s.GetNextConversationIndex(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 0, next)
+
+	for i, role := range []string{storage.MessageRoleUser, storage.MessageRoleAssistant, storage.MessageRoleUser} {
+		msg, err := s.AppendMessageIndex(ctx, "task-1", role, fmt.Sprintf("messages/task-1/%d.json", i))
+		require.NoError(t, err)
+		require.NotZero(t, msg.ID)
+		require.Equal(t, i, msg.ConversationIndex)
+		require.Equal(t, role, msg.Role)
+	}
+
+	next, err = s.GetNextConversationIndex(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 3, next)
+	task, err := s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 3, task.NextMessageIndex)
+	require.Equal(t, 1, task.Version, "appending messages does not bump the task version")
+
+	messages, err := s.ListMessageIndexByTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+	for i, m := range messages {
+		require.Equal(t, i, m.ConversationIndex)
+		require.Equal(t, fmt.Sprintf("messages/task-1/%d.json", i), m.FilePath)
+	}
+	messages, err = s.ListMessageIndexByTask(ctx, "other")
+	require.NoError(t, err)
+	require.Empty(t, messages)
+
+	_, err = s.AppendMessageIndex(ctx, "missing", storage.MessageRoleUser, "x")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.AppendMessageIndex(ctx, "task-1", "", "x")
+	require.Error(t, err)
+	_, err = s.AppendMessageIndex(ctx, "task-1", storage.MessageRoleUser, "")
+	require.Error(t, err)
+	next, err = s.GetNextConversationIndex(ctx, "missing")
+	require.NoError(t, err)
+	require.Equal(t, 0, next)
+}
+
+func testMessageRevisions(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	task := &storage.Task{TaskID: "task-1", AgentID: "agent-1", Status: storage.TaskStatusCompleted}
+	require.NoError(t, s.CreateTask(ctx, task))
+	for i, role := range []string{"user", "assistant", "user", "assistant"} {
+		msg, err := s.AppendMessageIndex(ctx, "task-1", role, fmt.Sprintf("m/%d.1", i))
+		require.NoError(t, err)
+		require.Equal(t, 1, msg.Revision)
+		require.Nil(t, msg.SupersededAt)
+	}
+	require.NoError(t, s.IndexSearchDocument(ctx, &storage.SearchDocument{TaskID: "task-1", AgentID: "agent-1", Source: storage.SearchSourceMessage, Ref: "m/1.1", Body: "needle answer"}))
+
+	// 작업을 읽은 뒤 다른 변경이 있었으면 대화도 상태도 바꾸지 않습니다.
+	stale := *task
+	require.NoError(t, s.UpdateTaskStatus(ctx, task, storage.TaskStatusFailed))
+	_, err := s.RewindMessages(ctx, &stale, 3)
+	require.ErrorIs(t, err, storage.ErrConflict)
+	_, err = s.ReviseMessage(ctx, &stale, 2, "user", "m/2.x")
+	require.ErrorIs(t, err, storage.ErrConflict)
+	next, err := s.GetNextConversationIndex(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 4, next)
+	current, err := s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusFailed, current.Status)
+
+	// 마지막 응답을 다시 생성하면 이전 응답은 대체된 버전으로 남고 같은 인덱스에 새 revision이 추가됩니다.
+	// 대화를 되돌리면서 같은 트랜잭션에서 작업을 pending으로 되돌립니다.
+	rewound, err := s.RewindMessages(ctx, task, 3)
+	require.NoError(t, err)
+	require.Equal(t, 1, rewound)
+	require.Equal(t, storage.TaskStatusPending, task.Status)
+	current, err = s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusPending, current.Status)
+	require.Equal(t, task.Version, current.Version)
+	regenerated, err := s.AppendMessageIndex(ctx, "task-1", "assistant", "m/3.2")
+	require.NoError(t, err)
+	require.Equal(t, 3, regenerated.ConversationIndex)
+	require.Equal(t, 2, regenerated.Revision)
+
+	// 질문을 수정하면 그 뒤의 대화는 모두 대체됩니다.
+	revised, err := s.ReviseMessage(ctx, task, 2, "user", "m/2.2")
+	require.NoError(t, err)
+	require.Equal(t, 2, revised.ConversationIndex)
+	require.Equal(t, 2, revised.Revision)
+	next, err = s.GetNextConversationIndex(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, 3, next)
+
+	active, err := s.ListMessageIndexByTask(ctx, "task-1")
+	require.NoError(t, err)
+	var files []string
+	for _, msg := range active {
+		files = append(files, msg.FilePath)
+	}
+	require.Equal(t, []string{"m/0.1", "m/1.1", "m/2.2"}, files)
+
+	versions, err := s.ListMessageVersions(ctx, "task-1")
+	require.NoError(t, err)
+	type version struct {
+		index, revision int
+		superseded      bool
+	}
+	var got []version
+	for _, msg := range versions {
+		got = append(got, version{msg.ConversationIndex, msg.Revision, msg.SupersededAt != nil})
+	}
+	require.Equal(t, []version{
+		{0, 1, false}, {1, 1, false}, {2, 1, true}, {2, 2, false}, {3, 1, true}, {3, 2, true},
+	}, got)
+
+	// 다음 응답은 인덱스 3의 세 번째 revision입니다.
+	answer, err := s.AppendMessageIndex(ctx, "task-1", "assistant", "m/3.3")
+	require.NoError(t, err)
+	require.Equal(t, 3, answer.Revision)
+
+	_, err = s.ReviseMessage(ctx, task, 9, "user", "m/9")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.ReviseMessage(ctx, &storage.Task{TaskID: "missing", Version: 1}, 0, "user", "m/0")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.RewindMessages(ctx, task, 9)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	_, err = s.RewindMessages(ctx, task, -1)
+	require.Error(t, err)
+
+	// 삭제는 대체된 버전까지 지우고 다른 메시지의 인덱스는 그대로 둡니다.
+	deleted, err := s.DeleteMessage(ctx, "task-1", 1)
+	require.NoError(t, err)
+	require.Len(t, deleted, 1)
+	require.Equal(t, "m/1.1", deleted[0].FilePath)
+	deleted, err = s.DeleteMessage(ctx, "task-1", 3)
+	require.NoError(t, err)
+	require.Len(t, deleted, 3)
+	_, err = s.DeleteMessage(ctx, "task-1", 1)
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	active, err = s.ListMessageIndexByTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Len(t, active, 2)
+	require.Equal(t, 0, active[0].ConversationIndex)
+	require.Equal(t, 2, active[1].ConversationIndex)
+
+	docs, err := s.SearchTasks(ctx, storage.SearchFilter{Query: "needle"})
+	require.NoError(t, err)
+	require.Empty(t, docs, "search documents of deleted messages are removed")
+}
+
+func testForkTask(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-parent", AgentID: "agent-1", Status: storage.TaskStatusCompleted}))
+	for i, role := range []string{"user", "assistant", "user", "assistant"} {
+		_, err := s.AppendMessageIndex(ctx, "task-parent", role, fmt.Sprintf("messages/task-parent/%d.json", i))
+		require.NoError(t, err)
+	}
+	parentMessages, err := s.ListMessageIndexByTask(ctx, "task-parent")
+	require.NoError(t, err)
+
+	fork := &storage.Task{
+		TaskID:       "task-fork",
+		AgentID:      "agent-2",
+		Prompt:       "branch prompt",
+		Status:       storage.TaskStatusPending,
+		ParentTaskID: "task-parent",
+		ForkIndex:    1,
+		Model:        "gpt-4o",
+	}
+	copied := []storage.MessageIndex{
+		{Role: parentMessages[0].Role, FilePath: "messages/task-fork/0.json", CreatedAt: parentMessages[0].CreatedAt},
+		{Role: parentMessages[1].Role, FilePath: "messages/task-fork/1.json", CreatedAt: parentMessages[1].CreatedAt},
+	}
+	require.NoError(t, s.ForkTask(ctx, fork, copied))
+	require.NotZero(t, fork.ID)
+	require.Equal(t, 2, fork.NextMessageIndex)
+
+	got, err := s.GetTask(ctx, "task-fork")
+	require.NoError(t, err)
+	require.Equal(t, "task-parent", got.ParentTaskID)
+	require.Equal(t, 1, got.ForkIndex)
+	require.Equal(t, "gpt-4o", got.Model)
+	require.Equal(t, "agent-2", got.AgentID)
+
+	messages, err := s.ListMessageIndexByTask(ctx, "task-fork")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	for i, msg := range messages {
+		require.Equal(t, "task-fork", msg.TaskID)
+		require.Equal(t, i, msg.ConversationIndex)
+		require.Equal(t, parentMessages[i].Role, msg.Role)
+		require.WithinDuration(t, parentMessages[i].CreatedAt, msg.CreatedAt, time.Second)
+	}
+
+	// 분기한 작업의 메시지 카운터는 복사한 메시지 다음부터 이어집니다.
+	next, err := s.AppendMessageIndex(ctx, "task-fork", "user", "messages/task-fork/2.json")
+	require.NoError(t, err)
+	require.Equal(t, 2, next.ConversationIndex)
+
+	// 부모 작업은 그대로입니다.
+	parentMessages, err = s.ListMessageIndexByTask(ctx, "task-parent")
+	require.NoError(t, err)
+	require.Len(t, parentMessages, 4)
+
+	// 작업을 만들 수 없으면 메시지도 추가되지 않습니다.
+	require.Error(t, s.ForkTask(ctx, &storage.Task{TaskID: "task-fork", AgentID: "agent-1", Status: storage.TaskStatusPending},
+		[]storage.MessageIndex{{Role: "user", FilePath: "messages/task-fork/dup.json"}}))
+	messages, err = s.ListMessageIndexByTask(ctx, "task-fork")
+	require.NoError(t, err)
+	require.Len(t, messages, 3)
+}
+
+func testConcurrentMessages(t *testing.T, s storage.Store) {
+	const writers, perWriter = 4, 10
+	ctx := context.Background()
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "agent-1", Status: storage.TaskStatusRunning}))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter)
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := s.AppendMessageIndex(ctx, "task-1", storage.MessageRoleUser, fmt.Sprintf("w%d/%03d", w, i)); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// 모든 메시지가 빠짐없이 연속된 인덱스를 받고, 작성자별 순서가 유지되어야 합니다.
+	messages, err := s.ListMessageIndexByTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Len(t, messages, writers*perWriter)
+	last := map[string]string{}
+	for i, m := range messages {
+		require.Equal(t, i, m.ConversationIndex)
+		writer, seq, _ := strings.Cut(m.FilePath, "/")
+		require.Greater(t, seq, last[writer], "messages from %s were reordered", writer)
+		last[writer] = seq
+	}
+}
+
+func testRunStepsAndCheckpoints(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "agent-1", Status: storage.TaskStatusRunning}))
+
+	second := &storage.RunStep{TaskID: "task-1", StepNo: 2, Type: storage.RunStepTypeTool, Status: storage.RunStepStatusRunning}
+	first := &storage.RunStep{TaskID: "task-1", StepNo: 1, Type: storage.RunStepTypeModel, Status: storage.RunStepStatusRunning}
+	require.NoError(t, s.UpsertRunStep(ctx, second))
+	require.NoError(t, s.UpsertRunStep(ctx, first))
+	first.Status = storage.RunStepStatusCompleted
+	require.NoError(t, s.UpsertRunStep(ctx, first))
+
+	steps, err := s.ListRunSteps(ctx, "task-1")
+	require.NoError(t, err)
+	require.Len(t, steps, 2)
+	require.Equal(t, 1, steps[0].StepNo)
+	require.Equal(t, storage.RunStepStatusCompleted, steps[0].Status)
+	require.Equal(t, 2, steps[1].StepNo)
+	require.Nil(t, steps[1].StartedAt)
+	require.Nil(t, steps[1].FinishedAt)
+	require.Error(t, s.UpsertRunStep(ctx, nil))
+
+	// 다시 기록하면 시각, 모델, 입출력, 에러, 토큰 사용량까지 모두 바뀌고 소요 시간이 계산됩니다.
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	started, finished := base, base.Add(1500*time.Millisecond)
+	second.ParentStepNo = 1
+	second.Status = storage.RunStepStatusFailed
+	second.Model = "gpt-4"
+	second.InputRef = "messages/task-1/0.json"
+	second.OutputRef = "messages/task-1/1.json"
+	second.ErrorMessage = "deadline exceeded"
+	second.ErrorClass = "timeout"
+	second.InputTokens, second.OutputTokens = 120, 30
+	second.StartedAt, second.FinishedAt = &started, &finished
+	require.NoError(t, s.UpsertRunStep(ctx, second))
+	require.Equal(t, int64(1500), second.DurationMS)
+
+	steps, err = s.ListRunSteps(ctx, "task-1")
+	require.NoError(t, err)
+	got := steps[1]
+	require.Equal(t, 1, got.ParentStepNo)
+	require.Equal(t, storage.RunStepStatusFailed, got.Status)
+	require.Equal(t, "gpt-4", got.Model)
+	require.Equal(t, "messages/task-1/0.json", got.InputRef)
+	require.Equal(t, "messages/task-1/1.json", got.OutputRef)
+	require.Equal(t, "deadline exceeded", got.ErrorMessage)
+	require.Equal(t, "timeout", got.ErrorClass)
+	require.Equal(t, 120, got.InputTokens)
+	require.Equal(t, 30, got.OutputTokens)
+	require.NotNil(t, got.StartedAt)
+	require.True(t, started.Equal(*got.StartedAt))
+	require.NotNil(t, got.FinishedAt)
+	require.True(t, finished.Equal(*got.FinishedAt))
+	require.Equal(t, 1500*time.Millisecond, got.Duration())
+
+	// 지정한 소요 시간은 그대로 저장합니다.
+	first.DurationMS = 42
+	require.NoError(t, s.UpsertRunStep(ctx, first))
+	steps, err = s.ListRunSteps(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, int64(42), steps[0].DurationMS)
+	require.Equal(t, 0, steps[0].ParentStepNo)
+
+	require.NoError(t, s.CreateCheckpoint(ctx, &storage.Checkpoint{TaskID: "task-1", GitHash: "bbb", CreatedAt: base.Add(time.Minute)}))
+	require.NoError(t, s.CreateCheckpoint(ctx, &storage.Checkpoint{TaskID: "task-1", GitHash: "aaa", CreatedAt: base}))
+	require.NoError(t, s.CreateCheckpoint(ctx, &storage.Checkpoint{TaskID: "task-1", GitHash: "aaa"}), "duplicates are ignored")
+
+	checkpoints, err := s.ListCheckpoints(ctx, "task-1")
+	require.NoError(t, err)
+	require.Len(t, checkpoints, 2)
+	require.Equal(t, "aaa", checkpoints[0].GitHash)
+	require.Equal(t, "bbb", checkpoints[1].GitHash)
+}
+
+func testAuditEvents(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, e := range []storage.AuditEvent{
+		{Actor: "cli:alice", Action: storage.AuditActionAgentCreate, TargetType: storage.AuditTargetAgent, TargetID: "agent-1", AgentID: "agent-1"},
+		{Actor: "discord:42", Action: storage.AuditActionTaskCancel, TargetType: storage.AuditTargetTask, TargetID: "task-1", AgentID: "agent-1"},
+		{Actor: "cli:alice", Action: storage.AuditActionAgentCreate, TargetType: storage.AuditTargetAgent, TargetID: "agent-2", AgentID: "agent-2"},
+	} {
+		e.CreatedAt = base.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, s.CreateAuditEvent(ctx, &e))
+	}
+	require.Error(t, s.CreateAuditEvent(ctx, &storage.AuditEvent{Actor: "cli:alice"}), "action is required")
+
+	targets := func(filter storage.AuditFilter) []string {
+		events, err := s.ListAuditEvents(ctx, filter)
+		require.NoError(t, err)
+		var out []string
+		for _, e := range events {
+			out = append(out, e.TargetID)
+		}
+		return out
+	}
+	require.Equal(t, []string{"agent-1", "task-1", "agent-2"}, targets(storage.AuditFilter{}))
+	require.Equal(t, []string{"agent-1", "task-1"}, targets(storage.AuditFilter{AgentID: "agent-1"}))
+	require.Equal(t, []string{"agent-2"}, targets(storage.AuditFilter{Actor: "cli:alice", Limit: 1}))
+	require.Equal(t, []string{"task-1"}, targets(storage.AuditFilter{Action: storage.AuditActionTaskCancel}))
+	require.Equal(t, []string{"task-1"}, targets(storage.AuditFilter{Since: base.Add(time.Hour), Until: base.Add(2 * time.Hour)}))
+}
+
+func testListPages(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i, a := range []struct{ id, model, status string }{
+		{"bot_a", "gpt-4", storage.AgentStatusActive},
+		{"Bot-b", "gpt-4o", storage.AgentStatusActive},
+		{"botxc", "gpt-4", storage.AgentStatusDeleted},
+		{"other", "gpt-4", storage.AgentStatusIdle},
+	} {
+		require.NoError(t, s.CreateAgent(ctx, &storage.Agent{
+			AgentID:   a.id,
+			Model:     a.model,
+			Prompt:    "긴 프롬프트",
+			Status:    a.status,
+			CreatedAt: base.Add(time.Duration(i) * time.Hour),
+		}))
+	}
+	// 여러 작업이 같은 시각에 생성되어도 id 순으로 빠짐없이 페이지가 나뉘어야 합니다.
+	for i := 0; i < 7; i++ {
+		status := storage.TaskStatusPending
+		if i%2 == 1 {
+			status = storage.TaskStatusCompleted
+		}
+		require.NoError(t, s.CreateTask(ctx, &storage.Task{
+			TaskID:    fmt.Sprintf("task-%d", i),
+			AgentID:   "bot_a",
+			Prompt:    fmt.Sprintf("Prompt number %d", i),
+			Status:    status,
+			CreatedAt: base.Add(time.Duration(i/3) * time.Minute),
+			UpdatedAt: base.Add(time.Duration(7-i) * time.Minute),
+		}))
+	}
+
+	collect := func(filter storage.TaskFilter) []string {
+		var ids []string
+		for pages := 0; ; pages++ {
+			require.Less(t, pages, 10)
+			tasks, next, err := s.ListTasksPage(ctx, filter)
+			require.NoError(t, err)
+			if filter.Limit > 0 {
+				require.LessOrEqual(t, len(tasks), filter.Limit)
+			}
+			for _, task := range tasks {
+				ids = append(ids, task.TaskID)
+			}
+			if next == "" {
+				return ids
+			}
+			filter.Cursor = next
+		}
+	}
+	all := []string{"task-0", "task-1", "task-2", "task-3", "task-4", "task-5", "task-6"}
+	require.Equal(t, all, collect(storage.TaskFilter{AgentID: "bot_a", Limit: 2}))
+	require.Equal(t, all, collect(storage.TaskFilter{}))
+	require.Equal(t, []string{"task-6", "task-5", "task-4", "task-3", "task-2", "task-1", "task-0"},
+		collect(storage.TaskFilter{AgentID: "bot_a", Sort: "-created", Limit: 3}))
+	require.Equal(t, []string{"task-6", "task-5", "task-4", "task-3", "task-2", "task-1", "task-0"},
+		collect(storage.TaskFilter{Sort: storage.SortUpdated, Limit: 4}))
+	require.Equal(t, []string{"task-1", "task-3", "task-5"},
+		collect(storage.TaskFilter{Statuses: []string{storage.TaskStatusCompleted}, Sort: "name", Limit: 1}))
+	require.Equal(t, []string{"task-3", "task-4", "task-5"},
+		collect(storage.TaskFilter{CreatedAfter: base.Add(time.Minute), CreatedBefore: base.Add(2 * time.Minute), Limit: 10}))
+	require.Equal(t, []string{"task-4"}, collect(storage.TaskFilter{PromptContains: "NUMBER 4"}))
+	require.Empty(t, collect(storage.TaskFilter{AgentID: "other"}))
+
+	// 다른 정렬 기준의 커서나 잘못된 커서는 거부합니다.
+	_, next, err := s.ListTasksPage(ctx, storage.TaskFilter{Limit: 1})
+	require.NoError(t, err)
+	_, _, err = s.ListTasksPage(ctx, storage.TaskFilter{Limit: 1, Sort: "name", Cursor: next})
+	require.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = s.ListTasksPage(ctx, storage.TaskFilter{Cursor: "not-a-cursor"})
+	require.ErrorIs(t, err, storage.ErrInvalidCursor)
+	_, _, err = s.ListTasksPage(ctx, storage.TaskFilter{Sort: "status"})
+	require.Error(t, err)
+
+	// 이름 접두사는 대소문자를 무시하고 LIKE 와일드카드를 문자 그대로 취급합니다.
+	agents, next, err := s.ListAgentsPage(ctx, storage.AgentFilter{NamePrefix: "bot_", Sort: storage.SortName})
+	require.NoError(t, err)
+	require.Empty(t, next)
+	require.Len(t, agents, 1)
+	require.Equal(t, "bot_a", agents[0].AgentID)
+	require.Empty(t, agents[0].Prompt, "목록 조회는 프롬프트를 읽지 않습니다")
+
+	agents, _, err = s.ListAgentsPage(ctx, storage.AgentFilter{NamePrefix: "BOT", Model: "gpt-4", Statuses: []string{storage.AgentStatusActive, storage.AgentStatusDeleted}})
+	require.NoError(t, err)
+	require.Len(t, agents, 2)
+	require.Equal(t, "bot_a", agents[0].AgentID)
+	require.Equal(t, "botxc", agents[1].AgentID)
+
+	agents, next, err = s.ListAgentsPage(ctx, storage.AgentFilter{Sort: "-created", Limit: 2})
+	require.NoError(t, err)
+	require.NotEmpty(t, next)
+	require.Equal(t, "other", agents[0].AgentID)
+	require.Equal(t, "Bot-b", agents[1].AgentID)
+	agents, next, err = s.ListAgentsPage(ctx, storage.AgentFilter{Sort: "-created", Limit: 2, Cursor: next})
+	require.NoError(t, err)
+	require.Empty(t, next)
+	require.Len(t, agents, 1)
+	require.Equal(t, "bot_a", agents[0].AgentID)
+
+	// 상태를 지정하지 않으면 삭제된 에이전트는 제외합니다.
+	agents, _, err = s.ListAgentsPage(ctx, storage.AgentFilter{Sort: storage.SortName, CreatedBefore: base.Add(3 * time.Hour)})
+	require.NoError(t, err)
+	names := make([]string, 0, len(agents))
+	for _, a := range agents {
+		names = append(names, a.AgentID)
+	}
+	require.Equal(t, []string{"Bot-b", "bot_a"}, names)
+}
+
+func testSearch(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "t1", AgentID: "billing", Status: storage.TaskStatusPending, Prompt: "결제가 실패했어요"}))
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "t2", AgentID: "support", Status: storage.TaskStatusPending, Prompt: "Refund my ORDER please"}))
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "t3", AgentID: "support", Status: storage.TaskStatusPending}))
+	require.NoError(t, s.IndexSearchDocument(ctx, &storage.SearchDocument{
+		TaskID: "t3", AgentID: "support", Source: storage.SearchSourceMessage, Ref: "messages/t3/1.json", Body: "order status?",
+	}))
+	require.Error(t, s.IndexSearchDocument(ctx, &storage.SearchDocument{TaskID: "t3", Body: "no source"}))
+
+	ids := func(filter storage.SearchFilter) []string {
+		docs, err := s.SearchTasks(ctx, filter)
+		require.NoError(t, err)
+		var out []string
+		for _, d := range docs {
+			out = append(out, d.TaskID)
+		}
+		return out
+	}
+
+	require.Equal(t, []string{"t1"}, ids(storage.SearchFilter{Query: "결제"}))
+	require.ElementsMatch(t, []string{"t2", "t3"}, ids(storage.SearchFilter{Query: "order"}))
+	require.Len(t, ids(storage.SearchFilter{Query: "order", Limit: 1}), 1)
+	require.Equal(t, []string{"t2"}, ids(storage.SearchFilter{Query: "refund, ord"}))
+	require.Equal(t, []string{"t3"}, ids(storage.SearchFilter{Query: "ORDER stat"}))
+	require.Empty(t, ids(storage.SearchFilter{Query: "order", AgentID: "billing"}))
+	require.Empty(t, ids(storage.SearchFilter{Query: "rder"}), "terms match word prefixes only")
+
+	// 같은 문서를 다시 색인하면 본문이 바뀌고 이전 단어로는 찾을 수 없습니다.
+	require.NoError(t, s.IndexSearchDocument(ctx, &storage.SearchDocument{
+		TaskID: "t3", AgentID: "support", Source: storage.SearchSourceMessage, Ref: "messages/t3/1.json", Body: "배송 문의",
+	}))
+	require.Equal(t, []string{"t2"}, ids(storage.SearchFilter{Query: "order"}))
+	require.Equal(t, []string{"t3"}, ids(storage.SearchFilter{Query: "배송"}))
+
+	_, err := s.SearchTasks(ctx, storage.SearchFilter{Query: " ,. "})
+	require.Error(t, err)
+}
+
+func testRetention(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	// a-1이 가장 오래되었고 a-4가 가장 최근 작업입니다. a-4는 아직 실행 중입니다.
+	for i, status := range []string{storage.TaskStatusCompleted, storage.TaskStatusFailed, storage.TaskStatusCompleted, storage.TaskStatusRunning} {
+		age := time.Duration(4-i) * day
+		require.NoError(t, s.CreateTask(ctx, &storage.Task{
+			TaskID:    fmt.Sprintf("a-%d", i+1),
+			AgentID:   "a",
+			Status:    status,
+			CreatedAt: now.Add(-age),
+			UpdatedAt: now.Add(-age),
+		}))
+	}
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{
+		TaskID: "b-1", AgentID: "b", Status: storage.TaskStatusCompleted,
+		CreatedAt: now.Add(-10 * day), UpdatedAt: now.Add(-10 * day),
+	}))
+
+	expired := func(rule storage.RetentionRule, exclude ...string) []string {
+		ids, err := s.ExpiredTasks(ctx, rule, exclude, now)
+		require.NoError(t, err)
+		return ids
+	}
+	require.ElementsMatch(t, []string{"a-1", "a-2", "b-1"}, expired(storage.RetentionRule{OlderThan: 60 * time.Hour}))
+	require.ElementsMatch(t, []string{"a-1", "a-2"}, expired(storage.RetentionRule{OlderThan: 60 * time.Hour}, "b"))
+	require.Equal(t, []string{"a-2"}, expired(storage.RetentionRule{AgentID: "a", Statuses: []string{storage.TaskStatusFailed}, OlderThan: time.Hour}))
+	require.Empty(t, expired(storage.RetentionRule{Statuses: []string{storage.TaskStatusRunning}, OlderThan: time.Hour}))
+	require.Equal(t, []string{"a-2", "a-1"}, expired(storage.RetentionRule{KeepLast: 1}))
+	require.Equal(t, []string{"a-1"}, expired(storage.RetentionRule{KeepLast: 1, OlderThan: 80 * time.Hour}))
+	_, err := s.ExpiredTasks(ctx, storage.RetentionRule{}, nil, now)
+	require.Error(t, err)
+
+	for _, id := range []string{"a-1", "a-4"} {
+		for i := 0; i < 2; i++ {
+			_, err := s.AppendMessageIndex(ctx, id, storage.MessageRoleUser, fmt.Sprintf("messages/%s/%d.json", id, i))
+			require.NoError(t, err)
+		}
+		require.NoError(t, s.UpsertRunStep(ctx, &storage.RunStep{TaskID: id, StepNo: 1, Type: storage.RunStepTypeModel, Status: storage.RunStepStatusCompleted}))
+		require.NoError(t, s.CreateCheckpoint(ctx, &storage.Checkpoint{TaskID: id, GitHash: "abc"}))
+		require.NoError(t, s.IndexSearchDocument(ctx, &storage.SearchDocument{TaskID: id, AgentID: "a", Source: storage.SearchSourcePrompt, Body: "hello"}))
+	}
+
+	usage, err := s.TaskUsage(ctx, []string{"a-1", "b-1", "missing"})
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskUsage{
+		Tasks: 2, Messages: 2, RunSteps: 1, Checkpoints: 1, SearchDocuments: 1,
+		Files: []string{"messages/a-1/0.json", "messages/a-1/1.json"},
+	}, *usage)
+
+	// 실행 중인 작업은 목록에 있어도 삭제하지 않습니다.
+	usage, deleted, err := s.DeleteTasks(ctx, []string{"a-1", "a-4", "b-1", "missing"})
+	require.NoError(t, err)
+	require.Len(t, deleted, 2)
+	require.Equal(t, "a-1", deleted[0].TaskID)
+	require.Equal(t, "b-1", deleted[1].TaskID)
+	require.Equal(t, int64(2), usage.Tasks)
+	require.Equal(t, int64(7), usage.Rows())
+	require.Equal(t, []string{"messages/a-1/0.json", "messages/a-1/1.json"}, usage.Files)
+
+	_, err = s.GetTask(ctx, "a-1")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	messages, err := s.ListMessageIndexByTask(ctx, "a-1")
+	require.NoError(t, err)
+	require.Empty(t, messages)
+	messages, err = s.ListMessageIndexByTask(ctx, "a-4")
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	docs, err := s.SearchTasks(ctx, storage.SearchFilter{Query: "hello"})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.Equal(t, "a-4", docs[0].TaskID)
+}
+
+func testPurgeAgent(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	for _, id := range []string{"agent-1", "agent-2"} {
+		require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: id}))
+		require.NoError(t, s.UpdateAgent(ctx, &storage.Agent{AgentID: id, Prompt: "v2", Version: 1}, "cli:alice"))
+		taskID := "task-" + id
+		require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: taskID, AgentID: id, Prompt: "hello", Status: storage.TaskStatusCompleted}))
+		_, err := s.AppendMessageIndex(ctx, taskID, storage.MessageRoleUser, "messages/"+taskID+"/0.json")
+		require.NoError(t, err)
+		require.NoError(t, s.UpsertRunStep(ctx, &storage.RunStep{TaskID: taskID, StepNo: 1, Type: storage.RunStepTypeModel, Status: storage.RunStepStatusCompleted}))
+	}
+	require.NoError(t, s.CreateAuditEvent(ctx, &storage.AuditEvent{Actor: "cli:alice", Action: storage.AuditActionAgentCreate, TargetType: storage.AuditTargetAgent, TargetID: "agent-1", AgentID: "agent-1"}))
+
+	// 삭제 상태가 아닌 에이전트는 영구 삭제하지 않습니다.
+	_, err := s.PurgeAgent(ctx, "agent-1")
+	require.ErrorIs(t, err, storage.ErrConflict)
+	_, err = s.GetTask(ctx, "task-agent-1")
+	require.NoError(t, err)
+
+	markAgentDeleted(t, s, "agent-1")
+	files, err := s.PurgeAgent(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Equal(t, []string{"messages/task-agent-1/0.json"}, files)
+	_, err = s.PurgeAgent(ctx, "agent-1")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	_, err = s.GetAgent(ctx, "agent-1")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	revisions, err := s.ListAgentRevisions(ctx, "agent-1")
+	require.NoError(t, err)
+	require.Empty(t, revisions)
+	_, err = s.GetTask(ctx, "task-agent-1")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	steps, err := s.ListRunSteps(ctx, "task-agent-1")
+	require.NoError(t, err)
+	require.Empty(t, steps)
+
+	// 다른 에이전트의 데이터와 감사 로그는 남습니다.
+	revisions, err = s.ListAgentRevisions(ctx, "agent-2")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	messages, err := s.ListMessageIndexByTask(ctx, "task-agent-2")
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	docs, err := s.SearchTasks(ctx, storage.SearchFilter{Query: "hello"})
+	require.NoError(t, err)
+	require.Len(t, docs, 1)
+	require.Equal(t, "task-agent-2", docs[0].TaskID)
+	events, err := s.ListAuditEvents(ctx, storage.AuditFilter{AgentID: "agent-1"})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+func testLabels(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "infra", Labels: map[string]string{"team": "infra"}}))
+	require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "web"}))
+	require.Error(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "bad", Labels: map[string]string{"bad key": "x"}}))
+	_, err := s.GetAgent(ctx, "bad")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+
+	tasks := []struct {
+		id     string
+		labels map[string]string
+	}{
+		{"t-1", map[string]string{"team": "infra", "env": "prod"}},
+		{"t-2", map[string]string{"team": "infra", "env": "dev", "ticket": "ABC-12"}},
+		{"t-3", map[string]string{"team": "web"}},
+		{"t-4", nil},
+	}
+	for _, task := range tasks {
+		require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: task.id, AgentID: "infra", Status: storage.TaskStatusCompleted, Labels: task.labels}))
+	}
+
+	agent, err := s.GetAgent(ctx, "infra")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "infra"}, agent.Labels)
+	task, err := s.GetTask(ctx, "t-2")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "infra", "env": "dev", "ticket": "ABC-12"}, task.Labels)
+	// 반환한 레이블을 수정해도 저장된 값은 바뀌지 않습니다.
+	task.Labels["team"] = "changed"
+	listed, err := s.ListTasksByAgent(ctx, "infra")
+	require.NoError(t, err)
+	require.Len(t, listed, 4)
+	require.Equal(t, "infra", listed[1].Labels["team"])
+	require.Empty(t, listed[3].Labels)
+
+	selectTasks := func(spec string) []string {
+		selector, err := storage.ParseLabelSelector(spec)
+		require.NoError(t, err)
+		page, _, err := s.ListTasksPage(ctx, storage.TaskFilter{Labels: selector})
+		require.NoError(t, err)
+		ids := make([]string, len(page))
+		for i, task := range page {
+			ids[i] = task.TaskID
+		}
+		return ids
+	}
+	require.Equal(t, []string{"t-1", "t-2"}, selectTasks("team=infra"))
+	require.Equal(t, []string{"t-2"}, selectTasks("team=infra,env!=prod"))
+	require.Equal(t, []string{"t-2", "t-3", "t-4"}, selectTasks("env!=prod"))
+	require.Equal(t, []string{"t-2"}, selectTasks("ticket"))
+	require.Equal(t, []string{"t-3", "t-4"}, selectTasks("!env"))
+	require.Equal(t, []string{"t-1", "t-2", "t-3", "t-4"}, selectTasks(""))
+
+	page, _, err := s.ListAgentsPage(ctx, storage.AgentFilter{Labels: storage.LabelSelector{{Key: "team", Operator: storage.LabelOpExists}}})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	require.Equal(t, "infra", page[0].AgentID)
+	require.Equal(t, map[string]string{"team": "infra"}, page[0].Labels)
+
+	// SetLabels는 레이블 전체를 바꿉니다.
+	require.NoError(t, s.SetLabels(ctx, storage.LabelTargetTask, "t-4", map[string]string{"env": "prod"}))
+	require.NoError(t, s.SetLabels(ctx, storage.LabelTargetTask, "t-1", nil))
+	require.Equal(t, []string{"t-4"}, selectTasks("env=prod"))
+	require.NoError(t, s.SetLabels(ctx, storage.LabelTargetAgent, "web", map[string]string{"team": "web"}))
+	agent, err = s.GetAgent(ctx, "web")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"team": "web"}, agent.Labels)
+	require.ErrorIs(t, s.SetLabels(ctx, storage.LabelTargetTask, "missing", map[string]string{"a": "b"}), storage.ErrNotFound)
+	require.Error(t, s.SetLabels(ctx, storage.LabelTargetTask, "t-1", map[string]string{"a": "b c"}))
+	require.Error(t, s.SetLabels(ctx, "unknown", "t-1", nil))
+
+	expired, err := s.ExpiredTasks(ctx, storage.RetentionRule{KeepLast: 1, Labels: storage.LabelSelector{{Key: "team", Operator: storage.LabelOpEquals, Value: "infra"}}}, nil, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, expired)
+	expired, err = s.ExpiredTasks(ctx, storage.RetentionRule{KeepLast: 1, Labels: storage.LabelSelector{{Key: "team", Operator: storage.LabelOpExists}}}, nil, time.Now())
+	require.NoError(t, err)
+	require.Equal(t, []string{"t-2"}, expired)
+
+	// 삭제한 작업과 에이전트의 레이블은 같은 ID로 다시 만들어도 남아 있지 않습니다.
+	_, _, err = s.DeleteTasks(ctx, []string{"t-2"})
+	require.NoError(t, err)
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "t-2", AgentID: "infra", Status: storage.TaskStatusPending}))
+	task, err = s.GetTask(ctx, "t-2")
+	require.NoError(t, err)
+	require.Empty(t, task.Labels)
+	markAgentDeleted(t, s, "infra")
+	_, err = s.PurgeAgent(ctx, "infra")
+	require.NoError(t, err)
+	require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "infra"}))
+	agent, err = s.GetAgent(ctx, "infra")
+	require.NoError(t, err)
+	require.Empty(t, agent.Labels)
+	require.Empty(t, selectTasks("team"))
+}
+
+func testTransactions(t *testing.T, s storage.Store) {
+	ctx := context.Background()
+	require.NoError(t, s.CreateAgent(ctx, &storage.Agent{AgentID: "bot"}))
+	require.NoError(t, s.CreateTask(ctx, &storage.Task{TaskID: "task-1", AgentID: "bot", Status: storage.TaskStatusPending}))
+	audit := func(action string) *storage.AuditEvent {
+		return &storage.AuditEvent{Actor: "cli:alice", Action: action, TargetType: storage.AuditTargetTask, TargetID: "task-1", AgentID: "bot"}
+	}
+
+	// 오류를 반환하면 트랜잭션 안의 쓰기가 모두 되돌려집니다.
+	failed := errors.New("audit failed")
+	err := s.InTx(ctx, func(tx storage.Store) error {
+		task, err := tx.GetTask(ctx, "task-1")
+		require.NoError(t, err)
+		require.NoError(t, tx.UpdateTaskStatus(ctx, task, storage.TaskStatusRunning))
+		require.NoError(t, tx.CreateAgent(ctx, &storage.Agent{AgentID: "other"}))
+		require.NoError(t, tx.CreateAuditEvent(ctx, audit(storage.AuditActionTaskStatus)))
+		return failed
+	})
+	require.ErrorIs(t, err, failed)
+	task, err := s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusPending, task.Status)
+	require.Equal(t, 1, task.Version)
+	_, err = s.GetAgent(ctx, "other")
+	require.ErrorIs(t, err, storage.ErrNotFound)
+	events, err := s.ListAuditEvents(ctx, storage.AuditFilter{})
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	require.NoError(t, s.InTx(ctx, func(tx storage.Store) error {
+		if err := tx.UpdateTaskStatus(ctx, task, storage.TaskStatusRunning); err != nil {
+			return err
+		}
+		return tx.CreateAuditEvent(ctx, audit(storage.AuditActionTaskStatus))
+	}))
+	task, err = s.GetTask(ctx, "task-1")
+	require.NoError(t, err)
+	require.Equal(t, storage.TaskStatusRunning, task.Status)
+	events, err = s.ListAuditEvents(ctx, storage.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+}
+
+// markAgentDeleted는 영구 삭제할 수 있도록 에이전트를 삭제 상태로 바꿉니다.
+func markAgentDeleted(t *testing.T, s storage.Store, agentID string) {
+	t.Helper()
+	agent, err := s.GetAgent(context.Background(), agentID)
+	require.NoError(t, err)
+	require.NoError(t, s.UpdateAgentStatus(context.Background(), agent, storage.AgentStatusDeleted))
+}
@@ -0,0 +1,101 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotFound는 조회하거나 수정할 레코드가 없을 때 반환됩니다.
+// GORM 구현과 같은 값이므로 errors.Is(err, gorm.ErrRecordNotFound)로도 확인할 수 있습니다.
+var ErrNotFound = gorm.ErrRecordNotFound
+
+// AgentStore는 에이전트와 에이전트 리비전을 저장합니다.
+type AgentStore interface {
+	CreateAgent(ctx context.Context, agent *Agent) error
+	UpdateAgentStatus(ctx context.Context, agent *Agent, status string) error
+	UpdateAgent(ctx context.Context, agent *Agent, author string) error
+	GetAgent(ctx context.Context, agentID string) (*Agent, error)
+	ListAgents(ctx context.Context, statuses ...string) ([]Agent, error)
+	ListAgentsPage(ctx context.Context, filter AgentFilter) ([]Agent, string, error)
+	PurgeAgent(ctx context.Context, agentID string) ([]string, error)
+	ListAgentRevisions(ctx context.Context, agentID string) ([]AgentRevision, error)
+	GetAgentRevision(ctx context.Context, agentID string, revision int) (*AgentRevision, error)
+}
+
+// TaskStore는 작업과 작업 검색 인덱스를 저장하고, 보존 정책에 따른 정리를 수행합니다.
+type TaskStore interface {
+	CreateTask(ctx context.Context, task *Task) error
+	ForkTask(ctx context.Context, task *Task, messages []MessageIndex) error
+	UpdateTaskStatus(ctx context.Context, task *Task, status string) error
+	SetTaskAgentRevision(ctx context.Context, task *Task, revision int, systemPrompt string) error
+	StartTaskAttempt(ctx context.Context, task *Task) error
+	FinishTask(ctx context.Context, task *Task, status string, result TaskResult) error
+	GetTask(ctx context.Context, taskID string) (*Task, error)
+	ListTasksByAgent(ctx context.Context, agentID string) ([]Task, error)
+	ListTasksPage(ctx context.Context, filter TaskFilter) ([]Task, string, error)
+	CountTasksByStatus(ctx context.Context) (map[string]int64, error)
+
+	IndexSearchDocument(ctx context.Context, doc *SearchDocument) error
+	SearchTasks(ctx context.Context, filter SearchFilter) ([]SearchDocument, error)
+
+	ExpiredTasks(ctx context.Context, rule RetentionRule, excludeAgents []string, now time.Time) ([]string, error)
+	TaskUsage(ctx context.Context, taskIDs []string) (*TaskUsage, error)
+	DeleteTasks(ctx context.Context, taskIDs []string) (*TaskUsage, []Task, error)
+}
+
+// MessageStore는 작업별 메시지 인덱스(대화 순서와 본문 경로)를 저장합니다.
+// 메시지 본문은 msgstore.MessageStore에 저장됩니다. 다시 생성하거나 수정한 메시지는 같은 인덱스의
+// 다음 revision으로 추가되고, 이전 버전은 대체된 버전(SupersededAt)으로 남습니다.
+type MessageStore interface {
+	GetNextConversationIndex(ctx context.Context, taskID string) (int, error)
+	AppendMessageIndex(ctx context.Context, taskID, role, filePath string) (*MessageIndex, error)
+	ListMessageIndexByTask(ctx context.Context, taskID string) ([]MessageIndex, error)
+	ListMessageVersions(ctx context.Context, taskID string) ([]MessageIndex, error)
+	RewindMessages(ctx context.Context, task *Task, fromIndex int) (int, error)
+	ReviseMessage(ctx context.Context, task *Task, index int, role, filePath string) (*MessageIndex, error)
+	DeleteMessage(ctx context.Context, taskID string, index int) ([]MessageIndex, error)
+}
+
+// RunStepStore는 작업의 실행 단계와 체크포인트를 저장합니다.
+type RunStepStore interface {
+	UpsertRunStep(ctx context.Context, step *RunStep) error
+	ListRunSteps(ctx context.Context, taskID string) ([]RunStep, error)
+	CreateCheckpoint(ctx context.Context, checkpoint *Checkpoint) error
+	ListCheckpoints(ctx context.Context, taskID string) ([]Checkpoint, error)
+}
+
+// AuditStore는 추가만 가능한 감사 로그를 저장합니다.
+type AuditStore interface {
+	CreateAuditEvent(ctx context.Context, event *AuditEvent) error
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]AuditEvent, error)
+}
+
+// LabelStore는 에이전트와 작업의 레이블을 저장합니다.
+type LabelStore interface {
+	SetLabels(ctx context.Context, targetType, targetID string, labels map[string]string) error
+}
+
+// Transactor는 여러 쓰기를 하나의 트랜잭션으로 묶습니다.
+type Transactor interface {
+	// InTx는 트랜잭션에 묶인 Store로 fn을 실행하고, fn이 오류를 반환하면 그 안의 쓰기를 모두 되돌립니다.
+	InTx(ctx context.Context, fn func(tx Store) error) error
+}
+
+// Store는 컨트롤러가 사용하는 모든 저장소 인터페이스를 합친 것입니다.
+// Repository(GORM)와 MemoryStore가 구현하며, 새 구현은 storagetest.Run을 통과해야 합니다.
+type Store interface {
+	AgentStore
+	TaskStore
+	MessageStore
+	RunStepStore
+	AuditStore
+	LabelStore
+	Transactor
+}
+
+var (
+	_ Store = (*Repository)(nil)
+	_ Store = (*MemoryStore)(nil)
+)
@@ -0,0 +1,84 @@
+package storage
+
+import (
+	"errors"
+
+	"github.com/cnap-oss/app/internal/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const tracingSpanKey = "cnap:tracing_span"
+
+// tracingPlugin은 GORM 쿼리마다 OpenTelemetry span을 생성하는 플러그인입니다.
+// span은 Repository가 WithContext로 넘긴 컨텍스트의 하위 span이 됩니다.
+type tracingPlugin struct{}
+
+// Name은 GORM 플러그인 이름을 반환합니다.
+func (tracingPlugin) Name() string {
+	return "cnap:tracing"
+}
+
+// Initialize는 각 GORM 콜백 체인의 앞뒤에 span 시작/종료 콜백을 등록합니다.
+func (p tracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	chains := []struct {
+		op     string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+	for _, chain := range chains {
+		if err := chain.before("cnap:tracing_before_"+chain.op, p.before(chain.op)); err != nil {
+			return err
+		}
+		if err := chain.after("cnap:tracing_after_"+chain.op, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tracingPlugin) before(op string) func(*gorm.DB) {
+	return func(tx *gorm.DB) {
+		ctx, span := tracing.Start(tx.Statement.Context, "db."+op,
+			attribute.String("db.system", tx.Dialector.Name()),
+			attribute.String("db.operation", op),
+			attribute.String("db.sql.table", tx.Statement.Table),
+		)
+		tx.Statement.Context = ctx
+		tx.InstanceSet(tracingSpanKey, span)
+	}
+}
+
+func (tracingPlugin) after(tx *gorm.DB) {
+	value, ok := tx.InstanceGet(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span, ok := value.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", tx.Statement.RowsAffected))
+	if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+		tracing.RecordError(span, tx.Error)
+	}
+}
+
+// EnableTracing은 db에 쿼리 span 플러그인을 등록합니다. 이미 등록되어 있으면 무시합니다.
+func EnableTracing(db *gorm.DB) error {
+	if err := db.Use(tracingPlugin{}); err != nil && !errors.Is(err, gorm.ErrRegistered) {
+		return err
+	}
+	return nil
+}
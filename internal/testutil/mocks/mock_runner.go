@@ -57,6 +57,7 @@ func (m *MockRunner) Run(ctx context.Context, req *taskrunner.RunRequest) (*task
 		Success: true,
 		Output:  response,
 		Error:   nil,
+		Model:   req.Model,
 	}, nil
 }
 
@@ -0,0 +1,134 @@
+// Package tracing은 OpenTelemetry 트레이서 초기화와 span/로그 연동 헬퍼를 제공합니다.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+)
+
+const instrumentationName = "github.com/cnap-oss/app"
+
+// 지원하는 exporter 종류입니다.
+const (
+	ExporterNone = "none"
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Config는 트레이서 설정입니다.
+type Config struct {
+	// Exporter는 span 전송 방식입니다 (none, otlp, file).
+	Exporter string
+	// FilePath는 file exporter가 span을 JSON으로 기록할 경로입니다.
+	FilePath string
+	// ServiceName은 resource의 service.name 속성입니다.
+	ServiceName string
+}
+
+// Setup은 전역 TracerProvider와 W3C trace context propagator를 설정합니다.
+// 반환된 함수는 남은 span을 내보내고 exporter를 종료합니다.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var (
+		exporter sdktrace.SpanExporter
+		closers  []func() error
+		err      error
+	)
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("create otlp exporter: %w", err)
+		}
+	case ExporterFile:
+		f, err := os.OpenFile(cfg.FilePath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open trace file: %w", err)
+		}
+		closers = append(closers, f.Close)
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("create file exporter: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter: %q (expected none, otlp or file)", cfg.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(cfg.ServiceName),
+	))
+	if err != nil {
+		return nil, fmt.Errorf("create resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		errs := []error{provider.Shutdown(ctx)}
+		for _, closeFn := range closers {
+			errs = append(errs, closeFn())
+		}
+		return errors.Join(errs...)
+	}, nil
+}
+
+// Start는 전역 TracerProvider로 새 span을 시작합니다.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// RecordError는 err가 nil이 아니면 span에 에러를 기록하고 상태를 Error로 설정합니다.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// Logger는 ctx에 유효한 span이 있으면 trace_id와 span_id 필드를 붙인 logger를 반환합니다.
+func Logger(ctx context.Context, logger *zap.Logger) *zap.Logger {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return logger
+	}
+	return logger.With(
+		zap.String("trace_id", sc.TraceID().String()),
+		zap.String("span_id", sc.SpanID().String()),
+	)
+}
+
+// TraceField는 ctx의 trace ID를 zap 필드로 반환합니다. 유효한 span이 없으면 zap.Skip()을 반환합니다.
+func TraceField(ctx context.Context) zap.Field {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return zap.Skip()
+	}
+	return zap.String("trace_id", sc.TraceID().String())
+}
@@ -0,0 +1,59 @@
+package tracing_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cnap-oss/app/internal/tracing"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestStartLoggerAndRecordError(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(prev)
+
+	core, logs := observer.New(zap.InfoLevel)
+	base := zap.New(core)
+
+	// span이 없으면 logger를 그대로 사용합니다.
+	tracing.Logger(context.Background(), base).Info("no span")
+
+	ctx, parent := tracing.Start(context.Background(), "parent")
+	_, child := tracing.Start(ctx, "child")
+	tracing.RecordError(child, errors.New("boom"))
+	child.End()
+	tracing.Logger(ctx, base).Info("with span")
+	parent.End()
+
+	entries := logs.All()
+	require.Len(t, entries, 2)
+	require.NotContains(t, entries[0].ContextMap(), "trace_id")
+	require.Equal(t, parent.SpanContext().TraceID().String(), entries[1].ContextMap()["trace_id"])
+	require.Equal(t, parent.SpanContext().SpanID().String(), entries[1].ContextMap()["span_id"])
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 2)
+	require.Equal(t, "child", spans[0].Name())
+	require.Equal(t, parent.SpanContext().SpanID(), spans[0].Parent().SpanID())
+	require.Equal(t, codes.Error, spans[0].Status().Code)
+	require.Equal(t, codes.Unset, spans[1].Status().Code)
+}
+
+func TestSetupRejectsUnknownExporter(t *testing.T) {
+	_, err := tracing.Setup(context.Background(), tracing.Config{Exporter: "jaeger"})
+	require.Error(t, err)
+
+	shutdown, err := tracing.Setup(context.Background(), tracing.Config{Exporter: tracing.ExporterNone})
+	require.NoError(t, err)
+	require.NoError(t, shutdown(context.Background()))
+}
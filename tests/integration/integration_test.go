@@ -102,7 +102,7 @@ func TestAPIIntegration(t *testing.T) {
 	}{
 		{
 			name:       "헬스체크 엔드포인트",
-			endpoint:   "/health",
+			endpoint:   "/healthz",
 			method:     "GET",
 			wantStatus: 200,
 		},